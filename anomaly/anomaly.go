@@ -0,0 +1,174 @@
+package anomaly
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidDetector is returned for unknown methods and out-of-range options
+var ErrInvalidDetector = errors.New("invalid anomaly detector")
+
+// Built-in detection methods
+const (
+	MethodRobustZ = "robust_z"
+	MethodEWMA    = "ewma"
+)
+
+// Options configures a detector
+type Options struct {
+	// Sensitivity is the number of spreads a value may lie from the baseline before it is anomalous;
+	// lower values flag more readings
+	Sensitivity float64
+	// Window is the number of preceding readings the baseline is computed from
+	Window int
+	// MinHistory is the number of preceding readings needed before anything is flagged
+	MinHistory int
+	// Alpha is the smoothing factor of the EWMA method, between 0 and 1
+	Alpha float64
+}
+
+// Result is the verdict on a single value
+type Result struct {
+	// Score is the signed distance of the value from the baseline in spreads
+	Score float64
+	// ExpectedLow and ExpectedHigh bound the values the baseline considers normal
+	ExpectedLow  float64
+	ExpectedHigh float64
+	Anomalous    bool
+}
+
+// Detector scores a value against the values that preceded it
+type Detector interface {
+	// Method returns the name the detector is registered under
+	Method() string
+	// Window returns the number of preceding values Detect wants
+	Window() int
+	// Detect scores value against history, oldest first. ok is false when history is too short to judge.
+	Detect(history []float64, value float64) (result Result, ok bool)
+}
+
+// Factory builds a detector from validated options
+type Factory func(Options) (Detector, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes a detection method available to New. Registering a method twice replaces it.
+func Register(method string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[method] = factory
+}
+
+// Methods returns the registered method names, sorted
+func Methods() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	methods := make([]string, 0, len(registry))
+	for method := range registry {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// New builds the detector registered under method
+func New(method string, opts Options) (Detector, error) {
+	if opts.Sensitivity <= 0 || math.IsInf(opts.Sensitivity, 0) || math.IsNaN(opts.Sensitivity) {
+		return nil, fmt.Errorf("%w: sensitivity must be a positive number", ErrInvalidDetector)
+	}
+	if opts.MinHistory < 2 || opts.Window < opts.MinHistory {
+		return nil, fmt.Errorf("%w: min_history must be at least 2 and window at least min_history", ErrInvalidDetector)
+	}
+
+	registryMu.RLock()
+	factory, ok := registry[method]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown method %q, use one of %s", ErrInvalidDetector, method, strings.Join(Methods(), ", "))
+	}
+	return factory(opts)
+}
+
+func init() {
+	Register(MethodRobustZ, func(opts Options) (Detector, error) { return robustZ{opts}, nil })
+	Register(MethodEWMA, func(opts Options) (Detector, error) {
+		if math.IsNaN(opts.Alpha) || opts.Alpha <= 0 || opts.Alpha > 1 {
+			return nil, fmt.Errorf("%w: alpha must be above 0 and at most 1", ErrInvalidDetector)
+		}
+		return ewma{opts}, nil
+	})
+}
+
+// verdict scores value against a baseline centre and spread. A zero spread, as for a perfectly flat
+// baseline, is floored so that any change is flagged with a large but finite score.
+func verdict(value, centre, spread, sensitivity float64) Result {
+	if floor := 1e-6 * math.Max(math.Abs(centre), 1); spread < floor {
+		spread = floor
+	}
+	score := (value - centre) / spread
+	return Result{
+		Score:        score,
+		ExpectedLow:  centre - sensitivity*spread,
+		ExpectedHigh: centre + sensitivity*spread,
+		Anomalous:    math.Abs(score) > sensitivity,
+	}
+}
+
+// robustZ scores values by their distance from the median in scaled median absolute deviations,
+// which a few earlier spikes in the baseline do not distort
+type robustZ struct{ opts Options }
+
+func (d robustZ) Method() string { return MethodRobustZ }
+func (d robustZ) Window() int    { return d.opts.Window }
+
+func (d robustZ) Detect(history []float64, value float64) (Result, bool) {
+	if len(history) < d.opts.MinHistory {
+		return Result{}, false
+	}
+	median := medianOf(history)
+	deviations := make([]float64, len(history))
+	for i, v := range history {
+		deviations[i] = math.Abs(v - median)
+	}
+	// 1.4826 makes the MAD consistent with the standard deviation of normally distributed values
+	return verdict(value, median, 1.4826*medianOf(deviations), d.opts.Sensitivity), true
+}
+
+// medianOf returns the median of values without reordering them
+func medianOf(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// ewma scores values against an exponentially weighted moving average and variance, which follow
+// gradual drift in consumption more closely than the robust z-score
+type ewma struct{ opts Options }
+
+func (d ewma) Method() string { return MethodEWMA }
+func (d ewma) Window() int    { return d.opts.Window }
+
+func (d ewma) Detect(history []float64, value float64) (Result, bool) {
+	if len(history) < d.opts.MinHistory {
+		return Result{}, false
+	}
+	alpha := d.opts.Alpha
+	mean, variance := history[0], 0.0
+	for _, v := range history[1:] {
+		diff := v - mean
+		mean += alpha * diff
+		variance = (1 - alpha) * (variance + alpha*diff*diff)
+	}
+	return verdict(value, mean, math.Sqrt(variance), d.opts.Sensitivity), true
+}
@@ -0,0 +1,161 @@
+package anomaly
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func detector(t *testing.T, method string, opts Options) Detector {
+	t.Helper()
+	d, err := New(method, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestVerdictFloorsFlatBaselines(t *testing.T) {
+	tests := []struct {
+		name   string
+		centre float64
+		value  float64
+		// spread is the floored spread expected for a zero spread
+		spread    float64
+		anomalous bool
+	}{
+		{"unchanged", 10, 10, 1e-5, false},
+		{"slight change", 10, 10.001, 1e-5, true},
+		{"zero baseline", 0, 0.001, 1e-6, true},
+		{"negative baseline", -200, -200, 2e-4, false},
+	}
+	for _, tt := range tests {
+		r := verdict(tt.value, tt.centre, 0, 3)
+		if math.IsInf(r.Score, 0) || math.IsNaN(r.Score) {
+			t.Errorf("%s: score %v is not finite", tt.name, r.Score)
+		}
+		if math.Abs(r.Score-(tt.value-tt.centre)/tt.spread) > 1e-6 || r.Anomalous != tt.anomalous {
+			t.Errorf("%s: got %+v, want a score of %v and anomalous %v", tt.name, r, (tt.value-tt.centre)/tt.spread, tt.anomalous)
+		}
+		if math.Abs(r.ExpectedLow-(tt.centre-3*tt.spread)) > 1e-12 || math.Abs(r.ExpectedHigh-(tt.centre+3*tt.spread)) > 1e-12 {
+			t.Errorf("%s: expected range %v to %v, want %v ± %v", tt.name, r.ExpectedLow, r.ExpectedHigh, tt.centre, 3*tt.spread)
+		}
+	}
+
+	// A spread above the floor is kept
+	if r := verdict(12, 10, 0.5, 3); r.Score != 4 || !r.Anomalous {
+		t.Errorf("verdict(12, 10, 0.5, 3) = %+v, want a score of 4", r)
+	}
+}
+
+func TestRobustZ(t *testing.T) {
+	d := detector(t, MethodRobustZ, Options{Sensitivity: 3.5, Window: 30, MinHistory: 5})
+	// One spike in the baseline: the median stays at 10 and the MAD at 0.5, where the standard deviation
+	// would be about 150 and hide every later anomaly
+	spiky := []float64{10, 11, 9, 10, 500, 10, 11, 9, 10, 10}
+	tests := []struct {
+		name      string
+		history   []float64
+		value     float64
+		score     float64
+		anomalous bool
+	}{
+		{"normal after a spike", spiky, 11, 1 / (1.4826 * 0.5), false},
+		{"low after a spike", spiky, 7, -3 / (1.4826 * 0.5), true},
+		{"high after a spike", spiky, 20, 10 / (1.4826 * 0.5), true},
+		{"even history", []float64{8, 12, 10, 14, 6, 10}, 10, 0, false},
+		{"flat history", []float64{10, 10, 10, 10, 10}, 10.5, 0.5 / 1e-5, true},
+	}
+	for _, tt := range tests {
+		r, ok := d.Detect(tt.history, tt.value)
+		if !ok {
+			t.Fatalf("%s: Detect refused %d values of history", tt.name, len(tt.history))
+		}
+		if math.Abs(r.Score-tt.score) > 1e-6 || r.Anomalous != tt.anomalous {
+			t.Errorf("%s: got %+v, want a score of %v and anomalous %v", tt.name, r, tt.score, tt.anomalous)
+		}
+	}
+
+	if _, ok := d.Detect([]float64{10, 10, 10, 10}, 100); ok {
+		t.Error("Detect judged a value with less than min_history values of history")
+	}
+	if d.Method() != MethodRobustZ || d.Window() != 30 {
+		t.Errorf("Method, Window = %q, %d, want %q, 30", d.Method(), d.Window(), MethodRobustZ)
+	}
+}
+
+func TestEWMA(t *testing.T) {
+	tests := []struct {
+		name    string
+		alpha   float64
+		history []float64
+		// mean and variance after the recurrence, worked out by hand
+		mean, variance float64
+	}{
+		// 12: mean 11, variance 0.5 × (0 + 0.5 × 4) = 1; 8: mean 9.5, variance 0.5 × (1 + 0.5 × 9) = 2.75
+		{"half", 0.5, []float64{10, 12, 8}, 9.5, 2.75},
+		// 20: mean 12, variance 0.8 × 0.2 × 100 = 16; 20: mean 13.6, variance 0.8 × (16 + 0.2 × 64) = 23.04
+		{"slow", 0.2, []float64{10, 20, 20}, 13.6, 23.04},
+		// Only the latest value counts, with no variance
+		{"alpha 1", 1, []float64{10, 20, 5}, 5, 0},
+		{"constant", 0.3, []float64{7, 7, 7, 7}, 7, 0},
+	}
+	for _, tt := range tests {
+		d := detector(t, MethodEWMA, Options{Sensitivity: 3, Window: 10, MinHistory: 3, Alpha: tt.alpha})
+		r, ok := d.Detect(tt.history, tt.mean)
+		if !ok {
+			t.Fatalf("%s: Detect refused %d values of history", tt.name, len(tt.history))
+		}
+		spread := math.Max(math.Sqrt(tt.variance), 1e-6*math.Max(math.Abs(tt.mean), 1))
+		if r.Score != 0 || math.Abs(r.ExpectedLow-(tt.mean-3*spread)) > 1e-9 || math.Abs(r.ExpectedHigh-(tt.mean+3*spread)) > 1e-9 {
+			t.Errorf("%s: got %+v, want a mean of %v and a variance of %v", tt.name, r, tt.mean, tt.variance)
+		}
+	}
+
+	d := detector(t, MethodEWMA, Options{Sensitivity: 3, Window: 10, MinHistory: 3, Alpha: 0.5})
+	if r, _ := d.Detect([]float64{10, 12, 8}, 15); !r.Anomalous {
+		t.Errorf("15 after a mean of 9.5 and a variance of 2.75 is not anomalous: %+v", r)
+	}
+	if _, ok := d.Detect([]float64{10, 12}, 100); ok {
+		t.Error("Detect judged a value with less than min_history values of history")
+	}
+}
+
+func TestNewRejectsInvalidOptions(t *testing.T) {
+	valid := Options{Sensitivity: 3.5, Window: 30, MinHistory: 7, Alpha: 0.3}
+	tests := []struct {
+		name   string
+		method string
+		change func(*Options)
+	}{
+		{"zero sensitivity", MethodRobustZ, func(o *Options) { o.Sensitivity = 0 }},
+		{"negative sensitivity", MethodRobustZ, func(o *Options) { o.Sensitivity = -1 }},
+		{"NaN sensitivity", MethodRobustZ, func(o *Options) { o.Sensitivity = math.NaN() }},
+		{"infinite sensitivity", MethodRobustZ, func(o *Options) { o.Sensitivity = math.Inf(1) }},
+		{"min_history below 2", MethodRobustZ, func(o *Options) { o.MinHistory, o.Window = 1, 1 }},
+		{"window below min_history", MethodRobustZ, func(o *Options) { o.Window = 6 }},
+		{"zero alpha", MethodEWMA, func(o *Options) { o.Alpha = 0 }},
+		{"alpha above 1", MethodEWMA, func(o *Options) { o.Alpha = 1.5 }},
+		{"NaN alpha", MethodEWMA, func(o *Options) { o.Alpha = math.NaN() }},
+		{"unknown method", "prophet", func(*Options) {}},
+	}
+	for _, tt := range tests {
+		opts := valid
+		tt.change(&opts)
+		if _, err := New(tt.method, opts); !errors.Is(err, ErrInvalidDetector) {
+			t.Errorf("%s: New returned %v, want %v", tt.name, err, ErrInvalidDetector)
+		}
+	}
+
+	for _, method := range Methods() {
+		if _, err := New(method, valid); err != nil {
+			t.Errorf("New(%q) with valid options failed: %v", method, err)
+		}
+	}
+	// Robust z-scores do not use alpha
+	opts := valid
+	opts.Alpha = 0
+	if _, err := New(MethodRobustZ, opts); err != nil {
+		t.Errorf("New(%q) without alpha failed: %v", MethodRobustZ, err)
+	}
+}
@@ -0,0 +1,212 @@
+package budget
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"product-tracker/costcalc"
+	"product-tracker/models"
+	"product-tracker/money"
+)
+
+// ErrInvalidBudget is returned for budgets with an inconsistent scope, metric, limit or thresholds
+var ErrInvalidBudget = errors.New("invalid budget")
+
+// Budget scopes
+const (
+	ScopeProduct   = "product"
+	ScopeCategory  = "category"
+	ScopePortfolio = "portfolio"
+)
+
+// Budget metrics
+const (
+	MetricEnergy = "energy"
+	MetricCost   = "cost"
+)
+
+// Alert kinds and states
+const (
+	KindThreshold        = "threshold"
+	KindProjectedOverrun = "projected_overrun"
+	StateFiring          = "firing"
+	StateResolved        = "resolved"
+)
+
+// DefaultThresholds are used for budgets created without thresholds, in percent of the limit
+var DefaultThresholds = []int{80, 100}
+
+// maxThreshold is the highest threshold accepted, in percent
+const maxThreshold = 1000
+
+// dateLayout is the layout of period dates
+const dateLayout = "2006-01-02"
+
+// Normalize checks that a budget is consistent, filling in default thresholds and sorting them.
+// Cost budgets need a tariff; their limit is converted to minor units of currency, the tariff's currency.
+func Normalize(b *models.Budget, currency string) error {
+	b.Name = strings.TrimSpace(b.Name)
+	if b.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidBudget)
+	}
+
+	switch b.Scope {
+	case ScopeProduct:
+		if b.ProductID == nil || b.CategoryID != nil {
+			return fmt.Errorf("%w: product budgets need product_id and no category_id", ErrInvalidBudget)
+		}
+	case ScopeCategory:
+		if b.CategoryID == nil || b.ProductID != nil {
+			return fmt.Errorf("%w: category budgets need category_id and no product_id", ErrInvalidBudget)
+		}
+	case ScopePortfolio:
+		if b.ProductID != nil || b.CategoryID != nil {
+			return fmt.Errorf("%w: portfolio budgets take neither product_id nor category_id", ErrInvalidBudget)
+		}
+	default:
+		return fmt.Errorf("%w: scope must be %s, %s or %s", ErrInvalidBudget, ScopeProduct, ScopeCategory, ScopePortfolio)
+	}
+
+	if b.Limit <= 0 || math.IsInf(b.Limit, 0) || math.IsNaN(b.Limit) {
+		return fmt.Errorf("%w: limit must be a positive number", ErrInvalidBudget)
+	}
+	switch b.Metric {
+	case MetricEnergy:
+		if b.TariffID != nil {
+			return fmt.Errorf("%w: energy budgets take no tariff_id", ErrInvalidBudget)
+		}
+		b.LimitMinor, b.Currency = nil, nil
+	case MetricCost:
+		if b.TariffID == nil {
+			return fmt.Errorf("%w: cost budgets need tariff_id", ErrInvalidBudget)
+		}
+		limit, err := money.FromMajor(b.Limit, currency)
+		if err != nil {
+			return fmt.Errorf("%w: limit: %v", ErrInvalidBudget, err)
+		}
+		b.LimitMinor, b.Currency = &limit.Amount, &limit.Currency
+	default:
+		return fmt.Errorf("%w: metric must be %s or %s", ErrInvalidBudget, MetricEnergy, MetricCost)
+	}
+
+	if len(b.Thresholds) == 0 {
+		b.Thresholds = append([]int(nil), DefaultThresholds...)
+	}
+	sort.Ints(b.Thresholds)
+	for i, t := range b.Thresholds {
+		if t < 1 || t > maxThreshold {
+			return fmt.Errorf("%w: thresholds must be between 1 and %d percent", ErrInvalidBudget, maxThreshold)
+		}
+		if i > 0 && t == b.Thresholds[i-1] {
+			return fmt.Errorf("%w: threshold %d is listed twice", ErrInvalidBudget, t)
+		}
+	}
+	return nil
+}
+
+// Period is a budget month in local time
+type Period struct {
+	// Start and End are the first and last local day at midnight UTC
+	Start time.Time
+	End   time.Time
+	// Elapsed is the fraction of the month that has passed
+	Elapsed float64
+	// ElapsedDays is the number of days of the month that have passed, including fractions
+	ElapsedDays float64
+}
+
+// CurrentPeriod returns the month containing now in location
+func CurrentPeriod(now time.Time, location *time.Location) Period {
+	local := now.In(location)
+	start := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, location)
+	next := start.AddDate(0, 1, 0)
+	elapsed := local.Sub(start)
+	return Period{
+		Start:       time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, time.UTC),
+		End:         time.Date(local.Year(), local.Month()+1, 0, 0, 0, 0, 0, time.UTC),
+		Elapsed:     elapsed.Seconds() / next.Sub(start).Seconds(),
+		ElapsedDays: elapsed.Hours() / 24,
+	}
+}
+
+// DayUsage is the energy consumed on one local day
+type DayUsage struct {
+	Day time.Time
+	KWh float64
+}
+
+// Condition is the state of one alert condition after an evaluation
+type Condition struct {
+	Kind string
+	// Threshold is the percentage of a threshold condition and zero for a projected overrun
+	Threshold int
+	Firing    bool
+}
+
+// Evaluate computes the month-to-date status of a budget from its daily usage and the conditions of its alerts.
+// tariff prices the usage of cost budgets. The month-to-date run rate is only projected once minProjectionDays
+// have passed, as earlier projections swing wildly.
+func Evaluate(b *models.Budget, period Period, usage []DayUsage, tariff *costcalc.Tariff, minProjectionDays float64,
+	now time.Time) (*models.BudgetStatus, []Condition, error) {
+	status := &models.BudgetStatus{
+		BudgetID:    b.ID,
+		PeriodStart: period.Start.Format(dateLayout),
+		PeriodEnd:   period.End.Format(dateLayout),
+		Unit:        "kWh",
+		Limit:       b.Limit,
+		EvaluatedAt: now,
+	}
+
+	switch b.Metric {
+	case MetricCost:
+		if tariff == nil || b.Currency == nil {
+			return nil, nil, fmt.Errorf("%w: cost budgets need a tariff", ErrInvalidBudget)
+		}
+		cost := new(big.Rat)
+		for _, u := range usage {
+			dayCost, err := tariff.EnergyCost(u.Day, u.KWh)
+			if err != nil {
+				return nil, nil, err
+			}
+			cost.Add(cost, dayCost)
+		}
+		used, err := money.FromRat(cost, *b.Currency)
+		if err != nil {
+			return nil, nil, err
+		}
+		status.Unit, status.Used = *b.Currency, used.Major()
+	default:
+		for _, u := range usage {
+			status.Used += u.KWh
+		}
+		status.Used = round(status.Used, 6)
+	}
+	status.Percent = round(100*status.Used/b.Limit, 2)
+
+	if period.ElapsedDays >= minProjectionDays && period.Elapsed > 0 {
+		projected := round(status.Used/period.Elapsed, 6)
+		projectedPercent := round(100*projected/b.Limit, 2)
+		status.Projected, status.ProjectedPercent = &projected, &projectedPercent
+	}
+
+	conditions := make([]Condition, 0, len(b.Thresholds)+1)
+	for _, t := range b.Thresholds {
+		conditions = append(conditions, Condition{Kind: KindThreshold, Threshold: t, Firing: status.Percent >= float64(t)})
+	}
+	conditions = append(conditions, Condition{
+		Kind:   KindProjectedOverrun,
+		Firing: status.ProjectedPercent != nil && *status.ProjectedPercent > 100,
+	})
+	return status, conditions, nil
+}
+
+// round rounds value to the given number of decimals
+func round(value float64, decimals int) float64 {
+	scale := math.Pow10(decimals)
+	return math.Round(value*scale) / scale
+}
@@ -0,0 +1,201 @@
+package budget
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"product-tracker/costcalc"
+	"product-tracker/models"
+)
+
+func TestCurrentPeriod(t *testing.T) {
+	tests := []struct {
+		name       string
+		now        string
+		location   string
+		start, end string
+		// elapsedHours and monthHours give the expected elapsed time and month length in real hours
+		elapsedHours, monthHours float64
+	}{
+		{"leap February", "2024-02-15T00:00:00Z", "UTC", "2024-02-01", "2024-02-29", 14 * 24, 29 * 24},
+		{"common February", "2023-02-28T12:00:00Z", "UTC", "2023-02-01", "2023-02-28", 27*24 + 12, 28 * 24},
+		{"December", "2024-12-31T23:00:00Z", "UTC", "2024-12-01", "2024-12-31", 30*24 + 23, 31 * 24},
+		// Berlin skips an hour on the last Sunday of March
+		{"spring forward", "2024-03-31T10:00:00Z", "Europe/Berlin", "2024-03-01", "2024-03-31", 30*24 + 11, 31*24 - 1},
+		// New York repeats an hour on the first Sunday of November
+		{"fall back", "2024-11-30T17:00:00Z", "America/New_York", "2024-11-01", "2024-11-30", 29*24 + 13, 30*24 + 1},
+		// Still February in UTC, but already March in Berlin
+		{"local month boundary", "2024-02-29T23:30:00Z", "Europe/Berlin", "2024-03-01", "2024-03-31", 0.5, 31*24 - 1},
+		{"first instant", "2024-06-01T00:00:00+09:00", "Asia/Tokyo", "2024-06-01", "2024-06-30", 0, 30 * 24},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now, err := time.Parse(time.RFC3339, tt.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			location, err := time.LoadLocation(tt.location)
+			if err != nil {
+				t.Fatal(err)
+			}
+			period := CurrentPeriod(now, location)
+			if got := period.Start.Format(dateLayout); got != tt.start {
+				t.Errorf("Start = %s, want %s", got, tt.start)
+			}
+			if got := period.End.Format(dateLayout); got != tt.end {
+				t.Errorf("End = %s, want %s", got, tt.end)
+			}
+			if period.Start.Location() != time.UTC || period.End.Location() != time.UTC {
+				t.Errorf("Start and End are in %s and %s, want UTC", period.Start.Location(), period.End.Location())
+			}
+			if want := tt.elapsedHours / 24; math.Abs(period.ElapsedDays-want) > 1e-9 {
+				t.Errorf("ElapsedDays = %v, want %v", period.ElapsedDays, want)
+			}
+			if want := tt.elapsedHours / tt.monthHours; math.Abs(period.Elapsed-want) > 1e-9 {
+				t.Errorf("Elapsed = %v, want %v", period.Elapsed, want)
+			}
+		})
+	}
+}
+
+func TestEvaluateEnergy(t *testing.T) {
+	day := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		usage   []float64
+		elapsed float64
+		days    float64
+		// firing lists the thresholds expected to fire; overrun is the projected overrun condition
+		firing    []int
+		projected *float64
+		overrun   bool
+	}{
+		{"below every threshold", []float64{10, 20}, 0.5, 15, nil, ptr(60), false},
+		{"threshold reached exactly", []float64{40, 40}, 0.5, 15, []int{50, 80}, ptr(160), true},
+		{"limit exceeded", []float64{60, 50}, 0.9, 27, []int{50, 80, 100}, ptr(122.222222), true},
+		{"projection exactly at the limit", []float64{25, 25}, 0.5, 15, []int{50}, ptr(100), false},
+		{"too early to project", []float64{40, 40}, 0.1, 3, []int{50, 80}, nil, false},
+		{"projection from the minimum day", []float64{40}, 7.0 / 30, 7, []int{}, ptr(171.428571), true},
+		{"nothing used", nil, 0.5, 15, nil, ptr(0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &models.Budget{ID: 1, Metric: MetricEnergy, Limit: 100, Thresholds: []int{50, 80, 100}}
+			var usage []DayUsage
+			for i, kwh := range tt.usage {
+				usage = append(usage, DayUsage{Day: day.AddDate(0, 0, i), KWh: kwh})
+			}
+			period := Period{Start: day, End: day.AddDate(0, 1, -1), Elapsed: tt.elapsed, ElapsedDays: tt.days}
+
+			status, conditions, err := Evaluate(b, period, usage, nil, 7, day)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.Unit != "kWh" || status.PeriodStart != "2024-06-01" || status.PeriodEnd != "2024-06-30" {
+				t.Errorf("status = %+v, want a kWh status for June 2024", status)
+			}
+			switch {
+			case tt.projected == nil && status.Projected != nil:
+				t.Errorf("Projected = %v, want none", *status.Projected)
+			case tt.projected != nil && (status.Projected == nil || *status.Projected != *tt.projected):
+				t.Errorf("Projected = %v, want %v", status.Projected, *tt.projected)
+			}
+
+			firing := map[int]bool{}
+			for _, threshold := range tt.firing {
+				firing[threshold] = true
+			}
+			if len(conditions) != len(b.Thresholds)+1 {
+				t.Fatalf("got %d conditions, want %d", len(conditions), len(b.Thresholds)+1)
+			}
+			for _, c := range conditions {
+				switch c.Kind {
+				case KindThreshold:
+					if c.Firing != firing[c.Threshold] {
+						t.Errorf("threshold %d at %v%%: Firing = %v", c.Threshold, status.Percent, c.Firing)
+					}
+				case KindProjectedOverrun:
+					if c.Firing != tt.overrun {
+						t.Errorf("projected overrun: Firing = %v, want %v", c.Firing, tt.overrun)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEvaluateCost(t *testing.T) {
+	tariff, err := costcalc.FromModel(&models.Tariff{
+		Currency: "EUR",
+		Rates:    []models.TariffRate{{UnitRate: "0.25", StandingCharge: "1", EffectiveFrom: "2024-01-01"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	currency := "EUR"
+	b := &models.Budget{ID: 1, Metric: MetricCost, Limit: 10, Currency: &currency, Thresholds: []int{50}}
+	day := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	usage := []DayUsage{{Day: day, KWh: 10}, {Day: day.AddDate(0, 0, 1), KWh: 12}}
+	period := Period{Start: day, End: day.AddDate(0, 1, -1), Elapsed: 0.5, ElapsedDays: 15}
+
+	status, conditions, err := Evaluate(b, period, usage, tariff, 7, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 22 kWh at 0.25; standing charges are not counted
+	if status.Unit != "EUR" || status.Used != 5.5 || status.Percent != 55 {
+		t.Errorf("status = %+v, want 5.5 EUR used, 55%%", status)
+	}
+	if !conditions[0].Firing {
+		t.Errorf("threshold 50 at %v%% is not firing", status.Percent)
+	}
+
+	if _, _, err := Evaluate(b, period, usage, nil, 7, day); !errors.Is(err, ErrInvalidBudget) {
+		t.Errorf("Evaluate without a tariff returned %v, want %v", err, ErrInvalidBudget)
+	}
+}
+
+func TestNormalizeThresholds(t *testing.T) {
+	tests := []struct {
+		name       string
+		thresholds []int
+		want       []int
+		fails      bool
+	}{
+		{"defaults", nil, DefaultThresholds, false},
+		{"sorted", []int{120, 50, 90}, []int{50, 90, 120}, false},
+		{"duplicate", []int{80, 80}, nil, true},
+		{"zero", []int{0, 80}, nil, true},
+		{"above the maximum", []int{maxThreshold + 1}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &models.Budget{Name: "Fridge", Scope: ScopePortfolio, Metric: MetricEnergy, Limit: 100, Thresholds: tt.thresholds}
+			err := Normalize(b, "EUR")
+			if tt.fails {
+				if !errors.Is(err, ErrInvalidBudget) {
+					t.Fatalf("Normalize returned %v, want %v", err, ErrInvalidBudget)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(b.Thresholds) != len(tt.want) {
+				t.Fatalf("Thresholds = %v, want %v", b.Thresholds, tt.want)
+			}
+			for i := range tt.want {
+				if b.Thresholds[i] != tt.want[i] {
+					t.Fatalf("Thresholds = %v, want %v", b.Thresholds, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func ptr(v float64) *float64 {
+	return &v
+}
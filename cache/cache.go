@@ -0,0 +1,206 @@
+package cache
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+
+	"product-tracker/metrics"
+)
+
+var (
+	entriesGauge = metrics.NewGauge("product_tracker_cache_entries",
+		"Responses held in the response cache")
+	evictionsTotal = metrics.NewCounter("product_tracker_cache_evictions_total",
+		"Responses evicted from the response cache, by reason: capacity or expired", "reason")
+	invalidationsTotal = metrics.NewCounter("product_tracker_cache_invalidations_total",
+		"Responses dropped from the response cache because the data they were built from changed")
+)
+
+// Entry is a cached response
+type Entry struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	ETag   string
+	// Tags name the data the response was built from, so that it is dropped when that data changes
+	Tags []string
+}
+
+// item is an entry in the recency list
+type item struct {
+	key     string
+	entry   *Entry
+	expires time.Time
+}
+
+// Cache is an LRU cache of responses whose entries expire after a TTL and can be invalidated by tag. It is safe
+// for concurrent use.
+type Cache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	// recency holds the items from most to least recently used
+	recency *list.List
+	items   map[string]*list.Element
+	tagged  map[string]map[*list.Element]struct{}
+	now     func() time.Time
+
+	// generation counts invalidations. Responses built while one of their tags was invalidated are not cached,
+	// as they may have read the data before the change.
+	generation uint64
+	// invalidated holds the generation each tag was last invalidated in, for the tags invalidated since floor
+	invalidated map[string]uint64
+	// floor is the generation every tag is considered invalidated in
+	floor uint64
+}
+
+// New creates a cache holding up to maxEntries responses for at most ttl each
+func New(maxEntries int, ttl time.Duration) *Cache {
+	return &Cache{
+		maxEntries:  maxEntries,
+		ttl:         ttl,
+		recency:     list.New(),
+		items:       make(map[string]*list.Element),
+		tagged:      make(map[string]map[*list.Element]struct{}),
+		now:         time.Now,
+		invalidated: make(map[string]uint64),
+	}
+}
+
+// Get returns the response cached under key, or nil if there is none or it expired
+func (c *Cache) Get(key string) *Entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.items[key]
+	if !ok {
+		return nil
+	}
+	it := element.Value.(*item)
+	if !c.now().Before(it.expires) {
+		c.remove(element)
+		evictionsTotal.Inc("expired")
+		return nil
+	}
+	c.recency.MoveToFront(element)
+	return it.entry
+}
+
+// Generation returns the current generation, to be passed to Set by the response built next
+func (c *Cache) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// Set caches a response under key, replacing any previous one and evicting the least recently used responses
+// beyond the capacity. since is the generation the response started being built in; the response is not cached
+// if any of its tags was invalidated since.
+func (c *Cache) Set(key string, entry *Entry, since uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.floor > since {
+		return
+	}
+	for _, tag := range entry.Tags {
+		if c.invalidated[tag] > since {
+			return
+		}
+	}
+
+	if element, ok := c.items[key]; ok {
+		c.remove(element)
+	}
+	element := c.recency.PushFront(&item{key: key, entry: entry, expires: c.now().Add(c.ttl)})
+	c.items[key] = element
+	for _, tag := range entry.Tags {
+		if c.tagged[tag] == nil {
+			c.tagged[tag] = make(map[*list.Element]struct{})
+		}
+		c.tagged[tag][element] = struct{}{}
+	}
+
+	for c.recency.Len() > c.maxEntries {
+		c.remove(c.recency.Back())
+		evictionsTotal.Inc("capacity")
+	}
+	entriesGauge.Set(float64(c.recency.Len()))
+}
+
+// Invalidate drops the responses carrying any of the tags
+func (c *Cache) Invalidate(tags ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	dropped := 0
+	for _, tag := range tags {
+		c.invalidated[tag] = c.generation
+		for element := range c.tagged[tag] {
+			c.remove(element)
+			dropped++
+		}
+	}
+	if len(c.invalidated) > c.maxEntries {
+		// Forget the tags rather than let them grow without bound, at the cost of not caching the responses
+		// being built
+		c.invalidated = make(map[string]uint64)
+		c.floor = c.generation
+	}
+	if dropped > 0 {
+		invalidationsTotal.Add(float64(dropped))
+	}
+}
+
+// Purge drops every response
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if n := c.recency.Len(); n > 0 {
+		invalidationsTotal.Add(float64(n))
+	}
+	c.recency.Init()
+	c.items = make(map[string]*list.Element)
+	c.tagged = make(map[string]map[*list.Element]struct{})
+	c.generation++
+	c.invalidated = make(map[string]uint64)
+	c.floor = c.generation
+	entriesGauge.Set(0)
+}
+
+// remove drops an element from the list and the indexes. The caller must hold the lock.
+func (c *Cache) remove(element *list.Element) {
+	it := element.Value.(*item)
+	c.recency.Remove(element)
+	delete(c.items, it.key)
+	for _, tag := range it.entry.Tags {
+		delete(c.tagged[tag], element)
+		if len(c.tagged[tag]) == 0 {
+			delete(c.tagged, tag)
+		}
+	}
+	entriesGauge.Set(float64(c.recency.Len()))
+}
+
+var (
+	defaultMu    sync.RWMutex
+	defaultCache *Cache
+)
+
+// SetDefault sets the cache used by the read endpoints and invalidated by writes
+func SetDefault(c *Cache) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultCache = c
+}
+
+// Default returns the cache used by the read endpoints, or nil if caching is disabled
+func Default() *Cache {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultCache
+}
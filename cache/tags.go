@@ -0,0 +1,68 @@
+package cache
+
+import "fmt"
+
+const (
+	// TagProducts is carried by responses listing or aggregating products
+	TagProducts = "products"
+	// TagAnyProduct is carried by every response about a single product
+	TagAnyProduct = "product:*"
+	// TagReadings is carried by responses listing or aggregating readings of any product
+	TagReadings = "readings"
+)
+
+// ProductTag is carried by responses about a single product
+func ProductTag(id int64) string {
+	return fmt.Sprintf("product:%d", id)
+}
+
+// ReadingsTag is carried by responses about the readings of a single product
+func ReadingsTag(productID int64) string {
+	return fmt.Sprintf("readings:%d", productID)
+}
+
+// Invalidate drops the responses of the default cache carrying any of the tags. It does nothing when caching is
+// disabled.
+func Invalidate(tags ...string) {
+	if c := Default(); c != nil {
+		c.Invalidate(tags...)
+	}
+}
+
+// Purge drops every response of the default cache, for when changes may have been missed
+func Purge() {
+	if c := Default(); c != nil {
+		c.Purge()
+	}
+}
+
+// ProductChanged drops the responses that may show a product that was created or updated
+func ProductChanged(id int64) {
+	Invalidate(TagProducts, ProductTag(id))
+}
+
+// ProductVisibilityChanged drops the responses that may show a product moved to or restored from the trash,
+// including its readings, which are hidden while it is in the trash
+func ProductVisibilityChanged(id int64) {
+	Invalidate(TagProducts, ProductTag(id), TagReadings, ReadingsTag(id))
+}
+
+// AllProductsChanged drops the responses about any product, for changes to many products at once such as a
+// rating scheme rerating them or a category or tag they refer to being changed
+func AllProductsChanged() {
+	Invalidate(AllProductsTags()...)
+}
+
+// AllProductsTags returns the tags AllProductsChanged drops, for announcing the change to other instances
+func AllProductsTags() []string {
+	return []string{TagProducts, TagAnyProduct}
+}
+
+// ReadingsChanged drops the responses that may show readings of the products, or readings without a product
+func ReadingsChanged(productIDs []int64) {
+	tags := []string{TagReadings}
+	for _, id := range productIDs {
+		tags = append(tags, ReadingsTag(id))
+	}
+	Invalidate(tags...)
+}
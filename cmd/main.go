@@ -1,16 +1,28 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"time"
 
+	"product-tracker/cache"
 	"product-tracker/config"
+	"product-tracker/emissions"
+	"product-tracker/grpcapi"
+	"product-tracker/ingest"
+	"product-tracker/jobs"
+	"product-tracker/money"
+	"product-tracker/rollups"
 	"product-tracker/routes"
+	"product-tracker/storage"
+	"product-tracker/stream"
 
 	_ "product-tracker/docs" // Import swagger docs
 
+	"github.com/gin-contrib/requestid"
 	"github.com/gin-gonic/gin"
 	_ "github.com/lib/pq"
 	swaggerFiles "github.com/swaggo/files"
@@ -62,6 +74,7 @@ func NewRouter(cfg *config.Config) *gin.Engine {
 	// Add middleware
 	router.Use(gin.Recovery())
 	router.Use(gin.Logger())
+	router.Use(requestid.New())
 
 	// Swagger documentation
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
@@ -72,6 +85,22 @@ func NewRouter(cfg *config.Config) *gin.Engine {
 	return router
 }
 
+// startGRPCServer serves the gRPC API on its own port
+func startGRPCServer(cfg config.GRPCConfig) {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%s", cfg.Port))
+	if err != nil {
+		log.Fatalf("❌ Failed to listen for gRPC: %v", err)
+	}
+
+	server := grpcapi.NewServer(cfg)
+	log.Printf("🚀 Starting gRPC server on :%s", cfg.Port)
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Fatalf("❌ Failed to serve gRPC: %v", err)
+		}
+	}()
+}
+
 func main() {
 	// Initialize logger
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
@@ -83,6 +112,114 @@ func main() {
 		log.Fatalf("❌ Failed to load config: %v", err)
 	}
 
+	if err := storage.ValidateNaturalKey(cfg.Products.NaturalKey); err != nil {
+		log.Fatalf("❌ Invalid products.natural_key: %v", err)
+	}
+	if _, err := money.ParseCurrency(cfg.Money.DefaultCurrency); err != nil {
+		log.Fatalf("❌ Invalid money.default_currency: %v", err)
+	}
+	if _, err := money.ParseCurrency(cfg.Money.ReportingCurrency); err != nil {
+		log.Fatalf("❌ Invalid money.reporting_currency: %v", err)
+	}
+	if cfg.Emissions.DefaultRegion != "" {
+		region, err := emissions.ParseRegion(cfg.Emissions.DefaultRegion)
+		if err != nil {
+			log.Fatalf("❌ Invalid emissions.default_region: %v", err)
+		}
+		cfg.Emissions.DefaultRegion = region
+	}
+	if cfg.TCO.DiscountRate < 0 || cfg.TCO.DiscountRate >= 1 {
+		log.Fatalf("❌ Invalid tco.discount_rate: %v must be at least 0 and below 1", cfg.TCO.DiscountRate)
+	}
+	if err := rollups.ValidateTimezones(cfg.Rollups.Timezones); err != nil {
+		log.Fatalf("❌ Invalid rollups.timezones: %v", err)
+	}
+	if _, err := storage.NewDetector(cfg); err != nil {
+		log.Fatalf("❌ Invalid anomalies configuration: %v", err)
+	}
+	if cfg.Budgets.ProjectionMinDays < 0 || cfg.Budgets.ProjectionMinDays > 31 {
+		log.Fatalf("❌ Invalid budgets.projection_min_days: %v must be between 0 and 31", cfg.Budgets.ProjectionMinDays)
+	}
+	if cfg.Webhooks.PollInterval > 0 {
+		if cfg.Webhooks.Timeout <= 0 || cfg.Webhooks.MaxAttempts < 1 || cfg.Webhooks.Concurrency < 1 {
+			log.Fatalf("❌ Invalid webhooks configuration: timeout, max_attempts and concurrency must be positive")
+		}
+		if cfg.Webhooks.InitialBackoff <= 0 || cfg.Webhooks.MaxBackoff < cfg.Webhooks.InitialBackoff {
+			log.Fatalf("❌ Invalid webhooks configuration: initial_backoff must be positive and at most max_backoff")
+		}
+	}
+
+	if err := storage.ValidateOutboxSinks(cfg.Outbox.Sinks); err != nil {
+		log.Fatalf("❌ Invalid outbox.sinks: %v", err)
+	}
+	if cfg.Outbox.PollInterval > 0 && (cfg.Outbox.BatchSize < 1 || cfg.Outbox.MaxBackoff <= 0) {
+		log.Fatalf("❌ Invalid outbox configuration: batch_size and max_backoff must be positive")
+	}
+	if cfg.MQTT.BrokerURL != "" {
+		if err := ingest.ValidateConfig(cfg.MQTT); err != nil {
+			log.Fatalf("❌ Invalid mqtt configuration: %v", err)
+		}
+	}
+	if cfg.GRPC.Port != "" && cfg.GRPC.IngestBatchSize < 1 {
+		log.Fatalf("❌ Invalid grpc.ingest_batch_size: %d must be positive", cfg.GRPC.IngestBatchSize)
+	}
+	if cfg.Stream.ReplaySize < 1 || cfg.Stream.Heartbeat <= 0 || cfg.Stream.ClientBuffer < 1 {
+		log.Fatalf("❌ Invalid stream configuration: replay_size, heartbeat and client_buffer must be positive")
+	}
+	if cfg.GraphQL.MaxDepth < 1 || cfg.GraphQL.MaxComplexity < 1 {
+		log.Fatalf("❌ Invalid graphql configuration: max_depth and max_complexity must be positive")
+	}
+	if cfg.Search.MinSimilarity <= 0 || cfg.Search.MinSimilarity > 1 {
+		log.Fatalf("❌ Invalid search.min_similarity: %v must be above 0 and at most 1", cfg.Search.MinSimilarity)
+	}
+	if cfg.Search.MaxResults < 1 {
+		log.Fatalf("❌ Invalid search.max_results: %d must be positive", cfg.Search.MaxResults)
+	}
+	if cfg.Cache.Enabled && (cfg.Cache.MaxEntries < 1 || cfg.Cache.TTL <= 0 || cfg.Cache.MaxAge < 0) {
+		log.Fatalf("❌ Invalid cache configuration: max_entries and ttl must be positive and max_age must not be negative")
+	}
+
+	// Apply database migrations
+	store, err := storage.NewStorage(cfg, storage.AllTenants)
+	if err != nil {
+		log.Fatalf("❌ Failed to connect to database: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.Migrate(context.Background()); err != nil {
+		log.Fatalf("❌ Failed to apply migrations: %v", err)
+	}
+	log.Println("✅ Database migrations applied")
+	if err := store.CheckRowLevelSecurity(context.Background()); err != nil {
+		log.Fatalf("❌ Tenant isolation would not be enforced: %v", err)
+	}
+
+	// Start background jobs
+	jobs.StartTrashPurge(context.Background(), store, cfg.Trash.PurgeInterval, cfg.Trash.Retention)
+	jobs.StartAnomalyDetection(context.Background(), store, cfg.Anomalies.BatchInterval)
+	jobs.StartBudgetSweep(context.Background(), store, cfg.Budgets.SweepInterval)
+	jobs.StartWebhookDelivery(context.Background(), store, cfg.Webhooks)
+	sinks, err := store.OutboxSinks(cfg.Outbox.Sinks)
+	if err != nil {
+		log.Fatalf("❌ Invalid outbox.sinks: %v", err)
+	}
+	jobs.StartOutboxDispatcher(context.Background(), store, sinks, cfg.Outbox)
+	hub := stream.NewHub(cfg.Stream.ReplaySize, cfg.Stream.ClientBuffer)
+	if err := jobs.StartEventStream(context.Background(), storage.DBConfig(cfg), store, hub); err != nil {
+		log.Fatalf("❌ Failed to listen for stream events: %v", err)
+	}
+	stream.SetDefault(hub)
+	if cfg.Cache.Enabled {
+		cache.SetDefault(cache.New(cfg.Cache.MaxEntries, cfg.Cache.TTL))
+	}
+	if err := jobs.StartMQTTIngestion(context.Background(), store, cfg.MQTT); err != nil {
+		log.Fatalf("❌ Failed to start MQTT ingestion: %v", err)
+	}
+
+	if cfg.GRPC.Port != "" {
+		startGRPCServer(cfg.GRPC)
+	}
+
 	// Create router
 	router := NewRouter(cfg)
 
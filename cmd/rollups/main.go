@@ -0,0 +1,77 @@
+// Command rollups rebuilds the daily, weekly and monthly reading rollups for a date range, e.g. after
+// adding a timezone to rollups.timezones or correcting readings directly in the database.
+//
+//	go run ./cmd/rollups -start 2024-01-01 -end 2024-12-31 [-tz Europe/Berlin,UTC]
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"strings"
+	"time"
+
+	"product-tracker/config"
+	"product-tracker/models"
+	"product-tracker/rollups"
+	"product-tracker/storage"
+
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	startFlag := flag.String("start", "", "First local date to rebuild (YYYY-MM-DD)")
+	endFlag := flag.String("end", "", "Last local date to rebuild (YYYY-MM-DD)")
+	tzFlag := flag.String("tz", "", "Comma-separated timezones to rebuild; defaults to all of rollups.timezones")
+	flag.Parse()
+
+	start, err := time.Parse(models.ReadingDateLayout, *startFlag)
+	if err != nil {
+		log.Fatal("❌ -start must be a date in YYYY-MM-DD format")
+	}
+	end, err := time.Parse(models.ReadingDateLayout, *endFlag)
+	if err != nil {
+		log.Fatal("❌ -end must be a date in YYYY-MM-DD format")
+	}
+	if end.Before(start) {
+		log.Fatal("❌ -end must not be before -start")
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("❌ Failed to load configuration: %v", err)
+	}
+	if err := rollups.ValidateTimezones(cfg.Rollups.Timezones); err != nil {
+		log.Fatalf("❌ Invalid rollups.timezones: %v", err)
+	}
+
+	// Only maintained timezones are rebuilt; rollups of others would go stale on the next insert
+	timezones := cfg.Rollups.Timezones
+	if *tzFlag != "" {
+		timezones = nil
+		for _, tz := range strings.Split(*tzFlag, ",") {
+			tz = strings.TrimSpace(tz)
+			if err := rollups.CheckTimezone(tz, cfg.Rollups.Timezones); err != nil {
+				log.Fatalf("❌ Invalid -tz: %v", err)
+			}
+			timezones = append(timezones, tz)
+		}
+	}
+
+	store, err := storage.NewStorage(cfg, storage.AllTenants)
+	if err != nil {
+		log.Fatalf("❌ Failed to connect to database: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	if err := store.Migrate(ctx); err != nil {
+		log.Fatalf("❌ Failed to apply migrations: %v", err)
+	}
+
+	written, err := store.RebuildRollups(ctx, start, end, timezones)
+	if err != nil {
+		log.Fatalf("❌ Failed to rebuild rollups: %v", err)
+	}
+	log.Printf("✅ Rebuilt %d rollups for %s to %s in %s", written, *startFlag, *endFlag, strings.Join(timezones, ", "))
+}
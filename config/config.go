@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -13,9 +15,26 @@ var cfg *Config
 
 // Config represents the application configuration
 type Config struct {
-	Server   ServerConfig   `yaml:"server" json:"server"`
-	Database DatabaseConfig `yaml:"database" json:"database"`
-	JWT      JWTConfig      `yaml:"jwt" json:"jwt"`
+	Server      ServerConfig      `yaml:"server" json:"server"`
+	Database    DatabaseConfig    `yaml:"database" json:"database"`
+	JWT         JWTConfig         `yaml:"jwt" json:"jwt"`
+	Idempotency IdempotencyConfig `yaml:"idempotency" json:"idempotency"`
+	Products    ProductsConfig    `yaml:"products" json:"products"`
+	Trash       TrashConfig       `yaml:"trash" json:"trash"`
+	Money       MoneyConfig       `yaml:"money" json:"money"`
+	Emissions   EmissionsConfig   `yaml:"emissions" json:"emissions"`
+	TCO         TCOConfig         `yaml:"tco" json:"tco"`
+	Rollups     RollupsConfig     `yaml:"rollups" json:"rollups"`
+	Anomalies   AnomaliesConfig   `yaml:"anomalies" json:"anomalies"`
+	Budgets     BudgetsConfig     `yaml:"budgets" json:"budgets"`
+	Webhooks    WebhooksConfig    `yaml:"webhooks" json:"webhooks"`
+	Outbox      OutboxConfig      `yaml:"outbox" json:"outbox"`
+	Stream      StreamConfig      `yaml:"stream" json:"stream"`
+	MQTT        MQTTConfig        `yaml:"mqtt" json:"mqtt"`
+	GRPC        GRPCConfig        `yaml:"grpc" json:"grpc"`
+	GraphQL     GraphQLConfig     `yaml:"graphql" json:"graphql"`
+	Search      SearchConfig      `yaml:"search" json:"search"`
+	Cache       CacheConfig       `yaml:"cache" json:"cache"`
 }
 
 // ServerConfig represents the server configuration
@@ -43,6 +62,185 @@ type JWTConfig struct {
 	ExpirationTime time.Duration `yaml:"expiration_time" json:"expiration_time"`
 }
 
+// IdempotencyConfig represents the Idempotency-Key handling configuration
+type IdempotencyConfig struct {
+	TTL time.Duration `yaml:"ttl" json:"ttl"`
+	// Lease is how long a key stays reserved for a request that has not completed, after which a retry may
+	// take it over, as when the server crashed while handling the original request
+	Lease time.Duration `yaml:"lease" json:"lease"`
+}
+
+// ProductsConfig represents the product catalogue configuration
+type ProductsConfig struct {
+	NaturalKey     []string `yaml:"natural_key" json:"natural_key"`
+	RequireIfMatch bool     `yaml:"require_if_match" json:"require_if_match"`
+}
+
+// TrashConfig represents the soft-delete retention configuration
+type TrashConfig struct {
+	Retention     time.Duration `yaml:"retention" json:"retention"`
+	PurgeInterval time.Duration `yaml:"purge_interval" json:"purge_interval"`
+}
+
+// MoneyConfig represents the currency configuration
+type MoneyConfig struct {
+	DefaultCurrency   string `yaml:"default_currency" json:"default_currency"`
+	ReportingCurrency string `yaml:"reporting_currency" json:"reporting_currency"`
+}
+
+// EmissionsConfig represents the carbon emissions configuration
+type EmissionsConfig struct {
+	// DefaultRegion is used for readings whose reading and product carry no region; empty means no fallback
+	DefaultRegion string `yaml:"default_region" json:"default_region"`
+}
+
+// TCOConfig represents the total cost of ownership comparison configuration
+type TCOConfig struct {
+	// DiscountRate is the yearly rate future energy costs are discounted at, e.g. 0.05 for 5%
+	DiscountRate float64 `yaml:"discount_rate" json:"discount_rate"`
+}
+
+// RollupsConfig represents the reading rollups configuration
+type RollupsConfig struct {
+	// Timezones lists the IANA timezones daily, weekly and monthly rollups are maintained in
+	Timezones []string `yaml:"timezones" json:"timezones"`
+}
+
+// AnomaliesConfig represents the reading anomaly detection configuration
+type AnomaliesConfig struct {
+	// Method names the detector, robust_z or ewma
+	Method string `yaml:"method" json:"method"`
+	// Sensitivity is the number of spreads a reading may lie from its baseline; lower values flag more readings
+	Sensitivity float64 `yaml:"sensitivity" json:"sensitivity"`
+	// Window is the number of preceding readings of the product the baseline is computed from
+	Window int `yaml:"window" json:"window"`
+	// MinHistory is the number of preceding readings needed before a reading is judged
+	MinHistory int `yaml:"min_history" json:"min_history"`
+	// Alpha is the smoothing factor of the ewma method
+	Alpha float64 `yaml:"alpha" json:"alpha"`
+	// BatchInterval is how often readings not checked on ingestion are checked; zero disables the job
+	BatchInterval time.Duration `yaml:"batch_interval" json:"batch_interval"`
+}
+
+// BudgetsConfig represents the budget evaluation configuration
+type BudgetsConfig struct {
+	// SweepInterval is how often every budget is evaluated; zero disables the sweep
+	SweepInterval time.Duration `yaml:"sweep_interval" json:"sweep_interval"`
+	// ProjectionMinDays is how many days of a month must pass before its run rate is projected
+	ProjectionMinDays float64 `yaml:"projection_min_days" json:"projection_min_days"`
+}
+
+// WebhooksConfig represents the webhook delivery configuration
+type WebhooksConfig struct {
+	// PollInterval is how often due deliveries are looked for; zero disables delivery
+	PollInterval time.Duration `yaml:"poll_interval" json:"poll_interval"`
+	// Timeout bounds each delivery request
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`
+	// MaxAttempts is the number of attempts after which a delivery is dead-lettered
+	MaxAttempts int `yaml:"max_attempts" json:"max_attempts"`
+	// InitialBackoff and MaxBackoff bound the exponential delay between attempts
+	InitialBackoff time.Duration `yaml:"initial_backoff" json:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" json:"max_backoff"`
+	// Concurrency is the number of deliveries sent at the same time
+	Concurrency int `yaml:"concurrency" json:"concurrency"`
+	// AllowPrivateNetworks lets deliveries reach loopback, private and link-local addresses, for local development
+	AllowPrivateNetworks bool `yaml:"allow_private_networks" json:"allow_private_networks"`
+}
+
+// OutboxConfig represents the outbox dispatcher configuration
+type OutboxConfig struct {
+	// PollInterval is how often due events are dispatched; zero disables the dispatcher
+	PollInterval time.Duration `yaml:"poll_interval" json:"poll_interval"`
+	// BatchSize is the number of events claimed per transaction
+	BatchSize int `yaml:"batch_size" json:"batch_size"`
+	// MaxBackoff is the longest delay before a failed event is dispatched again
+	MaxBackoff time.Duration `yaml:"max_backoff" json:"max_backoff"`
+	// Retention is how long published events are kept
+	Retention time.Duration `yaml:"retention" json:"retention"`
+	// Sinks names the sinks events are published to
+	Sinks []string `yaml:"sinks" json:"sinks"`
+}
+
+// StreamConfig represents the live event stream configuration
+type StreamConfig struct {
+	// ReplaySize is the number of recent events kept for clients resuming with Last-Event-ID
+	ReplaySize int `yaml:"replay_size" json:"replay_size"`
+	// Heartbeat is how often an idle connection gets a comment to keep proxies from closing it
+	Heartbeat time.Duration `yaml:"heartbeat" json:"heartbeat"`
+	// ClientBuffer is the number of events queued per client before a slow client is dropped
+	ClientBuffer int `yaml:"client_buffer" json:"client_buffer"`
+}
+
+// MQTTConfig represents the MQTT reading ingestion configuration
+type MQTTConfig struct {
+	// BrokerURL is the broker to subscribe to, such as tcp://localhost:1883; empty disables ingestion
+	BrokerURL string `yaml:"broker_url" json:"broker_url"`
+	// ClientID identifies the subscriber's session, which the broker keeps across reconnections
+	ClientID string `yaml:"client_id" json:"client_id"`
+	Username string `yaml:"username" json:"username"`
+	Password string `yaml:"password" json:"password"`
+	// Topics are the topic filters subscribed to, with + and # wildcards
+	Topics []string `yaml:"topics" json:"topics"`
+	// QoS is the quality of service of the subscriptions, from 0 to 2
+	QoS int `yaml:"qos" json:"qos"`
+	// Products maps topic filters to the products their readings belong to; the first match wins
+	Products []MQTTProductMapping `yaml:"products" json:"products"`
+	// Template renders a message into the JSON of a reading
+	Template string `yaml:"template" json:"template"`
+	// BatchSize is the number of readings committed per transaction
+	BatchSize int `yaml:"batch_size" json:"batch_size"`
+	// BatchInterval is the longest a reading waits for its batch to fill up before being committed
+	BatchInterval time.Duration `yaml:"batch_interval" json:"batch_interval"`
+	// MaxReconnectInterval bounds the backoff between attempts to reach the broker or the database
+	MaxReconnectInterval time.Duration `yaml:"max_reconnect_interval" json:"max_reconnect_interval"`
+}
+
+// MQTTProductMapping assigns the messages of a topic filter to a product
+type MQTTProductMapping struct {
+	Topic     string `yaml:"topic" json:"topic"`
+	ProductID int64  `yaml:"product_id" json:"product_id"`
+}
+
+// GRPCConfig represents the gRPC API configuration
+type GRPCConfig struct {
+	// Port is the port the gRPC API listens on; empty disables it
+	Port string `yaml:"port" json:"port"`
+	// IngestBatchSize is the number of streamed readings committed per transaction
+	IngestBatchSize int `yaml:"ingest_batch_size" json:"ingest_batch_size"`
+}
+
+// GraphQLConfig represents the limits of the GraphQL endpoint
+type GraphQLConfig struct {
+	// MaxDepth is the deepest nesting of fields a query may select
+	MaxDepth int `yaml:"max_depth" json:"max_depth"`
+	// MaxComplexity bounds the estimated cost of a query: every field costs one, multiplied by the page size
+	// of the lists it is selected in
+	MaxComplexity int `yaml:"max_complexity" json:"max_complexity"`
+}
+
+// SearchConfig represents the product search configuration
+type SearchConfig struct {
+	// MinSimilarity is the trigram word similarity, between 0 and 1, a product name needs to match a search
+	// that its full text does not
+	MinSimilarity float64 `yaml:"min_similarity" json:"min_similarity"`
+	// MaxResults bounds the limit a search may ask for
+	MaxResults int `yaml:"max_results" json:"max_results"`
+}
+
+// CacheConfig represents the response cache of the read endpoints
+type CacheConfig struct {
+	// Enabled turns the response cache on
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// MaxEntries is the number of responses kept before the least recently used is evicted
+	MaxEntries int `yaml:"max_entries" json:"max_entries"`
+	// TTL is how long a response is served from the cache at most, bounding the staleness of changes the cache
+	// is not told about
+	TTL time.Duration `yaml:"ttl" json:"ttl"`
+	// MaxAge lets clients reuse a response without revalidating it for this long; zero makes them revalidate
+	// every time
+	MaxAge time.Duration `yaml:"max_age" json:"max_age"`
+}
+
 // LoadConfig loads the configuration from config.yml and environment variables
 func LoadConfig() (*Config, error) {
 	// Default configuration
@@ -66,6 +264,83 @@ func LoadConfig() (*Config, error) {
 			Secret:         "your-secret-key",
 			ExpirationTime: 24 * time.Hour,
 		},
+		Idempotency: IdempotencyConfig{
+			TTL:   24 * time.Hour,
+			Lease: time.Minute,
+		},
+		Products: ProductsConfig{
+			NaturalKey: []string{"name", "model"},
+		},
+		Trash: TrashConfig{
+			Retention:     30 * 24 * time.Hour,
+			PurgeInterval: time.Hour,
+		},
+		Money: MoneyConfig{
+			DefaultCurrency:   "USD",
+			ReportingCurrency: "USD",
+		},
+		TCO: TCOConfig{
+			DiscountRate: 0.05,
+		},
+		Rollups: RollupsConfig{
+			Timezones: []string{"UTC"},
+		},
+		Anomalies: AnomaliesConfig{
+			Method:        "robust_z",
+			Sensitivity:   3.5,
+			Window:        30,
+			MinHistory:    7,
+			Alpha:         0.3,
+			BatchInterval: 15 * time.Minute,
+		},
+		Budgets: BudgetsConfig{
+			SweepInterval:     5 * time.Minute,
+			ProjectionMinDays: 3,
+		},
+		Webhooks: WebhooksConfig{
+			PollInterval:   5 * time.Second,
+			Timeout:        10 * time.Second,
+			MaxAttempts:    8,
+			InitialBackoff: 30 * time.Second,
+			MaxBackoff:     6 * time.Hour,
+			Concurrency:    4,
+		},
+		Outbox: OutboxConfig{
+			PollInterval: time.Second,
+			BatchSize:    100,
+			MaxBackoff:   5 * time.Minute,
+			Retention:    7 * 24 * time.Hour,
+			Sinks:        []string{"webhooks", "notify"},
+		},
+		Stream: StreamConfig{
+			ReplaySize:   1000,
+			Heartbeat:    15 * time.Second,
+			ClientBuffer: 64,
+		},
+		MQTT: MQTTConfig{
+			ClientID:             "product-tracker",
+			QoS:                  1,
+			BatchSize:            100,
+			BatchInterval:        time.Second,
+			MaxReconnectInterval: time.Minute,
+		},
+		GRPC: GRPCConfig{
+			Port:            "9090",
+			IngestBatchSize: 500,
+		},
+		GraphQL: GraphQLConfig{
+			MaxDepth:      8,
+			MaxComplexity: 10000,
+		},
+		Search: SearchConfig{
+			MinSimilarity: 0.5,
+			MaxResults:    100,
+		},
+		Cache: CacheConfig{
+			Enabled:    true,
+			MaxEntries: 10000,
+			TTL:        5 * time.Minute,
+		},
 	}
 
 	// Try to read config.yaml from the config directory
@@ -84,6 +359,60 @@ func LoadConfig() (*Config, error) {
 	cfg.Database.DbName = getEnvOrDefault("DB_NAME", cfg.Database.DbName)
 	cfg.Database.SSLMode = getEnvOrDefault("DB_SSL_MODE", cfg.Database.SSLMode)
 	cfg.JWT.Secret = getEnvOrDefault("JWT_SECRET", cfg.JWT.Secret)
+	cfg.Idempotency.TTL = getEnvDurationOrDefault("IDEMPOTENCY_TTL", cfg.Idempotency.TTL)
+	cfg.Idempotency.Lease = getEnvDurationOrDefault("IDEMPOTENCY_LEASE", cfg.Idempotency.Lease)
+	cfg.Products.NaturalKey = getEnvListOrDefault("PRODUCTS_NATURAL_KEY", cfg.Products.NaturalKey)
+	cfg.Products.RequireIfMatch = getEnvBoolOrDefault("PRODUCTS_REQUIRE_IF_MATCH", cfg.Products.RequireIfMatch)
+	cfg.Trash.Retention = getEnvDurationOrDefault("TRASH_RETENTION", cfg.Trash.Retention)
+	cfg.Trash.PurgeInterval = getEnvDurationOrDefault("TRASH_PURGE_INTERVAL", cfg.Trash.PurgeInterval)
+	cfg.Money.DefaultCurrency = getEnvOrDefault("MONEY_DEFAULT_CURRENCY", cfg.Money.DefaultCurrency)
+	cfg.Money.ReportingCurrency = getEnvOrDefault("MONEY_REPORTING_CURRENCY", cfg.Money.ReportingCurrency)
+	cfg.Emissions.DefaultRegion = getEnvOrDefault("EMISSIONS_DEFAULT_REGION", cfg.Emissions.DefaultRegion)
+	cfg.TCO.DiscountRate = getEnvFloatOrDefault("TCO_DISCOUNT_RATE", cfg.TCO.DiscountRate)
+	cfg.Rollups.Timezones = getEnvListOrDefault("ROLLUPS_TIMEZONES", cfg.Rollups.Timezones)
+	cfg.Anomalies.Method = getEnvOrDefault("ANOMALIES_METHOD", cfg.Anomalies.Method)
+	cfg.Anomalies.Sensitivity = getEnvFloatOrDefault("ANOMALIES_SENSITIVITY", cfg.Anomalies.Sensitivity)
+	cfg.Anomalies.Window = getEnvIntOrDefault("ANOMALIES_WINDOW", cfg.Anomalies.Window)
+	cfg.Anomalies.MinHistory = getEnvIntOrDefault("ANOMALIES_MIN_HISTORY", cfg.Anomalies.MinHistory)
+	cfg.Anomalies.Alpha = getEnvFloatOrDefault("ANOMALIES_ALPHA", cfg.Anomalies.Alpha)
+	cfg.Anomalies.BatchInterval = getEnvDurationOrDefault("ANOMALIES_BATCH_INTERVAL", cfg.Anomalies.BatchInterval)
+	cfg.Budgets.SweepInterval = getEnvDurationOrDefault("BUDGETS_SWEEP_INTERVAL", cfg.Budgets.SweepInterval)
+	cfg.Budgets.ProjectionMinDays = getEnvFloatOrDefault("BUDGETS_PROJECTION_MIN_DAYS", cfg.Budgets.ProjectionMinDays)
+	cfg.Webhooks.PollInterval = getEnvDurationOrDefault("WEBHOOKS_POLL_INTERVAL", cfg.Webhooks.PollInterval)
+	cfg.Webhooks.Timeout = getEnvDurationOrDefault("WEBHOOKS_TIMEOUT", cfg.Webhooks.Timeout)
+	cfg.Webhooks.MaxAttempts = getEnvIntOrDefault("WEBHOOKS_MAX_ATTEMPTS", cfg.Webhooks.MaxAttempts)
+	cfg.Webhooks.InitialBackoff = getEnvDurationOrDefault("WEBHOOKS_INITIAL_BACKOFF", cfg.Webhooks.InitialBackoff)
+	cfg.Webhooks.MaxBackoff = getEnvDurationOrDefault("WEBHOOKS_MAX_BACKOFF", cfg.Webhooks.MaxBackoff)
+	cfg.Webhooks.Concurrency = getEnvIntOrDefault("WEBHOOKS_CONCURRENCY", cfg.Webhooks.Concurrency)
+	cfg.Webhooks.AllowPrivateNetworks = getEnvBoolOrDefault("WEBHOOKS_ALLOW_PRIVATE_NETWORKS", cfg.Webhooks.AllowPrivateNetworks)
+	cfg.Outbox.PollInterval = getEnvDurationOrDefault("OUTBOX_POLL_INTERVAL", cfg.Outbox.PollInterval)
+	cfg.Outbox.BatchSize = getEnvIntOrDefault("OUTBOX_BATCH_SIZE", cfg.Outbox.BatchSize)
+	cfg.Outbox.MaxBackoff = getEnvDurationOrDefault("OUTBOX_MAX_BACKOFF", cfg.Outbox.MaxBackoff)
+	cfg.Outbox.Retention = getEnvDurationOrDefault("OUTBOX_RETENTION", cfg.Outbox.Retention)
+	cfg.Outbox.Sinks = getEnvListOrDefault("OUTBOX_SINKS", cfg.Outbox.Sinks)
+	cfg.Stream.ReplaySize = getEnvIntOrDefault("STREAM_REPLAY_SIZE", cfg.Stream.ReplaySize)
+	cfg.Stream.Heartbeat = getEnvDurationOrDefault("STREAM_HEARTBEAT", cfg.Stream.Heartbeat)
+	cfg.Stream.ClientBuffer = getEnvIntOrDefault("STREAM_CLIENT_BUFFER", cfg.Stream.ClientBuffer)
+	cfg.MQTT.BrokerURL = getEnvOrDefault("MQTT_BROKER_URL", cfg.MQTT.BrokerURL)
+	cfg.MQTT.ClientID = getEnvOrDefault("MQTT_CLIENT_ID", cfg.MQTT.ClientID)
+	cfg.MQTT.Username = getEnvOrDefault("MQTT_USERNAME", cfg.MQTT.Username)
+	cfg.MQTT.Password = getEnvOrDefault("MQTT_PASSWORD", cfg.MQTT.Password)
+	cfg.MQTT.Topics = getEnvListOrDefault("MQTT_TOPICS", cfg.MQTT.Topics)
+	cfg.MQTT.QoS = getEnvIntOrDefault("MQTT_QOS", cfg.MQTT.QoS)
+	cfg.MQTT.Template = getEnvOrDefault("MQTT_TEMPLATE", cfg.MQTT.Template)
+	cfg.MQTT.BatchSize = getEnvIntOrDefault("MQTT_BATCH_SIZE", cfg.MQTT.BatchSize)
+	cfg.MQTT.BatchInterval = getEnvDurationOrDefault("MQTT_BATCH_INTERVAL", cfg.MQTT.BatchInterval)
+	cfg.MQTT.MaxReconnectInterval = getEnvDurationOrDefault("MQTT_MAX_RECONNECT_INTERVAL", cfg.MQTT.MaxReconnectInterval)
+	cfg.GRPC.Port = getEnvOrDefault("GRPC_PORT", cfg.GRPC.Port)
+	cfg.GRPC.IngestBatchSize = getEnvIntOrDefault("GRPC_INGEST_BATCH_SIZE", cfg.GRPC.IngestBatchSize)
+	cfg.GraphQL.MaxDepth = getEnvIntOrDefault("GRAPHQL_MAX_DEPTH", cfg.GraphQL.MaxDepth)
+	cfg.GraphQL.MaxComplexity = getEnvIntOrDefault("GRAPHQL_MAX_COMPLEXITY", cfg.GraphQL.MaxComplexity)
+	cfg.Search.MinSimilarity = getEnvFloatOrDefault("SEARCH_MIN_SIMILARITY", cfg.Search.MinSimilarity)
+	cfg.Search.MaxResults = getEnvIntOrDefault("SEARCH_MAX_RESULTS", cfg.Search.MaxResults)
+	cfg.Cache.Enabled = getEnvBoolOrDefault("CACHE_ENABLED", cfg.Cache.Enabled)
+	cfg.Cache.MaxEntries = getEnvIntOrDefault("CACHE_MAX_ENTRIES", cfg.Cache.MaxEntries)
+	cfg.Cache.TTL = getEnvDurationOrDefault("CACHE_TTL", cfg.Cache.TTL)
+	cfg.Cache.MaxAge = getEnvDurationOrDefault("CACHE_MAX_AGE", cfg.Cache.MaxAge)
 
 	log.Printf("Loaded configuration - Database: %s@%s:%s/%s",
 		cfg.Database.User, cfg.Database.Host, cfg.Database.Port, cfg.Database.DbName)
@@ -103,6 +432,72 @@ func getEnvOrDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvDurationOrDefault(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Warning: Invalid duration for %s: %v", key, err)
+		return defaultValue
+	}
+	return d
+}
+
+func getEnvBoolOrDefault(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Warning: Invalid boolean for %s: %v", key, err)
+		return defaultValue
+	}
+	return b
+}
+
+func getEnvFloatOrDefault(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Warning: Invalid number for %s: %v", key, err)
+		return defaultValue
+	}
+	return f
+}
+
+func getEnvIntOrDefault(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Warning: Invalid integer for %s: %v", key, err)
+		return defaultValue
+	}
+	return i
+}
+
+func getEnvListOrDefault(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 // GetDSN returns the database connection string
 func (c *Config) GetDSN() string {
 	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
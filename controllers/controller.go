@@ -33,7 +33,7 @@ func InsertProduct(c context.Context, p Product) error {
 }
 
 func GetProductsByName(c context.Context, name string) ([]Product, error) {
-	products, err := S.GetProductsByName(c, name)
+	products, err := S.GetProductsByName(c, name, storage.ProductFilter{})
 	if err != nil {
 		return nil, err
 	}
@@ -50,7 +50,7 @@ func GetProductsByName(c context.Context, name string) ([]Product, error) {
 }
 
 func GetProducts(c context.Context) ([]Product, error) {
-	products, err := S.GetProducts(c)
+	products, err := S.GetProducts(c, storage.ProductFilter{})
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,276 @@
+package costcalc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"product-tracker/models"
+	"product-tracker/money"
+	"product-tracker/units"
+)
+
+// Custom errors for tariff validation and cost projections
+var (
+	ErrInvalidTariff = errors.New("invalid tariff")
+	ErrNoRate        = errors.New("no tariff rate")
+	ErrInvalidUsage  = errors.New("invalid usage")
+)
+
+// MaxYears is the longest projection accepted
+const MaxYears = 50
+
+// dateLayout is the layout of the dates in projections
+const dateLayout = "2006-01-02"
+
+// Period is a flat tariff rate over a range of days
+type Period struct {
+	// From is the first day of the period at midnight UTC
+	From time.Time
+	// To is the last day of the period at midnight UTC, or nil if the period is open-ended
+	To *time.Time
+	// UnitRate is the price of one kWh in major units
+	UnitRate *big.Rat
+	// StandingCharge is the fixed price per day in major units
+	StandingCharge *big.Rat
+}
+
+// contains reports whether day falls within the period
+func (p Period) contains(day time.Time) bool {
+	return !day.Before(p.From) && (p.To == nil || !day.After(*p.To))
+}
+
+// Tariff is a validated tariff ready for cost calculations
+type Tariff struct {
+	Currency string
+	// Periods are sorted by start date and do not overlap
+	Periods []Period
+}
+
+// FromModel parses and validates a stored tariff
+func FromModel(t *models.Tariff) (*Tariff, error) {
+	currency, err := money.ParseCurrency(t.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTariff, err)
+	}
+	if len(t.Rates) == 0 {
+		return nil, fmt.Errorf("%w: at least one rate is required", ErrInvalidTariff)
+	}
+
+	tariff := &Tariff{Currency: currency}
+	for i, r := range t.Rates {
+		period, err := parsePeriod(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: rate %d: %v", ErrInvalidTariff, i+1, err)
+		}
+		tariff.Periods = append(tariff.Periods, period)
+	}
+
+	sort.Slice(tariff.Periods, func(i, j int) bool {
+		return tariff.Periods[i].From.Before(tariff.Periods[j].From)
+	})
+	for i := 1; i < len(tariff.Periods); i++ {
+		previous := tariff.Periods[i-1]
+		if previous.To == nil || !previous.To.Before(tariff.Periods[i].From) {
+			return nil, fmt.Errorf("%w: rates starting %s and %s overlap", ErrInvalidTariff,
+				previous.From.Format(dateLayout), tariff.Periods[i].From.Format(dateLayout))
+		}
+	}
+	return tariff, nil
+}
+
+// parsePeriod parses the prices and dates of a tariff rate
+func parsePeriod(r models.TariffRate) (Period, error) {
+	var (
+		period Period
+		err    error
+	)
+	if period.UnitRate, err = parseDecimal("unit_rate", r.UnitRate); err != nil {
+		return period, err
+	}
+	if period.StandingCharge, err = parseDecimal("standing_charge", r.StandingCharge); err != nil {
+		return period, err
+	}
+	if period.From, err = time.Parse(models.TariffDateLayout, r.EffectiveFrom); err != nil {
+		return period, errors.New("effective_from must be a date in YYYY-MM-DD format")
+	}
+	if r.EffectiveTo != nil {
+		to, err := time.Parse(models.TariffDateLayout, *r.EffectiveTo)
+		if err != nil {
+			return period, errors.New("effective_to must be a date in YYYY-MM-DD format")
+		}
+		if to.Before(period.From) {
+			return period, errors.New("effective_to must not be before effective_from")
+		}
+		period.To = &to
+	}
+	return period, nil
+}
+
+// parseDecimal parses a non-negative decimal price such as "0.2834"
+func parseDecimal(name, value string) (*big.Rat, error) {
+	if strings.TrimSpace(value) == "" {
+		return new(big.Rat), nil
+	}
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok || r.Sign() < 0 {
+		return nil, fmt.Errorf("%s must be a non-negative decimal number", name)
+	}
+	return r, nil
+}
+
+// periodOn returns the period in effect on day. Days after the last period use the most recent
+// period and are reported as extrapolated.
+func (t *Tariff) periodOn(day time.Time) (*Period, bool, error) {
+	var latest *Period
+	for i := range t.Periods {
+		p := &t.Periods[i]
+		if p.contains(day) {
+			return p, false, nil
+		}
+		if !p.From.After(day) {
+			latest = p
+		}
+	}
+	if latest == nil {
+		return nil, false, fmt.Errorf("%w before %s", ErrNoRate, t.Periods[0].From.Format(dateLayout))
+	}
+	return latest, true, nil
+}
+
+// EnergyCost returns the price in major units of kwh consumed on day, at the rate in effect that day or, beyond
+// the last rate, at the most recent one. Standing charges are not included.
+func (t *Tariff) EnergyCost(day time.Time, kwh float64) (*big.Rat, error) {
+	if kwh < 0 || math.IsNaN(kwh) || math.IsInf(kwh, 0) {
+		return nil, fmt.Errorf("%w: energy must be a finite, non-negative number", ErrInvalidInterval)
+	}
+	period, _, err := t.periodOn(truncateDay(day))
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Rat).Mul(new(big.Rat).SetFloat64(kwh), period.UnitRate), nil
+}
+
+// Usage describes how a product is used over a projection
+type Usage struct {
+	// AnnualKWh is the energy consumed per year
+	AnnualKWh float64
+	// Start is the first day of the projection
+	Start time.Time
+	// Years is the number of years to project
+	Years int
+}
+
+// YearCost is the projected running cost of one year
+type YearCost struct {
+	Year           int         `json:"year" example:"1"`
+	From           string      `json:"from" example:"2024-01-01"`
+	To             string      `json:"to" example:"2024-12-31"`
+	EnergyKWh      float64     `json:"energy_kwh" example:"438"`
+	EnergyCost     money.Money `json:"energy_cost"`
+	StandingCharge money.Money `json:"standing_charge"`
+	Total          money.Money `json:"total"`
+	// Extrapolated is set when part of the year lies beyond the last tariff rate
+	Extrapolated bool `json:"extrapolated"`
+}
+
+// Projection is the projected running cost of a product over several years
+type Projection struct {
+	Currency  string      `json:"currency" example:"EUR"`
+	AnnualKWh float64     `json:"annual_kwh" example:"438"`
+	Years     []YearCost  `json:"years"`
+	Total     money.Money `json:"total"`
+}
+
+// Project computes the running cost of usage under the tariff, year by year.
+// Energy is spread evenly over the days of each year and priced at the rate in effect on each day.
+func Project(t *Tariff, u Usage) (*Projection, error) {
+	if u.Years < 1 || u.Years > MaxYears {
+		return nil, fmt.Errorf("%w: years must be between 1 and %d", ErrInvalidUsage, MaxYears)
+	}
+	if u.AnnualKWh < 0 || math.IsNaN(u.AnnualKWh) || math.IsInf(u.AnnualKWh, 0) {
+		return nil, fmt.Errorf("%w: annual consumption must be a finite, non-negative number", ErrInvalidUsage)
+	}
+
+	projection := &Projection{
+		Currency:  t.Currency,
+		AnnualKWh: u.AnnualKWh,
+		Total:     money.Money{Currency: t.Currency},
+	}
+	annual := new(big.Rat).SetFloat64(u.AnnualKWh)
+	start := truncateDay(u.Start)
+
+	for year := 0; year < u.Years; year++ {
+		from := start.AddDate(year, 0, 0)
+		to := start.AddDate(year+1, 0, 0)
+		days := int(to.Sub(from).Hours() / 24)
+		daily := new(big.Rat).Quo(annual, big.NewRat(int64(days), 1))
+
+		energyCost, standingCharge := new(big.Rat), new(big.Rat)
+		extrapolated := false
+		for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+			period, beyond, err := t.periodOn(day)
+			if err != nil {
+				return nil, err
+			}
+			extrapolated = extrapolated || beyond
+			energyCost.Add(energyCost, new(big.Rat).Mul(daily, period.UnitRate))
+			standingCharge.Add(standingCharge, period.StandingCharge)
+		}
+
+		cost := YearCost{
+			Year:         year + 1,
+			From:         from.Format(dateLayout),
+			To:           to.AddDate(0, 0, -1).Format(dateLayout),
+			EnergyKWh:    u.AnnualKWh,
+			Extrapolated: extrapolated,
+		}
+		var err error
+		if cost.EnergyCost, err = money.FromRat(energyCost, t.Currency); err != nil {
+			return nil, err
+		}
+		if cost.StandingCharge, err = money.FromRat(standingCharge, t.Currency); err != nil {
+			return nil, err
+		}
+		if cost.Total, err = cost.EnergyCost.Add(cost.StandingCharge); err != nil {
+			return nil, err
+		}
+		if projection.Total, err = projection.Total.Add(cost.Total); err != nil {
+			return nil, err
+		}
+		projection.Years = append(projection.Years, cost)
+	}
+	return projection, nil
+}
+
+// AnnualConsumption returns the yearly energy use of a product in kWh. Without usageHours the
+// stored consumption is used as is. With usageHours, the hours of use per day, the consumption is
+// scaled from the duty cycle submitted with the product, or from continuous use if there was none.
+func AnnualConsumption(p *models.Product, usageHours *float64) (float64, error) {
+	annual, err := units.ConvertSymbols(p.EnergyConsumption, p.EnergyUnit, units.ProductCanonical, units.Options{})
+	if err != nil {
+		return 0, err
+	}
+	if usageHours == nil {
+		return annual, nil
+	}
+	// NaN fails every comparison, so it is rejected explicitly
+	if math.IsNaN(*usageHours) || *usageHours < 0 || *usageHours > 24 {
+		return 0, fmt.Errorf("%w: usage_hours must be between 0 and 24", ErrInvalidUsage)
+	}
+
+	ratedHours := 24.0
+	if p.EnergyInput != nil && p.EnergyInput.DutyCycle != nil {
+		ratedHours = 24 * *p.EnergyInput.DutyCycle
+	}
+	return annual * *usageHours / ratedHours, nil
+}
+
+// truncateDay returns midnight UTC of the day of t
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,53 @@
+package costcalc
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"product-tracker/models"
+)
+
+func TestEnergyCost(t *testing.T) {
+	firstHalf := "2024-06-30"
+	tariff, err := FromModel(&models.Tariff{
+		Currency: "EUR",
+		Rates: []models.TariffRate{
+			{UnitRate: "0.25", StandingCharge: "1", EffectiveFrom: "2024-01-01", EffectiveTo: &firstHalf},
+			{UnitRate: "0.30", StandingCharge: "1", EffectiveFrom: "2024-07-01"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		day  string
+		kwh  float64
+		want string
+		err  error
+	}{
+		{"2024-06-30", 10, "2.50", nil},
+		{"2024-07-01", 10, "3.00", nil},
+		// Beyond the last rate the most recent one applies
+		{"2026-01-01", 10, "3.00", nil},
+		{"2023-12-31", 10, "", ErrNoRate},
+		{"2024-06-30", -1, "", ErrInvalidInterval},
+		{"2024-06-30", math.NaN(), "", ErrInvalidInterval},
+		{"2024-06-30", math.Inf(1), "", ErrInvalidInterval},
+	}
+	for _, tt := range tests {
+		day, err := time.Parse(dateLayout, tt.day)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cost, err := tariff.EnergyCost(day, tt.kwh)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("EnergyCost(%s, %v) returned %v, want %v", tt.day, tt.kwh, err, tt.err)
+			continue
+		}
+		if err == nil && cost.FloatString(2) != tt.want {
+			t.Errorf("EnergyCost(%s, %v) = %s, want %s", tt.day, tt.kwh, cost.FloatString(2), tt.want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package costcalc
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"time"
+
+	"product-tracker/money"
+)
+
+// MaxCandidates is the largest number of products compared at once
+const MaxCandidates = 20
+
+// Candidate is a product in a total cost of ownership comparison
+type Candidate struct {
+	ProductID int64
+	Name      string
+	// Purchase is the purchase price in the currency of the tariff
+	Purchase money.Money
+	// AnnualKWh is the energy the product consumes per year
+	AnnualKWh float64
+}
+
+// Lifetime describes the period products are compared over
+type Lifetime struct {
+	// Start is the day of purchase
+	Start time.Time
+	// Years is the number of years the products are used
+	Years int
+	// DiscountRate is the yearly rate future costs are discounted at, e.g. 0.05 for 5%
+	DiscountRate *big.Rat
+}
+
+// TCO is the total cost of ownership of one product over its lifetime
+type TCO struct {
+	Rank         int         `json:"rank" example:"1"`
+	ProductID    int64       `json:"product_id" example:"1"`
+	Name         string      `json:"name" example:"Fridge A+++"`
+	AnnualKWh    float64     `json:"annual_kwh" example:"110"`
+	PurchaseCost money.Money `json:"purchase_cost"`
+	// EnergyCost is the undiscounted energy cost over the lifetime
+	EnergyCost money.Money `json:"energy_cost"`
+	// TotalCost is the undiscounted purchase and energy cost
+	TotalCost money.Money `json:"total_cost"`
+	// NPV is the purchase cost plus the energy cost of each year discounted to the day of purchase
+	NPV money.Money `json:"npv"`
+	// Extrapolated is set when part of the lifetime lies beyond the last tariff rate
+	Extrapolated bool `json:"extrapolated"`
+}
+
+// BreakEven is the point at which a product with a higher purchase cost has saved enough energy cost
+// to match the discounted cumulative cost of another product
+type BreakEven struct {
+	// ProductID is the product with the higher purchase cost
+	ProductID int64 `json:"product_id" example:"1"`
+	// OtherProductID is the product with the lower purchase cost
+	OtherProductID int64 `json:"other_product_id" example:"2"`
+	// Years is the time from purchase to break-even, or nil if it is not reached within the lifetime
+	Years *float64 `json:"years" example:"3.42"`
+	// Date is the day of break-even, or nil if it is not reached within the lifetime
+	Date *string `json:"date" example:"2027-06-03"`
+}
+
+// Comparison ranks products by the net present value of their total cost of ownership
+type Comparison struct {
+	Currency     string      `json:"currency" example:"EUR"`
+	Start        string      `json:"start" example:"2024-01-01"`
+	Years        int         `json:"years" example:"10"`
+	DiscountRate string      `json:"discount_rate" example:"0.05"`
+	Products     []TCO       `json:"products"`
+	BreakEvens   []BreakEven `json:"break_evens"`
+}
+
+// Compare computes the total cost of ownership of each candidate under the tariff and ranks them by NPV,
+// lowest first. Energy is priced as in Project; standing charges are left out as they do not depend on the
+// product. Each year's energy cost is discounted from the end of that year.
+func Compare(t *Tariff, candidates []Candidate, lifetime Lifetime) (*Comparison, error) {
+	if len(candidates) < 2 || len(candidates) > MaxCandidates {
+		return nil, fmt.Errorf("%w: compare between 2 and %d products", ErrInvalidUsage, MaxCandidates)
+	}
+	rate := lifetime.DiscountRate
+	if rate == nil {
+		rate = new(big.Rat)
+	}
+	if rate.Sign() < 0 || rate.Cmp(big.NewRat(1, 1)) >= 0 {
+		return nil, fmt.Errorf("%w: discount rate must be at least 0 and below 1", ErrInvalidUsage)
+	}
+
+	start := truncateDay(lifetime.Start)
+	comparison := &Comparison{
+		Currency:     t.Currency,
+		Start:        start.Format(dateLayout),
+		Years:        lifetime.Years,
+		DiscountRate: rate.FloatString(4),
+		Products:     []TCO{},
+		BreakEvens:   []BreakEven{},
+	}
+
+	// cumulative[i][k] is the discounted cost of candidate i up to the end of year k, in major units
+	cumulative := make([][]*big.Rat, len(candidates))
+	for i, candidate := range candidates {
+		if candidate.Purchase.Currency != t.Currency {
+			return nil, fmt.Errorf("%w: %s and %s", money.ErrCurrencyMismatch, candidate.Purchase.Currency, t.Currency)
+		}
+		projection, err := Project(t, Usage{AnnualKWh: candidate.AnnualKWh, Start: start, Years: lifetime.Years})
+		if err != nil {
+			return nil, err
+		}
+
+		tco := TCO{
+			ProductID:    candidate.ProductID,
+			Name:         candidate.Name,
+			AnnualKWh:    candidate.AnnualKWh,
+			PurchaseCost: candidate.Purchase,
+			EnergyCost:   money.Money{Currency: t.Currency},
+		}
+		discount := big.NewRat(1, 1)
+		factor := new(big.Rat).Add(big.NewRat(1, 1), rate)
+		cumulative[i] = []*big.Rat{majorRat(candidate.Purchase)}
+		for _, year := range projection.Years {
+			if tco.EnergyCost, err = tco.EnergyCost.Add(year.EnergyCost); err != nil {
+				return nil, err
+			}
+			tco.Extrapolated = tco.Extrapolated || year.Extrapolated
+			discount.Quo(discount, factor)
+			discounted := new(big.Rat).Mul(majorRat(year.EnergyCost), discount)
+			previous := cumulative[i][len(cumulative[i])-1]
+			cumulative[i] = append(cumulative[i], new(big.Rat).Add(previous, discounted))
+		}
+		if tco.TotalCost, err = tco.PurchaseCost.Add(tco.EnergyCost); err != nil {
+			return nil, err
+		}
+		if tco.NPV, err = money.FromRat(cumulative[i][lifetime.Years], t.Currency); err != nil {
+			return nil, err
+		}
+		comparison.Products = append(comparison.Products, tco)
+	}
+
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return comparison.Products[order[a]].NPV.Amount < comparison.Products[order[b]].NPV.Amount
+	})
+	ranked := make([]TCO, len(order))
+	for rank, i := range order {
+		ranked[rank] = comparison.Products[i]
+		ranked[rank].Rank = rank + 1
+	}
+
+	for a := 0; a < len(order); a++ {
+		for b := a + 1; b < len(order); b++ {
+			i, j := order[a], order[b]
+			// i is the product with the higher purchase cost; on a tie the better ranked one
+			if cumulative[j][0].Cmp(cumulative[i][0]) > 0 {
+				i, j = j, i
+			}
+			comparison.BreakEvens = append(comparison.BreakEvens,
+				breakEven(candidates[i].ProductID, candidates[j].ProductID, cumulative[i], cumulative[j], start))
+		}
+	}
+	comparison.Products = ranked
+	return comparison, nil
+}
+
+// breakEven finds when the cumulative cost of the product with the higher purchase cost first drops to
+// that of the other product, interpolating linearly within the year it happens in
+func breakEven(productID, otherID int64, costs, otherCosts []*big.Rat, start time.Time) BreakEven {
+	result := BreakEven{ProductID: productID, OtherProductID: otherID}
+	previous := new(big.Rat).Sub(costs[0], otherCosts[0])
+	if previous.Sign() <= 0 {
+		years := 0.0
+		date := start.Format(dateLayout)
+		result.Years, result.Date = &years, &date
+		return result
+	}
+	for k := 1; k < len(costs); k++ {
+		difference := new(big.Rat).Sub(costs[k], otherCosts[k])
+		if difference.Sign() <= 0 {
+			fraction, _ := new(big.Rat).Quo(previous, new(big.Rat).Sub(previous, difference)).Float64()
+			years := math.Round((float64(k-1)+fraction)*100) / 100
+			from := start.AddDate(k-1, 0, 0)
+			days := start.AddDate(k, 0, 0).Sub(from).Hours() / 24
+			date := from.AddDate(0, 0, int(fraction*days)).Format(dateLayout)
+			result.Years, result.Date = &years, &date
+			return result
+		}
+		previous = difference
+	}
+	return result
+}
+
+// majorRat returns an amount of money in major units as an exact rational number
+func majorRat(m money.Money) *big.Rat {
+	denominator := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(money.Exponent(m.Currency))), nil)
+	return new(big.Rat).SetFrac(big.NewInt(m.Amount), denominator)
+}
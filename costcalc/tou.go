@@ -0,0 +1,432 @@
+package costcalc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"product-tracker/models"
+	"product-tracker/money"
+)
+
+// Custom errors for time-of-use schedules
+var (
+	ErrInvalidSchedule = errors.New("invalid tariff schedule")
+	ErrInvalidInterval = errors.New("invalid reading interval")
+)
+
+// minutesPerDay is the number of wall-clock minutes in a day
+const minutesPerDay = 24 * 60
+
+// weekdays maps the day names used in schedules to time.Weekday
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Granularity is the length of the periods a time-of-use cost is broken down into
+type Granularity string
+
+const (
+	// Daily breaks costs down by local calendar day
+	Daily Granularity = "day"
+	// Monthly breaks costs down by local calendar month
+	Monthly Granularity = "month"
+)
+
+// ParseGranularity parses a period name, defaulting to Daily
+func ParseGranularity(value string) (Granularity, error) {
+	switch g := Granularity(strings.ToLower(strings.TrimSpace(value))); g {
+	case "":
+		return Daily, nil
+	case Daily, Monthly:
+		return g, nil
+	default:
+		return "", fmt.Errorf("period must be %q or %q", Daily, Monthly)
+	}
+}
+
+// layout returns the layout of period keys of the granularity
+func (g Granularity) layout() string {
+	if g == Monthly {
+		return "2006-01"
+	}
+	return "2006-01-02"
+}
+
+// band is a validated band entry of a schedule
+type band struct {
+	name       string
+	days       [7]bool
+	start, end int
+}
+
+// Schedule is a validated time-of-use schedule ready for cost calculations
+type Schedule struct {
+	Currency string
+	Location *time.Location
+	bands    []band
+	// rates holds the unit rate of each band name; names keeps the order bands were first defined in
+	rates    map[string]*big.Rat
+	names    []string
+	holidays map[string]time.Weekday
+}
+
+// ScheduleFromModel parses and validates a stored tariff schedule
+func ScheduleFromModel(m *models.TariffSchedule) (*Schedule, error) {
+	currency, err := money.ParseCurrency(m.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	if strings.TrimSpace(m.Timezone) == "" {
+		return nil, fmt.Errorf("%w: timezone is required", ErrInvalidSchedule)
+	}
+	location, err := time.LoadLocation(m.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidSchedule, m.Timezone)
+	}
+
+	s := &Schedule{
+		Currency: currency,
+		Location: location,
+		rates:    map[string]*big.Rat{},
+		holidays: map[string]time.Weekday{},
+	}
+	for i, b := range m.Bands {
+		if err := s.addBand(b); err != nil {
+			return nil, fmt.Errorf("%w: band %d: %v", ErrInvalidSchedule, i+1, err)
+		}
+	}
+	if err := s.checkCoverage(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+
+	for i, h := range m.Holidays {
+		date, err := time.Parse(models.TariffDateLayout, h.Date)
+		if err != nil {
+			return nil, fmt.Errorf("%w: holiday %d: date must be a date in YYYY-MM-DD format", ErrInvalidSchedule, i+1)
+		}
+		day, ok := weekdays[strings.ToLower(strings.TrimSpace(h.PricedAs))]
+		if !ok {
+			return nil, fmt.Errorf("%w: holiday %d: priced_as must be one of mon, tue, wed, thu, fri, sat, sun", ErrInvalidSchedule, i+1)
+		}
+		key := date.Format(models.TariffDateLayout)
+		if _, ok := s.holidays[key]; ok {
+			return nil, fmt.Errorf("%w: holiday %s is listed twice", ErrInvalidSchedule, key)
+		}
+		s.holidays[key] = day
+	}
+	return s, nil
+}
+
+// addBand validates a band entry and records its rate
+func (s *Schedule) addBand(b models.TariffBand) error {
+	name := strings.TrimSpace(b.Name)
+	if name == "" {
+		return errors.New("name is required")
+	}
+	rate, err := parseDecimal("unit_rate", b.UnitRate)
+	if err != nil {
+		return err
+	}
+	if existing, ok := s.rates[name]; ok {
+		if existing.Cmp(rate) != 0 {
+			return fmt.Errorf("entries of band %q must share the same unit_rate", name)
+		}
+	} else {
+		s.rates[name] = rate
+		s.names = append(s.names, name)
+	}
+
+	entry := band{name: name}
+	if entry.start, err = parseClock(b.Start); err != nil {
+		return fmt.Errorf("start: %v", err)
+	}
+	if entry.end, err = parseClock(b.End); err != nil {
+		return fmt.Errorf("end: %v", err)
+	}
+	if entry.end <= entry.start {
+		return errors.New("end must be after start; split bands spanning midnight into two entries")
+	}
+	if len(b.Days) == 0 {
+		return errors.New("days are required")
+	}
+	for _, name := range b.Days {
+		day, ok := weekdays[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return fmt.Errorf("unknown day %q: use mon, tue, wed, thu, fri, sat or sun", name)
+		}
+		entry.days[day] = true
+	}
+	s.bands = append(s.bands, entry)
+	return nil
+}
+
+// checkCoverage verifies that every minute of the week belongs to exactly one band
+func (s *Schedule) checkCoverage() error {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		var covered [minutesPerDay]string
+		for _, b := range s.bands {
+			if !b.days[day] {
+				continue
+			}
+			for minute := b.start; minute < b.end; minute++ {
+				if covered[minute] != "" {
+					return fmt.Errorf("bands %q and %q overlap on %s at %s", covered[minute], b.name,
+						strings.ToLower(day.String()[:3]), formatClock(minute))
+				}
+				covered[minute] = b.name
+			}
+		}
+		for minute, name := range covered {
+			if name == "" {
+				return fmt.Errorf("no band covers %s at %s", strings.ToLower(day.String()[:3]), formatClock(minute))
+			}
+		}
+	}
+	return nil
+}
+
+// parseClock parses a wall-clock time such as "07:30" into minutes after midnight; "24:00" is midnight at the end of the day
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		if strings.TrimSpace(value) == "24:00" {
+			return minutesPerDay, nil
+		}
+		return 0, fmt.Errorf("%q must be a time in HH:MM format", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// formatClock formats minutes after midnight as HH:MM
+func formatClock(minute int) string {
+	return fmt.Sprintf("%02d:%02d", minute/60, minute%60)
+}
+
+// bandAt returns the band covering a local wall-clock time, taking holidays into account
+func (s *Schedule) bandAt(local time.Time) band {
+	day := local.Weekday()
+	if pricedAs, ok := s.holidays[local.Format(models.TariffDateLayout)]; ok {
+		day = pricedAs
+	}
+	minute := local.Hour()*60 + local.Minute()
+	for _, b := range s.bands {
+		if b.days[day] && minute >= b.start && minute < b.end {
+			return b
+		}
+	}
+	// Unreachable for validated schedules, which cover the whole week
+	return band{name: s.names[0], start: minute, end: minutesPerDay}
+}
+
+// Interval is an amount of energy consumed evenly over a span of time
+type Interval struct {
+	Start     time.Time
+	Duration  time.Duration
+	EnergyKWh float64
+}
+
+// DayInterval returns the interval covering a whole local calendar day, which lasts 23 or 25 hours on DST changes
+func (s *Schedule) DayInterval(date string, energyKWh float64) (Interval, error) {
+	day, err := time.ParseInLocation(models.ReadingDateLayout, date, s.Location)
+	if err != nil {
+		return Interval{}, fmt.Errorf("%w: date must be a date in YYYY-MM-DD format", ErrInvalidInterval)
+	}
+	next := time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, s.Location)
+	return Interval{Start: day, Duration: next.Sub(day), EnergyKWh: energyKWh}, nil
+}
+
+// ReadingInterval returns the interval of a reading. Interval readings use their recorded start and length;
+// other readings are spread over their whole local day.
+func (s *Schedule) ReadingInterval(r models.Reading) (Interval, error) {
+	if r.RecordedAt == nil {
+		return s.DayInterval(r.Date, r.EnergyConsumed)
+	}
+	if r.IntervalSeconds == nil || *r.IntervalSeconds <= 0 {
+		return Interval{}, fmt.Errorf("%w: reading %d has no interval length", ErrInvalidInterval, r.ID)
+	}
+	return Interval{
+		Start:     *r.RecordedAt,
+		Duration:  time.Duration(*r.IntervalSeconds) * time.Second,
+		EnergyKWh: r.EnergyConsumed,
+	}, nil
+}
+
+// segment is the part of an interval that falls into one band on one local day
+type segment struct {
+	band   string
+	local  time.Time
+	energy *big.Rat
+}
+
+// split divides an interval at band boundaries and local midnights, sharing its energy out by elapsed time.
+// Boundaries are found in wall-clock time within each UTC offset, so DST changes are handled exactly.
+func (s *Schedule) split(iv Interval) ([]segment, error) {
+	if iv.Duration <= 0 {
+		return nil, fmt.Errorf("%w: duration must be positive", ErrInvalidInterval)
+	}
+	if iv.EnergyKWh < 0 || math.IsNaN(iv.EnergyKWh) || math.IsInf(iv.EnergyKWh, 0) {
+		return nil, fmt.Errorf("%w: energy must be a finite, non-negative number", ErrInvalidInterval)
+	}
+
+	energy := new(big.Rat).SetFloat64(iv.EnergyKWh)
+	total := big.NewRat(int64(iv.Duration), 1)
+	end := iv.Start.Add(iv.Duration)
+
+	var segments []segment
+	for t := iv.Start; t.Before(end); {
+		local := t.In(s.Location)
+		b := s.bandAt(local)
+
+		sinceMidnight := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute +
+			time.Duration(local.Second())*time.Second + time.Duration(local.Nanosecond())
+		next := t.Add(time.Duration(b.end)*time.Minute - sinceMidnight)
+		if _, zoneEnd := local.ZoneBounds(); !zoneEnd.IsZero() && zoneEnd.Before(next) {
+			next = zoneEnd
+		}
+		if next.After(end) {
+			next = end
+		}
+
+		share := new(big.Rat).Mul(energy, big.NewRat(int64(next.Sub(t)), 1))
+		segments = append(segments, segment{band: b.name, local: local, energy: share.Quo(share, total)})
+		t = next
+	}
+	return segments, nil
+}
+
+// Cost returns the cost of the energy of an interval, rounded once to the minor unit
+func (s *Schedule) Cost(iv Interval) (money.Money, error) {
+	segments, err := s.split(iv)
+	if err != nil {
+		return money.Money{}, err
+	}
+	cost := new(big.Rat)
+	for _, seg := range segments {
+		cost.Add(cost, new(big.Rat).Mul(seg.energy, s.rates[seg.band]))
+	}
+	return money.FromRat(cost, s.Currency)
+}
+
+// BandCost is the energy and cost attributed to one band
+type BandCost struct {
+	Band      string      `json:"band" example:"peak"`
+	UnitRate  string      `json:"unit_rate" example:"0.341200"`
+	EnergyKWh float64     `json:"energy_kwh" example:"12.5"`
+	Cost      money.Money `json:"cost"`
+}
+
+// PeriodCost is the energy and cost of one local day or month, broken down by band
+type PeriodCost struct {
+	Period    string      `json:"period" example:"2024-03-31"`
+	EnergyKWh float64     `json:"energy_kwh" example:"20.1"`
+	Bands     []BandCost  `json:"bands"`
+	Cost      money.Money `json:"cost"`
+}
+
+// TOUCost is the cost of a set of intervals under a time-of-use schedule.
+// Band and overall totals are sums of the rounded period figures, so every breakdown adds up.
+type TOUCost struct {
+	Currency  string       `json:"currency" example:"EUR"`
+	Timezone  string       `json:"timezone" example:"Europe/Berlin"`
+	Period    Granularity  `json:"period" example:"day"`
+	EnergyKWh float64      `json:"energy_kwh" example:"20.1"`
+	Bands     []BandCost   `json:"bands"`
+	Periods   []PeriodCost `json:"periods"`
+	Total     money.Money  `json:"total"`
+}
+
+// Apply prices intervals under the schedule, breaking the cost down by band and by period
+func (s *Schedule) Apply(intervals []Interval, granularity Granularity) (*TOUCost, error) {
+	energy := map[string]map[string]*big.Rat{}
+	for _, iv := range intervals {
+		segments, err := s.split(iv)
+		if err != nil {
+			return nil, err
+		}
+		for _, seg := range segments {
+			period := seg.local.Format(granularity.layout())
+			if energy[period] == nil {
+				energy[period] = map[string]*big.Rat{}
+			}
+			if energy[period][seg.band] == nil {
+				energy[period][seg.band] = new(big.Rat)
+			}
+			energy[period][seg.band].Add(energy[period][seg.band], seg.energy)
+		}
+	}
+
+	periods := make([]string, 0, len(energy))
+	for period := range energy {
+		periods = append(periods, period)
+	}
+	sort.Strings(periods)
+
+	result := &TOUCost{
+		Currency: s.Currency,
+		Timezone: s.Location.String(),
+		Period:   granularity,
+		Bands:    []BandCost{},
+		Periods:  []PeriodCost{},
+		Total:    money.Money{Currency: s.Currency},
+	}
+	totals := map[string]*BandCost{}
+	bandEnergy := map[string]*big.Rat{}
+	for _, period := range periods {
+		pc := PeriodCost{Period: period, Cost: money.Money{Currency: s.Currency}}
+		periodEnergy := new(big.Rat)
+		for _, name := range s.names {
+			kwh, ok := energy[period][name]
+			if !ok {
+				continue
+			}
+			cost, err := money.FromRat(new(big.Rat).Mul(kwh, s.rates[name]), s.Currency)
+			if err != nil {
+				return nil, err
+			}
+			pc.Bands = append(pc.Bands, s.bandCost(name, kwh, cost))
+			if pc.Cost, err = pc.Cost.Add(cost); err != nil {
+				return nil, err
+			}
+			periodEnergy.Add(periodEnergy, kwh)
+
+			if totals[name] == nil {
+				totals[name] = &BandCost{Band: name, Cost: money.Money{Currency: s.Currency}}
+				bandEnergy[name] = new(big.Rat)
+			}
+			if totals[name].Cost, err = totals[name].Cost.Add(cost); err != nil {
+				return nil, err
+			}
+			bandEnergy[name].Add(bandEnergy[name], kwh)
+		}
+		pc.EnergyKWh, _ = periodEnergy.Float64()
+		result.Periods = append(result.Periods, pc)
+	}
+
+	totalEnergy := new(big.Rat)
+	for _, name := range s.names {
+		bc, ok := totals[name]
+		if !ok {
+			continue
+		}
+		result.Bands = append(result.Bands, s.bandCost(name, bandEnergy[name], bc.Cost))
+		totalEnergy.Add(totalEnergy, bandEnergy[name])
+		var err error
+		if result.Total, err = result.Total.Add(bc.Cost); err != nil {
+			return nil, err
+		}
+	}
+	result.EnergyKWh, _ = totalEnergy.Float64()
+	return result, nil
+}
+
+// bandCost builds the cost line of a band
+func (s *Schedule) bandCost(name string, kwh *big.Rat, cost money.Money) BandCost {
+	energy, _ := kwh.Float64()
+	return BandCost{Band: name, UnitRate: s.rates[name].FloatString(6), EnergyKWh: energy, Cost: cost}
+}
@@ -0,0 +1,270 @@
+package costcalc
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"product-tracker/models"
+)
+
+// testBands price nights at 0.10, weekdays at 0.30 and weekends at 0.20
+var testBands = []models.TariffBand{
+	{Name: "night", UnitRate: "0.10", Days: []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}, Start: "00:00", End: "07:00"},
+	{Name: "peak", UnitRate: "0.30", Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "07:00", End: "23:00"},
+	{Name: "weekend", UnitRate: "0.20", Days: []string{"sat", "sun"}, Start: "07:00", End: "23:00"},
+	{Name: "night", UnitRate: "0.10", Days: []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}, Start: "23:00", End: "24:00"},
+}
+
+func testSchedule(t *testing.T) *Schedule {
+	t.Helper()
+	s, err := ScheduleFromModel(&models.TariffSchedule{
+		Currency: "EUR",
+		Timezone: "Europe/Berlin",
+		Bands:    testBands,
+		Holidays: []models.TariffHoliday{{Date: "2024-12-25", Name: "Christmas Day", PricedAs: "sun"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestDayIntervalFollowsDST(t *testing.T) {
+	s := testSchedule(t)
+	tests := []struct {
+		date  string
+		hours time.Duration
+	}{
+		{"2024-03-31", 23},
+		{"2024-10-27", 25},
+		{"2024-06-14", 24},
+	}
+	for _, tt := range tests {
+		iv, err := s.DayInterval(tt.date, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		midnight, _ := time.ParseInLocation(models.ReadingDateLayout, tt.date, s.Location)
+		if !iv.Start.Equal(midnight) || iv.Duration != tt.hours*time.Hour {
+			t.Errorf("DayInterval(%s) starts at %s and lasts %s, want %s and %dh", tt.date, iv.Start, iv.Duration, midnight, tt.hours)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	type bandWant struct {
+		kwh  float64
+		cost int64
+	}
+	tests := []struct {
+		name string
+		// date is priced as a whole local day; otherwise the interval from start lasts hours
+		date    string
+		start   string
+		hours   time.Duration
+		energy  float64
+		bands   map[string]bandWant
+		periods []string
+		total   int64
+	}{
+		{
+			// 23 real hours: night from 00:00 to 07:00 lasts only 6 of them
+			name: "spring forward", date: "2024-03-31", energy: 23,
+			bands:   map[string]bandWant{"night": {7, 70}, "weekend": {16, 320}},
+			periods: []string{"2024-03-31"}, total: 390,
+		},
+		{
+			// 25 real hours: night from 00:00 to 07:00 lasts 8 of them
+			name: "fall back", date: "2024-10-27", energy: 25,
+			bands:   map[string]bandWant{"night": {9, 90}, "weekend": {16, 320}},
+			periods: []string{"2024-10-27"}, total: 410,
+		},
+		{
+			// 02:00 to 03:00 summer time, then the same hour again in winter time
+			name: "repeated hour", start: "2024-10-27T00:00:00Z", hours: 2, energy: 2,
+			bands:   map[string]bandWant{"night": {2, 20}},
+			periods: []string{"2024-10-27"}, total: 20,
+		},
+		{
+			name: "weekday", date: "2024-12-24", energy: 24,
+			bands:   map[string]bandWant{"night": {8, 80}, "peak": {16, 480}},
+			periods: []string{"2024-12-24"}, total: 560,
+		},
+		{
+			// A Wednesday priced as a Sunday
+			name: "holiday", date: "2024-12-25", energy: 24,
+			bands:   map[string]bandWant{"night": {8, 80}, "weekend": {16, 320}},
+			periods: []string{"2024-12-25"}, total: 400,
+		},
+		{
+			// Friday 22:00 to Saturday 08:00 local time
+			name: "across midnight and bands", start: "2024-06-14T20:00:00Z", hours: 10, energy: 10,
+			bands:   map[string]bandWant{"peak": {1, 30}, "night": {8, 80}, "weekend": {1, 20}},
+			periods: []string{"2024-06-14", "2024-06-15"}, total: 130,
+		},
+		{
+			// Half a cent of night and a cent and a half of peak energy are each rounded half away from zero
+			name: "rounding", start: "2024-06-14T04:30:00Z", hours: 1, energy: 0.1,
+			bands:   map[string]bandWant{"night": {0.05, 1}, "peak": {0.05, 2}},
+			periods: []string{"2024-06-14"}, total: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := testSchedule(t)
+			var iv Interval
+			var err error
+			if tt.date != "" {
+				iv, err = s.DayInterval(tt.date, tt.energy)
+			} else {
+				var start time.Time
+				start, err = time.Parse(time.RFC3339, tt.start)
+				iv = Interval{Start: start, Duration: tt.hours * time.Hour, EnergyKWh: tt.energy}
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := s.Apply([]Interval{iv}, Daily)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Bands) != len(tt.bands) {
+				t.Fatalf("bands = %+v, want %v", result.Bands, tt.bands)
+			}
+			for _, b := range result.Bands {
+				want, ok := tt.bands[b.Band]
+				if !ok || math.Abs(b.EnergyKWh-want.kwh) > 1e-9 || b.Cost.Amount != want.cost || b.Cost.Currency != "EUR" {
+					t.Errorf("band %s: %v kWh costing %s, want %v kWh costing %d cents", b.Band, b.EnergyKWh, b.Cost, want.kwh, want.cost)
+				}
+			}
+			if len(result.Periods) != len(tt.periods) {
+				t.Fatalf("periods = %+v, want %v", result.Periods, tt.periods)
+			}
+			for i, p := range result.Periods {
+				if p.Period != tt.periods[i] {
+					t.Errorf("period %d = %s, want %s", i, p.Period, tt.periods[i])
+				}
+			}
+			if result.Total.Amount != tt.total || math.Abs(result.EnergyKWh-tt.energy) > 1e-9 {
+				t.Errorf("total = %v kWh costing %s, want %v kWh costing %d cents", result.EnergyKWh, result.Total, tt.energy, tt.total)
+			}
+		})
+	}
+}
+
+func TestApplyByMonth(t *testing.T) {
+	s := testSchedule(t)
+	var intervals []Interval
+	for _, date := range []string{"2024-03-30", "2024-03-31", "2024-04-01"} {
+		iv, err := s.DayInterval(date, 24)
+		if err != nil {
+			t.Fatal(err)
+		}
+		intervals = append(intervals, iv)
+	}
+	result, err := s.Apply(intervals, Monthly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Periods) != 2 || result.Periods[0].Period != "2024-03" || result.Periods[1].Period != "2024-04" {
+		t.Fatalf("periods = %+v, want 2024-03 and 2024-04", result.Periods)
+	}
+	if result.Periods[0].EnergyKWh != 48 || result.Periods[1].EnergyKWh != 24 {
+		t.Errorf("period energy = %v and %v, want 48 and 24", result.Periods[0].EnergyKWh, result.Periods[1].EnergyKWh)
+	}
+	var sum int64
+	for _, p := range result.Periods {
+		sum += p.Cost.Amount
+	}
+	if sum != result.Total.Amount {
+		t.Errorf("periods add up to %d cents, want the total of %d", sum, result.Total.Amount)
+	}
+}
+
+func TestSplitRejectsInvalidIntervals(t *testing.T) {
+	s := testSchedule(t)
+	start := time.Date(2024, 6, 14, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		duration time.Duration
+		energy   float64
+	}{
+		{"zero duration", 0, 1},
+		{"negative duration", -time.Hour, 1},
+		{"negative energy", time.Hour, -1},
+		{"NaN energy", time.Hour, math.NaN()},
+		{"infinite energy", time.Hour, math.Inf(1)},
+		{"negative infinite energy", time.Hour, math.Inf(-1)},
+	}
+	for _, tt := range tests {
+		iv := Interval{Start: start, Duration: tt.duration, EnergyKWh: tt.energy}
+		if _, err := s.Cost(iv); !errors.Is(err, ErrInvalidInterval) {
+			t.Errorf("%s: Cost returned %v, want %v", tt.name, err, ErrInvalidInterval)
+		}
+		if _, err := s.Apply([]Interval{iv}, Daily); !errors.Is(err, ErrInvalidInterval) {
+			t.Errorf("%s: Apply returned %v, want %v", tt.name, err, ErrInvalidInterval)
+		}
+	}
+}
+
+func TestScheduleFromModelChecksCoverage(t *testing.T) {
+	everyDay := []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}
+	tests := []struct {
+		name  string
+		bands []models.TariffBand
+		want  string
+	}{
+		{
+			name: "gap before midnight",
+			bands: []models.TariffBand{
+				{Name: "day", UnitRate: "0.3", Days: everyDay, Start: "00:00", End: "23:00"},
+			},
+			want: "no band covers sun at 23:00",
+		},
+		{
+			name: "uncovered weekend",
+			bands: []models.TariffBand{
+				{Name: "week", UnitRate: "0.3", Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "00:00", End: "24:00"},
+			},
+			want: "no band covers sun at 00:00",
+		},
+		{
+			name: "overlap",
+			bands: []models.TariffBand{
+				{Name: "day", UnitRate: "0.3", Days: everyDay, Start: "00:00", End: "23:30"},
+				{Name: "night", UnitRate: "0.1", Days: everyDay, Start: "23:00", End: "24:00"},
+			},
+			want: `bands "day" and "night" overlap on sun at 23:00`,
+		},
+		{
+			name: "band spanning midnight",
+			bands: []models.TariffBand{
+				{Name: "night", UnitRate: "0.1", Days: everyDay, Start: "23:00", End: "07:00"},
+			},
+			want: "split bands spanning midnight",
+		},
+		{
+			name: "one band at two rates",
+			bands: []models.TariffBand{
+				{Name: "night", UnitRate: "0.1", Days: everyDay, Start: "00:00", End: "12:00"},
+				{Name: "night", UnitRate: "0.2", Days: everyDay, Start: "12:00", End: "24:00"},
+			},
+			want: "must share the same unit_rate",
+		},
+	}
+	for _, tt := range tests {
+		_, err := ScheduleFromModel(&models.TariffSchedule{Currency: "EUR", Timezone: "Europe/Berlin", Bands: tt.bands})
+		if !errors.Is(err, ErrInvalidSchedule) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: ScheduleFromModel returned %v, want %v mentioning %q", tt.name, err, ErrInvalidSchedule, tt.want)
+		}
+	}
+
+	if _, err := ScheduleFromModel(&models.TariffSchedule{Currency: "EUR", Timezone: "Europe/Berlin", Bands: testBands}); err != nil {
+		t.Errorf("ScheduleFromModel rejected a schedule covering the week: %v", err)
+	}
+}
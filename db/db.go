@@ -26,6 +26,8 @@ type DBConfig struct {
 	User     string
 	Password string
 	DbName   string
+	// Options are run-time parameters set on every connection, as in the options connection parameter
+	Options string
 }
 
 // ValidateConfig validates the database configuration
@@ -53,12 +55,19 @@ type DB struct {
 	*sql.DB
 }
 
-// NewDB creates a new database connection
-func NewDB(cfg *DBConfig) (*sql.DB, error) {
+// DSN returns the connection string of the database
+func DSN(cfg *DBConfig) string {
 	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DbName)
+	if cfg.Options != "" {
+		dsn += fmt.Sprintf(" options='%s'", cfg.Options)
+	}
+	return dsn
+}
 
-	db, err := sql.Open("postgres", dsn)
+// NewDB creates a new database connection
+func NewDB(cfg *DBConfig) (*sql.DB, error) {
+	db, err := sql.Open("postgres", DSN(cfg))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database connection: %v", err)
 	}
@@ -0,0 +1,681 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// migrationLockID is the advisory lock key used to serialize migrations
+// when several instances start at the same time
+const migrationLockID = 7243591
+
+// Migration represents a single versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// migrations lists every schema change in the order it must be applied.
+// Append new entries at the end; never edit a migration that has shipped.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_products",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS products (
+				id                 BIGSERIAL PRIMARY KEY,
+				name               TEXT NOT NULL,
+				description        TEXT NOT NULL DEFAULT '',
+				price              DOUBLE PRECISION NOT NULL DEFAULT 0,
+				energy_consumption DOUBLE PRECISION NOT NULL DEFAULT 0,
+				created_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				updated_at         TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			)`,
+	},
+	{
+		Version: 2,
+		Name:    "create_product_tracker",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS product_tracker (
+				name            TEXT NOT NULL,
+				quantity        INTEGER NOT NULL,
+				energy_consumed DOUBLE PRECISION NOT NULL,
+				date            DATE NOT NULL
+			)`,
+	},
+	{
+		Version: 3,
+		Name:    "add_product_model",
+		SQL: `
+			ALTER TABLE products ADD COLUMN IF NOT EXISTS model TEXT NOT NULL DEFAULT '';
+			CREATE INDEX IF NOT EXISTS products_name_model_idx ON products (name, model)`,
+	},
+	{
+		Version: 4,
+		Name:    "create_idempotency_keys",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS idempotency_keys (
+				user_id       BIGINT NOT NULL,
+				route         TEXT NOT NULL,
+				key           TEXT NOT NULL,
+				request_hash  TEXT NOT NULL,
+				status_code   INTEGER NOT NULL DEFAULT 0,
+				response_body BYTEA,
+				created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				expires_at    TIMESTAMPTZ NOT NULL,
+				PRIMARY KEY (user_id, route, key)
+			);
+			CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at)`,
+	},
+	{
+		Version: 5,
+		Name:    "create_product_history",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS product_history (
+				id         BIGSERIAL PRIMARY KEY,
+				product_id BIGINT NOT NULL,
+				action     TEXT NOT NULL,
+				actor_id   BIGINT NOT NULL DEFAULT 0,
+				request_id TEXT NOT NULL DEFAULT '',
+				before     JSONB,
+				after      JSONB,
+				changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			);
+			CREATE INDEX IF NOT EXISTS product_history_product_id_changed_at_idx
+				ON product_history (product_id, changed_at);
+
+			-- Seed a baseline so existing products can be reconstructed as of their last update
+			INSERT INTO product_history (product_id, action, after, changed_at)
+			SELECT id, 'snapshot', jsonb_build_object(
+				'id', id,
+				'name', name,
+				'model', model,
+				'description', description,
+				'price', price,
+				'energy_consumption', energy_consumption,
+				'created_at', created_at,
+				'updated_at', updated_at
+			), updated_at
+			FROM products`,
+	},
+	{
+		Version: 6,
+		Name:    "add_soft_delete",
+		SQL: `
+			ALTER TABLE products ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+			CREATE INDEX IF NOT EXISTS products_deleted_at_idx ON products (deleted_at) WHERE deleted_at IS NOT NULL;
+
+			ALTER TABLE product_tracker ADD COLUMN IF NOT EXISTS id BIGSERIAL PRIMARY KEY;
+			ALTER TABLE product_tracker ADD COLUMN IF NOT EXISTS product_id BIGINT
+				REFERENCES products (id) ON DELETE CASCADE;
+			CREATE INDEX IF NOT EXISTS product_tracker_product_id_idx ON product_tracker (product_id);
+
+			-- Link existing readings to the oldest product with the same name
+			UPDATE product_tracker t
+			SET product_id = (SELECT MIN(p.id) FROM products p WHERE p.name = t.name)
+			WHERE t.product_id IS NULL`,
+	},
+	{
+		Version: 7,
+		Name:    "add_product_version",
+		SQL: `
+			ALTER TABLE products ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1`,
+	},
+	{
+		Version: 8,
+		Name:    "create_categories_and_tags",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS categories (
+				id         BIGSERIAL PRIMARY KEY,
+				name       TEXT NOT NULL,
+				parent_id  BIGINT REFERENCES categories (id),
+				created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			);
+			CREATE UNIQUE INDEX IF NOT EXISTS categories_parent_name_idx
+				ON categories (COALESCE(parent_id, 0), lower(name));
+
+			CREATE TABLE IF NOT EXISTS tags (
+				id         BIGSERIAL PRIMARY KEY,
+				name       TEXT NOT NULL UNIQUE,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			);
+
+			CREATE TABLE IF NOT EXISTS product_tags (
+				product_id BIGINT NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+				tag_id     BIGINT NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+				PRIMARY KEY (product_id, tag_id)
+			);
+			CREATE INDEX IF NOT EXISTS product_tags_tag_id_idx ON product_tags (tag_id);
+
+			ALTER TABLE products ADD COLUMN IF NOT EXISTS category_id BIGINT
+				REFERENCES categories (id) ON DELETE SET NULL;
+			CREATE INDEX IF NOT EXISTS products_category_id_idx ON products (category_id)`,
+	},
+	{
+		Version: 9,
+		Name:    "add_energy_input",
+		SQL: `
+			ALTER TABLE products ADD COLUMN IF NOT EXISTS energy_input JSONB;
+			ALTER TABLE product_tracker ADD COLUMN IF NOT EXISTS energy_input JSONB;
+			COMMENT ON COLUMN products.energy_consumption IS 'Canonical unit: kWh/year';
+			COMMENT ON COLUMN products.energy_input IS 'Energy figure and unit as submitted';
+			COMMENT ON COLUMN product_tracker.energy_consumed IS 'Canonical unit: kWh';
+			COMMENT ON COLUMN product_tracker.energy_input IS 'Energy figure and unit as submitted'`,
+	},
+	{
+		Version: 10,
+		Name:    "add_money_and_fx_rates",
+		SQL: `
+			-- Prices had no currency so far; they are taken to be USD cents
+			ALTER TABLE products ADD COLUMN IF NOT EXISTS price_minor BIGINT NOT NULL DEFAULT 0;
+			ALTER TABLE products ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+			UPDATE products SET price_minor = ROUND(price::numeric * 100);
+			ALTER TABLE products ALTER COLUMN currency DROP DEFAULT;
+			ALTER TABLE products DROP COLUMN price;
+
+			CREATE TABLE IF NOT EXISTS fx_rates (
+				base_currency  CHAR(3) NOT NULL,
+				quote_currency CHAR(3) NOT NULL,
+				rate           NUMERIC(24, 12) NOT NULL CHECK (rate > 0),
+				effective_date DATE NOT NULL,
+				source         TEXT NOT NULL DEFAULT '',
+				created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				PRIMARY KEY (base_currency, quote_currency, effective_date)
+			)`,
+	},
+	{
+		Version: 11,
+		Name:    "create_tariffs",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS tariffs (
+				id         BIGSERIAL PRIMARY KEY,
+				name       TEXT NOT NULL UNIQUE,
+				currency   CHAR(3) NOT NULL,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			);
+
+			CREATE TABLE IF NOT EXISTS tariff_rates (
+				id              BIGSERIAL PRIMARY KEY,
+				tariff_id       BIGINT NOT NULL REFERENCES tariffs (id) ON DELETE CASCADE,
+				unit_rate       NUMERIC(12, 6) NOT NULL CHECK (unit_rate >= 0),
+				standing_charge NUMERIC(12, 6) NOT NULL DEFAULT 0 CHECK (standing_charge >= 0),
+				effective_from  DATE NOT NULL,
+				effective_to    DATE CHECK (effective_to >= effective_from)
+			);
+
+			CREATE INDEX IF NOT EXISTS tariff_rates_tariff_id_idx ON tariff_rates (tariff_id, effective_from)`,
+	},
+	{
+		Version: 12,
+		Name:    "add_interval_readings_and_tariff_schedules",
+		SQL: `
+			ALTER TABLE product_tracker ADD COLUMN IF NOT EXISTS recorded_at TIMESTAMPTZ;
+			ALTER TABLE product_tracker ADD COLUMN IF NOT EXISTS interval_seconds INTEGER
+				CHECK (interval_seconds > 0);
+			ALTER TABLE product_tracker ADD CONSTRAINT product_tracker_interval_check
+				CHECK ((recorded_at IS NULL) = (interval_seconds IS NULL));
+			COMMENT ON COLUMN product_tracker.recorded_at IS 'Start of the metering interval; NULL for whole-day readings';
+
+			CREATE TABLE IF NOT EXISTS tariff_schedules (
+				id         BIGSERIAL PRIMARY KEY,
+				name       TEXT NOT NULL UNIQUE,
+				currency   CHAR(3) NOT NULL,
+				timezone   TEXT NOT NULL,
+				bands      JSONB NOT NULL,
+				holidays   JSONB NOT NULL DEFAULT '[]',
+				created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			)`,
+	},
+	{
+		Version: 13,
+		Name:    "add_regions_and_emission_factors",
+		SQL: `
+			ALTER TABLE products ADD COLUMN IF NOT EXISTS region TEXT;
+			ALTER TABLE product_tracker ADD COLUMN IF NOT EXISTS region TEXT;
+			COMMENT ON COLUMN product_tracker.region IS 'Grid region; NULL falls back to the region of the product';
+
+			CREATE TABLE IF NOT EXISTS emission_factors (
+				region     TEXT NOT NULL,
+				year       INTEGER NOT NULL,
+				version    INTEGER NOT NULL,
+				factor     NUMERIC(12, 6) NOT NULL CHECK (factor >= 0),
+				source     TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				PRIMARY KEY (region, year, version)
+			);
+			COMMENT ON COLUMN emission_factors.factor IS 'kg CO2e per kWh'`,
+	},
+	{
+		Version: 14,
+		Name:    "add_product_attributes_and_rating_schemes",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS rating_schemes (
+				id                 BIGSERIAL PRIMARY KEY,
+				category_id        BIGINT NOT NULL UNIQUE REFERENCES categories (id) ON DELETE CASCADE,
+				name               TEXT NOT NULL,
+				capacity_attribute TEXT NOT NULL,
+				classes            JSONB NOT NULL,
+				version            INTEGER NOT NULL DEFAULT 1,
+				created_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				updated_at         TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			);
+
+			CREATE TABLE IF NOT EXISTS rating_scheme_versions (
+				scheme_id          BIGINT NOT NULL REFERENCES rating_schemes (id) ON DELETE CASCADE,
+				version            INTEGER NOT NULL,
+				name               TEXT NOT NULL,
+				capacity_attribute TEXT NOT NULL,
+				classes            JSONB NOT NULL,
+				created_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				PRIMARY KEY (scheme_id, version)
+			);
+
+			ALTER TABLE products
+				ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}',
+				ADD COLUMN IF NOT EXISTS rating_class TEXT,
+				ADD COLUMN IF NOT EXISTS rating_index NUMERIC(14, 6),
+				ADD COLUMN IF NOT EXISTS rating_scheme_id BIGINT REFERENCES rating_schemes (id) ON DELETE SET NULL,
+				ADD COLUMN IF NOT EXISTS rating_scheme_version INTEGER;
+			CREATE INDEX IF NOT EXISTS products_rating_class_idx ON products (rating_class)`,
+	},
+	{
+		Version: 15,
+		Name:    "create_reading_rollups",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS reading_rollups (
+				timezone     TEXT NOT NULL,
+				granularity  TEXT NOT NULL CHECK (granularity IN ('day', 'week', 'month')),
+				period_start DATE NOT NULL,
+				product_id   BIGINT REFERENCES products (id) ON DELETE CASCADE,
+				readings     BIGINT NOT NULL,
+				quantity     BIGINT NOT NULL,
+				energy_kwh   DOUBLE PRECISION NOT NULL,
+				updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			);
+			CREATE UNIQUE INDEX IF NOT EXISTS reading_rollups_period_idx
+				ON reading_rollups (timezone, granularity, period_start, (COALESCE(product_id, 0)));
+			CREATE INDEX IF NOT EXISTS reading_rollups_product_id_idx ON reading_rollups (product_id);
+
+			-- Backfill the default UTC rollups; other timezones are built with cmd/rollups
+			INSERT INTO reading_rollups (timezone, granularity, period_start, product_id, readings, quantity, energy_kwh)
+			SELECT 'UTC', g.granularity,
+				date_trunc(g.granularity, (CASE WHEN t.recorded_at IS NULL THEN t.date
+					ELSE (t.recorded_at AT TIME ZONE 'UTC')::date END)::timestamp)::date,
+				t.product_id, COUNT(*), SUM(t.quantity), SUM(t.energy_consumed)
+			FROM product_tracker t CROSS JOIN unnest(ARRAY['day', 'week', 'month']) AS g (granularity)
+			GROUP BY 1, 2, 3, 4
+			ON CONFLICT DO NOTHING`,
+	},
+	{
+		Version: 16,
+		Name:    "create_anomalies",
+		SQL: `
+			ALTER TABLE product_tracker ADD COLUMN IF NOT EXISTS anomaly_checked_at TIMESTAMPTZ;
+			COMMENT ON COLUMN product_tracker.anomaly_checked_at IS 'NULL until the reading has been checked for anomalies';
+			CREATE INDEX IF NOT EXISTS product_tracker_anomaly_unchecked_idx
+				ON product_tracker (id) WHERE anomaly_checked_at IS NULL;
+
+			CREATE TABLE IF NOT EXISTS anomalies (
+				id              BIGSERIAL PRIMARY KEY,
+				reading_id      BIGINT NOT NULL UNIQUE REFERENCES product_tracker (id) ON DELETE CASCADE,
+				product_id      BIGINT NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+				method          TEXT NOT NULL,
+				score           DOUBLE PRECISION NOT NULL,
+				energy_kwh      DOUBLE PRECISION NOT NULL,
+				expected_low    DOUBLE PRECISION NOT NULL,
+				expected_high   DOUBLE PRECISION NOT NULL,
+				baseline_size   INTEGER NOT NULL,
+				detected_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				acknowledged_at TIMESTAMPTZ,
+				acknowledged_by BIGINT,
+				note            TEXT NOT NULL DEFAULT ''
+			);
+			CREATE INDEX IF NOT EXISTS anomalies_product_id_idx ON anomalies (product_id);
+			CREATE INDEX IF NOT EXISTS anomalies_unacknowledged_idx ON anomalies (detected_at) WHERE acknowledged_at IS NULL`,
+	},
+	{
+		Version: 17,
+		Name:    "create_budgets_and_alerts",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS budgets (
+				id          BIGSERIAL PRIMARY KEY,
+				name        TEXT NOT NULL,
+				scope       TEXT NOT NULL CHECK (scope IN ('product', 'category', 'portfolio')),
+				product_id  BIGINT REFERENCES products (id) ON DELETE CASCADE,
+				category_id BIGINT REFERENCES categories (id) ON DELETE CASCADE,
+				metric      TEXT NOT NULL CHECK (metric IN ('energy', 'cost')),
+				limit_value DOUBLE PRECISION NOT NULL CHECK (limit_value > 0),
+				limit_minor BIGINT,
+				currency    CHAR(3),
+				tariff_id   BIGINT REFERENCES tariffs (id),
+				timezone    TEXT NOT NULL,
+				thresholds  INTEGER[] NOT NULL DEFAULT '{80,100}',
+				created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				CHECK ((scope = 'product') = (product_id IS NOT NULL)),
+				CHECK ((scope = 'category') = (category_id IS NOT NULL)),
+				CHECK ((metric = 'cost') = (tariff_id IS NOT NULL))
+			);
+			COMMENT ON COLUMN budgets.limit_value IS 'kWh for energy budgets, major units of currency for cost budgets';
+			CREATE INDEX IF NOT EXISTS budgets_product_id_idx ON budgets (product_id);
+			CREATE INDEX IF NOT EXISTS budgets_category_id_idx ON budgets (category_id);
+			CREATE INDEX IF NOT EXISTS budgets_tariff_id_idx ON budgets (tariff_id);
+
+			CREATE TABLE IF NOT EXISTS alerts (
+				id                BIGSERIAL PRIMARY KEY,
+				budget_id         BIGINT NOT NULL REFERENCES budgets (id) ON DELETE CASCADE,
+				kind              TEXT NOT NULL,
+				threshold         INTEGER NOT NULL DEFAULT 0,
+				period_start      DATE NOT NULL,
+				state             TEXT NOT NULL CHECK (state IN ('firing', 'resolved')),
+				unit              TEXT NOT NULL,
+				used              DOUBLE PRECISION NOT NULL,
+				limit_value       DOUBLE PRECISION NOT NULL,
+				percent           DOUBLE PRECISION NOT NULL,
+				projected         DOUBLE PRECISION,
+				fire_count        INTEGER NOT NULL DEFAULT 1,
+				fired_at          TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				resolved_at       TIMESTAMPTZ,
+				last_evaluated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				acknowledged_at   TIMESTAMPTZ,
+				acknowledged_by   BIGINT,
+				UNIQUE (budget_id, period_start, kind, threshold)
+			);
+			CREATE INDEX IF NOT EXISTS alerts_firing_idx ON alerts (fired_at) WHERE state = 'firing'`,
+	},
+	{
+		Version: 18,
+		Name:    "create_webhooks",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS webhooks (
+				id          BIGSERIAL PRIMARY KEY,
+				url         TEXT NOT NULL,
+				secret      TEXT NOT NULL,
+				events      TEXT[] NOT NULL,
+				active      BOOLEAN NOT NULL DEFAULT TRUE,
+				description TEXT NOT NULL DEFAULT '',
+				created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			);
+
+			CREATE TABLE IF NOT EXISTS webhook_deliveries (
+				id              BIGSERIAL PRIMARY KEY,
+				webhook_id      BIGINT NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+				event_id        TEXT NOT NULL,
+				event           TEXT NOT NULL,
+				payload         JSONB NOT NULL,
+				state           TEXT NOT NULL DEFAULT 'pending' CHECK (state IN ('pending', 'succeeded', 'dead')),
+				attempts        INTEGER NOT NULL DEFAULT 0,
+				next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				last_status     INTEGER,
+				last_error      TEXT,
+				created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				delivered_at    TIMESTAMPTZ
+			);
+			CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, id);
+			CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE state = 'pending';
+
+			CREATE TABLE IF NOT EXISTS webhook_attempts (
+				id           BIGSERIAL PRIMARY KEY,
+				delivery_id  BIGINT NOT NULL REFERENCES webhook_deliveries (id) ON DELETE CASCADE,
+				status_code  INTEGER,
+				response     TEXT NOT NULL DEFAULT '',
+				error        TEXT NOT NULL DEFAULT '',
+				duration_ms  INTEGER NOT NULL,
+				attempted_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			);
+			CREATE INDEX IF NOT EXISTS webhook_attempts_delivery_id_idx ON webhook_attempts (delivery_id)`,
+	},
+	{
+		Version: 19,
+		Name:    "create_outbox",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS outbox (
+				id              BIGSERIAL PRIMARY KEY,
+				aggregate_type  TEXT NOT NULL,
+				aggregate_id    TEXT NOT NULL,
+				event           TEXT NOT NULL,
+				payload         JSONB NOT NULL,
+				created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				published_at    TIMESTAMPTZ,
+				attempts        INTEGER NOT NULL DEFAULT 0,
+				next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				last_error      TEXT
+			);
+			CREATE INDEX IF NOT EXISTS outbox_unpublished_idx ON outbox (aggregate_type, aggregate_id, id) WHERE published_at IS NULL;
+			CREATE INDEX IF NOT EXISTS outbox_published_at_idx ON outbox (published_at) WHERE published_at IS NOT NULL;
+
+			CREATE UNIQUE INDEX IF NOT EXISTS webhook_deliveries_event_idx ON webhook_deliveries (webhook_id, event_id)`,
+	},
+	{
+		Version: 20,
+		Name:    "add_product_search",
+		SQL: `
+			CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+			ALTER TABLE products ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+				setweight(to_tsvector('english', name), 'A') || setweight(to_tsvector('english', description), 'B')
+			) STORED;
+			CREATE INDEX IF NOT EXISTS products_search_vector_idx ON products USING GIN (search_vector);
+			CREATE INDEX IF NOT EXISTS products_name_trgm_idx ON products USING GIN (name gin_trgm_ops)`,
+	},
+	{
+		Version: 21,
+		Name:    "add_organizations_and_tenancy",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS organizations (
+				id         BIGSERIAL PRIMARY KEY,
+				name       TEXT NOT NULL,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			);
+			CREATE UNIQUE INDEX IF NOT EXISTS organizations_name_idx ON organizations (lower(name));
+
+			-- Existing data belongs to a default organization
+			INSERT INTO organizations (id, name) VALUES (1, 'Default') ON CONFLICT DO NOTHING;
+			SELECT setval(pg_get_serial_sequence('organizations', 'id'), (SELECT MAX(id) FROM organizations));
+
+			CREATE TABLE IF NOT EXISTS tenant_access_audit (
+				id             BIGSERIAL PRIMARY KEY,
+				user_id        BIGINT NOT NULL,
+				home_tenant_id BIGINT NOT NULL,
+				tenant_id      BIGINT NOT NULL REFERENCES organizations (id),
+				method         TEXT NOT NULL,
+				path           TEXT NOT NULL,
+				request_id     TEXT NOT NULL DEFAULT '',
+				accessed_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			);
+			CREATE INDEX IF NOT EXISTS tenant_access_audit_accessed_at_idx ON tenant_access_audit (accessed_at);
+
+			-- Connections are bound to a tenant with app.tenant_id, or to every tenant with app.all_tenants for
+			-- system work, when they are opened
+			CREATE OR REPLACE FUNCTION current_tenant() RETURNS BIGINT LANGUAGE sql STABLE AS $$
+				SELECT NULLIF(current_setting('app.tenant_id', true), '')::bigint
+			$$;
+			CREATE OR REPLACE FUNCTION tenant_visible(tenant BIGINT) RETURNS BOOLEAN LANGUAGE sql STABLE AS $$
+				SELECT COALESCE(current_setting('app.all_tenants', true) = 'on', false) OR tenant = current_tenant()
+			$$;
+
+			-- Tables owned by a tenant carry its ID, defaulting to the tenant of the connection, and only show
+			-- its rows. FORCE applies the policies to the owner of the tables too.
+			DO $$
+			DECLARE
+				t TEXT;
+			BEGIN
+				FOREACH t IN ARRAY ARRAY['products', 'product_tracker', 'product_history', 'categories', 'tags',
+					'rating_schemes', 'reading_rollups', 'budgets', 'webhooks', 'outbox', 'idempotency_keys'] LOOP
+					EXECUTE format('ALTER TABLE %I ADD COLUMN IF NOT EXISTS tenant_id BIGINT NOT NULL DEFAULT 1
+						REFERENCES organizations (id)', t);
+					EXECUTE format('ALTER TABLE %I ALTER COLUMN tenant_id SET DEFAULT current_tenant()', t);
+					EXECUTE format('CREATE INDEX IF NOT EXISTS %I ON %I (tenant_id)', t || '_tenant_id_idx', t);
+					EXECUTE format('ALTER TABLE %I ENABLE ROW LEVEL SECURITY', t);
+					EXECUTE format('ALTER TABLE %I FORCE ROW LEVEL SECURITY', t);
+					EXECUTE format('CREATE POLICY tenant_isolation ON %I
+						USING (tenant_visible(tenant_id)) WITH CHECK (tenant_visible(tenant_id))', t);
+				END LOOP;
+			END
+			$$;
+
+			ALTER TABLE organizations ENABLE ROW LEVEL SECURITY;
+			ALTER TABLE organizations FORCE ROW LEVEL SECURITY;
+			CREATE POLICY tenant_isolation ON organizations USING (tenant_visible(id));
+			ALTER TABLE tenant_access_audit ENABLE ROW LEVEL SECURITY;
+			ALTER TABLE tenant_access_audit FORCE ROW LEVEL SECURITY;
+			CREATE POLICY tenant_isolation ON tenant_access_audit
+				USING (tenant_visible(tenant_id)) WITH CHECK (tenant_visible(tenant_id));
+
+			-- Rows of other tables belong to the tenant of their parent
+			ALTER TABLE product_tags ENABLE ROW LEVEL SECURITY;
+			ALTER TABLE product_tags FORCE ROW LEVEL SECURITY;
+			CREATE POLICY tenant_isolation ON product_tags
+				USING (EXISTS (SELECT 1 FROM products p WHERE p.id = product_id))
+				WITH CHECK (EXISTS (SELECT 1 FROM products p WHERE p.id = product_id)
+					AND EXISTS (SELECT 1 FROM tags tg WHERE tg.id = tag_id));
+			ALTER TABLE anomalies ENABLE ROW LEVEL SECURITY;
+			ALTER TABLE anomalies FORCE ROW LEVEL SECURITY;
+			CREATE POLICY tenant_isolation ON anomalies
+				USING (EXISTS (SELECT 1 FROM products p WHERE p.id = product_id));
+			ALTER TABLE rating_scheme_versions ENABLE ROW LEVEL SECURITY;
+			ALTER TABLE rating_scheme_versions FORCE ROW LEVEL SECURITY;
+			CREATE POLICY tenant_isolation ON rating_scheme_versions
+				USING (EXISTS (SELECT 1 FROM rating_schemes s WHERE s.id = scheme_id));
+			ALTER TABLE alerts ENABLE ROW LEVEL SECURITY;
+			ALTER TABLE alerts FORCE ROW LEVEL SECURITY;
+			CREATE POLICY tenant_isolation ON alerts
+				USING (EXISTS (SELECT 1 FROM budgets b WHERE b.id = budget_id));
+			ALTER TABLE webhook_deliveries ENABLE ROW LEVEL SECURITY;
+			ALTER TABLE webhook_deliveries FORCE ROW LEVEL SECURITY;
+			CREATE POLICY tenant_isolation ON webhook_deliveries
+				USING (EXISTS (SELECT 1 FROM webhooks w WHERE w.id = webhook_id));
+			ALTER TABLE webhook_attempts ENABLE ROW LEVEL SECURITY;
+			ALTER TABLE webhook_attempts FORCE ROW LEVEL SECURITY;
+			CREATE POLICY tenant_isolation ON webhook_attempts
+				USING (EXISTS (SELECT 1 FROM webhook_deliveries d WHERE d.id = delivery_id));
+
+			-- Readings of a product belong to its tenant
+			CREATE OR REPLACE FUNCTION set_reading_tenant() RETURNS TRIGGER LANGUAGE plpgsql AS $$
+			BEGIN
+				IF NEW.product_id IS NOT NULL THEN
+					SELECT tenant_id INTO NEW.tenant_id FROM products WHERE id = NEW.product_id;
+					IF NOT FOUND THEN
+						RAISE foreign_key_violation USING MESSAGE = format('product %s does not exist', NEW.product_id);
+					END IF;
+				END IF;
+				RETURN NEW;
+			END
+			$$;
+			CREATE TRIGGER product_tracker_tenant BEFORE INSERT OR UPDATE OF product_id ON product_tracker
+				FOR EACH ROW EXECUTE FUNCTION set_reading_tenant();
+
+			-- Foreign keys are checked regardless of the policies, so references to rows of other tenants are
+			-- rejected as if the rows did not exist
+			CREATE OR REPLACE FUNCTION check_tenant_reference() RETURNS TRIGGER LANGUAGE plpgsql AS $$
+			DECLARE
+				ref BIGINT;
+				visible BOOLEAN;
+			BEGIN
+				EXECUTE format('SELECT ($1).%I', TG_ARGV[0]) INTO ref USING NEW;
+				IF ref IS NOT NULL THEN
+					EXECUTE format('SELECT EXISTS (SELECT 1 FROM %I WHERE id = $1)', TG_ARGV[1]) INTO visible USING ref;
+					IF NOT visible THEN
+						RAISE foreign_key_violation USING MESSAGE = format('%s %s does not exist', TG_ARGV[1], ref);
+					END IF;
+				END IF;
+				RETURN NEW;
+			END
+			$$;
+			CREATE TRIGGER products_category_tenant BEFORE INSERT OR UPDATE OF category_id ON products
+				FOR EACH ROW EXECUTE FUNCTION check_tenant_reference('category_id', 'categories');
+			CREATE TRIGGER categories_parent_tenant BEFORE INSERT OR UPDATE OF parent_id ON categories
+				FOR EACH ROW EXECUTE FUNCTION check_tenant_reference('parent_id', 'categories');
+			CREATE TRIGGER rating_schemes_category_tenant BEFORE INSERT OR UPDATE OF category_id ON rating_schemes
+				FOR EACH ROW EXECUTE FUNCTION check_tenant_reference('category_id', 'categories');
+			CREATE TRIGGER budgets_product_tenant BEFORE INSERT OR UPDATE OF product_id ON budgets
+				FOR EACH ROW EXECUTE FUNCTION check_tenant_reference('product_id', 'products');
+			CREATE TRIGGER budgets_category_tenant BEFORE INSERT OR UPDATE OF category_id ON budgets
+				FOR EACH ROW EXECUTE FUNCTION check_tenant_reference('category_id', 'categories');
+
+			-- Names and keys are unique per tenant
+			DROP INDEX IF EXISTS categories_parent_name_idx;
+			CREATE UNIQUE INDEX IF NOT EXISTS categories_parent_name_idx
+				ON categories (tenant_id, COALESCE(parent_id, 0), lower(name));
+			ALTER TABLE tags DROP CONSTRAINT IF EXISTS tags_name_key;
+			CREATE UNIQUE INDEX IF NOT EXISTS tags_tenant_name_idx ON tags (tenant_id, name);
+			DROP INDEX IF EXISTS reading_rollups_period_idx;
+			CREATE UNIQUE INDEX IF NOT EXISTS reading_rollups_period_idx
+				ON reading_rollups (tenant_id, timezone, granularity, period_start, (COALESCE(product_id, 0)));
+			ALTER TABLE idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_pkey;
+			ALTER TABLE idempotency_keys ADD PRIMARY KEY (tenant_id, user_id, route, key)`,
+	},
+}
+
+// Migrate applies all pending migrations to the database
+func Migrate(ctx context.Context, conn *sql.DB) error {
+	if conn == nil {
+		return ErrDBNoTInitiated
+	}
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	// Advisory locks are held per session, so pin a single connection
+	lockConn, err := conn.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer lockConn.Close()
+
+	if _, err := lockConn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer lockConn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	for _, m := range migrations {
+		if err := applyMigration(ctx, lockConn, m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyMigration runs a single migration in its own transaction unless it has already been applied
+func applyMigration(ctx context.Context, conn *sql.Conn, m Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %d: %w", m.Version, err)
+	}
+	defer tx.Rollback()
+
+	var applied bool
+	if err := tx.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)", m.Version,
+	).Scan(&applied); err != nil {
+		return fmt.Errorf("failed to check migration %d: %w", m.Version, err)
+	}
+	if applied {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+		return fmt.Errorf("failed to apply migration %d (%s): %w", m.Version, m.Name, err)
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.Version, m.Name,
+	); err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,218 @@
+package emissions
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"product-tracker/models"
+)
+
+// Custom errors for emission factors and calculations
+var (
+	ErrInvalidRegion = errors.New("invalid region")
+	ErrInvalidFactor = errors.New("invalid emission factor")
+	ErrNoFactor      = errors.New("no emission factor")
+	ErrNoRegion      = errors.New("no region")
+)
+
+// regionPattern matches region codes such as "DE", "US-CA" or "NERC-RFCE"
+var regionPattern = regexp.MustCompile(`^[A-Z0-9][A-Z0-9-]{0,31}$`)
+
+// ParseRegion normalizes a region code to upper case and checks its format
+func ParseRegion(region string) (string, error) {
+	region = strings.ToUpper(strings.TrimSpace(region))
+	if !regionPattern.MatchString(region) {
+		return "", fmt.Errorf("%w %q: use up to 32 letters, digits and dashes, e.g. DE or US-CA", ErrInvalidRegion, region)
+	}
+	return region, nil
+}
+
+// NormalizeFactor validates an emission factor and normalizes its region code and value
+func NormalizeFactor(f *models.EmissionFactor) error {
+	var err error
+	if f.Region, err = ParseRegion(f.Region); err != nil {
+		return err
+	}
+	if f.Year < 1900 || f.Year > 2200 {
+		return fmt.Errorf("%w: year %d is out of range", ErrInvalidFactor, f.Year)
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(f.Factor), 64)
+	if err != nil || value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("%w %q: factor must be a non-negative number of kg CO2e per kWh", ErrInvalidFactor, f.Factor)
+	}
+	f.Factor = strconv.FormatFloat(value, 'f', 6, 64)
+	f.Source = strings.TrimSpace(f.Source)
+	return nil
+}
+
+// Period is the length of the periods emissions are aggregated over
+type Period string
+
+const (
+	// Daily aggregates by calendar day
+	Daily Period = "day"
+	// Monthly aggregates by calendar month
+	Monthly Period = "month"
+	// Yearly aggregates by calendar year
+	Yearly Period = "year"
+)
+
+// ParsePeriod parses a period name, defaulting to Monthly
+func ParsePeriod(value string) (Period, error) {
+	switch p := Period(strings.ToLower(strings.TrimSpace(value))); p {
+	case "":
+		return Monthly, nil
+	case Daily, Monthly, Yearly:
+		return p, nil
+	default:
+		return "", fmt.Errorf("period must be %q, %q or %q", Daily, Monthly, Yearly)
+	}
+}
+
+// key returns the period a reading date belongs to
+func (p Period) key(date string) string {
+	switch p {
+	case Yearly:
+		return date[:4]
+	case Monthly:
+		return date[:7]
+	default:
+		return date
+	}
+}
+
+// factor is a parsed emission factor
+type factor struct {
+	models.EmissionFactor
+	value float64
+}
+
+// regionYear identifies the factor of a region for a year
+type regionYear struct {
+	region string
+	year   int
+}
+
+// Factors looks up the emission factor of a region for a year
+type Factors struct {
+	asOf time.Time
+	// byRegion holds the factors of each region sorted by year
+	byRegion map[string][]factor
+	// used records the factors returned by lookups, for reporting
+	used map[regionYear]models.EmissionFactor
+}
+
+// NewFactors indexes the factors in effect at asOf, which should hold one version per region and year
+func NewFactors(list []models.EmissionFactor, asOf time.Time) (*Factors, error) {
+	f := &Factors{asOf: asOf, byRegion: map[string][]factor{}, used: map[regionYear]models.EmissionFactor{}}
+	for _, ef := range list {
+		value, err := strconv.ParseFloat(ef.Factor, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q for %s %d", ErrInvalidFactor, ef.Factor, ef.Region, ef.Year)
+		}
+		f.byRegion[ef.Region] = append(f.byRegion[ef.Region], factor{EmissionFactor: ef, value: value})
+	}
+	for _, factors := range f.byRegion {
+		sort.Slice(factors, func(i, j int) bool { return factors[i].Year < factors[j].Year })
+	}
+	return f, nil
+}
+
+// Lookup returns the factor of region for year, falling back to the latest earlier year
+// when the year has not been published yet
+func (f *Factors) Lookup(region string, year int) (models.EmissionFactor, float64, error) {
+	factors := f.byRegion[region]
+	i := sort.Search(len(factors), func(i int) bool { return factors[i].Year > year }) - 1
+	if i < 0 {
+		return models.EmissionFactor{}, 0, fmt.Errorf("%w for region %s in %d", ErrNoFactor, region, year)
+	}
+	found := factors[i]
+	f.used[regionYear{found.Region, found.Year}] = found.EmissionFactor
+	return found.EmissionFactor, found.value, nil
+}
+
+// Used returns the factors returned by lookups so far, ordered by region and year
+func (f *Factors) Used() []models.EmissionFactor {
+	used := make([]models.EmissionFactor, 0, len(f.used))
+	for _, ef := range f.used {
+		used = append(used, ef)
+	}
+	sort.Slice(used, func(i, j int) bool {
+		if used[i].Region != used[j].Region {
+			return used[i].Region < used[j].Region
+		}
+		return used[i].Year < used[j].Year
+	})
+	return used
+}
+
+// ForReadings computes the emissions of readings whose energy is in kWh. A reading without a region
+// uses defaultRegion; if that is empty too, ErrNoRegion is returned.
+func (f *Factors) ForReadings(readings []models.Reading, defaultRegion string) ([]models.ReadingEmissions, error) {
+	result := make([]models.ReadingEmissions, 0, len(readings))
+	for _, r := range readings {
+		region := defaultRegion
+		if r.Region != nil {
+			region = *r.Region
+		}
+		if region == "" {
+			return nil, fmt.Errorf("%w for reading %d: assign a region to the reading or its product", ErrNoRegion, r.ID)
+		}
+
+		year, err := strconv.Atoi(r.Date[:4])
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q of reading %d", r.Date, r.ID)
+		}
+		ef, value, err := f.Lookup(region, year)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, models.ReadingEmissions{
+			ReadingID:     r.ID,
+			ProductID:     r.ProductID,
+			Date:          r.Date,
+			Region:        region,
+			EnergyKWh:     r.EnergyConsumed,
+			Factor:        ef.Factor,
+			FactorYear:    ef.Year,
+			FactorVersion: ef.Version,
+			CO2eKg:        r.EnergyConsumed * value,
+		})
+	}
+	return result, nil
+}
+
+// Report aggregates reading emissions per period
+func (f *Factors) Report(items []models.ReadingEmissions, period Period) models.EmissionsReport {
+	report := models.EmissionsReport{
+		FactorsAsOf: f.asOf,
+		Period:      string(period),
+		Periods:     []models.PeriodEmissions{},
+		Factors:     f.Used(),
+	}
+	index := map[string]int{}
+	for _, item := range items {
+		key := period.key(item.Date)
+		i, ok := index[key]
+		if !ok {
+			i = len(report.Periods)
+			index[key] = i
+			report.Periods = append(report.Periods, models.PeriodEmissions{Period: key})
+		}
+		report.Periods[i].Readings++
+		report.Periods[i].EnergyKWh += item.EnergyKWh
+		report.Periods[i].CO2eKg += item.CO2eKg
+
+		report.Readings++
+		report.EnergyKWh += item.EnergyKWh
+		report.CO2eKg += item.CO2eKg
+	}
+	sort.Slice(report.Periods, func(i, j int) bool { return report.Periods[i].Period < report.Periods[j].Period })
+	return report
+}
@@ -0,0 +1,37 @@
+package emissions
+
+import (
+	"errors"
+	"testing"
+
+	"product-tracker/models"
+)
+
+func TestNormalizeFactor(t *testing.T) {
+	tests := []struct {
+		factor string
+		want   string
+		err    error
+	}{
+		{"0.38", "0.380000", nil},
+		{" 0 ", "0.000000", nil},
+		{"-0.1", "", ErrInvalidFactor},
+		{"NaN", "", ErrInvalidFactor},
+		{"nan", "", ErrInvalidFactor},
+		{"Inf", "", ErrInvalidFactor},
+		{"+Inf", "", ErrInvalidFactor},
+		{"1e400", "", ErrInvalidFactor},
+		{"abc", "", ErrInvalidFactor},
+	}
+	for _, tt := range tests {
+		f := &models.EmissionFactor{Region: "de", Year: 2023, Factor: tt.factor}
+		err := NormalizeFactor(f)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("NormalizeFactor(%q) returned %v, want %v", tt.factor, err, tt.err)
+			continue
+		}
+		if err == nil && (f.Factor != tt.want || f.Region != "DE") {
+			t.Errorf("NormalizeFactor(%q) gave %q in %s, want %q in DE", tt.factor, f.Factor, f.Region, tt.want)
+		}
+	}
+}
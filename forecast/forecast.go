@@ -0,0 +1,275 @@
+package forecast
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrInsufficientHistory is returned when a series is too short to fit a model
+var ErrInsufficientHistory = errors.New("insufficient history")
+
+// Model names
+const (
+	ModelSeasonalNaive = "seasonal_naive"
+	ModelLinearTrend   = "linear_trend"
+	ModelHoltWinters   = "holt_winters"
+)
+
+// zScores maps the supported prediction interval levels, in percent, to two-sided normal quantiles
+var zScores = map[int]float64{80: 1.2816, 90: 1.6449, 95: 1.9600, 99: 2.5758}
+
+// ValidLevel reports whether level is a supported prediction interval level
+func ValidLevel(level int) bool {
+	_, ok := zScores[level]
+	return ok
+}
+
+// Point is a forecast value with its prediction interval
+type Point struct {
+	Value float64
+	Lower float64
+	Upper float64
+}
+
+// Result is the forecast of one model
+type Result struct {
+	Model  string
+	Points []Point
+	// MAPE and MAE measure the accuracy of the model on the most recent periods when fitted without them;
+	// MAPE is nil when every held-out value is zero and both are nil when the series is too short to backtest
+	MAPE *float64
+	MAE  *float64
+	// Err is set instead of Points when the model cannot be fitted
+	Err error
+}
+
+// fit produces h point forecasts from y, with the in-sample one-step residuals they are scored by and a
+// function giving the spread of the forecast h steps ahead in units of the residual standard deviation
+type fit func(y []float64, season, h int) (forecast, residuals []float64, spread func(step int) float64, err error)
+
+// models lists the fitted models in response order
+var models = []struct {
+	name string
+	fit  fit
+}{
+	{ModelSeasonalNaive, seasonalNaive},
+	{ModelLinearTrend, linearTrend},
+	{ModelHoltWinters, holtWinters},
+}
+
+// Run fits every model to y, a series of consecutive period totals oldest first, and forecasts horizon
+// periods ahead with prediction intervals at level percent. season is the number of periods per cycle.
+func Run(y []float64, season, horizon, level int) []Result {
+	z := zScores[level]
+	results := make([]Result, 0, len(models))
+	for _, m := range models {
+		result := Result{Model: m.name}
+		forecast, residuals, spread, err := m.fit(y, season, horizon)
+		if err != nil {
+			result.Err = err
+			results = append(results, result)
+			continue
+		}
+
+		sigma := rms(residuals)
+		result.Points = make([]Point, horizon)
+		for i, value := range forecast {
+			width := z * sigma * spread(i+1)
+			// Consumption cannot be negative
+			result.Points[i] = Point{
+				Value: math.Max(value, 0),
+				Lower: math.Max(value-width, 0),
+				Upper: math.Max(value+width, 0),
+			}
+		}
+		result.MAPE, result.MAE = backtest(m.fit, y, season, horizon)
+		results = append(results, result)
+	}
+	return results
+}
+
+// Best returns the name of the model with the lowest MAPE, or an empty string when none could be backtested
+func Best(results []Result) string {
+	best, bestMAPE := "", math.Inf(1)
+	for _, r := range results {
+		if r.Err == nil && r.MAPE != nil && *r.MAPE < bestMAPE {
+			best, bestMAPE = r.Model, *r.MAPE
+		}
+	}
+	return best
+}
+
+// backtest refits on all but the last holdout periods, up to the horizon and a quarter of the series,
+// and measures the forecast against them
+func backtest(f fit, y []float64, season, horizon int) (mape, mae *float64) {
+	holdout := horizon
+	if quarter := len(y) / 4; holdout > quarter {
+		holdout = quarter
+	}
+	if holdout < 1 {
+		return nil, nil
+	}
+	train, actual := y[:len(y)-holdout], y[len(y)-holdout:]
+	forecast, _, _, err := f(train, season, holdout)
+	if err != nil {
+		return nil, nil
+	}
+
+	var absErr, pctErr float64
+	nonZero := 0
+	for i, a := range actual {
+		e := math.Abs(a - math.Max(forecast[i], 0))
+		absErr += e
+		if a != 0 {
+			pctErr += e / math.Abs(a)
+			nonZero++
+		}
+	}
+	meanAbs := round(absErr / float64(holdout))
+	mae = &meanAbs
+	if nonZero > 0 {
+		meanPct := round(100 * pctErr / float64(nonZero))
+		mape = &meanPct
+	}
+	return mape, mae
+}
+
+// seasonalNaive repeats the last observed cycle
+func seasonalNaive(y []float64, season, h int) ([]float64, []float64, func(int) float64, error) {
+	if len(y) <= season {
+		return nil, nil, nil, fmt.Errorf("%w: %s needs more than %d periods", ErrInsufficientHistory, ModelSeasonalNaive, season)
+	}
+	forecast := make([]float64, h)
+	last := y[len(y)-season:]
+	for i := range forecast {
+		forecast[i] = last[i%season]
+	}
+	residuals := make([]float64, 0, len(y)-season)
+	for t := season; t < len(y); t++ {
+		residuals = append(residuals, y[t]-y[t-season])
+	}
+	// Every full cycle ahead adds another season's worth of uncertainty
+	spread := func(step int) float64 { return math.Sqrt(float64((step-1)/season + 1)) }
+	return forecast, residuals, spread, nil
+}
+
+// linearTrend extends an ordinary least squares line through the series
+func linearTrend(y []float64, _, h int) ([]float64, []float64, func(int) float64, error) {
+	n := len(y)
+	if n < 3 {
+		return nil, nil, nil, fmt.Errorf("%w: %s needs at least 3 periods", ErrInsufficientHistory, ModelLinearTrend)
+	}
+	meanT := float64(n-1) / 2
+	var meanY, sxx, sxy float64
+	for _, v := range y {
+		meanY += v
+	}
+	meanY /= float64(n)
+	for t, v := range y {
+		dt := float64(t) - meanT
+		sxx += dt * dt
+		sxy += dt * (v - meanY)
+	}
+	slope := sxy / sxx
+	intercept := meanY - slope*meanT
+
+	residuals := make([]float64, n)
+	for t, v := range y {
+		residuals[t] = v - (intercept + slope*float64(t))
+	}
+	forecast := make([]float64, h)
+	for i := range forecast {
+		forecast[i] = intercept + slope*float64(n+i)
+	}
+	// Standard prediction interval of a regression line, widening away from the centre of the data
+	spread := func(step int) float64 {
+		dt := float64(n-1+step) - meanT
+		return math.Sqrt(1 + 1/float64(n) + dt*dt/sxx)
+	}
+	return forecast, residuals, spread, nil
+}
+
+// holtWintersGrid are the smoothing parameters tried for each of level, trend and season
+var holtWintersGrid = []float64{0.1, 0.3, 0.5, 0.7, 0.9}
+
+// holtWinters fits additive Holt-Winters exponential smoothing, picking the smoothing parameters with the
+// lowest in-sample one-step squared error
+func holtWinters(y []float64, season, h int) ([]float64, []float64, func(int) float64, error) {
+	if season < 2 || len(y) < 2*season {
+		return nil, nil, nil, fmt.Errorf("%w: %s needs at least %d periods", ErrInsufficientHistory, ModelHoltWinters, 2*season)
+	}
+
+	var bestForecast, bestResiduals []float64
+	bestSSE := math.Inf(1)
+	for _, alpha := range holtWintersGrid {
+		for _, beta := range holtWintersGrid {
+			for _, gamma := range holtWintersGrid {
+				forecast, residuals := holtWintersRun(y, season, h, alpha, beta, gamma)
+				if sse := sumSquares(residuals); sse < bestSSE {
+					bestSSE, bestForecast, bestResiduals = sse, forecast, residuals
+				}
+			}
+		}
+	}
+	// Approximation: uncertainty grows with the square root of the steps ahead
+	spread := func(step int) float64 { return math.Sqrt(float64(step)) }
+	return bestForecast, bestResiduals, spread, nil
+}
+
+// holtWintersRun smooths y with the given parameters, initialised from its first two cycles
+func holtWintersRun(y []float64, season, h int, alpha, beta, gamma float64) (forecast, residuals []float64) {
+	first, second := mean(y[:season]), mean(y[season:2*season])
+	level, trend := first, (second-first)/float64(season)
+	seasonal := make([]float64, season)
+	for i := range seasonal {
+		seasonal[i] = y[i] - first
+	}
+
+	residuals = make([]float64, 0, len(y)-season)
+	for t := season; t < len(y); t++ {
+		s := seasonal[t%season]
+		residuals = append(residuals, y[t]-(level+trend+s))
+		previous := level
+		level = alpha*(y[t]-s) + (1-alpha)*(level+trend)
+		trend = beta*(level-previous) + (1-beta)*trend
+		seasonal[t%season] = gamma*(y[t]-level) + (1-gamma)*s
+	}
+
+	forecast = make([]float64, h)
+	for i := range forecast {
+		forecast[i] = level + float64(i+1)*trend + seasonal[(len(y)+i)%season]
+	}
+	return forecast, residuals
+}
+
+// mean returns the arithmetic mean of values
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// sumSquares returns the sum of the squared values
+func sumSquares(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v * v
+	}
+	return sum
+}
+
+// rms returns the root mean square of values, or zero for none
+func rms(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	return math.Sqrt(sumSquares(values) / float64(len(values)))
+}
+
+// round rounds accuracy metrics to four decimals
+func round(value float64) float64 {
+	return math.Round(value*1e4) / 1e4
+}
@@ -0,0 +1,225 @@
+package forecast
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// seasonalSeries returns n periods of base plus slope per period plus the repeating pattern
+func seasonalSeries(n int, base, slope float64, pattern []float64) []float64 {
+	y := make([]float64, n)
+	for t := range y {
+		y[t] = base + slope*float64(t) + pattern[t%len(pattern)]
+	}
+	return y
+}
+
+func TestModelsNeedEnoughHistory(t *testing.T) {
+	tests := []struct {
+		model  string
+		fit    fit
+		season int
+		// short is the longest series refused; a series one period longer is fitted unless never is set
+		short int
+		never bool
+	}{
+		{ModelSeasonalNaive, seasonalNaive, 4, 4, false},
+		{ModelLinearTrend, linearTrend, 4, 2, false},
+		{ModelHoltWinters, holtWinters, 4, 7, false},
+		{ModelHoltWinters, holtWinters, 1, 100, true},
+	}
+	for _, tt := range tests {
+		y := seasonalSeries(tt.short, 10, 1, []float64{0})
+		if _, _, _, err := tt.fit(y, tt.season, 3); !errors.Is(err, ErrInsufficientHistory) {
+			t.Errorf("%s with %d periods and season %d returned %v, want %v", tt.model, tt.short, tt.season, err, ErrInsufficientHistory)
+		}
+		if tt.never {
+			continue
+		}
+		y = seasonalSeries(tt.short+1, 10, 1, []float64{0})
+		if _, _, _, err := tt.fit(y, tt.season, 3); err != nil {
+			t.Errorf("%s with %d periods and season %d failed: %v", tt.model, tt.short+1, tt.season, err)
+		}
+	}
+}
+
+func TestSeasonalNaiveRepeatsLastCycle(t *testing.T) {
+	y := []float64{1, 2, 3, 4, 5, 6}
+	forecast, residuals, spread, err := seasonalNaive(y, 3, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{4, 5, 6, 4, 5}
+	for i := range want {
+		if forecast[i] != want[i] {
+			t.Fatalf("forecast = %v, want %v", forecast, want)
+		}
+	}
+	if len(residuals) != 3 || residuals[0] != 3 {
+		t.Errorf("residuals = %v, want three of 3", residuals)
+	}
+	if spread(3) != 1 || spread(4) != math.Sqrt2 {
+		t.Errorf("spread(3), spread(4) = %v, %v, want 1 and √2", spread(3), spread(4))
+	}
+}
+
+func TestLinearTrendExtendsExactLine(t *testing.T) {
+	y := seasonalSeries(6, 5, 2, []float64{0})
+	forecast, residuals, _, err := linearTrend(y, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(forecast[0]-17) > 1e-9 || math.Abs(forecast[1]-19) > 1e-9 {
+		t.Errorf("forecast = %v, want [17 19]", forecast)
+	}
+	if rms(residuals) > 1e-9 {
+		t.Errorf("residuals = %v, want zeros", residuals)
+	}
+}
+
+func TestHoltWintersPicksBestGridParameters(t *testing.T) {
+	tests := []struct {
+		name string
+		y    []float64
+	}{
+		{"trend and season", seasonalSeries(24, 100, 2, []float64{10, -5, 0, -5})},
+		{"noisy", []float64{12, 7, 9, 14, 13, 6, 11, 15, 10, 9, 12, 17, 14, 8, 10, 18}},
+		{"flat", seasonalSeries(12, 50, 0, []float64{0})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forecast, residuals, _, err := holtWinters(tt.y, 4, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			best := sumSquares(residuals)
+			for _, alpha := range holtWintersGrid {
+				for _, beta := range holtWintersGrid {
+					for _, gamma := range holtWintersGrid {
+						_, r := holtWintersRun(tt.y, 4, 4, alpha, beta, gamma)
+						if sse := sumSquares(r); sse < best {
+							t.Fatalf("α=%v β=%v γ=%v has SSE %v below the chosen %v", alpha, beta, gamma, sse, best)
+						}
+					}
+				}
+			}
+			if len(forecast) != 4 || len(residuals) != len(tt.y)-4 {
+				t.Errorf("got %d forecasts and %d residuals, want 4 and %d", len(forecast), len(residuals), len(tt.y)-4)
+			}
+		})
+	}
+}
+
+func TestHoltWintersFollowsTrendAndSeason(t *testing.T) {
+	pattern := []float64{10, -5, 0, -5}
+	y := seasonalSeries(24, 100, 2, pattern)
+	forecast, _, _, err := holtWinters(y, 4, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := seasonalSeries(28, 100, 2, pattern)[24:]
+	for i := range want {
+		if math.Abs(forecast[i]-want[i]) > 1 {
+			t.Fatalf("forecast = %v, want about %v", forecast, want)
+		}
+	}
+}
+
+func TestBacktestHoldout(t *testing.T) {
+	tests := []struct {
+		name            string
+		n, horizon      int
+		wantTrain, hold int // zero hold means the series is too short to backtest
+	}{
+		{"horizon within a quarter", 20, 3, 17, 3},
+		{"capped at a quarter", 12, 6, 9, 3},
+		{"single period", 7, 6, 6, 1},
+		{"too short", 3, 6, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var train, h int
+			f := func(y []float64, _, horizon int) ([]float64, []float64, func(int) float64, error) {
+				train, h = len(y), horizon
+				return make([]float64, horizon), nil, nil, nil
+			}
+			y := make([]float64, tt.n)
+			for i := range y {
+				y[i] = 10
+			}
+			mape, mae := backtest(f, y, 4, tt.horizon)
+			if tt.hold == 0 {
+				if mape != nil || mae != nil || train != 0 {
+					t.Fatalf("backtested a series of %d periods", tt.n)
+				}
+				return
+			}
+			if train != tt.wantTrain || h != tt.hold {
+				t.Errorf("fitted %d periods holding out %d, want %d and %d", train, h, tt.wantTrain, tt.hold)
+			}
+			// Forecasting zeros for a series of tens is off by 10, or 100%
+			if mape == nil || *mape != 100 || mae == nil || *mae != 10 {
+				t.Errorf("MAPE, MAE = %v, %v, want 100 and 10", mape, mae)
+			}
+		})
+	}
+}
+
+func TestBacktestWithZeroActuals(t *testing.T) {
+	y := []float64{5, 5, 5, 5, 5, 5, 0, 0}
+	mape, mae := backtest(linearTrend, y, 4, 2)
+	if mape != nil {
+		t.Errorf("MAPE = %v, want nil when every held-out value is zero", *mape)
+	}
+	if mae == nil || *mae != 5 {
+		t.Errorf("MAE = %v, want 5", mae)
+	}
+}
+
+func TestRunClampsAndReportsUnfittedModels(t *testing.T) {
+	// A steep decline forecasts negative consumption, which is clamped to zero
+	y := []float64{100, 80, 60, 40, 20}
+	results := Run(y, 12, 3, 95)
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	for _, r := range results {
+		switch r.Model {
+		case ModelSeasonalNaive, ModelHoltWinters:
+			if !errors.Is(r.Err, ErrInsufficientHistory) || r.Points != nil {
+				t.Errorf("%s: got %v and %v, want insufficient history", r.Model, r.Points, r.Err)
+			}
+		case ModelLinearTrend:
+			if r.Err != nil {
+				t.Fatalf("%s failed: %v", r.Model, r.Err)
+			}
+			for _, p := range r.Points {
+				if p.Value != 0 || p.Lower != 0 || p.Upper < 0 {
+					t.Errorf("%s: point %+v, want a zero forecast", r.Model, p)
+				}
+			}
+		}
+	}
+	if best := Best(results); best != ModelLinearTrend {
+		t.Errorf("Best = %q, want %q", best, ModelLinearTrend)
+	}
+}
+
+func TestBestPrefersLowestMAPE(t *testing.T) {
+	low, high := 5.0, 20.0
+	tests := []struct {
+		name    string
+		results []Result
+		want    string
+	}{
+		{"lowest wins", []Result{{Model: "a", MAPE: &high}, {Model: "b", MAPE: &low}}, "b"},
+		{"failed models are skipped", []Result{{Model: "a", MAPE: &low, Err: ErrInsufficientHistory}, {Model: "b", MAPE: &high}}, "b"},
+		{"nothing backtested", []Result{{Model: "a"}, {Model: "b"}}, ""},
+	}
+	for _, tt := range tests {
+		if got := Best(tt.results); got != tt.want {
+			t.Errorf("%s: Best = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
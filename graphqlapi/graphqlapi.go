@@ -0,0 +1,136 @@
+package graphqlapi
+
+import (
+	"context"
+	"sync"
+
+	"product-tracker/config"
+	"product-tracker/models"
+	"product-tracker/storage"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// Request is a GraphQL request as posted to the endpoint
+type Request struct {
+	Query         string         `json:"query" binding:"required" example:"{ product(id: \"1\") { name readings(startDate: \"2024-01-01\") { nodes { date energyConsumed } } } }"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// loadSchema builds the schema on first use
+var loadSchema = sync.OnceValues(newSchema)
+
+// readingsQuery selects the readings loaded per product; products queried alike share a loader
+type readingsQuery struct {
+	filter  storage.ReadingFilter
+	afterID int64
+	limit   int
+}
+
+// request holds the storage and loaders of one request
+type request struct {
+	ctx      context.Context
+	store    *storage.Storage
+	products *loader[*models.Product]
+	stats    *loader[models.ReadingStats]
+
+	mu               sync.Mutex
+	readingLoaders   map[readingsQuery]*loader[[]models.Reading]
+	aggregateLoaders map[storage.RollupFilter]*loader[[]models.ReadingPeriod]
+}
+
+// requestKey is the context key of the request
+type requestKey struct{}
+
+// requestFrom returns the request a field is resolved for
+func requestFrom(ctx context.Context) *request {
+	return ctx.Value(requestKey{}).(*request)
+}
+
+// newRequest returns the state of a request reading from store
+func newRequest(ctx context.Context, store *storage.Storage) *request {
+	r := &request{
+		ctx:              ctx,
+		store:            store,
+		readingLoaders:   map[readingsQuery]*loader[[]models.Reading]{},
+		aggregateLoaders: map[storage.RollupFilter]*loader[[]models.ReadingPeriod]{},
+	}
+	r.products = newLoader(func(ids []int64) (map[int64]*models.Product, error) {
+		products, err := r.store.GetProductsByIDs(r.ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		byID := make(map[int64]*models.Product, len(products))
+		for i := range products {
+			byID[products[i].ID] = &products[i]
+		}
+		return byID, nil
+	})
+	r.stats = newLoader(func(ids []int64) (map[int64]models.ReadingStats, error) {
+		return r.store.GetReadingStatsByProducts(r.ctx, ids)
+	})
+	return r
+}
+
+// readings returns the loader of the readings of products selected by query, loading one more than the limit
+// to tell whether there is a next page
+func (r *request) readings(query readingsQuery) *loader[[]models.Reading] {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if l, ok := r.readingLoaders[query]; ok {
+		return l
+	}
+	l := newLoader(func(ids []int64) (map[int64][]models.Reading, error) {
+		return r.store.GetReadingsByProducts(r.ctx, ids, query.filter, query.afterID, query.limit+1)
+	})
+	r.readingLoaders[query] = l
+	return l
+}
+
+// aggregates returns the loader of the rollups of products matching filter
+func (r *request) aggregates(filter storage.RollupFilter) *loader[[]models.ReadingPeriod] {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if l, ok := r.aggregateLoaders[filter]; ok {
+		return l
+	}
+	l := newLoader(func(ids []int64) (map[int64][]models.ReadingPeriod, error) {
+		return r.store.GetReadingAggregatesByProducts(r.ctx, filter, ids)
+	})
+	r.aggregateLoaders[filter] = l
+	return l
+}
+
+// Execute parses, validates and runs a query against store. Queries deeper or costlier than the limits are
+// rejected before they run. The result has no data when the request itself is invalid.
+func Execute(ctx context.Context, store *storage.Storage, req Request, limits config.GraphQLConfig) *graphql.Result {
+	schema, err := loadSchema()
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	document, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+	if validation := graphql.ValidateDocument(&schema, document, nil); !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}
+	}
+	if err := checkLimits(&schema, document, req.OperationName, req.Variables, limits); err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        schema,
+		AST:           document,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       context.WithValue(ctx, requestKey{}, newRequest(ctx, store)),
+	})
+}
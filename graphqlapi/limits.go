@@ -0,0 +1,155 @@
+package graphqlapi
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"product-tracker/config"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// Errors returned for queries exceeding the limits
+var (
+	ErrQueryTooDeep    = errors.New("query is too deep")
+	ErrQueryTooComplex = errors.New("query is too complex")
+)
+
+// analysis walks the operation of a validated document to measure its depth and complexity
+type analysis struct {
+	schema    *graphql.Schema
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]any
+	defaults  map[string]ast.Value
+	maxDepth  int
+}
+
+// checkLimits returns an error if the operation to run is nested deeper than limits.MaxDepth fields or costs more
+// than limits.MaxComplexity. Every field costs one and the fields below a connection count once per node of the
+// page it asks for. Introspection fields are free.
+func checkLimits(schema *graphql.Schema, document *ast.Document, operationName string, variables map[string]any, limits config.GraphQLConfig) error {
+	a := analysis{
+		schema:    schema,
+		fragments: map[string]*ast.FragmentDefinition{},
+		variables: variables,
+		defaults:  map[string]ast.Value{},
+	}
+	var operation *ast.OperationDefinition
+	for _, definition := range document.Definitions {
+		switch definition := definition.(type) {
+		case *ast.FragmentDefinition:
+			a.fragments[definition.Name.Value] = definition
+		case *ast.OperationDefinition:
+			if operation == nil && (operationName == "" || definition.Name != nil && definition.Name.Value == operationName) {
+				operation = definition
+			}
+		}
+	}
+	if operation == nil {
+		// The executor reports unknown operations
+		return nil
+	}
+	for _, variable := range operation.VariableDefinitions {
+		if variable.DefaultValue != nil {
+			a.defaults[variable.Variable.Name.Value] = variable.DefaultValue
+		}
+	}
+
+	complexity := a.selectionSet(operation.SelectionSet, schema.QueryType(), 1)
+	if a.maxDepth > limits.MaxDepth {
+		return fmt.Errorf("%w: %d levels of fields exceed the limit of %d", ErrQueryTooDeep, a.maxDepth, limits.MaxDepth)
+	}
+	if complexity > limits.MaxComplexity {
+		return fmt.Errorf("%w: a complexity of %d exceeds the limit of %d", ErrQueryTooComplex, complexity, limits.MaxComplexity)
+	}
+	return nil
+}
+
+// selectionSet returns the cost of the selections on parent at depth and records the deepest field
+func (a *analysis) selectionSet(set *ast.SelectionSet, parent *graphql.Object, depth int) int {
+	if set == nil || parent == nil {
+		return 0
+	}
+	cost := 0
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			cost += a.field(selection, parent, depth)
+		case *ast.InlineFragment:
+			cost += a.selectionSet(selection.SelectionSet, a.typeCondition(selection.TypeCondition, parent), depth)
+		case *ast.FragmentSpread:
+			if fragment, ok := a.fragments[selection.Name.Value]; ok {
+				cost += a.selectionSet(fragment.SelectionSet, a.typeCondition(fragment.TypeCondition, parent), depth)
+			}
+		}
+	}
+	return cost
+}
+
+// field returns the cost of a field selected on parent at depth
+func (a *analysis) field(selection *ast.Field, parent *graphql.Object, depth int) int {
+	name := selection.Name.Value
+	if strings.HasPrefix(name, "__") {
+		return 0
+	}
+	a.maxDepth = max(a.maxDepth, depth)
+
+	definition, ok := parent.Fields()[name]
+	if !ok {
+		return 1
+	}
+	child, _ := graphql.GetNamed(definition.Type).(*graphql.Object)
+	return 1 + a.pageSize(selection, definition)*a.selectionSet(selection.SelectionSet, child, depth+1)
+}
+
+// pageSize returns the number of nodes a connection field asks for, and 1 for other fields
+func (a *analysis) pageSize(selection *ast.Field, definition *graphql.FieldDefinition) int {
+	for _, argument := range definition.Args {
+		if argument.PrivateName != "first" {
+			continue
+		}
+		first, _ := argument.DefaultValue.(int)
+		for _, given := range selection.Arguments {
+			if given.Name.Value == "first" {
+				first = a.intValue(given.Value, first)
+			}
+		}
+		return min(max(first, 1), maxPageSize)
+	}
+	return 1
+}
+
+// intValue returns the integer an argument value stands for, or fallback if it is not an integer
+func (a *analysis) intValue(value ast.Value, fallback int) int {
+	switch value := value.(type) {
+	case *ast.IntValue:
+		if n, err := strconv.Atoi(value.Value); err == nil {
+			return n
+		}
+	case *ast.Variable:
+		name := value.Name.Value
+		switch n := a.variables[name].(type) {
+		case int:
+			return n
+		case float64:
+			return int(n)
+		case nil:
+			if defaultValue, ok := a.defaults[name]; ok {
+				return a.intValue(defaultValue, fallback)
+			}
+		}
+	}
+	return fallback
+}
+
+// typeCondition returns the type a fragment applies to, or parent if it has no condition
+func (a *analysis) typeCondition(condition *ast.Named, parent *graphql.Object) *graphql.Object {
+	if condition == nil {
+		return parent
+	}
+	object, _ := a.schema.Type(condition.Name.Value).(*graphql.Object)
+	return object
+}
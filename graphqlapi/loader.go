@@ -0,0 +1,68 @@
+package graphqlapi
+
+import "sync"
+
+// loader batches loads by ID: the IDs requested while a level of the query is resolved are loaded with one
+// query when the first of their values is read. The executor reads values breadth-first, so every product of a
+// list is requested before any nested field is resolved.
+type loader[V any] struct {
+	load func(ids []int64) (map[int64]V, error)
+
+	mu      sync.Mutex
+	pending []int64
+	loaded  map[int64]V
+	failed  map[int64]error
+}
+
+// newLoader returns a loader running load for each batch of IDs; IDs missing from its result have zero values
+func newLoader[V any](load func(ids []int64) (map[int64]V, error)) *loader[V] {
+	return &loader[V]{load: load, loaded: map[int64]V{}, failed: map[int64]error{}}
+}
+
+// Load requests the value of id and returns a thunk reading it, in the form the executor resolves lazily
+func (l *loader[V]) Load(id int64) func() (V, error) {
+	l.mu.Lock()
+	l.pending = append(l.pending, id)
+	l.mu.Unlock()
+
+	return func() (V, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if err, ok := l.failed[id]; ok {
+			var zero V
+			return zero, err
+		}
+		if value, ok := l.loaded[id]; ok {
+			return value, nil
+		}
+
+		batch := distinctIDs(l.pending)
+		l.pending = nil
+		values, err := l.load(batch)
+		for _, batchID := range batch {
+			if err != nil {
+				l.failed[batchID] = err
+			} else {
+				l.loaded[batchID] = values[batchID]
+			}
+		}
+		if err != nil {
+			var zero V
+			return zero, err
+		}
+		return l.loaded[id], nil
+	}
+}
+
+// distinctIDs returns ids without repetitions, in order of first appearance
+func distinctIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	var unique []int64
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,649 @@
+package graphqlapi
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"product-tracker/config"
+	"product-tracker/models"
+	"product-tracker/rollups"
+	"product-tracker/storage"
+
+	"github.com/graphql-go/graphql"
+)
+
+// Page sizes of the connection fields
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// errInvalidCursor is returned for cursors that were not issued by a connection field
+var errInvalidCursor = errors.New("invalid cursor")
+
+// productSource returns the product a Product field is resolved on
+func productSource(p graphql.ResolveParams) *models.Product {
+	return p.Source.(*models.Product)
+}
+
+// readingSource returns the reading a Reading field is resolved on
+func readingSource(p graphql.ResolveParams) *models.Reading {
+	return p.Source.(*models.Reading)
+}
+
+// field returns a field of type t resolved by get
+func field(t graphql.Output, description string, get func(p graphql.ResolveParams) any) *graphql.Field {
+	return &graphql.Field{
+		Type:        t,
+		Description: description,
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			return get(p), nil
+		},
+	}
+}
+
+// nonNull wraps t in a non-null type
+func nonNull(t graphql.Type) *graphql.NonNull {
+	return graphql.NewNonNull(t)
+}
+
+// listOf returns a non-null list of non-null t
+func listOf(t graphql.Type) *graphql.NonNull {
+	return nonNull(graphql.NewList(nonNull(t)))
+}
+
+// formatID formats a database ID as a GraphQL ID
+func formatID(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+// formatOptionalID formats an optional database ID, returning nil when it is unset
+func formatOptionalID(id *int64) any {
+	if id == nil {
+		return nil
+	}
+	return formatID(*id)
+}
+
+var intervalEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name:        "Interval",
+	Description: "Length of the periods readings are rolled up into",
+	Values: graphql.EnumValueConfigMap{
+		"DAY":   &graphql.EnumValueConfig{Value: rollups.Day, Description: "Local calendar day"},
+		"WEEK":  &graphql.EnumValueConfig{Value: rollups.Week, Description: "ISO week starting on Monday"},
+		"MONTH": &graphql.EnumValueConfig{Value: rollups.Month, Description: "Calendar month"},
+	},
+})
+
+var fillEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name:        "Fill",
+	Description: "How periods without readings are reported",
+	Values: graphql.EnumValueConfigMap{
+		"NONE": &graphql.EnumValueConfig{Value: rollups.FillNone, Description: "Leave them out"},
+		"ZERO": &graphql.EnumValueConfig{Value: rollups.FillZero, Description: "Report zero totals"},
+		"NULL": &graphql.EnumValueConfig{Value: rollups.FillNull, Description: "Report null totals"},
+	},
+})
+
+var pageInfoType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PageInfo",
+	Fields: graphql.Fields{
+		"endCursor": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Cursor to pass as after for the next page",
+		},
+		"hasNextPage": &graphql.Field{Type: nonNull(graphql.Boolean)},
+	},
+})
+
+var attributeType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Attribute",
+	Fields: graphql.Fields{
+		"name":  &graphql.Field{Type: nonNull(graphql.String)},
+		"value": &graphql.Field{Type: nonNull(graphql.Float)},
+	},
+})
+
+var readingPeriodType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "ReadingPeriod",
+	Description: "Totals of the readings of one day, week or month; null for empty periods filled with nulls",
+	Fields: graphql.Fields{
+		"periodStart": field(nonNull(graphql.String), "First local day of the period", func(p graphql.ResolveParams) any {
+			return p.Source.(models.ReadingPeriod).PeriodStart
+		}),
+		"readings": field(graphql.Int, "", func(p graphql.ResolveParams) any {
+			return p.Source.(models.ReadingPeriod).Readings
+		}),
+		"quantity": field(graphql.Int, "", func(p graphql.ResolveParams) any {
+			return p.Source.(models.ReadingPeriod).Quantity
+		}),
+		"energyKWh": field(graphql.Float, "", func(p graphql.ResolveParams) any {
+			return p.Source.(models.ReadingPeriod).EnergyKWh
+		}),
+	},
+})
+
+var readingStatsType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "ReadingStats",
+	Description: "Totals of the readings of a product",
+	Fields: graphql.Fields{
+		"readings": field(nonNull(graphql.Int), "", func(p graphql.ResolveParams) any {
+			return p.Source.(models.ReadingStats).Readings
+		}),
+		"quantity": field(nonNull(graphql.Int), "", func(p graphql.ResolveParams) any {
+			return p.Source.(models.ReadingStats).Quantity
+		}),
+		"energyKWh": field(nonNull(graphql.Float), "", func(p graphql.ResolveParams) any {
+			return p.Source.(models.ReadingStats).EnergyKWh
+		}),
+		"averageEnergyKWh": field(nonNull(graphql.Float), "", func(p graphql.ResolveParams) any {
+			return p.Source.(models.ReadingStats).AverageEnergyKWh
+		}),
+	},
+})
+
+var statsType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "Stats",
+	Description: "Totals of every reading, as returned by GET /api/v1/product/stats",
+	Fields: graphql.Fields{
+		"totalProducts": field(nonNull(graphql.Int), "Number of readings", func(p graphql.ResolveParams) any {
+			return p.Source.(map[string]any)["total_products"]
+		}),
+		"totalQuantity": field(nonNull(graphql.Int), "", func(p graphql.ResolveParams) any {
+			return p.Source.(map[string]any)["total_quantity"]
+		}),
+		"totalEnergy": field(nonNull(graphql.Float), "", func(p graphql.ResolveParams) any {
+			return p.Source.(map[string]any)["total_energy"]
+		}),
+		"avgEnergy": field(nonNull(graphql.Float), "", func(p graphql.ResolveParams) any {
+			return p.Source.(map[string]any)["avg_energy"]
+		}),
+	},
+})
+
+// connection is a page of nodes
+type connection struct {
+	Nodes       any
+	EndCursor   *string
+	HasNextPage bool
+}
+
+// connectionType returns the type of a page of nodes
+func connectionType(name string, node *graphql.Object) *graphql.Object {
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: name,
+		Fields: graphql.Fields{
+			"nodes": field(listOf(node), "", func(p graphql.ResolveParams) any {
+				return p.Source.(connection).Nodes
+			}),
+			"pageInfo": field(nonNull(pageInfoType), "", func(p graphql.ResolveParams) any {
+				page := p.Source.(connection)
+				return map[string]any{"endCursor": page.EndCursor, "hasNextPage": page.HasNextPage}
+			}),
+		},
+	})
+}
+
+// pageArgs are the arguments of connection fields
+func pageArgs(args graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	args["first"] = &graphql.ArgumentConfig{
+		Type:         graphql.Int,
+		DefaultValue: defaultPageSize,
+		Description:  fmt.Sprintf("Number of nodes to return, at most %d", maxPageSize),
+	}
+	args["after"] = &graphql.ArgumentConfig{
+		Type:        graphql.String,
+		Description: "endCursor of the previous page",
+	}
+	return args
+}
+
+// page returns the ID after which a page starts and its size
+func page(args map[string]any) (int64, int, error) {
+	first, _ := args["first"].(int)
+	if first < 1 || first > maxPageSize {
+		return 0, 0, fmt.Errorf("first must be between 1 and %d", maxPageSize)
+	}
+	cursor, _ := args["after"].(string)
+	if cursor == "" {
+		return 0, first, nil
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, 0, errInvalidCursor
+	}
+	afterID, err := strconv.ParseInt(string(decoded), 10, 64)
+	if err != nil || afterID <= 0 {
+		return 0, 0, errInvalidCursor
+	}
+	return afterID, first, nil
+}
+
+// pageOf returns the connection of nodes loaded with one more than limit asked for, which tells whether there is
+// a next page
+func pageOf[T any](nodes []T, limit int, id func(*T) int64) connection {
+	page := connection{}
+	if len(nodes) > limit {
+		nodes = nodes[:limit]
+		page.HasNextPage = true
+	}
+	pointers := make([]*T, len(nodes))
+	for i := range nodes {
+		pointers[i] = &nodes[i]
+	}
+	page.Nodes = pointers
+	if len(nodes) > 0 {
+		cursor := base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id(&nodes[len(nodes)-1]), 10)))
+		page.EndCursor = &cursor
+	}
+	return page
+}
+
+// idArg parses an ID argument, returning 0 when it is not given
+func idArg(args map[string]any, name string) (int64, error) {
+	value, ok := args[name].(string)
+	if !ok {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%s must be an ID", name)
+	}
+	return id, nil
+}
+
+// dateArg parses a date argument, returning the zero time when it is not given
+func dateArg(args map[string]any, name string) (time.Time, error) {
+	value, _ := args[name].(string)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	date, err := time.Parse(models.ReadingDateLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be a date in YYYY-MM-DD format", name)
+	}
+	return date, nil
+}
+
+// readingFilter parses the startDate and endDate arguments of reading fields
+func readingFilter(args map[string]any) (storage.ReadingFilter, error) {
+	var filter storage.ReadingFilter
+	for name, bound := range map[string]*string{"startDate": &filter.Start, "endDate": &filter.End} {
+		date, err := dateArg(args, name)
+		if err != nil {
+			return filter, err
+		}
+		if !date.IsZero() {
+			*bound = date.Format(models.ReadingDateLayout)
+		}
+	}
+	return filter, nil
+}
+
+// aggregateArgs are the arguments of aggregate fields
+func aggregateArgs(args graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	args["interval"] = &graphql.ArgumentConfig{Type: nonNull(intervalEnum)}
+	args["timezone"] = &graphql.ArgumentConfig{
+		Type:        graphql.String,
+		Description: "IANA timezone of the periods, one of the rollup timezones; defaults to the first",
+	}
+	args["startDate"] = &graphql.ArgumentConfig{Type: nonNull(graphql.String), Description: "YYYY-MM-DD"}
+	args["endDate"] = &graphql.ArgumentConfig{Type: nonNull(graphql.String), Description: "YYYY-MM-DD"}
+	args["fill"] = &graphql.ArgumentConfig{Type: fillEnum, DefaultValue: rollups.FillNone}
+	return args
+}
+
+// aggregate is a parsed aggregate query
+type aggregate struct {
+	filter storage.RollupFilter
+	fill   rollups.Fill
+}
+
+// aggregateQuery parses the arguments of aggregate fields as GET /api/v1/readings/aggregate does
+func aggregateQuery(args map[string]any) (aggregate, error) {
+	interval, _ := args["interval"].(rollups.Interval)
+	fill, _ := args["fill"].(rollups.Fill)
+	timezones := config.GetConfig().Rollups.Timezones
+	timezone, _ := args["timezone"].(string)
+	if timezone == "" {
+		timezone = timezones[0]
+	}
+	if err := rollups.CheckTimezone(timezone, timezones); err != nil {
+		return aggregate{}, err
+	}
+	start, err := dateArg(args, "startDate")
+	if err != nil {
+		return aggregate{}, err
+	}
+	end, err := dateArg(args, "endDate")
+	if err != nil {
+		return aggregate{}, err
+	}
+	if end.Before(start) {
+		return aggregate{}, errors.New("endDate must not be before startDate")
+	}
+	return aggregate{
+		filter: storage.RollupFilter{Timezone: timezone, Interval: interval, Start: interval.Truncate(start), End: end},
+		fill:   fill,
+	}, nil
+}
+
+// fillPeriods fills the gaps of the periods of an aggregate
+func (a aggregate) fillPeriods(periods []models.ReadingPeriod) ([]models.ReadingPeriod, error) {
+	if periods == nil {
+		periods = []models.ReadingPeriod{}
+	}
+	return rollups.FillPeriods(periods, a.filter.Interval, a.filter.Start, a.filter.End, a.fill)
+}
+
+// newSchema builds the schema. Nested fields of products and readings are loaded in batches through the loaders
+// of the request.
+func newSchema() (graphql.Schema, error) {
+	var productType *graphql.Object
+
+	readingType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Reading",
+		Description: "Energy consumption reading",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id": field(nonNull(graphql.ID), "", func(p graphql.ResolveParams) any {
+					return formatID(readingSource(p).ID)
+				}),
+				"productId": field(graphql.ID, "", func(p graphql.ResolveParams) any {
+					return formatOptionalID(readingSource(p).ProductID)
+				}),
+				"product": &graphql.Field{
+					Type:        productType,
+					Description: "Product the reading belongs to, unless it is in the trash",
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						reading := readingSource(p)
+						if reading.ProductID == nil {
+							return nil, nil
+						}
+						load := requestFrom(p.Context).products.Load(*reading.ProductID)
+						return func() (any, error) {
+							return load()
+						}, nil
+					},
+				},
+				"name": field(nonNull(graphql.String), "", func(p graphql.ResolveParams) any {
+					return readingSource(p).Name
+				}),
+				"quantity": field(nonNull(graphql.Int), "", func(p graphql.ResolveParams) any {
+					return readingSource(p).Quantity
+				}),
+				"energyConsumed": field(nonNull(graphql.Float), "Energy consumed in kWh", func(p graphql.ResolveParams) any {
+					return readingSource(p).EnergyConsumed
+				}),
+				"date": field(nonNull(graphql.String), "YYYY-MM-DD", func(p graphql.ResolveParams) any {
+					return readingSource(p).Date
+				}),
+				"recordedAt": field(graphql.DateTime, "Start of the metering interval of interval readings", func(p graphql.ResolveParams) any {
+					return readingSource(p).RecordedAt
+				}),
+				"intervalSeconds": field(graphql.Int, "", func(p graphql.ResolveParams) any {
+					return readingSource(p).IntervalSeconds
+				}),
+				"region": field(graphql.String, "Grid region of the reading or of its product", func(p graphql.ResolveParams) any {
+					return readingSource(p).Region
+				}),
+			}
+		}),
+	})
+	readingConnectionType := connectionType("ReadingConnection", readingType)
+
+	productType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Product",
+		Fields: graphql.Fields{
+			"id": field(nonNull(graphql.ID), "", func(p graphql.ResolveParams) any {
+				return formatID(productSource(p).ID)
+			}),
+			"name": field(nonNull(graphql.String), "", func(p graphql.ResolveParams) any {
+				return productSource(p).Name
+			}),
+			"model": field(nonNull(graphql.String), "", func(p graphql.ResolveParams) any {
+				return productSource(p).Model
+			}),
+			"description": field(nonNull(graphql.String), "", func(p graphql.ResolveParams) any {
+				return productSource(p).Description
+			}),
+			"price": field(nonNull(graphql.Float), "Price in major units of its currency", func(p graphql.ResolveParams) any {
+				return productSource(p).Price
+			}),
+			"currency": field(nonNull(graphql.String), "", func(p graphql.ResolveParams) any {
+				return productSource(p).Currency
+			}),
+			"energyConsumption": field(nonNull(graphql.Float), "Energy consumption in kWh/year", func(p graphql.ResolveParams) any {
+				return productSource(p).EnergyConsumption
+			}),
+			"categoryId": field(graphql.ID, "", func(p graphql.ResolveParams) any {
+				return formatOptionalID(productSource(p).CategoryID)
+			}),
+			"tags": field(listOf(graphql.String), "", func(p graphql.ResolveParams) any {
+				return productSource(p).Tags
+			}),
+			"region": field(graphql.String, "", func(p graphql.ResolveParams) any {
+				return productSource(p).Region
+			}),
+			"attributes": field(listOf(attributeType), "Technical attributes, ordered by name", func(p graphql.ResolveParams) any {
+				attributes := productSource(p).Attributes
+				names := make([]string, 0, len(attributes))
+				for name := range attributes {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				list := make([]map[string]any, len(names))
+				for i, name := range names {
+					list[i] = map[string]any{"name": name, "value": attributes[name]}
+				}
+				return list
+			}),
+			"ratingClass": field(graphql.String, "Energy efficiency class", func(p graphql.ResolveParams) any {
+				if rating := productSource(p).Rating; rating != nil {
+					return rating.Class
+				}
+				return nil
+			}),
+			"version": field(nonNull(graphql.Int), "", func(p graphql.ResolveParams) any {
+				return productSource(p).Version
+			}),
+			"createdAt": field(nonNull(graphql.DateTime), "", func(p graphql.ResolveParams) any {
+				return productSource(p).CreatedAt
+			}),
+			"updatedAt": field(nonNull(graphql.DateTime), "", func(p graphql.ResolveParams) any {
+				return productSource(p).UpdatedAt
+			}),
+			"readings": &graphql.Field{
+				Type:        nonNull(readingConnectionType),
+				Description: "Readings of the product in ID order, within startDate to endDate when given",
+				Args: pageArgs(graphql.FieldConfigArgument{
+					"startDate": &graphql.ArgumentConfig{Type: graphql.String, Description: "YYYY-MM-DD"},
+					"endDate":   &graphql.ArgumentConfig{Type: graphql.String, Description: "YYYY-MM-DD"},
+				}),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					filter, err := readingFilter(p.Args)
+					if err != nil {
+						return nil, err
+					}
+					afterID, limit, err := page(p.Args)
+					if err != nil {
+						return nil, err
+					}
+					load := requestFrom(p.Context).readings(readingsQuery{filter: filter, afterID: afterID, limit: limit}).
+						Load(productSource(p).ID)
+					return func() (any, error) {
+						readings, err := load()
+						if err != nil {
+							return nil, err
+						}
+						return pageOf(readings, limit, func(r *models.Reading) int64 { return r.ID }), nil
+					}, nil
+				},
+			},
+			"aggregates": &graphql.Field{
+				Type:        listOf(readingPeriodType),
+				Description: "Reading totals of the product per period, from the rollups maintained on ingestion",
+				Args:        aggregateArgs(graphql.FieldConfigArgument{}),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					query, err := aggregateQuery(p.Args)
+					if err != nil {
+						return nil, err
+					}
+					load := requestFrom(p.Context).aggregates(query.filter).Load(productSource(p).ID)
+					return func() (any, error) {
+						periods, err := load()
+						if err != nil {
+							return nil, err
+						}
+						return query.fillPeriods(periods)
+					}, nil
+				},
+			},
+			"stats": &graphql.Field{
+				Type:        nonNull(readingStatsType),
+				Description: "Totals of every reading of the product",
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					load := requestFrom(p.Context).stats.Load(productSource(p).ID)
+					return func() (any, error) {
+						return load()
+					}, nil
+				},
+			},
+		},
+	})
+	productConnectionType := connectionType("ProductConnection", productType)
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"product": &graphql.Field{
+				Type:        productType,
+				Description: "Product by ID, null when it does not exist or is in the trash",
+				Args:        graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: nonNull(graphql.ID)}},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					id, err := idArg(p.Args, "id")
+					if err != nil {
+						return nil, err
+					}
+					product, err := requestFrom(p.Context).store.GetProduct(p.Context, id)
+					if errors.Is(err, storage.ErrNotFound) {
+						return nil, nil
+					}
+					return product, err
+				},
+			},
+			"products": &graphql.Field{
+				Type:        nonNull(productConnectionType),
+				Description: "Products in ID order, excluding the trash",
+				Args: pageArgs(graphql.FieldConfigArgument{
+					"categoryId": &graphql.ArgumentConfig{
+						Type:        graphql.ID,
+						Description: "Keep products in the category or any of its descendants",
+					},
+					"tag": &graphql.ArgumentConfig{Type: graphql.String, Description: "Keep products carrying the tag"},
+				}),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					categoryID, err := idArg(p.Args, "categoryId")
+					if err != nil {
+						return nil, err
+					}
+					afterID, limit, err := page(p.Args)
+					if err != nil {
+						return nil, err
+					}
+					tag, _ := p.Args["tag"].(string)
+					filter := storage.ProductFilter{CategoryID: categoryID, Tag: tag}
+					products, err := requestFrom(p.Context).store.GetProductsPage(p.Context, filter, afterID, limit+1)
+					if err != nil {
+						return nil, err
+					}
+					return pageOf(products, limit, func(product *models.Product) int64 { return product.ID }), nil
+				},
+			},
+			"reading": &graphql.Field{
+				Type:        readingType,
+				Description: "Reading by ID, null when it does not exist or its product is in the trash",
+				Args:        graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: nonNull(graphql.ID)}},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					id, err := idArg(p.Args, "id")
+					if err != nil {
+						return nil, err
+					}
+					reading, err := requestFrom(p.Context).store.GetReading(p.Context, id)
+					if errors.Is(err, storage.ErrNotFound) {
+						return nil, nil
+					}
+					return reading, err
+				},
+			},
+			"readings": &graphql.Field{
+				Type:        nonNull(readingConnectionType),
+				Description: "Readings in ID order, within startDate to endDate when given",
+				Args: pageArgs(graphql.FieldConfigArgument{
+					"productId": &graphql.ArgumentConfig{Type: graphql.ID},
+					"startDate": &graphql.ArgumentConfig{Type: graphql.String, Description: "YYYY-MM-DD"},
+					"endDate":   &graphql.ArgumentConfig{Type: graphql.String, Description: "YYYY-MM-DD"},
+				}),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					filter, err := readingFilter(p.Args)
+					if err != nil {
+						return nil, err
+					}
+					productID, err := idArg(p.Args, "productId")
+					if err != nil {
+						return nil, err
+					}
+					if productID != 0 {
+						filter.ProductID = &productID
+					}
+					afterID, limit, err := page(p.Args)
+					if err != nil {
+						return nil, err
+					}
+					readings, err := requestFrom(p.Context).store.GetReadingsPage(p.Context, filter, afterID, limit+1)
+					if err != nil {
+						return nil, err
+					}
+					return pageOf(readings, limit, func(r *models.Reading) int64 { return r.ID }), nil
+				},
+			},
+			"aggregates": &graphql.Field{
+				Type:        listOf(readingPeriodType),
+				Description: "Reading totals per period, from the rollups maintained on ingestion",
+				Args: aggregateArgs(graphql.FieldConfigArgument{
+					"productId": &graphql.ArgumentConfig{Type: graphql.ID, Description: "Only aggregate the readings of this product"},
+				}),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					query, err := aggregateQuery(p.Args)
+					if err != nil {
+						return nil, err
+					}
+					productID, err := idArg(p.Args, "productId")
+					if err != nil {
+						return nil, err
+					}
+					if productID != 0 {
+						query.filter.ProductID = &productID
+					}
+					periods, err := requestFrom(p.Context).store.GetReadingAggregates(p.Context, query.filter)
+					if err != nil {
+						return nil, err
+					}
+					return query.fillPeriods(periods)
+				},
+			},
+			"stats": &graphql.Field{
+				Type: nonNull(statsType),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return requestFrom(p.Context).store.GetProductStats(p.Context)
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
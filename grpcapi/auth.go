@@ -0,0 +1,170 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"product-tracker/config"
+	"product-tracker/models"
+	"product-tracker/storage"
+	"product-tracker/utils"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// publicServices are the services callable without a token
+var publicServices = []string{
+	"/grpc.health.v1.Health/",
+	"/grpc.reflection.v1.ServerReflection/",
+	"/grpc.reflection.v1alpha.ServerReflection/",
+}
+
+// claimsKey is the context key of the token claims
+type claimsKey struct{}
+
+// storeKey is the context key of the storage confined to the organization of the call
+type storeKey struct{}
+
+// tenantMetadata selects the organization a superadmin call acts on, as the X-Tenant-ID header does for REST calls
+const tenantMetadata = "x-tenant-id"
+
+// ClaimsFromContext returns the claims of the token the call was made with, if any
+func ClaimsFromContext(ctx context.Context) (*utils.TokenClaims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*utils.TokenClaims)
+	return claims, ok
+}
+
+// storeFromContext returns the storage of the call, confined to the organization it acts on
+func storeFromContext(ctx context.Context) *storage.Storage {
+	return ctx.Value(storeKey{}).(*storage.Storage)
+}
+
+// authenticate validates the bearer token in the authorization metadata, as AuthMiddleware does for REST calls,
+// and returns a context carrying its claims, the actor recorded in the product history and the storage of the
+// organization the call acts on, along with a function closing that storage
+func authenticate(ctx context.Context, method string) (context.Context, func(), error) {
+	for _, prefix := range publicServices {
+		if strings.HasPrefix(method, prefix) {
+			return ctx, func() {}, nil
+		}
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, nil, status.Error(codes.Unauthenticated, "Missing authorization metadata")
+	}
+	tokenString := strings.TrimPrefix(values[0], "Bearer ")
+	if tokenString == values[0] {
+		return nil, nil, status.Error(codes.Unauthenticated, "Invalid authorization metadata format")
+	}
+
+	claims, err := utils.ValidateToken(tokenString)
+	if err != nil {
+		return nil, nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	actor := storage.Actor{UserID: claims.UserID}
+	if requestIDs := md.Get("x-request-id"); len(requestIDs) > 0 {
+		actor.RequestID = requestIDs[0]
+	}
+
+	tenantID, err := resolveTenant(ctx, md, claims, method, actor.RequestID)
+	if err != nil {
+		return nil, nil, err
+	}
+	store, err := storage.NewStorage(config.GetConfig(), storage.TenantScope(tenantID))
+	if err != nil {
+		log.Printf("❌ Failed to connect to database: %v", err)
+		return nil, nil, status.Error(codes.Unavailable, "Failed to connect to database")
+	}
+
+	ctx = context.WithValue(ctx, claimsKey{}, claims)
+	ctx = context.WithValue(ctx, storeKey{}, store)
+	return storage.WithActor(ctx, actor), func() { store.Close() }, nil
+}
+
+// resolveTenant returns the organization a call acts on: the one of the token, or the one of the x-tenant-id
+// metadata for superadmins, whose calls to other organizations are written to the audit log first
+func resolveTenant(ctx context.Context, md metadata.MD, claims *utils.TokenClaims, method, requestID string) (int64, error) {
+	var requested int64
+	if values := md.Get(tenantMetadata); len(values) > 0 {
+		id, err := strconv.ParseInt(values[0], 10, 64)
+		if err != nil || id <= 0 {
+			return 0, status.Error(codes.InvalidArgument, "Invalid x-tenant-id metadata")
+		}
+		requested = id
+	}
+
+	tenantID, crossTenant, err := utils.ResolveTenant(claims, requested)
+	switch {
+	case errors.Is(err, utils.ErrTenantNotFound):
+		return 0, status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, utils.ErrCrossTenant):
+		return 0, status.Error(codes.PermissionDenied, err.Error())
+	}
+	if !crossTenant {
+		return tenantID, nil
+	}
+
+	audit, err := storage.NewStorage(config.GetConfig(), storage.AllTenants)
+	if err != nil {
+		log.Printf("❌ Failed to connect to database to audit tenant access: %v", err)
+		return 0, status.Error(codes.Unavailable, "Failed to connect to database")
+	}
+	defer audit.Close()
+
+	access := &models.TenantAccess{
+		UserID:       claims.UserID,
+		HomeTenantID: claims.TenantID,
+		TenantID:     tenantID,
+		Method:       "GRPC",
+		Path:         method,
+		RequestID:    requestID,
+	}
+	if err := audit.RecordTenantAccess(ctx, access); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return 0, status.Error(codes.NotFound, "Organization not found")
+		}
+		log.Printf("❌ Failed to audit tenant access: %v", err)
+		return 0, status.Error(codes.Internal, "Failed to audit cross-tenant access")
+	}
+	return tenantID, nil
+}
+
+// unaryAuth authenticates unary calls
+func unaryAuth(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, done, err := authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	return handler(ctx, req)
+}
+
+// authenticatedStream is a server stream whose context carries the token claims
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the authenticated context
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// streamAuth authenticates streaming calls
+func streamAuth(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, done, err := authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	defer done()
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
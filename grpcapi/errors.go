@@ -0,0 +1,55 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"product-tracker/emissions"
+	"product-tracker/money"
+	"product-tracker/rating"
+	"product-tracker/storage"
+	"product-tracker/units"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// toStatus maps storage and validation errors to gRPC status errors, as the REST handlers map them to HTTP
+// status codes
+func toStatus(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	code := codes.Internal
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		code = codes.NotFound
+	case errors.Is(err, storage.ErrVersionMismatch):
+		code = codes.FailedPrecondition
+	case errors.Is(err, storage.ErrDuplicate):
+		code = codes.AlreadyExists
+	case errors.Is(err, storage.ErrInvalidCategory), errors.Is(err, storage.ErrInvalidTag),
+		errors.Is(err, storage.ErrInvalidProduct), errors.Is(err, storage.ErrInvalidInterval),
+		errors.Is(err, rating.ErrInvalidAttribute), errors.Is(err, units.ErrUnknownUnit),
+		errors.Is(err, units.ErrIncompatibleUnits), errors.Is(err, emissions.ErrInvalidRegion),
+		errors.Is(err, money.ErrUnknownCurrency), errors.Is(err, money.ErrInvalidAmount),
+		errors.Is(err, errInvalidPageToken):
+		code = codes.InvalidArgument
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	}
+	return status.Error(code, err.Error())
+}
+
+// requireFinite rejects NaN and infinite values of a field. Unlike JSON numbers, protobuf doubles can carry them,
+// and the min=0 validation the REST API relies on lets +Inf through.
+func requireFinite(field string, value float64) error {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return status.Errorf(codes.InvalidArgument, "%s must be a finite number", field)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package grpcapi
+
+import (
+	"context"
+
+	"product-tracker/config"
+	"product-tracker/handlers"
+	"product-tracker/models"
+	"product-tracker/pb"
+	"product-tracker/storage"
+
+	"github.com/gin-gonic/gin/binding"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// productServer implements pb.ProductServiceServer
+type productServer struct {
+	pb.UnimplementedProductServiceServer
+}
+
+// CreateProduct validates and converts the request as the REST insert does, then stores the product
+func (s *productServer) CreateProduct(ctx context.Context, req *pb.CreateProductRequest) (*pb.Product, error) {
+	request := handlers.Product{
+		Name:              req.GetName(),
+		Model:             req.GetModel(),
+		Description:       req.GetDescription(),
+		Price:             req.GetPrice(),
+		Currency:          req.GetCurrency(),
+		EnergyConsumption: req.GetEnergyConsumption(),
+		EnergyUnit:        req.GetEnergyUnit(),
+		DutyCycle:         req.DutyCycle,
+		CyclesPerYear:     req.CyclesPerYear,
+		CategoryID:        req.CategoryId,
+		Tags:              req.GetTags(),
+		Region:            req.GetRegion(),
+		Attributes:        req.GetAttributes(),
+	}
+	if err := requireFinite("price", request.Price); err != nil {
+		return nil, err
+	}
+	if err := requireFinite("energy_consumption", request.EnergyConsumption); err != nil {
+		return nil, err
+	}
+	if err := binding.Validator.ValidateStruct(request); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	product, err := request.ToModel(config.GetConfig().Money.DefaultCurrency)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := storeFromContext(ctx).InsertProduct(ctx, product); err != nil {
+		return nil, toStatus(err)
+	}
+	// Read back for the tags and rating derived on insert
+	stored, err := storeFromContext(ctx).GetProduct(ctx, product.ID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return productToProto(stored), nil
+}
+
+// GetProduct returns a product
+func (s *productServer) GetProduct(ctx context.Context, req *pb.GetProductRequest) (*pb.Product, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id must be a product ID")
+	}
+	product, err := storeFromContext(ctx).GetProduct(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return productToProto(product), nil
+}
+
+// ListProducts returns a page of products in ID order
+func (s *productServer) ListProducts(ctx context.Context, req *pb.ListProductsRequest) (*pb.ListProductsResponse, error) {
+	afterID, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if req.GetCategoryId() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "category_id must be a category ID")
+	}
+	filter := storage.ProductFilter{CategoryID: req.GetCategoryId(), Tag: req.GetTag()}
+
+	limit := pageSize(req.GetPageSize())
+	// One more product than asked for tells whether there is a next page
+	products, err := storeFromContext(ctx).GetProductsPage(ctx, filter, afterID, limit+1)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	response := &pb.ListProductsResponse{}
+	if len(products) > limit {
+		products = products[:limit]
+		response.NextPageToken = encodePageToken(products[limit-1].ID)
+	}
+	for i := range products {
+		response.Products = append(response.Products, productToProto(&products[i]))
+	}
+	return response, nil
+}
+
+// productToProto converts a product to its protobuf message
+func productToProto(p *models.Product) *pb.Product {
+	message := &pb.Product{
+		Id:                p.ID,
+		Name:              p.Name,
+		Model:             p.Model,
+		Description:       p.Description,
+		PriceMinor:        p.PriceMinor,
+		Currency:          p.Currency,
+		EnergyConsumption: p.EnergyConsumption,
+		CategoryId:        p.CategoryID,
+		Tags:              p.Tags,
+		Region:            p.Region,
+		Attributes:        p.Attributes,
+		Version:           p.Version,
+		CreatedAt:         timestamppb.New(p.CreatedAt),
+		UpdatedAt:         timestamppb.New(p.UpdatedAt),
+	}
+	if p.Rating != nil {
+		message.RatingClass = &p.Rating.Class
+	}
+	return message
+}
@@ -0,0 +1,32 @@
+package grpcapi
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"product-tracker/pb"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCreateProductRejectsNonFiniteNumbers(t *testing.T) {
+	tests := []struct {
+		name          string
+		price, energy float64
+	}{
+		{"NaN price", math.NaN(), 50},
+		{"infinite price", math.Inf(1), 50},
+		{"NaN energy", 99.99, math.NaN()},
+		{"infinite energy", 99.99, math.Inf(1)},
+		{"negative infinite energy", 99.99, math.Inf(-1)},
+	}
+	for _, tt := range tests {
+		req := &pb.CreateProductRequest{Name: "Fridge", Price: tt.price, EnergyConsumption: tt.energy}
+		_, err := (&productServer{}).CreateProduct(context.Background(), req)
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s: CreateProduct returned %v, want %s", tt.name, err, codes.InvalidArgument)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"product-tracker/models"
+	"product-tracker/pb"
+	"product-tracker/storage"
+
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// readingServer implements pb.ReadingServiceServer
+type readingServer struct {
+	pb.UnimplementedReadingServiceServer
+	// batchSize is the number of streamed readings committed per transaction
+	batchSize int
+}
+
+// readingValidator checks readings against the rules of storage.Product
+var readingValidator = validator.New()
+
+// CreateReading records a reading
+func (s *readingServer) CreateReading(ctx context.Context, req *pb.CreateReadingRequest) (*pb.Reading, error) {
+	reading, err := readingFromProto(req)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := storeFromContext(ctx).InsertProducts(ctx, []storage.Product{reading})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	stored, err := storeFromContext(ctx).GetReading(ctx, ids[0])
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return readingToProto(stored), nil
+}
+
+// GetReading returns a reading
+func (s *readingServer) GetReading(ctx context.Context, req *pb.GetReadingRequest) (*pb.Reading, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id must be a reading ID")
+	}
+	reading, err := storeFromContext(ctx).GetReading(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return readingToProto(reading), nil
+}
+
+// ListReadings returns a page of readings in ID order
+func (s *readingServer) ListReadings(ctx context.Context, req *pb.ListReadingsRequest) (*pb.ListReadingsResponse, error) {
+	afterID, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	filter := storage.ReadingFilter{Start: req.GetStartDate(), End: req.GetEndDate(), ProductID: req.ProductId}
+	if err := readingValidator.Var(filter.Start, "omitempty,datetime=2006-01-02"); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "start_date must be formatted as YYYY-MM-DD")
+	}
+	if err := readingValidator.Var(filter.End, "omitempty,datetime=2006-01-02"); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "end_date must be formatted as YYYY-MM-DD")
+	}
+
+	limit := pageSize(req.GetPageSize())
+	// One more reading than asked for tells whether there is a next page
+	readings, err := storeFromContext(ctx).GetReadingsPage(ctx, filter, afterID, limit+1)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	response := &pb.ListReadingsResponse{}
+	if len(readings) > limit {
+		readings = readings[:limit]
+		response.NextPageToken = encodePageToken(readings[limit-1].ID)
+	}
+	for i := range readings {
+		response.Readings = append(response.Readings, readingToProto(&readings[i]))
+	}
+	return response, nil
+}
+
+// IngestReadings records streamed readings, committing every batchSize readings and the rest when the client
+// closes the stream. If a reading is invalid the call fails, keeping the batches committed before it; the error
+// tells how many readings were recorded so that the client can resume after them.
+func (s *readingServer) IngestReadings(stream grpc.ClientStreamingServer[pb.CreateReadingRequest, pb.IngestReadingsResponse]) error {
+	ctx := stream.Context()
+	var count int64
+	batch := make([]storage.Product, 0, s.batchSize)
+
+	commit := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if _, err := storeFromContext(ctx).InsertProducts(ctx, batch); err != nil {
+			return withCount(toStatus(err), count)
+		}
+		count += int64(len(batch))
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		reading, err := readingFromProto(req)
+		if err != nil {
+			// Readings before the invalid one are recorded, as they would have been one batch later
+			if commitErr := commit(); commitErr != nil {
+				return commitErr
+			}
+			return withCount(err, count)
+		}
+		batch = append(batch, reading)
+		if len(batch) >= s.batchSize {
+			if err := commit(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := commit(); err != nil {
+		return err
+	}
+	return stream.SendAndClose(&pb.IngestReadingsResponse{Count: count})
+}
+
+// withCount adds the number of readings recorded so far to the message of a status error
+func withCount(err error, count int64) error {
+	st := status.Convert(err)
+	return status.Error(st.Code(), fmt.Sprintf("%s (%d readings recorded)", st.Message(), count))
+}
+
+// readingFromProto converts and validates a reading request
+func readingFromProto(req *pb.CreateReadingRequest) (storage.Product, error) {
+	reading := storage.Product{
+		ProductID:      req.ProductId,
+		Name:           req.GetName(),
+		Quantity:       int(req.GetQuantity()),
+		EnergyConsumed: req.GetEnergyConsumed(),
+		EnergyUnit:     req.GetEnergyUnit(),
+		Date:           req.GetDate(),
+		Region:         req.Region,
+	}
+	if req.RecordedAt != nil {
+		recordedAt := req.GetRecordedAt().AsTime()
+		reading.RecordedAt = &recordedAt
+	}
+	if req.IntervalSeconds != nil {
+		interval := int(req.GetIntervalSeconds())
+		reading.IntervalSeconds = &interval
+	}
+	if err := requireFinite("energy_consumed", reading.EnergyConsumed); err != nil {
+		return reading, err
+	}
+	if err := readingValidator.Struct(reading); err != nil {
+		return reading, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return reading, nil
+}
+
+// readingToProto converts a reading to its protobuf message
+func readingToProto(r *models.Reading) *pb.Reading {
+	message := &pb.Reading{
+		Id:             r.ID,
+		ProductId:      r.ProductID,
+		Name:           r.Name,
+		Quantity:       int32(r.Quantity),
+		EnergyConsumed: r.EnergyConsumed,
+		Date:           r.Date,
+		Region:         r.Region,
+	}
+	if r.RecordedAt != nil {
+		message.RecordedAt = timestamppb.New(*r.RecordedAt)
+	}
+	if r.IntervalSeconds != nil {
+		interval := int32(*r.IntervalSeconds)
+		message.IntervalSeconds = &interval
+	}
+	return message
+}
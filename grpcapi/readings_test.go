@@ -0,0 +1,32 @@
+package grpcapi
+
+import (
+	"math"
+	"testing"
+
+	"product-tracker/pb"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestReadingFromProto(t *testing.T) {
+	productID := int64(7)
+	tests := []struct {
+		energy float64
+		code   codes.Code
+	}{
+		{12.5, codes.OK},
+		{-1, codes.InvalidArgument},
+		{math.NaN(), codes.InvalidArgument},
+		{math.Inf(1), codes.InvalidArgument},
+		{math.Inf(-1), codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		req := &pb.CreateReadingRequest{ProductId: &productID, Name: "Fridge", Quantity: 1, EnergyConsumed: tt.energy, Date: "2024-03-01"}
+		_, err := readingFromProto(req)
+		if status.Code(err) != tt.code {
+			t.Errorf("readingFromProto with energy %v returned %v, want %s", tt.energy, err, tt.code)
+		}
+	}
+}
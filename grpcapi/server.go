@@ -0,0 +1,75 @@
+package grpcapi
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+
+	"product-tracker/config"
+	"product-tracker/pb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// Page sizes of the list methods
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// errInvalidPageToken is returned for page tokens that were not issued by a list method
+var errInvalidPageToken = errors.New("invalid page token")
+
+// NewServer returns a gRPC server exposing the product and reading services, along with the health and
+// reflection services. Every method but those of the health and reflection services needs a JWT, and works on the
+// data of the organization of its token.
+func NewServer(cfg config.GRPCConfig) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryAuth),
+		grpc.ChainStreamInterceptor(streamAuth),
+	)
+
+	pb.RegisterProductServiceServer(server, &productServer{})
+	pb.RegisterReadingServiceServer(server, &readingServer{batchSize: cfg.IngestBatchSize})
+
+	healthServer := health.NewServer()
+	for service := range server.GetServiceInfo() {
+		healthServer.SetServingStatus(service, healthpb.HealthCheckResponse_SERVING)
+	}
+	healthpb.RegisterHealthServer(server, healthServer)
+	reflection.Register(server)
+
+	return server
+}
+
+// pageSize returns the number of items to list for a requested page size
+func pageSize(requested int32) int {
+	if requested <= 0 {
+		return defaultPageSize
+	}
+	return min(int(requested), maxPageSize)
+}
+
+// encodePageToken returns the token of the page after the item with the given ID
+func encodePageToken(lastID int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(lastID, 10)))
+}
+
+// decodePageToken returns the ID after which a page starts, 0 for the first page
+func decodePageToken(token string) (int64, error) {
+	if token == "" {
+		return 0, nil
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, errInvalidPageToken
+	}
+	lastID, err := strconv.ParseInt(string(decoded), 10, 64)
+	if err != nil || lastID <= 0 {
+		return 0, errInvalidPageToken
+	}
+	return lastID, nil
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"product-tracker/budget"
+	"product-tracker/config"
+	"product-tracker/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAlerts godoc
+// @Summary      List budget alerts
+// @Description  Get budget alerts, most recently fired first. An alert is kept per budget, month, kind and threshold and
+// @Description  reopens if its condition holds again; fire_count counts how often it fired.
+// @Tags         alerts
+// @Produce      json
+// @Param        state         query     string  false  "Only return firing or resolved alerts"  Enums(firing, resolved)
+// @Param        budget_id     query     int     false  "Only return the alerts of this budget"
+// @Param        acknowledged  query     bool    false  "Only return acknowledged (true) or unacknowledged (false) alerts"
+// @Success      200           {array}   models.Alert
+// @Failure      400           {object}  map[string]string
+// @Failure      401           {object}  map[string]string
+// @Failure      500           {object}  map[string]string
+// @Router       /alerts [get]
+// @Security     BearerAuth
+func GetAlerts(c *gin.Context) {
+	var filter storage.AlertFilter
+	switch state := c.Query("state"); state {
+	case "", budget.StateFiring, budget.StateResolved:
+		filter.State = state
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "state must be firing or resolved"})
+		return
+	}
+	if value := c.Query("budget_id"); value != "" {
+		budgetID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || budgetID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "budget_id must be a budget ID"})
+			return
+		}
+		filter.BudgetID = &budgetID
+	}
+	if value := c.Query("acknowledged"); value != "" {
+		acknowledged, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "acknowledged must be a boolean"})
+			return
+		}
+		filter.Acknowledged = &acknowledged
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	alerts, err := storageInstance.GetAlerts(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, alerts)
+}
+
+// AcknowledgeAlert godoc
+// @Summary      Acknowledge a budget alert
+// @Description  Mark an alert as seen. Acknowledging twice keeps the first acknowledgement; an alert that fires again after
+// @Description  being resolved needs acknowledging again.
+// @Tags         alerts
+// @Produce      json
+// @Param        id   path      int  true  "Alert ID"
+// @Success      200  {object}  models.Alert
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /alerts/{id}/acknowledge [post]
+// @Security     BearerAuth
+func AcknowledgeAlert(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	alert, err := storageInstance.AcknowledgeAlert(c.Request.Context(), id, c.GetUint("userID"))
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, alert)
+}
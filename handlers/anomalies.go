@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"product-tracker/config"
+	"product-tracker/models"
+	"product-tracker/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AcknowledgeAnomalyRequest represents the anomaly acknowledgement request structure
+// @Description Optional note on the cause of the anomaly
+type AcknowledgeAnomalyRequest struct {
+	Note string `json:"note" example:"Compressor replaced" binding:"max=1000"`
+}
+
+// GetAnomalies godoc
+// @Summary      List anomalies
+// @Description  Get the readings flagged as anomalous, most recently detected first, with their score and expected range.
+// @Description  Anomalies of products in the trash are hidden.
+// @Tags         anomalies
+// @Produce      json
+// @Param        product_id    query     int     false  "Only return the anomalies of this product"
+// @Param        acknowledged  query     bool    false  "Only return acknowledged (true) or open (false) anomalies"
+// @Param        start         query     string  false  "Earliest reading date (YYYY-MM-DD)"
+// @Param        end           query     string  false  "Latest reading date (YYYY-MM-DD)"
+// @Param        limit         query     int     false  "Maximum number of anomalies, up to 1000 (default 100)"
+// @Success      200           {array}   models.Anomaly
+// @Failure      400           {object}  map[string]string
+// @Failure      401           {object}  map[string]string
+// @Failure      500           {object}  map[string]string
+// @Router       /anomalies [get]
+// @Security     BearerAuth
+func GetAnomalies(c *gin.Context) {
+	filter, ok := anomalyFilter(c)
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	anomalies, err := storageInstance.GetAnomalies(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, anomalies)
+}
+
+// AcknowledgeAnomaly godoc
+// @Summary      Acknowledge an anomaly
+// @Description  Mark an anomaly as seen, optionally with a note. Acknowledged readings count towards the baseline of later
+// @Description  readings again. Acknowledging twice keeps the first acknowledgement and replaces the note if one is given.
+// @Tags         anomalies
+// @Accept       json
+// @Produce      json
+// @Param        id       path      int                        true   "Anomaly ID"
+// @Param        request  body      AcknowledgeAnomalyRequest  false  "Acknowledgement note"
+// @Success      200      {object}  models.Anomaly
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /anomalies/{id}/acknowledge [post]
+// @Security     BearerAuth
+func AcknowledgeAnomaly(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var request AcknowledgeAnomalyRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	anomaly, err := storageInstance.AcknowledgeAnomaly(c.Request.Context(), id, c.GetUint("userID"), request.Note)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Anomaly not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, anomaly)
+}
+
+// anomalyFilter builds the anomaly listing filter from the query string, responding with 400 if it is invalid
+func anomalyFilter(c *gin.Context) (storage.AnomalyFilter, bool) {
+	filter := storage.AnomalyFilter{Start: c.Query("start"), End: c.Query("end")}
+
+	if value := c.Query("product_id"); value != "" {
+		productID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || productID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "product_id must be a product ID"})
+			return filter, false
+		}
+		filter.ProductID = &productID
+	}
+	if value := c.Query("acknowledged"); value != "" {
+		acknowledged, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "acknowledged must be a boolean"})
+			return filter, false
+		}
+		filter.Acknowledged = &acknowledged
+	}
+	for _, name := range []string{"start", "end"} {
+		if value := c.Query(name); value != "" {
+			if _, err := time.Parse(models.ReadingDateLayout, value); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be a date in YYYY-MM-DD format"})
+				return filter, false
+			}
+		}
+	}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > storage.MaxAnomalyLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(storage.MaxAnomalyLimit)})
+			return filter, false
+		}
+		filter.Limit = limit
+	}
+	return filter, true
+}
@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"product-tracker/budget"
+	"product-tracker/config"
+	"product-tracker/costcalc"
+	"product-tracker/models"
+	"product-tracker/rollups"
+	"product-tracker/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BudgetRequest represents the budget request structure
+// @Description Monthly limit on the energy (kWh) or energy cost (major units of the tariff currency) of a product, a category
+// @Description with its subcategories, or the portfolio. Thresholds are in percent of the limit and default to 80 and 100.
+type BudgetRequest struct {
+	Name       string  `json:"name" example:"Cold storage" binding:"required,max=255"`
+	Scope      string  `json:"scope" example:"category" binding:"required" enums:"product,category,portfolio"`
+	ProductID  *int64  `json:"product_id,omitempty"`
+	CategoryID *int64  `json:"category_id,omitempty" example:"3"`
+	Metric     string  `json:"metric" example:"energy" binding:"required" enums:"energy,cost"`
+	Limit      float64 `json:"limit" example:"1200" binding:"required"`
+	TariffID   *int64  `json:"tariff_id,omitempty"`
+	Timezone   string  `json:"timezone,omitempty" example:"UTC"`
+	Thresholds []int   `json:"thresholds,omitempty" example:"80,100"`
+}
+
+// toModel converts the request to a budget, counting months in the first rollup timezone unless one is given
+func (r BudgetRequest) toModel(cfg *config.Config) (*models.Budget, error) {
+	timezone := r.Timezone
+	if timezone == "" {
+		timezone = cfg.Rollups.Timezones[0]
+	}
+	if err := rollups.CheckTimezone(timezone, cfg.Rollups.Timezones); err != nil {
+		return nil, err
+	}
+	return &models.Budget{
+		Name:       r.Name,
+		Scope:      r.Scope,
+		ProductID:  r.ProductID,
+		CategoryID: r.CategoryID,
+		Metric:     r.Metric,
+		Limit:      r.Limit,
+		TariffID:   r.TariffID,
+		Timezone:   timezone,
+		Thresholds: r.Thresholds,
+	}, nil
+}
+
+// GetBudgets godoc
+// @Summary      List budgets
+// @Description  Get every budget ordered by name
+// @Tags         budgets
+// @Produce      json
+// @Success      200  {array}   models.Budget
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /budgets [get]
+// @Security     BearerAuth
+func GetBudgets(c *gin.Context) {
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	budgets, err := storageInstance.GetBudgets(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, budgets)
+}
+
+// GetBudget godoc
+// @Summary      Get a budget
+// @Description  Get a single budget
+// @Tags         budgets
+// @Produce      json
+// @Param        id   path      int  true  "Budget ID"
+// @Success      200  {object}  models.Budget
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /budgets/{id} [get]
+// @Security     BearerAuth
+func GetBudget(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	b, err := storageInstance.GetBudget(c.Request.Context(), id)
+	if err != nil {
+		writeBudgetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, b)
+}
+
+// CreateBudget godoc
+// @Summary      Create a budget
+// @Description  Create a budget and evaluate it against the current month. Cost budgets need a tariff and take its currency;
+// @Description  they price energy only, without standing charges. Admin only.
+// @Tags         budgets
+// @Accept       json
+// @Produce      json
+// @Param        budget  body      BudgetRequest  true  "Budget object"
+// @Success      201     {object}  models.Budget
+// @Failure      400     {object}  map[string]string
+// @Failure      401     {object}  map[string]string
+// @Failure      403     {object}  map[string]string
+// @Failure      500     {object}  map[string]string
+// @Router       /budgets [post]
+// @Security     BearerAuth
+func CreateBudget(c *gin.Context) {
+	var request BudgetRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cfg := config.GetConfig()
+	b, err := request.toModel(cfg)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	if err := storageInstance.CreateBudget(c.Request.Context(), b); err != nil {
+		writeBudgetError(c, err)
+		return
+	}
+	if _, err := storageInstance.EvaluateBudget(c.Request.Context(), b.ID, time.Now()); err != nil {
+		log.Printf("❌ Failed to evaluate budget %d: %v", b.ID, err)
+	}
+
+	c.JSON(http.StatusCreated, b)
+}
+
+// UpdateBudget godoc
+// @Summary      Update a budget
+// @Description  Replace a budget and evaluate it against the current month. Firing alerts of removed thresholds are resolved. Admin only.
+// @Tags         budgets
+// @Accept       json
+// @Produce      json
+// @Param        id      path      int            true  "Budget ID"
+// @Param        budget  body      BudgetRequest  true  "Budget object"
+// @Success      200     {object}  models.Budget
+// @Failure      400     {object}  map[string]string
+// @Failure      401     {object}  map[string]string
+// @Failure      403     {object}  map[string]string
+// @Failure      404     {object}  map[string]string
+// @Failure      500     {object}  map[string]string
+// @Router       /budgets/{id} [put]
+// @Security     BearerAuth
+func UpdateBudget(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var request BudgetRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cfg := config.GetConfig()
+	b, err := request.toModel(cfg)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	b.ID = id
+
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	if err := storageInstance.UpdateBudget(c.Request.Context(), b); err != nil {
+		writeBudgetError(c, err)
+		return
+	}
+	if _, err := storageInstance.EvaluateBudget(c.Request.Context(), b.ID, time.Now()); err != nil {
+		log.Printf("❌ Failed to evaluate budget %d: %v", b.ID, err)
+	}
+
+	c.JSON(http.StatusOK, b)
+}
+
+// DeleteBudget godoc
+// @Summary      Delete a budget
+// @Description  Delete a budget and its alerts. Admin only.
+// @Tags         budgets
+// @Produce      json
+// @Param        id   path      int  true  "Budget ID"
+// @Success      204
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /budgets/{id} [delete]
+// @Security     BearerAuth
+func DeleteBudget(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	if err := storageInstance.DeleteBudget(c.Request.Context(), id); err != nil {
+		writeBudgetError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetBudgetStatus godoc
+// @Summary      Get the status of a budget
+// @Description  Get the month-to-date usage of a budget in kWh or in its currency, with the month-end projection from the
+// @Description  run rate once enough of the month has passed. Readings of products in the trash do not count.
+// @Tags         budgets
+// @Produce      json
+// @Param        id   path      int  true  "Budget ID"
+// @Success      200  {object}  models.BudgetStatus
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      422  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /budgets/{id}/status [get]
+// @Security     BearerAuth
+func GetBudgetStatus(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	status, err := storageInstance.GetBudgetStatus(c.Request.Context(), id, time.Now())
+	if err != nil {
+		writeBudgetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// writeBudgetError maps errors of budget operations to HTTP responses
+func writeBudgetError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Budget not found"})
+	case errors.Is(err, budget.ErrInvalidBudget), errors.Is(err, storage.ErrInvalidCategory):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, costcalc.ErrNoRate), errors.Is(err, costcalc.ErrInvalidTariff):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"product-tracker/config"
+	"product-tracker/models"
+	"product-tracker/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CategoryRequest represents the category request structure
+// @Description Category name and optional parent
+type CategoryRequest struct {
+	Name     string `json:"name" example:"Refrigerators" binding:"required,max=128"`
+	ParentID *int64 `json:"parent_id,omitempty" example:"1"`
+}
+
+// TagRequest represents the tag request structure
+// @Description Tag name
+type TagRequest struct {
+	Name string `json:"name" example:"office-floor-2" binding:"required"`
+}
+
+// GetCategories godoc
+// @Summary      List categories
+// @Description  Get the category tree. Root categories are returned with their subcategories nested in children.
+// @Tags         categories
+// @Produce      json
+// @Success      200  {array}   models.Category
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /categories [get]
+// @Security     BearerAuth
+func GetCategories(c *gin.Context) {
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	categories, err := storageInstance.GetCategories(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, categories)
+}
+
+// GetCategory godoc
+// @Summary      Get a category
+// @Description  Get a single category with its direct subcategories
+// @Tags         categories
+// @Produce      json
+// @Param        id   path      int  true  "Category ID"
+// @Success      200  {object}  models.Category
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /categories/{id} [get]
+// @Security     BearerAuth
+func GetCategory(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	category, err := storageInstance.GetCategory(c.Request.Context(), id)
+	if err != nil {
+		writeCategoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// CreateCategory godoc
+// @Summary      Create a category
+// @Description  Create a category, optionally below an existing parent. Names are unique among siblings.
+// @Tags         categories
+// @Accept       json
+// @Produce      json
+// @Param        category  body      CategoryRequest  true  "Category object"
+// @Success      201       {object}  models.Category
+// @Failure      400       {object}  map[string]string
+// @Failure      401       {object}  map[string]string
+// @Failure      409       {object}  map[string]string
+// @Failure      500       {object}  map[string]string
+// @Router       /categories [post]
+// @Security     BearerAuth
+func CreateCategory(c *gin.Context) {
+	var request CategoryRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	category := &models.Category{Name: request.Name, ParentID: request.ParentID}
+	if err := storageInstance.CreateCategory(c.Request.Context(), category); err != nil {
+		writeCategoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, category)
+}
+
+// UpdateCategory godoc
+// @Summary      Update a category
+// @Description  Rename a category or move it below another parent. A category cannot be moved below one of its own descendants.
+// @Tags         categories
+// @Accept       json
+// @Produce      json
+// @Param        id        path      int              true  "Category ID"
+// @Param        category  body      CategoryRequest  true  "Category object"
+// @Success      200       {object}  models.Category
+// @Failure      400       {object}  map[string]string
+// @Failure      401       {object}  map[string]string
+// @Failure      404       {object}  map[string]string
+// @Failure      409       {object}  map[string]string
+// @Failure      500       {object}  map[string]string
+// @Router       /categories/{id} [put]
+// @Security     BearerAuth
+func UpdateCategory(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var request CategoryRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	category := &models.Category{ID: id, Name: request.Name, ParentID: request.ParentID}
+	if err := storageInstance.UpdateCategory(c.Request.Context(), category); err != nil {
+		writeCategoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// DeleteCategory godoc
+// @Summary      Delete a category
+// @Description  Delete a category without subcategories. Products in the category become uncategorized.
+// @Tags         categories
+// @Produce      json
+// @Param        id   path      int  true  "Category ID"
+// @Success      204
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /categories/{id} [delete]
+// @Security     BearerAuth
+func DeleteCategory(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	if err := storageInstance.DeleteCategory(c.Request.Context(), id); err != nil {
+		writeCategoryError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetTags godoc
+// @Summary      List tags
+// @Description  Get every tag with the number of products carrying it
+// @Tags         tags
+// @Produce      json
+// @Success      200  {array}   models.Tag
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /tags [get]
+// @Security     BearerAuth
+func GetTags(c *gin.Context) {
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	tags, err := storageInstance.GetTags(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// CreateTag godoc
+// @Summary      Create a tag
+// @Description  Create a tag. Tag names are trimmed and lowercased. Tags are also created on the fly when assigned to a product.
+// @Tags         tags
+// @Accept       json
+// @Produce      json
+// @Param        tag  body      TagRequest  true  "Tag object"
+// @Success      201  {object}  models.Tag
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /tags [post]
+// @Security     BearerAuth
+func CreateTag(c *gin.Context) {
+	var request TagRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	tag := &models.Tag{Name: request.Name}
+	if err := storageInstance.CreateTag(c.Request.Context(), tag); err != nil {
+		writeTagError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, tag)
+}
+
+// RenameTag godoc
+// @Summary      Rename a tag
+// @Description  Rename a tag on every product carrying it
+// @Tags         tags
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int         true  "Tag ID"
+// @Param        tag  body      TagRequest  true  "Tag object"
+// @Success      200  {object}  models.Tag
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /tags/{id} [put]
+// @Security     BearerAuth
+func RenameTag(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var request TagRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	tag := &models.Tag{ID: id, Name: request.Name}
+	if err := storageInstance.RenameTag(c.Request.Context(), tag); err != nil {
+		writeTagError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// DeleteTag godoc
+// @Summary      Delete a tag
+// @Description  Delete a tag and remove it from every product
+// @Tags         tags
+// @Produce      json
+// @Param        id   path      int  true  "Tag ID"
+// @Success      204
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /tags/{id} [delete]
+// @Security     BearerAuth
+func DeleteTag(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	if err := storageInstance.DeleteTag(c.Request.Context(), id); err != nil {
+		writeTagError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// writeCategoryError maps storage errors of category operations to HTTP responses
+func writeCategoryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+	case errors.Is(err, storage.ErrInvalidCategory):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parent category does not exist"})
+	case errors.Is(err, storage.ErrCategoryCycle):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, storage.ErrDuplicate):
+		c.JSON(http.StatusConflict, gin.H{"error": "A category with this name already exists under the same parent"})
+	case errors.Is(err, storage.ErrCategoryNotEmpty):
+		c.JSON(http.StatusConflict, gin.H{"error": "Category has subcategories"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// writeTagError maps storage errors of tag operations to HTTP responses
+func writeTagError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+	case errors.Is(err, storage.ErrInvalidTag):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, storage.ErrDuplicate):
+		c.JSON(http.StatusConflict, gin.H{"error": "A tag with this name already exists"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
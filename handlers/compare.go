@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"product-tracker/config"
+	"product-tracker/costcalc"
+	"product-tracker/models"
+	"product-tracker/money"
+	"product-tracker/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CompareRequest represents a total cost of ownership comparison request
+// @Description Products to compare with the usage profile, tariff and lifetime to compare them over
+type CompareRequest struct {
+	ProductIDs []int64 `json:"product_ids" example:"1,2" binding:"required"`
+	TariffID   int64   `json:"tariff_id" example:"1" binding:"required"`
+	Years      int     `json:"years" example:"10" binding:"required"`
+	// Start is the day of purchase (default today)
+	Start string `json:"start,omitempty" example:"2024-01-01"`
+	// UsageHours is the hours of use per day; without it the stored consumption is used
+	UsageHours *float64 `json:"usage_hours,omitempty" example:"8"`
+	// DiscountRate overrides tco.discount_rate
+	DiscountRate *json.Number `json:"discount_rate,omitempty" swaggertype:"string" example:"0.05"`
+}
+
+// CompareProducts godoc
+// @Summary      Compare the total cost of ownership of products
+// @Description  Rank products by the net present value of their purchase cost and energy cost over a lifetime under a tariff.
+// @Description  Purchase prices are converted to the tariff currency at the rates in effect on start. Each year's energy cost
+// @Description  is discounted at discount_rate (default tco.discount_rate). break_evens gives, for each pair, when the product
+// @Description  with the higher purchase cost catches up with the other on discounted cumulative cost.
+// @Description  With format=csv the ranked table is returned as CSV with a break_even_<id> column per product.
+// @Tags         compare
+// @Accept       json
+// @Produce      json
+// @Produce      text/csv
+// @Param        request  body      CompareRequest  true   "Comparison"
+// @Param        format   query     string          false  "json (default) or csv"
+// @Success      200      {object}  costcalc.Comparison
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Failure      422      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /compare [post]
+// @Security     BearerAuth
+func CompareProducts(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", "json"))
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or csv"})
+		return
+	}
+
+	var req CompareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cfg := config.GetConfig()
+	lifetime := costcalc.Lifetime{Start: time.Now().UTC(), Years: req.Years}
+	lifetime.DiscountRate, _ = new(big.Rat).SetString(strconv.FormatFloat(cfg.TCO.DiscountRate, 'f', -1, 64))
+	if req.Start != "" {
+		start, err := time.Parse(models.TariffDateLayout, req.Start)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start must be a date in YYYY-MM-DD format"})
+			return
+		}
+		lifetime.Start = start
+	}
+	if req.DiscountRate != nil {
+		rate, ok := new(big.Rat).SetString(req.DiscountRate.String())
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "discount_rate must be a decimal number"})
+			return
+		}
+		lifetime.DiscountRate = rate
+	}
+	seen := map[int64]bool{}
+	for _, id := range req.ProductIDs {
+		if seen[id] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("product %d is listed twice", id)})
+			return
+		}
+		seen[id] = true
+	}
+	if len(req.ProductIDs) < 2 || len(req.ProductIDs) > costcalc.MaxCandidates {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("product_ids must list between 2 and %d products", costcalc.MaxCandidates)})
+		return
+	}
+
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	stored, err := storageInstance.GetTariff(c.Request.Context(), req.TariffID)
+	if err != nil {
+		writeTariffError(c, err)
+		return
+	}
+	tariff, err := costcalc.FromModel(stored)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	converter := storageInstance.NewFXConverter(lifetime.Start)
+	candidates := make([]costcalc.Candidate, 0, len(req.ProductIDs))
+	for _, id := range req.ProductIDs {
+		product, err := storageInstance.GetProduct(c.Request.Context(), id)
+		if err != nil {
+			writeProductError(c, err)
+			return
+		}
+		purchase, err := converter.Convert(c.Request.Context(), product.PriceMoney(), tariff.Currency)
+		if err != nil {
+			writeMoneyError(c, err)
+			return
+		}
+		annual, err := costcalc.AnnualConsumption(product, req.UsageHours)
+		if err != nil {
+			writeTariffError(c, err)
+			return
+		}
+		candidates = append(candidates, costcalc.Candidate{
+			ProductID: product.ID,
+			Name:      product.Name,
+			Purchase:  purchase,
+			AnnualKWh: annual,
+		})
+	}
+
+	comparison, err := costcalc.Compare(tariff, candidates, lifetime)
+	if err != nil {
+		writeTariffError(c, err)
+		return
+	}
+
+	if format == "csv" {
+		writeComparisonCSV(c, comparison)
+		return
+	}
+	c.JSON(http.StatusOK, comparison)
+}
+
+// writeComparisonCSV writes the ranked products of a comparison as CSV. Each product gets a break_even_<id> column
+// holding the years after which the product of the row catches up with that product, if it costs more to buy.
+func writeComparisonCSV(c *gin.Context, comparison *costcalc.Comparison) {
+	breakEvens := map[[2]int64]*float64{}
+	for _, b := range comparison.BreakEvens {
+		breakEvens[[2]int64{b.ProductID, b.OtherProductID}] = b.Years
+	}
+
+	header := []string{"rank", "product_id", "name", "annual_kwh", "purchase_cost", "energy_cost", "total_cost", "npv", "currency"}
+	for _, p := range comparison.Products {
+		header = append(header, fmt.Sprintf("break_even_%d", p.ProductID))
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=compare_%s.csv", comparison.Start))
+	c.Status(http.StatusOK)
+
+	decimals := money.Exponent(comparison.Currency)
+	formatMoney := func(m money.Money) string {
+		return strconv.FormatFloat(m.Major(), 'f', decimals, 64)
+	}
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write(header)
+	for _, p := range comparison.Products {
+		record := []string{
+			strconv.Itoa(p.Rank),
+			strconv.FormatInt(p.ProductID, 10),
+			p.Name,
+			strconv.FormatFloat(p.AnnualKWh, 'f', -1, 64),
+			formatMoney(p.PurchaseCost),
+			formatMoney(p.EnergyCost),
+			formatMoney(p.TotalCost),
+			formatMoney(p.NPV),
+			comparison.Currency,
+		}
+		for _, other := range comparison.Products {
+			field := ""
+			if years, ok := breakEvens[[2]int64{p.ProductID, other.ProductID}]; ok {
+				field = "never"
+				if years != nil {
+					field = strconv.FormatFloat(*years, 'f', 2, 64)
+				}
+			}
+			record = append(record, field)
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+}
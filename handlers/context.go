@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"product-tracker/storage"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+// actorContext returns the request context annotated with the authenticated user and request ID
+// so that storage writes can be attributed in the product history
+func actorContext(c *gin.Context) context.Context {
+	return storage.WithActor(c.Request.Context(), storage.Actor{
+		UserID:    c.GetUint("userID"),
+		RequestID: requestid.Get(c),
+	})
+}
+
+// tenantScope confines storage to the organization the request acts on, as set by AuthMiddleware
+func tenantScope(c *gin.Context) storage.Scope {
+	return storage.TenantScope(c.GetInt64("tenantID"))
+}
+
+// parseIDParam parses a positive integer path parameter, responding with 400 if it is invalid
+func parseIDParam(c *gin.Context, name string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name + " parameter"})
+		return 0, false
+	}
+	return id, true
+}
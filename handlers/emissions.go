@@ -0,0 +1,436 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"product-tracker/config"
+	"product-tracker/emissions"
+	"product-tracker/models"
+	"product-tracker/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EmissionFactorRequest represents an emission factor in an import request
+// @Description Grid emission factor in kg CO2e per kWh; factor may be sent as a number or a decimal string
+type EmissionFactorRequest struct {
+	Region string      `json:"region" example:"DE" binding:"required"`
+	Year   int         `json:"year" example:"2023" binding:"required"`
+	Factor json.Number `json:"factor" swaggertype:"string" example:"0.380" binding:"required"`
+	Source string      `json:"source,omitempty" example:"uba"`
+}
+
+// emissionFactorCSVHeader lists the columns of an emission factor CSV import; source is optional
+var emissionFactorCSVHeader = []string{"region", "year", "factor", "source"}
+
+// GetEmissionFactors godoc
+// @Summary      List emission factors
+// @Description  Get the latest version of each emission factor, or with as_of the versions in effect at that time
+// @Tags         emissions
+// @Produce      json
+// @Param        region        query     string  false  "Region code"
+// @Param        year          query     int     false  "Year"
+// @Param        as_of         query     string  false  "Return the versions recorded at or before this RFC 3339 time"
+// @Param        all_versions  query     bool    false  "Return every version instead of the latest one"
+// @Success      200           {array}   models.EmissionFactor
+// @Failure      400           {object}  map[string]string
+// @Failure      401           {object}  map[string]string
+// @Failure      500           {object}  map[string]string
+// @Router       /emissions/factors [get]
+// @Security     BearerAuth
+func GetEmissionFactors(c *gin.Context) {
+	var filter storage.EmissionFactorFilter
+	if value := c.Query("region"); value != "" {
+		region, err := emissions.ParseRegion(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		filter.Region = region
+	}
+	if value := c.Query("year"); value != "" {
+		year, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "year must be an integer"})
+			return
+		}
+		filter.Year = year
+	}
+	if value := c.Query("all_versions"); value != "" {
+		allVersions, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "all_versions must be a boolean"})
+			return
+		}
+		filter.AllVersions = allVersions
+	}
+	asOf, ok := factorsAsOfParam(c)
+	if !ok {
+		return
+	}
+	filter.AsOf = asOf
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	factors, err := storageInstance.GetEmissionFactors(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, factors)
+}
+
+// ImportEmissionFactors godoc
+// @Summary      Import emission factors
+// @Description  Store emission factors from a JSON array or, with Content-Type text/csv, from a CSV file with the header
+// @Description  region,year,factor[,source]. Each changed factor is stored as a new version; unchanged factors keep their version.
+// @Description  The import is all or nothing. Superadmin only, as the data is shared by every organization.
+// @Tags         emissions
+// @Accept       json
+// @Accept       text/csv
+// @Produce      json
+// @Param        factors  body      []EmissionFactorRequest  true  "Emission factors"
+// @Success      200      {array}   models.EmissionFactor
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      403      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /emissions/factors [post]
+// @Security     BearerAuth
+func ImportEmissionFactors(c *gin.Context) {
+	var (
+		factors []models.EmissionFactor
+		err     error
+	)
+	if c.ContentType() == "text/csv" {
+		factors, err = parseEmissionFactorsCSV(c.Request.Body)
+	} else {
+		var requests []EmissionFactorRequest
+		if err = c.ShouldBindJSON(&requests); err == nil {
+			for _, r := range requests {
+				factors = append(factors, models.EmissionFactor{
+					Region: r.Region,
+					Year:   r.Year,
+					Factor: r.Factor.String(),
+					Source: r.Source,
+				})
+			}
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(factors) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No emission factors given"})
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	if err := storageInstance.ImportEmissionFactors(c.Request.Context(), factors); err != nil {
+		writeEmissionsError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, factors)
+}
+
+// GetReadingsEmissions godoc
+// @Summary      Emissions per reading
+// @Description  Get the CO2e of each reading recorded between two dates, using the location-based factor of the reading's
+// @Description  region for the year of the reading. A reading without a region uses its product's region, then emissions.default_region.
+// @Tags         emissions
+// @Produce      json
+// @Param        start       query     string  true   "Start date (YYYY-MM-DD)"
+// @Param        end         query     string  true   "End date (YYYY-MM-DD)"
+// @Param        product_id  query     int     false  "Only return the readings of this product"
+// @Param        as_of       query     string  false  "Use the factor versions in effect at this RFC 3339 time"
+// @Success      200         {array}   models.ReadingEmissions
+// @Failure      400         {object}  map[string]string
+// @Failure      401         {object}  map[string]string
+// @Failure      422         {object}  map[string]string
+// @Failure      500         {object}  map[string]string
+// @Router       /readings/emissions [get]
+// @Security     BearerAuth
+func GetReadingsEmissions(c *gin.Context) {
+	filter, ok := readingFilter(c)
+	if !ok {
+		return
+	}
+	asOf, ok := factorsAsOfParam(c)
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	_, items, ok := readingEmissions(c, storageInstance, filter, asOf)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, items)
+}
+
+// GetEmissionsReport godoc
+// @Summary      Emissions per period
+// @Description  Aggregate the CO2e of the readings recorded between two dates per day, month or year.
+// @Description  The response lists the factor versions used; pass its factors_as_of as as_of to reproduce the report.
+// @Tags         emissions
+// @Produce      json
+// @Param        start       query     string  true   "Start date (YYYY-MM-DD)"
+// @Param        end         query     string  true   "End date (YYYY-MM-DD)"
+// @Param        product_id  query     int     false  "Only include the readings of this product"
+// @Param        period      query     string  false  "day, month (default) or year"
+// @Param        as_of       query     string  false  "Use the factor versions in effect at this RFC 3339 time"
+// @Success      200         {object}  models.EmissionsReport
+// @Failure      400         {object}  map[string]string
+// @Failure      401         {object}  map[string]string
+// @Failure      422         {object}  map[string]string
+// @Failure      500         {object}  map[string]string
+// @Router       /emissions/report [get]
+// @Security     BearerAuth
+func GetEmissionsReport(c *gin.Context) {
+	filter, ok := readingFilter(c)
+	if !ok {
+		return
+	}
+	period, err := emissions.ParsePeriod(c.Query("period"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	asOf, ok := factorsAsOfParam(c)
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	factors, items, ok := readingEmissions(c, storageInstance, filter, asOf)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, factors.Report(items, period))
+}
+
+// GetProductEmissions godoc
+// @Summary      Emissions of a product
+// @Description  Estimate the annual CO2e of a product from its rated consumption and the factor of its region for the year of end,
+// @Description  and aggregate the CO2e of its readings between start and end (default: the current calendar year).
+// @Tags         products
+// @Produce      json
+// @Param        id      path      int     true   "Product ID"
+// @Param        start   query     string  false  "Start date (YYYY-MM-DD)"
+// @Param        end     query     string  false  "End date (YYYY-MM-DD)"
+// @Param        period  query     string  false  "day, month (default) or year"
+// @Param        as_of   query     string  false  "Use the factor versions in effect at this RFC 3339 time"
+// @Success      200     {object}  models.ProductEmissions
+// @Failure      400     {object}  map[string]string
+// @Failure      401     {object}  map[string]string
+// @Failure      404     {object}  map[string]string
+// @Failure      422     {object}  map[string]string
+// @Failure      500     {object}  map[string]string
+// @Router       /product/{id}/emissions [get]
+// @Security     BearerAuth
+func GetProductEmissions(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var filter storage.ReadingFilter
+	if c.Query("start") == "" && c.Query("end") == "" {
+		now := time.Now().UTC()
+		filter.Start = fmt.Sprintf("%d-01-01", now.Year())
+		filter.End = now.Format(models.ReadingDateLayout)
+	} else if filter, ok = readingFilter(c); !ok {
+		return
+	}
+	filter.ProductID = &id
+
+	period, err := emissions.ParsePeriod(c.Query("period"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	asOf, ok := factorsAsOfParam(c)
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	product, err := storageInstance.GetProduct(c.Request.Context(), id)
+	if err != nil {
+		writeProductError(c, err)
+		return
+	}
+
+	factors, items, ok := readingEmissions(c, storageInstance, filter, asOf)
+	if !ok {
+		return
+	}
+
+	result := models.ProductEmissions{ProductID: product.ID, Region: cfg.Emissions.DefaultRegion}
+	if product.Region != nil {
+		result.Region = *product.Region
+	}
+	if result.Region == "" {
+		writeEmissionsError(c, fmt.Errorf("%w for product %d", emissions.ErrNoRegion, product.ID))
+		return
+	}
+	year, _ := strconv.Atoi(filter.End[:4])
+	annualFactor, value, err := factors.Lookup(result.Region, year)
+	if err != nil {
+		writeEmissionsError(c, err)
+		return
+	}
+	result.AnnualEnergyKWh = product.EnergyConsumption
+	result.AnnualCO2eKg = product.EnergyConsumption * value
+	result.AnnualFactor = annualFactor
+	result.Readings = factors.Report(items, period)
+
+	c.JSON(http.StatusOK, result)
+}
+
+// readingEmissions loads the factors in effect at asOf and computes the emissions of the readings matching filter,
+// responding with an error if that fails
+func readingEmissions(c *gin.Context, s *storage.Storage, filter storage.ReadingFilter, asOf time.Time) (*emissions.Factors, []models.ReadingEmissions, bool) {
+	factors, err := s.NewEmissionFactors(c.Request.Context(), asOf)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, nil, false
+	}
+
+	readings, err := s.GetReadings(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, nil, false
+	}
+
+	items, err := factors.ForReadings(readings, config.GetConfig().Emissions.DefaultRegion)
+	if err != nil {
+		writeEmissionsError(c, err)
+		return nil, nil, false
+	}
+	return factors, items, true
+}
+
+// factorsAsOfParam parses the ?as_of= query parameter, responding with 400 if it is invalid.
+// The zero time means the latest factors.
+func factorsAsOfParam(c *gin.Context) (time.Time, bool) {
+	value := c.Query("as_of")
+	if value == "" {
+		return time.Time{}, true
+	}
+	asOf, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "as_of must be an RFC 3339 timestamp"})
+		return time.Time{}, false
+	}
+	return asOf, true
+}
+
+// parseEmissionFactorsCSV reads emission factors from CSV with a header row
+func parseEmissionFactorsCSV(r io.Reader) ([]models.EmissionFactor, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range emissionFactorCSVHeader[:3] {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("CSV header must contain %s", strings.Join(emissionFactorCSVHeader, ","))
+		}
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var factors []models.EmissionFactor
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		year, err := strconv.Atoi(field(record, "year"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: year must be an integer", line)
+		}
+		factors = append(factors, models.EmissionFactor{
+			Region: field(record, "region"),
+			Year:   year,
+			Factor: field(record, "factor"),
+			Source: field(record, "source"),
+		})
+	}
+	return factors, nil
+}
+
+// writeEmissionsError maps emissions errors to HTTP responses
+func writeEmissionsError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, emissions.ErrInvalidRegion), errors.Is(err, emissions.ErrInvalidFactor):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, emissions.ErrNoFactor), errors.Is(err, emissions.ErrNoRegion):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"product-tracker/config"
+	"product-tracker/models"
+	"product-tracker/storage"
+	"product-tracker/units"
+
+	"github.com/gin-gonic/gin"
+)
+
+// productETag returns the entity tag of the current state of a product
+func productETag(p *models.Product) string {
+	return fmt.Sprintf(`"%d-%d"`, p.ID, p.Version)
+}
+
+// renderedProductETag returns the entity tag of a product rendered in unit and currency, at the exchange rates
+// in effect on date. Renderings other than the stored one get a tag of their own, which changes with the rate
+// date, so a representation cached with the rates of one day is not revalidated on the next.
+func renderedProductETag(p *models.Product, unit *units.Unit, currency string, date time.Time) string {
+	if unit == nil && currency == "" {
+		return productETag(p)
+	}
+	h := fnv.New32a()
+	if unit != nil {
+		h.Write([]byte(unit.Symbol))
+	}
+	h.Write([]byte{0})
+	if currency != "" {
+		h.Write([]byte(currency + "\x00" + date.Format(models.FXRateDateLayout)))
+	}
+	return fmt.Sprintf(`"%d-%d-%08x"`, p.ID, p.Version, h.Sum32())
+}
+
+// parseProductETag extracts the product ID and version from a strong entity tag, ignoring the rendering
+// suffix of renderedProductETag
+func parseProductETag(tag string) (int64, int64, bool) {
+	tag = strings.TrimSpace(tag)
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, 0, false
+	}
+	idPart, versionPart, found := strings.Cut(tag[1:len(tag)-1], "-")
+	if !found {
+		return 0, 0, false
+	}
+	versionPart, _, _ = strings.Cut(versionPart, "-")
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	version, err := strconv.ParseInt(versionPart, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, 0, false
+	}
+	return id, version, true
+}
+
+// expectedVersion returns the product version a write must apply to according to If-Match.
+// Without the header (or with "*") any version is accepted, unless products.require_if_match
+// is set, in which case the request is rejected with 428. An If-Match that cannot match the
+// product is rejected with 412.
+func expectedVersion(c *gin.Context, id int64) (int64, bool) {
+	version, err := ifMatchVersion(c.GetHeader("If-Match"), id)
+	switch {
+	case errors.Is(err, storage.ErrVersionRequired):
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header is required"})
+		return 0, false
+	case err != nil:
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "If-Match does not match the product"})
+		return 0, false
+	}
+	return version, true
+}
+
+// ifMatchVersion returns the version of product id that the If-Match header allows a write to, failing with
+// storage.ErrVersionRequired or storage.ErrVersionMismatch as expectedVersion describes
+func ifMatchVersion(header string, id int64) (int64, error) {
+	if header == "" {
+		if config.GetConfig().Products.RequireIfMatch {
+			return 0, storage.ErrVersionRequired
+		}
+		return storage.AnyVersion, nil
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimSpace(tag) == "*" {
+			return storage.AnyVersion, nil
+		}
+		if tagID, version, ok := parseProductETag(tag); ok && tagID == id {
+			return version, nil
+		}
+	}
+	return 0, storage.ErrVersionMismatch
+}
+
+// notModified reports whether If-None-Match matches etag, in which case the response can be a 304
+func notModified(c *gin.Context, etag string) bool {
+	header := c.GetHeader("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
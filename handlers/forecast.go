@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"product-tracker/config"
+	"product-tracker/forecast"
+	"product-tracker/models"
+	"product-tracker/rollups"
+	"product-tracker/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// forecastLimits holds the season length and the default and maximum horizon and history of an interval, in periods
+var forecastLimits = map[rollups.Interval]struct {
+	season, horizon, maxHorizon, history, maxHistory int
+}{
+	rollups.Day:   {season: 7, horizon: 90, maxHorizon: 366, history: 730, maxHistory: 3660},
+	rollups.Week:  {season: 52, horizon: 13, maxHorizon: 104, history: 156, maxHistory: 520},
+	rollups.Month: {season: 12, horizon: 3, maxHorizon: 24, history: 36, maxHistory: 120},
+}
+
+// defaultForecastLevel is the prediction interval coverage used when none is requested, in percent
+const defaultForecastLevel = 95
+
+// GetProductForecast godoc
+// @Summary      Forecast the consumption of a product
+// @Description  Fit seasonal naive, linear trend and Holt-Winters models to the daily, weekly or monthly rollups of a product
+// @Description  and project its consumption horizon periods ahead, starting with the current period. Each model reports
+// @Description  prediction intervals and its MAPE and MAE when refitted without the most recent periods; best names the
+// @Description  model with the lowest MAPE. Periods without readings after the first reading count as zero.
+// @Tags         forecast
+// @Produce      json
+// @Param        id        path      int     true   "Product ID"
+// @Param        interval  query     string  false  "Period length (default month)"  Enums(day, week, month)
+// @Param        horizon   query     int     false  "Number of periods to forecast (default 90 days, 13 weeks or 3 months)"
+// @Param        history   query     int     false  "Number of past periods to fit (default 730 days, 156 weeks or 36 months)"
+// @Param        level     query     int     false  "Prediction interval coverage in percent (default 95)"  Enums(80, 90, 95, 99)
+// @Param        tz        query     string  false  "Rollup timezone of the periods; defaults to the first configured"
+// @Success      200       {object}  models.Forecast
+// @Failure      400       {object}  map[string]string
+// @Failure      401       {object}  map[string]string
+// @Failure      404       {object}  map[string]string
+// @Failure      422       {object}  map[string]string
+// @Failure      500       {object}  map[string]string
+// @Router       /product/{id}/forecast [get]
+// @Security     BearerAuth
+func GetProductForecast(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	writeForecast(c, &id)
+}
+
+// GetPortfolioForecast godoc
+// @Summary      Forecast the consumption of the portfolio
+// @Description  Like the product forecast, for the readings of every product outside the trash together.
+// @Tags         forecast
+// @Produce      json
+// @Param        interval  query     string  false  "Period length (default month)"  Enums(day, week, month)
+// @Param        horizon   query     int     false  "Number of periods to forecast (default 90 days, 13 weeks or 3 months)"
+// @Param        history   query     int     false  "Number of past periods to fit (default 730 days, 156 weeks or 36 months)"
+// @Param        level     query     int     false  "Prediction interval coverage in percent (default 95)"  Enums(80, 90, 95, 99)
+// @Param        tz        query     string  false  "Rollup timezone of the periods; defaults to the first configured"
+// @Success      200       {object}  models.Forecast
+// @Failure      400       {object}  map[string]string
+// @Failure      401       {object}  map[string]string
+// @Failure      422       {object}  map[string]string
+// @Failure      500       {object}  map[string]string
+// @Router       /forecast [get]
+// @Security     BearerAuth
+func GetPortfolioForecast(c *gin.Context) {
+	writeForecast(c, nil)
+}
+
+// writeForecast fits the forecast models to the rollups of a product, or of all products when productID is nil
+func writeForecast(c *gin.Context, productID *int64) {
+	interval, err := rollups.ParseInterval(c.DefaultQuery("interval", string(rollups.Month)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limits := forecastLimits[interval]
+	horizon, ok := intQueryParam(c, "horizon", limits.horizon, limits.maxHorizon)
+	if !ok {
+		return
+	}
+	history, ok := intQueryParam(c, "history", limits.history, limits.maxHistory)
+	if !ok {
+		return
+	}
+	level, err := strconv.Atoi(c.DefaultQuery("level", strconv.Itoa(defaultForecastLevel)))
+	if err != nil || !forecast.ValidLevel(level) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "level must be 80, 90, 95 or 99"})
+		return
+	}
+
+	cfg := config.GetConfig()
+	timezone := c.DefaultQuery("tz", cfg.Rollups.Timezones[0])
+	if err := rollups.CheckTimezone(timezone, cfg.Rollups.Timezones); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The current period is incomplete, so the history ends with the one before it
+	now := time.Now().In(location)
+	current := interval.Truncate(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC))
+	filter := storage.RollupFilter{
+		Timezone:  timezone,
+		Interval:  interval,
+		Start:     interval.Add(current, -history),
+		End:       current.AddDate(0, 0, -1),
+		ProductID: productID,
+	}
+
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	if productID != nil {
+		if _, err := storageInstance.GetProduct(c.Request.Context(), *productID); err != nil {
+			writeProductError(c, err)
+			return
+		}
+	}
+
+	stored, err := storageInstance.GetReadingAggregates(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(stored) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "No readings in the history to forecast from"})
+		return
+	}
+
+	// Fit from the first period with readings, counting later gaps as zero consumption
+	first, _ := time.Parse(models.ReadingDateLayout, stored[0].PeriodStart)
+	periods, err := rollups.FillPeriods(stored, interval, first, filter.End, rollups.FillZero)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	series := make([]float64, len(periods))
+	for i, p := range periods {
+		series[i] = *p.EnergyKWh
+	}
+
+	results := forecast.Run(series, limits.season, horizon, level)
+	response := models.Forecast{
+		ProductID:      productID,
+		Interval:       string(interval),
+		Timezone:       timezone,
+		Level:          level,
+		HistoryStart:   stored[0].PeriodStart,
+		HistoryEnd:     filter.End.Format(models.ReadingDateLayout),
+		HistoryPeriods: len(series),
+		Best:           forecast.Best(results),
+		Models:         make([]models.ModelForecast, len(results)),
+	}
+	for i, r := range results {
+		model := models.ModelForecast{Model: r.Model, MAPE: r.MAPE, MAE: r.MAE}
+		if r.Err != nil {
+			model.Error = r.Err.Error()
+		}
+		period := current
+		for _, p := range r.Points {
+			model.Points = append(model.Points, models.ForecastPoint{
+				PeriodStart: period.Format(models.ReadingDateLayout),
+				EnergyKWh:   p.Value,
+				LowerKWh:    p.Lower,
+				UpperKWh:    p.Upper,
+			})
+			period = interval.Next(period)
+		}
+		response.Models[i] = model
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// intQueryParam parses an optional positive integer query parameter of at most maxValue, responding with 400 if it is invalid
+func intQueryParam(c *gin.Context, name string, defaultValue, maxValue int) (int, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return defaultValue, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 || n > maxValue {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be between 1 and %d", name, maxValue)})
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"product-tracker/config"
+	"product-tracker/models"
+	"product-tracker/money"
+	"product-tracker/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FXRateRequest represents an exchange rate in an import request
+// @Description Exchange rate; rate may be sent as a number or a decimal string
+type FXRateRequest struct {
+	Base          string      `json:"base" example:"EUR" binding:"required"`
+	Quote         string      `json:"quote" example:"USD" binding:"required"`
+	Rate          json.Number `json:"rate" swaggertype:"string" example:"1.0842" binding:"required"`
+	EffectiveDate string      `json:"effective_date" example:"2024-01-01" binding:"required"`
+	Source        string      `json:"source,omitempty" example:"ecb"`
+}
+
+// fxRateCSVHeader lists the columns of an exchange rate CSV import; source is optional
+var fxRateCSVHeader = []string{"base", "quote", "rate", "effective_date", "source"}
+
+// GetFXRates godoc
+// @Summary      List exchange rates
+// @Description  Get the recorded exchange rates. With date only the rate in effect on that date is returned for each pair.
+// @Tags         fx
+// @Produce      json
+// @Param        base   query     string  false  "Base currency"
+// @Param        quote  query     string  false  "Quote currency"
+// @Param        date   query     string  false  "Only return the rates in effect on this date (YYYY-MM-DD)"
+// @Success      200    {array}   models.FXRate
+// @Failure      400    {object}  map[string]string
+// @Failure      401    {object}  map[string]string
+// @Failure      500    {object}  map[string]string
+// @Router       /fx/rates [get]
+// @Security     BearerAuth
+func GetFXRates(c *gin.Context) {
+	var filter storage.FXRateFilter
+	for param, target := range map[string]*string{"base": &filter.Base, "quote": &filter.Quote} {
+		if value := c.Query(param); value != "" {
+			currency, err := money.ParseCurrency(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			*target = currency
+		}
+	}
+	if value := c.Query("date"); value != "" {
+		date, err := time.Parse(models.FXRateDateLayout, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date must be a date in YYYY-MM-DD format"})
+			return
+		}
+		filter.Date = &date
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	rates, err := storageInstance.GetFXRates(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rates)
+}
+
+// ImportFXRates godoc
+// @Summary      Import exchange rates
+// @Description  Store exchange rates from a JSON array or, with Content-Type text/csv, from a CSV file with the header
+// @Description  base,quote,rate,effective_date[,source]. A rate already recorded for the same pair and date is replaced.
+// @Description  The import is all or nothing. Superadmin only, as the data is shared by every organization.
+// @Tags         fx
+// @Accept       json
+// @Accept       text/csv
+// @Produce      json
+// @Param        rates  body      []FXRateRequest  true  "Exchange rates"
+// @Success      200    {array}   models.FXRate
+// @Failure      400    {object}  map[string]string
+// @Failure      401    {object}  map[string]string
+// @Failure      403    {object}  map[string]string
+// @Failure      500    {object}  map[string]string
+// @Router       /fx/rates [post]
+// @Security     BearerAuth
+func ImportFXRates(c *gin.Context) {
+	var (
+		rates []models.FXRate
+		err   error
+	)
+	if c.ContentType() == "text/csv" {
+		rates, err = parseFXRatesCSV(c.Request.Body)
+	} else {
+		var requests []FXRateRequest
+		if err = c.ShouldBindJSON(&requests); err == nil {
+			for _, r := range requests {
+				rates = append(rates, models.FXRate{
+					Base:          r.Base,
+					Quote:         r.Quote,
+					Rate:          r.Rate.String(),
+					EffectiveDate: r.EffectiveDate,
+					Source:        r.Source,
+				})
+			}
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(rates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No exchange rates given"})
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	if err := storageInstance.UpsertFXRates(c.Request.Context(), rates); err != nil {
+		writeMoneyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rates)
+}
+
+// parseFXRatesCSV reads exchange rates from CSV with a header row
+func parseFXRatesCSV(r io.Reader) ([]models.FXRate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range fxRateCSVHeader[:4] {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("CSV header must contain %s", strings.Join(fxRateCSVHeader, ","))
+		}
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var rates []models.FXRate
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rates = append(rates, models.FXRate{
+			Base:          field(record, "base"),
+			Quote:         field(record, "quote"),
+			Rate:          field(record, "rate"),
+			EffectiveDate: field(record, "effective_date"),
+			Source:        field(record, "source"),
+		})
+	}
+	return rates, nil
+}
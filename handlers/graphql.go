@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+
+	"product-tracker/config"
+	"product-tracker/graphqlapi"
+	"product-tracker/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GraphQL godoc
+// @Summary      Run a GraphQL query
+// @Description  Run a GraphQL query over products, their readings, aggregates and stats. Connections are paginated with
+// @Description  first and after, and the nested fields of a list are loaded with one query per field rather than per item.
+// @Description  Queries nested deeper than graphql.max_depth fields or estimated to cost more than graphql.max_complexity
+// @Description  are rejected before they run; every field costs one and the fields below a connection count once per
+// @Description  node of the page it asks for. Errors of a query that ran are reported in errors with status 200.
+// @Tags         graphql
+// @Accept       json
+// @Produce      json
+// @Param        request  body      graphqlapi.Request  true  "GraphQL request"
+// @Success      200      {object}  map[string]interface{}
+// @Failure      400      {object}  map[string]interface{}
+// @Failure      401      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /graphql [post]
+// @Security     BearerAuth
+func GraphQL(c *gin.Context) {
+	var request graphqlapi.Request
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	result := graphqlapi.Execute(c.Request.Context(), storageInstance, request, cfg.GraphQL)
+	if result.Data == nil && result.HasErrors() {
+		// The query did not run: it could not be parsed, is invalid or exceeds the limits
+		c.JSON(http.StatusBadRequest, result)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
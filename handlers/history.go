@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"product-tracker/config"
+	"product-tracker/models"
+	"product-tracker/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProductHistoryChange represents one recorded product write as a field-level diff
+// @Description Product change with actor attribution
+type ProductHistoryChange struct {
+	ID        int64                `json:"id" example:"1"`
+	Action    string               `json:"action" example:"update" enums:"snapshot,create,update,delete,restore,purge"`
+	ActorID   uint                 `json:"actor_id" example:"42"`
+	RequestID string               `json:"request_id" example:"3f2b8c1e-6a4d-4c1b-9a43-2f0e5d7c9b10"`
+	ChangedAt time.Time            `json:"changed_at"`
+	Changes   []models.FieldChange `json:"changes"`
+}
+
+// GetProductHistory godoc
+// @Summary      Get product change history
+// @Description  Get every recorded write to a product, oldest first, with the fields that changed, the actor and the request ID
+// @Tags         products
+// @Produce      json
+// @Param        id   path      int  true  "Product ID"
+// @Success      200  {array}   ProductHistoryChange
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /product/{id}/history [get]
+// @Security     BearerAuth
+func GetProductHistory(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	entries, err := storageInstance.GetProductHistory(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(entries) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	changes := make([]ProductHistoryChange, 0, len(entries))
+	for _, entry := range entries {
+		changes = append(changes, ProductHistoryChange{
+			ID:        entry.ID,
+			Action:    entry.Action,
+			ActorID:   entry.ActorID,
+			RequestID: entry.RequestID,
+			ChangedAt: entry.ChangedAt,
+			Changes:   entry.Diff(),
+		})
+	}
+
+	c.JSON(http.StatusOK, changes)
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"product-tracker/config"
+	"product-tracker/models"
+	"product-tracker/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+)
+
+// idempotent runs fn at most once per Idempotency-Key and replays the stored
+// response for retries within the configured window. Requests without the
+// header are executed as usual. Reusing a key with a different payload is
+// rejected with 422.
+func idempotent(c *gin.Context, s *storage.Storage, payload any, fn func() (int, any)) {
+	key := c.GetHeader(idempotencyKeyHeader)
+	if key == "" {
+		status, body := fn()
+		c.JSON(status, body)
+		return
+	}
+
+	if len(key) > maxIdempotencyKeyLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+		return
+	}
+
+	hash, err := hashPayload(payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	cfg := config.GetConfig()
+	record := &models.IdempotencyRecord{
+		UserID:      c.GetUint("userID"),
+		Route:       c.Request.Method + " " + c.FullPath(),
+		Key:         key,
+		RequestHash: hash,
+		ExpiresAt:   time.Now().Add(cfg.Idempotency.TTL),
+	}
+
+	existing, err := s.ReserveIdempotencyKey(c.Request.Context(), record, cfg.Idempotency.Lease)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if existing != nil {
+		switch {
+		case existing.RequestHash != hash:
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different payload"})
+		case existing.StatusCode == 0:
+			c.JSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still being processed"})
+		default:
+			c.Header(idempotencyReplayedHeader, "true")
+			c.Data(existing.StatusCode, gin.MIMEJSON+"; charset=utf-8", existing.ResponseBody)
+		}
+		return
+	}
+
+	// The outcome is stored even if the client went away meanwhile, or a retry would be refused until the
+	// lease ends
+	ctx := context.WithoutCancel(c.Request.Context())
+	defer func() {
+		if r := recover(); r != nil {
+			if err := s.ReleaseIdempotencyKey(ctx, record); err != nil {
+				log.Printf("Warning: Failed to release idempotency key: %v", err)
+			}
+			panic(r)
+		}
+	}()
+
+	status, body := fn()
+	data, err := json.Marshal(body)
+	if err != nil {
+		status = http.StatusInternalServerError
+		data, _ = json.Marshal(gin.H{"error": err.Error()})
+	}
+
+	// Server errors are not stored so that the client can retry with the same key
+	if status >= http.StatusInternalServerError {
+		if err := s.ReleaseIdempotencyKey(ctx, record); err != nil {
+			log.Printf("Warning: Failed to release idempotency key: %v", err)
+		}
+	} else {
+		record.StatusCode = status
+		record.ResponseBody = data
+		if err := s.CompleteIdempotencyKey(ctx, record); err != nil {
+			log.Printf("Warning: Failed to store idempotent response: %v", err)
+		}
+	}
+
+	c.Data(status, gin.MIMEJSON+"; charset=utf-8", data)
+}
+
+// hashPayload returns a stable fingerprint of a request payload
+func hashPayload(payload any) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
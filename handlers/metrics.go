@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+
+	"product-tracker/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMetrics godoc
+// @Summary      Get metrics
+// @Description  Get the server metrics in the Prometheus text exposition format, such as MQTT ingestion counts and lag
+// @Tags         metrics
+// @Produce      plain
+// @Success      200  {string}  string  "Metrics"
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Router       /metrics [get]
+// @Security     BearerAuth
+func GetMetrics(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	metrics.WriteText(c.Writer)
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"product-tracker/models"
+	"product-tracker/money"
+	"product-tracker/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// currencyParams parses the ?currency= and ?date= query parameters, responding with 400 if either is invalid.
+// currency is empty when no conversion was requested; date defaults to today.
+func currencyParams(c *gin.Context) (string, time.Time, bool) {
+	date := time.Now().UTC().Truncate(24 * time.Hour)
+	if value := c.Query("date"); value != "" {
+		parsed, err := time.Parse(models.FXRateDateLayout, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date must be a date in YYYY-MM-DD format"})
+			return "", date, false
+		}
+		date = parsed
+	}
+
+	value := c.Query("currency")
+	if value == "" {
+		return "", date, true
+	}
+	currency, err := money.ParseCurrency(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", date, false
+	}
+	return currency, date, true
+}
+
+// renderProductPrices converts the prices of products to currency in place at the rates in effect on date
+func renderProductPrices(c *gin.Context, s *storage.Storage, products []models.Product, currency string, date time.Time) bool {
+	converter := s.NewFXConverter(date)
+	for i := range products {
+		if !renderPrice(c, converter, &products[i], currency) {
+			return false
+		}
+	}
+	return true
+}
+
+// renderPrice converts the price of a product to currency in place, responding with an error if no rate is known
+func renderPrice(c *gin.Context, converter *storage.FXConverter, product *models.Product, currency string) bool {
+	if currency == "" {
+		return true
+	}
+	price, err := converter.Convert(c.Request.Context(), product.PriceMoney(), currency)
+	if err != nil {
+		writeMoneyError(c, err)
+		return false
+	}
+	product.SetPrice(price)
+	return true
+}
+
+// writeMoneyError maps currency conversion errors to HTTP responses
+func writeMoneyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, storage.ErrNoFXRate):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, storage.ErrInvalidFXRate), errors.Is(err, money.ErrUnknownCurrency), errors.Is(err, money.ErrInvalidAmount):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"product-tracker/config"
+	"product-tracker/models"
+	"product-tracker/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OrganizationRequest represents the organization request structure
+// @Description Organization name
+type OrganizationRequest struct {
+	Name string `json:"name" example:"Acme Facilities" binding:"required"`
+}
+
+// GetOrganizations godoc
+// @Summary      List organizations
+// @Description  Get every organization. Restricted to superadmins.
+// @Tags         organizations
+// @Produce      json
+// @Success      200  {array}   models.Organization
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /organizations [get]
+// @Security     BearerAuth
+func GetOrganizations(c *gin.Context) {
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, storage.AllTenants)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	organizations, err := storageInstance.GetOrganizations(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, organizations)
+}
+
+// GetOrganization godoc
+// @Summary      Get an organization
+// @Description  Get a single organization. Restricted to superadmins.
+// @Tags         organizations
+// @Produce      json
+// @Param        id   path      int  true  "Organization ID"
+// @Success      200  {object}  models.Organization
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /organizations/{id} [get]
+// @Security     BearerAuth
+func GetOrganization(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, storage.AllTenants)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	organization, err := storageInstance.GetOrganization(c.Request.Context(), id)
+	if err != nil {
+		writeOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, organization)
+}
+
+// CreateOrganization godoc
+// @Summary      Create an organization
+// @Description  Create an organization. Tokens with its ID in the tenant_id claim then work on its data only.
+// @Description  Names are unique, ignoring case. Restricted to superadmins.
+// @Tags         organizations
+// @Accept       json
+// @Produce      json
+// @Param        organization  body      OrganizationRequest  true  "Organization object"
+// @Success      201           {object}  models.Organization
+// @Failure      400           {object}  map[string]string
+// @Failure      401           {object}  map[string]string
+// @Failure      403           {object}  map[string]string
+// @Failure      409           {object}  map[string]string
+// @Failure      500           {object}  map[string]string
+// @Router       /organizations [post]
+// @Security     BearerAuth
+func CreateOrganization(c *gin.Context) {
+	var request OrganizationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, storage.AllTenants)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	organization := &models.Organization{Name: request.Name}
+	if err := storageInstance.CreateOrganization(c.Request.Context(), organization); err != nil {
+		writeOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, organization)
+}
+
+// RenameOrganization godoc
+// @Summary      Rename an organization
+// @Description  Change the name of an organization. Restricted to superadmins.
+// @Tags         organizations
+// @Accept       json
+// @Produce      json
+// @Param        id            path      int                  true  "Organization ID"
+// @Param        organization  body      OrganizationRequest  true  "Organization object"
+// @Success      200           {object}  models.Organization
+// @Failure      400           {object}  map[string]string
+// @Failure      401           {object}  map[string]string
+// @Failure      403           {object}  map[string]string
+// @Failure      404           {object}  map[string]string
+// @Failure      409           {object}  map[string]string
+// @Failure      500           {object}  map[string]string
+// @Router       /organizations/{id} [put]
+// @Security     BearerAuth
+func RenameOrganization(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var request OrganizationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, storage.AllTenants)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	organization := &models.Organization{ID: id, Name: request.Name}
+	if err := storageInstance.RenameOrganization(c.Request.Context(), organization); err != nil {
+		writeOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, organization)
+}
+
+// GetTenantAccessLog godoc
+// @Summary      List cross-tenant access
+// @Description  Get the audit log of requests superadmins made to other organizations than their own with the
+// @Description  X-Tenant-ID header, most recent first. Restricted to superadmins.
+// @Tags         organizations
+// @Produce      json
+// @Param        tenant_id  query     int  false  "Only list access to this organization"
+// @Param        limit      query     int  false  "Maximum number of entries, up to 1000 (default 100)"
+// @Success      200        {array}   models.TenantAccess
+// @Failure      400        {object}  map[string]string
+// @Failure      401        {object}  map[string]string
+// @Failure      403        {object}  map[string]string
+// @Failure      500        {object}  map[string]string
+// @Router       /organizations/access-log [get]
+// @Security     BearerAuth
+func GetTenantAccessLog(c *gin.Context) {
+	var tenantID *int64
+	if value := c.Query("tenant_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "tenant_id must be an organization ID"})
+			return
+		}
+		tenantID = &id
+	}
+	limit := storage.DefaultTenantAccessLimit
+	if value := c.Query("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > storage.MaxTenantAccessLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(storage.MaxTenantAccessLimit)})
+			return
+		}
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, storage.AllTenants)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	entries, err := storageInstance.GetTenantAccessLog(c.Request.Context(), tenantID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// writeOrganizationError maps storage errors of organization operations to HTTP responses
+func writeOrganizationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+	case errors.Is(err, storage.ErrInvalidOrganization):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, storage.ErrDuplicate):
+		c.JSON(http.StatusConflict, gin.H{"error": "An organization with this name already exists"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -1,10 +1,19 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"product-tracker/config"
+	"product-tracker/emissions"
 	"product-tracker/models"
+	"product-tracker/money"
+	"product-tracker/rating"
 	"product-tracker/storage"
+	"product-tracker/units"
+	"product-tracker/utils"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,23 +21,110 @@ import (
 // Product represents the product request/response structure
 // @Description Product information
 type Product struct {
-	Name              string  `json:"name" example:"Product A" binding:"required"`
-	Description       string  `json:"description" example:"Product description"`
-	Price             float64 `json:"price" example:"99.99" binding:"required,min=0"`
-	EnergyConsumption float64 `json:"energy_consumption" example:"50.5" binding:"required,min=0"`
+	Name              string   `json:"name" example:"Product A" binding:"required"`
+	Model             string   `json:"model" example:"X-200"`
+	Description       string   `json:"description" example:"Product description"`
+	Price             float64  `json:"price" example:"99.99" binding:"required,min=0"`
+	Currency          string   `json:"currency,omitempty" example:"EUR"`
+	EnergyConsumption float64  `json:"energy_consumption" example:"50.5" binding:"required,min=0"`
+	EnergyUnit        string   `json:"energy_unit,omitempty" example:"kWh/year"`
+	DutyCycle         *float64 `json:"duty_cycle,omitempty" example:"0.35"`
+	CyclesPerYear     *float64 `json:"cycles_per_year,omitempty" example:"220"`
+	CategoryID        *int64   `json:"category_id,omitempty" example:"3"`
+	Tags              []string `json:"tags,omitempty" example:"office-floor-2"`
+	Region            string   `json:"region,omitempty" example:"DE"`
+	// Attributes are numeric product properties such as capacities used by rating schemes
+	Attributes map[string]float64 `json:"attributes,omitempty" swaggertype:"object,number" example:"volume_l:380"`
+}
+
+// ToModel converts the request into a product, converting its price to minor units and its energy
+// consumption to kWh/year. An empty currency means defaultCurrency and an empty energy unit means kWh/year.
+// The grid region is normalized to upper case. It is shared with the gRPC API.
+func (p Product) ToModel(defaultCurrency string) (*models.Product, error) {
+	currency := p.Currency
+	if currency == "" {
+		currency = defaultCurrency
+	}
+	price, err := money.FromMajor(p.Price, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	input := &models.EnergyInput{
+		Value: p.EnergyConsumption,
+		Unit:  p.EnergyUnit,
+		Options: units.Options{
+			DutyCycle:     p.DutyCycle,
+			CyclesPerYear: p.CyclesPerYear,
+		},
+	}
+	if input.Unit == "" {
+		input.Unit = units.ProductCanonical
+	}
+
+	energy, err := input.Convert(units.ProductCanonical)
+	if err != nil {
+		return nil, err
+	}
+
+	var region *string
+	if p.Region != "" {
+		normalized, err := emissions.ParseRegion(p.Region)
+		if err != nil {
+			return nil, err
+		}
+		region = &normalized
+	}
+
+	product := &models.Product{
+		Name:              p.Name,
+		Model:             p.Model,
+		Description:       p.Description,
+		EnergyConsumption: energy,
+		EnergyInput:       input,
+		CategoryID:        p.CategoryID,
+		Tags:              p.Tags,
+		Region:            region,
+		Attributes:        p.Attributes,
+	}
+	product.SetPrice(price)
+	return product, nil
+}
+
+// ProductUpsertResult represents the response of an upsert on the product natural key
+// @Description Upserted product and whether it was created or updated
+type ProductUpsertResult struct {
+	Product Product `json:"product"`
+	Result  string  `json:"result" example:"created" enums:"created,updated"`
 }
 
 // ImportProduct godoc
 // @Summary      Import a new product
-// @Description  Import a single product with its details
+// @Description  Import a single product with its details. Send an Idempotency-Key header to make retries safe:
+// @Description  the first response is stored and replayed, and reusing the key with a different payload returns 422.
+// @Description  With upsert=true the product is matched on the configured natural key and updated if it already exists.
+// @Description  Updating an existing product honours If-Match as PUT does: a stale ETag returns 412, and a missing one 428 when products.require_if_match is set.
+// @Description  currency gives the ISO 4217 currency of price (default money.default_currency); prices are stored in minor units.
+// @Description  energy_unit gives the unit of energy_consumption (default kWh/year); the value is stored in kWh/year.
+// @Description  Power units such as W need duty_cycle and per-cycle units such as kWh/cycle need cycles_per_year.
+// @Description  region assigns the product to a grid region for emissions estimates, e.g. DE or US-CA.
+// @Description  attributes holds numeric properties such as volume_l; the product is rated under the rating scheme of its category.
 // @Tags         products
 // @Accept       json
 // @Produce      json
-// @Param        product  body      Product  true  "Product object"
-// @Success      201      {object}  Product
-// @Failure      400      {object}  map[string]string
-// @Failure      401      {object}  map[string]string
-// @Failure      500      {object}  map[string]string
+// @Param        product          body      Product  true   "Product object"
+// @Param        upsert           query     bool     false  "Update the product sharing the same natural key instead of inserting a duplicate"
+// @Param        If-Match         header    string   false  "ETag of the product version an upsert may update"
+// @Param        Idempotency-Key  header    string   false  "Client-generated key identifying this request"
+// @Success      200              {object}  ProductUpsertResult  "Existing product updated (upsert=true)"
+// @Success      201              {object}  Product              "Product created; a ProductUpsertResult is returned when upsert=true"
+// @Failure      400              {object}  map[string]string
+// @Failure      401              {object}  map[string]string
+// @Failure      409              {object}  map[string]string
+// @Failure      412              {object}  map[string]string
+// @Failure      422              {object}  map[string]string
+// @Failure      428              {object}  map[string]string
+// @Failure      500              {object}  map[string]string
 // @Router       /product/insert [post]
 // @Security     BearerAuth
 func ImportProduct(c *gin.Context) {
@@ -38,64 +134,280 @@ func ImportProduct(c *gin.Context) {
 		return
 	}
 
+	upsert := c.Query("upsert") == "true"
+
+	record, err := product.ToModel(config.GetConfig().Money.DefaultCurrency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	cfg := config.GetConfig()
-	storageInstance, err := storage.NewStorage(cfg)
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
 		return
 	}
 	defer storageInstance.Close()
 
-	if err := storageInstance.InsertProduct(c.Request.Context(), &models.Product{
-		Name:              product.Name,
-		Description:       product.Description,
-		Price:             product.Price,
-		EnergyConsumption: product.EnergyConsumption,
-	}); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	payload := struct {
+		Product Product `json:"product"`
+		Upsert  bool    `json:"upsert"`
+		IfMatch string  `json:"if_match,omitempty"`
+	}{product, upsert, c.GetHeader("If-Match")}
+
+	idempotent(c, storageInstance, payload, func() (int, any) {
+		ctx := actorContext(c)
+		if !upsert {
+			if err := storageInstance.InsertProduct(ctx, record); err != nil {
+				return productErrorResponse(err)
+			}
+			return http.StatusCreated, product
+		}
+
+		created, err := storageInstance.UpsertProduct(ctx, record, cfg.Products.NaturalKey, func(id int64) (int64, error) {
+			return ifMatchVersion(payload.IfMatch, id)
+		})
+		if err != nil {
+			return productErrorResponse(err)
+		}
+		if created {
+			return http.StatusCreated, ProductUpsertResult{Product: product, Result: "created"}
+		}
+		return http.StatusOK, ProductUpsertResult{Product: product, Result: "updated"}
+	})
+}
+
+// UpdateProduct godoc
+// @Summary      Update a product
+// @Description  Replace the details of an existing product. The previous values are kept in the product history.
+// @Description  Send the product ETag in If-Match to avoid overwriting a concurrent change; a stale ETag returns 412.
+// @Description  energy_unit, duty_cycle and cycles_per_year are handled as on insert.
+// @Tags         products
+// @Accept       json
+// @Produce      json
+// @Param        id        path      int      true   "Product ID"
+// @Param        If-Match  header    string   false  "ETag of the product version being updated"
+// @Param        product   body      Product  true   "Product object"
+// @Success      200       {object}  models.Product
+// @Header       200       {string}  ETag  "Entity tag of the updated product"
+// @Failure      400       {object}  map[string]string
+// @Failure      401       {object}  map[string]string
+// @Failure      404       {object}  map[string]string
+// @Failure      412       {object}  map[string]string
+// @Failure      428       {object}  map[string]string
+// @Failure      500       {object}  map[string]string
+// @Router       /product/{id} [put]
+// @Security     BearerAuth
+func UpdateProduct(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	version, ok := expectedVersion(c, id)
+	if !ok {
+		return
+	}
+
+	var product Product
+	if err := c.ShouldBindJSON(&product); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	record, err := product.ToModel(config.GetConfig().Money.DefaultCurrency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	record.ID = id
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	if err := storageInstance.UpdateProduct(actorContext(c), record, version); err != nil {
+		writeProductError(c, err)
+		return
+	}
+
+	c.Header("ETag", productETag(record))
+	c.JSON(http.StatusOK, record)
+}
+
+// GetProduct godoc
+// @Summary      Get a product
+// @Description  Get a single product by ID. With as_of the product is reconstructed from its history as it was at that time.
+// @Description  The current state carries an ETag; sending it back in If-None-Match returns 304 while the product is unchanged.
+// @Tags         products
+// @Produce      json
+// @Param        id             path      int     true   "Product ID"
+// @Param        as_of          query     string  false  "RFC 3339 timestamp to reconstruct the product at"
+// @Param        unit           query     string  false  "Unit to render energy_consumption in, e.g. kWh/month or W"
+// @Param        currency       query     string  false  "Currency to render the price in, e.g. EUR"
+// @Param        date           query     string  false  "Date of the exchange rate (YYYY-MM-DD, default as_of or today)"
+// @Param        If-None-Match  header    string  false  "ETag of a cached copy of the product"
+// @Success      200            {object}  models.Product
+// @Header       200            {string}  ETag  "Entity tag of the product"
+// @Success      304
+// @Failure      400            {object}  map[string]string
+// @Failure      401            {object}  map[string]string
+// @Failure      404            {object}  map[string]string
+// @Failure      422            {object}  map[string]string
+// @Failure      500            {object}  map[string]string
+// @Router       /product/{id} [get]
+// @Security     BearerAuth
+func GetProduct(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var asOf time.Time
+	if value := c.Query("as_of"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "as_of must be an RFC 3339 timestamp"})
+			return
+		}
+		asOf = parsed
+	}
+
+	unit, ok := energyUnitParam(c)
+	if !ok {
+		return
+	}
+
+	currency, date, ok := currencyParams(c)
+	if !ok {
+		return
+	}
+	if !asOf.IsZero() && c.Query("date") == "" {
+		date = asOf.UTC().Truncate(24 * time.Hour)
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
 		return
 	}
+	defer storageInstance.Close()
 
-	c.JSON(http.StatusCreated, product)
+	if !asOf.IsZero() {
+		product, err := storageInstance.GetProductAsOf(c.Request.Context(), id, asOf)
+		if err != nil {
+			writeProductError(c, err)
+			return
+		}
+		if !renderEnergy(c, product, unit) || !renderPrice(c, storageInstance.NewFXConverter(date), product, currency) {
+			return
+		}
+		c.JSON(http.StatusOK, product)
+		return
+	}
+
+	product, err := storageInstance.GetProduct(c.Request.Context(), id)
+	if err != nil {
+		writeProductError(c, err)
+		return
+	}
+
+	etag := renderedProductETag(product, unit, currency, date)
+	c.Header("ETag", etag)
+	if notModified(c, etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	if !renderEnergy(c, product, unit) || !renderPrice(c, storageInstance.NewFXConverter(date), product, currency) {
+		return
+	}
+
+	c.JSON(http.StatusOK, product)
 }
 
 // GetProducts godoc
 // @Summary      List all products
-// @Description  Get a list of all products in the system
+// @Description  Get a list of all products in the system. Products in the trash are excluded unless an admin sets include_deleted.
 // @Tags         products
 // @Produce      json
-// @Success      200  {array}   Product
-// @Failure      401  {object}  map[string]string
-// @Failure      500  {object}  map[string]string
+// @Param        include_deleted  query     bool    false  "Also return soft-deleted products (admin only)"
+// @Param        category         query     int     false  "Only return products in this category or its descendants"
+// @Param        tag              query     string  false  "Only return products carrying this tag"
+// @Param        rating           query     string  false  "Only return products rated in one of these comma-separated classes, e.g. A,B"
+// @Param        unit             query     string  false  "Unit to render energy_consumption in, e.g. kWh/month or W"
+// @Param        currency         query     string  false  "Currency to render prices in, e.g. EUR"
+// @Param        date             query     string  false  "Date of the exchange rates (YYYY-MM-DD, default today)"
+// @Success      200              {array}   models.Product
+// @Failure      400              {object}  map[string]string
+// @Failure      401              {object}  map[string]string
+// @Failure      403              {object}  map[string]string
+// @Failure      422              {object}  map[string]string
+// @Failure      500              {object}  map[string]string
 // @Router       /product/list [get]
 // @Security     BearerAuth
 func GetProducts(c *gin.Context) {
+	filter, ok := productFilter(c)
+	if !ok {
+		return
+	}
+
+	unit, ok := energyUnitParam(c)
+	if !ok {
+		return
+	}
+
+	currency, date, ok := currencyParams(c)
+	if !ok {
+		return
+	}
+
 	cfg := config.GetConfig()
-	storageInstance, err := storage.NewStorage(cfg)
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
 		return
 	}
 	defer storageInstance.Close()
 
-	products, err := storageInstance.GetProducts(c.Request.Context())
+	products, err := storageInstance.GetProducts(c.Request.Context(), filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	if !renderProductEnergy(c, products, unit) || !renderProductPrices(c, storageInstance, products, currency, date) {
+		return
+	}
+
 	c.JSON(http.StatusOK, products)
 }
 
 // GetProductsByName godoc
 // @Summary      Get products by name
-// @Description  Get a list of products filtered by name
+// @Description  Get a list of products filtered by name. Products in the trash are excluded unless an admin sets include_deleted.
 // @Tags         products
 // @Produce      json
-// @Param        name  path      string  true  "Product name"
-// @Success      200   {array}   Product
-// @Failure      401   {object}  map[string]string
-// @Failure      500   {object}  map[string]string
+// @Param        name             path      string  true   "Product name"
+// @Param        include_deleted  query     bool    false  "Also return soft-deleted products (admin only)"
+// @Param        category         query     int     false  "Only return products in this category or its descendants"
+// @Param        tag              query     string  false  "Only return products carrying this tag"
+// @Param        rating           query     string  false  "Only return products rated in one of these comma-separated classes, e.g. A,B"
+// @Param        unit             query     string  false  "Unit to render energy_consumption in, e.g. kWh/month or W"
+// @Param        currency         query     string  false  "Currency to render prices in, e.g. EUR"
+// @Param        date             query     string  false  "Date of the exchange rates (YYYY-MM-DD, default today)"
+// @Success      200              {array}   models.Product
+// @Failure      400              {object}  map[string]string
+// @Failure      401              {object}  map[string]string
+// @Failure      403              {object}  map[string]string
+// @Failure      422              {object}  map[string]string
+// @Failure      500              {object}  map[string]string
 // @Router       /product/list/{name} [get]
 // @Security     BearerAuth
 func GetProductsByName(c *gin.Context) {
@@ -105,19 +417,307 @@ func GetProductsByName(c *gin.Context) {
 		return
 	}
 
+	filter, ok := productFilter(c)
+	if !ok {
+		return
+	}
+
+	unit, ok := energyUnitParam(c)
+	if !ok {
+		return
+	}
+
+	currency, date, ok := currencyParams(c)
+	if !ok {
+		return
+	}
+
 	cfg := config.GetConfig()
-	storageInstance, err := storage.NewStorage(cfg)
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
 		return
 	}
 	defer storageInstance.Close()
 
-	products, err := storageInstance.GetProductsByName(c.Request.Context(), name)
+	products, err := storageInstance.GetProductsByName(c.Request.Context(), name, filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	if !renderProductEnergy(c, products, unit) || !renderProductPrices(c, storageInstance, products, currency, date) {
+		return
+	}
+
 	c.JSON(http.StatusOK, products)
 }
+
+// DeleteProduct godoc
+// @Summary      Delete a product
+// @Description  Move a product to the trash. It can be restored until it is purged after the retention period.
+// @Description  Readings linked to the product are hidden while it is in the trash.
+// @Tags         products
+// @Produce      json
+// @Param        id        path      int     true   "Product ID"
+// @Param        If-Match  header    string  false  "ETag of the product version being deleted"
+// @Success      204
+// @Failure      400       {object}  map[string]string
+// @Failure      401       {object}  map[string]string
+// @Failure      404       {object}  map[string]string
+// @Failure      412       {object}  map[string]string
+// @Failure      428       {object}  map[string]string
+// @Failure      500       {object}  map[string]string
+// @Router       /product/{id} [delete]
+// @Security     BearerAuth
+func DeleteProduct(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	version, ok := expectedVersion(c, id)
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	if err := storageInstance.DeleteProduct(actorContext(c), id, version); err != nil {
+		writeProductError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RestoreProduct godoc
+// @Summary      Restore a deleted product
+// @Description  Take a product out of the trash together with its readings
+// @Tags         products
+// @Produce      json
+// @Param        id        path      int     true   "Product ID"
+// @Param        If-Match  header    string  false  "ETag of the deleted product version being restored"
+// @Success      200       {object}  models.Product
+// @Header       200       {string}  ETag  "Entity tag of the restored product"
+// @Failure      400       {object}  map[string]string
+// @Failure      401       {object}  map[string]string
+// @Failure      404       {object}  map[string]string
+// @Failure      412       {object}  map[string]string
+// @Failure      428       {object}  map[string]string
+// @Failure      500       {object}  map[string]string
+// @Router       /product/{id}/restore [post]
+// @Security     BearerAuth
+func RestoreProduct(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	version, ok := expectedVersion(c, id)
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	if err := storageInstance.RestoreProduct(actorContext(c), id, version); err != nil {
+		writeProductError(c, err)
+		return
+	}
+
+	product, err := storageInstance.GetProduct(c.Request.Context(), id)
+	if err != nil {
+		writeProductError(c, err)
+		return
+	}
+
+	c.Header("ETag", productETag(product))
+	c.JSON(http.StatusOK, product)
+}
+
+// GetTrash godoc
+// @Summary      List deleted products
+// @Description  Get the products currently in the trash, most recently deleted first (admin only)
+// @Tags         products
+// @Produce      json
+// @Param        unit      query     string  false  "Unit to render energy_consumption in, e.g. kWh/month or W"
+// @Param        currency  query     string  false  "Currency to render prices in, e.g. EUR"
+// @Param        date      query     string  false  "Date of the exchange rates (YYYY-MM-DD, default today)"
+// @Success      200       {array}   models.Product
+// @Failure      400       {object}  map[string]string
+// @Failure      401       {object}  map[string]string
+// @Failure      403       {object}  map[string]string
+// @Failure      422       {object}  map[string]string
+// @Failure      500       {object}  map[string]string
+// @Router       /trash [get]
+// @Security     BearerAuth
+func GetTrash(c *gin.Context) {
+	unit, ok := energyUnitParam(c)
+	if !ok {
+		return
+	}
+
+	currency, date, ok := currencyParams(c)
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	products, err := storageInstance.GetDeletedProducts(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !renderProductEnergy(c, products, unit) || !renderProductPrices(c, storageInstance, products, currency, date) {
+		return
+	}
+
+	c.JSON(http.StatusOK, products)
+}
+
+// GetProductStats godoc
+// @Summary      Get reading statistics
+// @Description  Get totals and averages over the recorded readings. With group_by=category the statistics are
+// @Description  returned per product category, with uncategorized products grouped under a null category_id.
+// @Description  The overall statistics include product price totals converted to a single reporting currency.
+// @Tags         products
+// @Produce      json
+// @Param        group_by  query     string  false  "Grouping of the statistics"  Enums(category)
+// @Param        currency  query     string  false  "Reporting currency of the price totals (default money.reporting_currency)"
+// @Param        date      query     string  false  "Date of the exchange rates (YYYY-MM-DD, default today)"
+// @Success      200       {object}  map[string]interface{}  "Overall statistics, or an array of models.CategoryStats with group_by=category"
+// @Failure      400       {object}  map[string]string
+// @Failure      401       {object}  map[string]string
+// @Failure      422       {object}  map[string]string
+// @Failure      500       {object}  map[string]string
+// @Router       /product/stats [get]
+// @Security     BearerAuth
+func GetProductStats(c *gin.Context) {
+	groupBy := c.Query("group_by")
+	if groupBy != "" && groupBy != "category" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be category"})
+		return
+	}
+
+	currency, date, ok := currencyParams(c)
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	if currency == "" {
+		currency = cfg.Money.ReportingCurrency
+	}
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	if groupBy == "category" {
+		stats, err := storageInstance.GetProductStatsByCategory(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, stats)
+		return
+	}
+
+	stats, err := storageInstance.GetProductStats(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	prices, err := storageInstance.GetPriceStats(c.Request.Context(), currency, date)
+	if err != nil {
+		writeMoneyError(c, err)
+		return
+	}
+	stats["prices"] = prices
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// writeProductError maps storage errors of product operations to HTTP responses
+func writeProductError(c *gin.Context, err error) {
+	c.JSON(productErrorResponse(err))
+}
+
+// productErrorResponse returns the HTTP status and body for a storage error of a product operation
+func productErrorResponse(err error) (int, any) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return http.StatusNotFound, gin.H{"error": "Product not found"}
+	case errors.Is(err, storage.ErrVersionMismatch):
+		return http.StatusPreconditionFailed, gin.H{"error": "Product has been modified since the given ETag"}
+	case errors.Is(err, storage.ErrVersionRequired):
+		return http.StatusPreconditionRequired, gin.H{"error": "If-Match header is required"}
+	case errors.Is(err, storage.ErrInvalidCategory), errors.Is(err, storage.ErrInvalidTag),
+		errors.Is(err, rating.ErrInvalidAttribute):
+		return http.StatusBadRequest, gin.H{"error": err.Error()}
+	default:
+		return http.StatusInternalServerError, gin.H{"error": err.Error()}
+	}
+}
+
+// productFilter builds the listing filter from the query string, responding with an error if it is not allowed
+func productFilter(c *gin.Context) (storage.ProductFilter, bool) {
+	var filter storage.ProductFilter
+
+	if value := c.Query("include_deleted"); value != "" {
+		includeDeleted, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "include_deleted must be a boolean"})
+			return filter, false
+		}
+		if includeDeleted && !utils.HasRole(c.GetString("role"), utils.RoleAdmin) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "include_deleted is restricted to admins"})
+			return filter, false
+		}
+		filter.IncludeDeleted = includeDeleted
+	}
+
+	if value := c.Query("category"); value != "" {
+		categoryID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || categoryID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "category must be a category ID"})
+			return filter, false
+		}
+		filter.CategoryID = categoryID
+	}
+	filter.Tag = c.Query("tag")
+
+	if value := c.Query("rating"); value != "" {
+		for _, class := range strings.Split(value, ",") {
+			if class = strings.TrimSpace(class); class != "" {
+				filter.RatingClasses = append(filter.RatingClasses, class)
+			}
+		}
+	}
+
+	return filter, true
+}
@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"product-tracker/config"
+	"product-tracker/models"
+	"product-tracker/rating"
+	"product-tracker/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RatingSchemeRequest represents the rating scheme request structure
+// @Description Efficiency classes of a category, from most to least efficient, by energy index
+type RatingSchemeRequest struct {
+	CategoryID        int64                `json:"category_id" example:"3" binding:"required"`
+	Name              string               `json:"name" example:"Refrigerators 2024" binding:"required,max=255"`
+	CapacityAttribute string               `json:"capacity_attribute" example:"volume_l" binding:"required"`
+	Classes           []models.RatingClass `json:"classes" binding:"required,min=2"`
+}
+
+// toModel converts the request to a rating scheme
+func (r RatingSchemeRequest) toModel() *models.RatingScheme {
+	return &models.RatingScheme{
+		CategoryID:        r.CategoryID,
+		Name:              r.Name,
+		CapacityAttribute: r.CapacityAttribute,
+		Classes:           r.Classes,
+	}
+}
+
+// RatingSchemeResult represents the response of a rating scheme write
+// @Description Written rating scheme and the number of product ratings that changed as a result
+type RatingSchemeResult struct {
+	Scheme  models.RatingScheme `json:"scheme"`
+	Rerated int64               `json:"rerated" example:"42"`
+}
+
+// GetRatingSchemes godoc
+// @Summary      List rating schemes
+// @Description  Get the current version of every rating scheme
+// @Tags         ratings
+// @Produce      json
+// @Success      200  {array}   models.RatingScheme
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /rating-schemes [get]
+// @Security     BearerAuth
+func GetRatingSchemes(c *gin.Context) {
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	schemes, err := storageInstance.GetRatingSchemes(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, schemes)
+}
+
+// GetRatingScheme godoc
+// @Summary      Get a rating scheme
+// @Description  Get the current version of a rating scheme
+// @Tags         ratings
+// @Produce      json
+// @Param        id   path      int  true  "Rating scheme ID"
+// @Success      200  {object}  models.RatingScheme
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /rating-schemes/{id} [get]
+// @Security     BearerAuth
+func GetRatingScheme(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	scheme, err := storageInstance.GetRatingScheme(c.Request.Context(), id)
+	if err != nil {
+		writeRatingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, scheme)
+}
+
+// GetRatingSchemeVersion godoc
+// @Summary      Get a rating scheme version
+// @Description  Get a past or current version of a rating scheme, as referenced by product ratings
+// @Tags         ratings
+// @Produce      json
+// @Param        id       path      int  true  "Rating scheme ID"
+// @Param        version  path      int  true  "Scheme version"
+// @Success      200      {object}  models.RatingScheme
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /rating-schemes/{id}/versions/{version} [get]
+// @Security     BearerAuth
+func GetRatingSchemeVersion(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "version must be a positive integer"})
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	scheme, err := storageInstance.GetRatingSchemeVersion(c.Request.Context(), id, version)
+	if err != nil {
+		writeRatingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, scheme)
+}
+
+// CreateRatingScheme godoc
+// @Summary      Create a rating scheme
+// @Description  Create the rating scheme of a category and rate the products of the category and of its subcategories
+// @Description  without a scheme of their own. Every class but the last needs a max_index, increasing from class to class.
+// @Description  A category has at most one scheme. Admin only.
+// @Tags         ratings
+// @Accept       json
+// @Produce      json
+// @Param        scheme  body      RatingSchemeRequest  true  "Rating scheme object"
+// @Success      201     {object}  RatingSchemeResult
+// @Failure      400     {object}  map[string]string
+// @Failure      401     {object}  map[string]string
+// @Failure      403     {object}  map[string]string
+// @Failure      409     {object}  map[string]string
+// @Failure      500     {object}  map[string]string
+// @Router       /rating-schemes [post]
+// @Security     BearerAuth
+func CreateRatingScheme(c *gin.Context) {
+	var request RatingSchemeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	scheme := request.toModel()
+	rerated, err := storageInstance.CreateRatingScheme(c.Request.Context(), scheme)
+	if err != nil {
+		writeRatingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, RatingSchemeResult{Scheme: *scheme, Rerated: rerated})
+}
+
+// UpdateRatingScheme godoc
+// @Summary      Update a rating scheme
+// @Description  Replace the name, capacity attribute and classes of a rating scheme as a new version and rerate the products
+// @Description  it covers. category_id must match the scheme's category. Rerating does not change product versions. Admin only.
+// @Tags         ratings
+// @Accept       json
+// @Produce      json
+// @Param        id      path      int                  true  "Rating scheme ID"
+// @Param        scheme  body      RatingSchemeRequest  true  "Rating scheme object"
+// @Success      200     {object}  RatingSchemeResult
+// @Failure      400     {object}  map[string]string
+// @Failure      401     {object}  map[string]string
+// @Failure      403     {object}  map[string]string
+// @Failure      404     {object}  map[string]string
+// @Failure      500     {object}  map[string]string
+// @Router       /rating-schemes/{id} [put]
+// @Security     BearerAuth
+func UpdateRatingScheme(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var request RatingSchemeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	current, err := storageInstance.GetRatingScheme(c.Request.Context(), id)
+	if err != nil {
+		writeRatingError(c, err)
+		return
+	}
+	if current.CategoryID != request.CategoryID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The category of a rating scheme cannot change"})
+		return
+	}
+
+	scheme := request.toModel()
+	scheme.ID = id
+	rerated, err := storageInstance.UpdateRatingScheme(c.Request.Context(), scheme)
+	if err != nil {
+		writeRatingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, RatingSchemeResult{Scheme: *scheme, Rerated: rerated})
+}
+
+// DeleteRatingScheme godoc
+// @Summary      Delete a rating scheme
+// @Description  Delete a rating scheme and its versions. Its products fall back to the scheme of an ancestor category
+// @Description  or become unrated. Admin only.
+// @Tags         ratings
+// @Produce      json
+// @Param        id   path      int  true  "Rating scheme ID"
+// @Success      204
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /rating-schemes/{id} [delete]
+// @Security     BearerAuth
+func DeleteRatingScheme(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	if _, err := storageInstance.DeleteRatingScheme(c.Request.Context(), id); err != nil {
+		writeRatingError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetProductRating godoc
+// @Summary      Explain the rating of a product
+// @Description  Get the efficiency rating of a product with the energy consumption, capacity and scheme version it was computed from.
+// @Description  rating is null for products without a scheme or without a positive capacity attribute.
+// @Tags         products
+// @Produce      json
+// @Param        id   path      int  true  "Product ID"
+// @Success      200  {object}  models.RatingExplanation
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /product/{id}/rating [get]
+// @Security     BearerAuth
+func GetProductRating(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	explanation, err := storageInstance.ExplainProductRating(c.Request.Context(), id)
+	if err != nil {
+		writeProductError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, explanation)
+}
+
+// writeRatingError maps rating scheme errors to HTTP responses
+func writeRatingError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rating scheme not found"})
+	case errors.Is(err, storage.ErrDuplicate):
+		c.JSON(http.StatusConflict, gin.H{"error": "The category already has a rating scheme"})
+	case errors.Is(err, rating.ErrInvalidScheme), errors.Is(err, storage.ErrInvalidCategory):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
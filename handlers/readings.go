@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"product-tracker/config"
+	"product-tracker/costcalc"
+	"product-tracker/models"
+	"product-tracker/money"
+	"product-tracker/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readingExportColumns lists the columns a readings export may contain, in output order.
+// cost and currency are only available with a tariff schedule.
+var readingExportColumns = []string{
+	"id", "product_id", "name", "quantity", "date", "recorded_at", "interval_seconds",
+	"energy_consumed", "energy_unit", "cost", "currency",
+}
+
+// defaultReadingExportColumns are exported when no columns are requested
+var defaultReadingExportColumns = readingExportColumns[:9]
+
+// GetReadings godoc
+// @Summary      List readings
+// @Description  Get the energy readings recorded between two dates. Readings of products in the trash are hidden.
+// @Tags         readings
+// @Produce      json
+// @Param        start       query     string  true   "Start date (YYYY-MM-DD)"
+// @Param        end         query     string  true   "End date (YYYY-MM-DD)"
+// @Param        product_id  query     int     false  "Only return the readings of this product"
+// @Param        unit        query     string  false  "Unit to render energy_consumed in, e.g. Wh or MJ"
+// @Success      200         {array}   models.Reading
+// @Failure      400         {object}  map[string]string
+// @Failure      401         {object}  map[string]string
+// @Failure      500         {object}  map[string]string
+// @Router       /readings/list [get]
+// @Security     BearerAuth
+func GetReadings(c *gin.Context) {
+	filter, ok := readingFilter(c)
+	if !ok {
+		return
+	}
+
+	unit, ok := energyUnitParam(c)
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	readings, err := storageInstance.GetReadings(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !renderReadingEnergy(c, readings, unit) {
+		return
+	}
+
+	c.JSON(http.StatusOK, readings)
+}
+
+// GetReadingsCost godoc
+// @Summary      Price readings under a time-of-use tariff
+// @Description  Apply a tariff schedule to the readings recorded between two dates and return the cost per band and per period.
+// @Description  Interval readings are split at band boundaries in the schedule's timezone; readings without recorded_at are
+// @Description  spread evenly over their local day. Periods are local days or months.
+// @Tags         readings
+// @Produce      json
+// @Param        schedule    query     int     true   "Tariff schedule ID"
+// @Param        start       query     string  true   "Start date (YYYY-MM-DD)"
+// @Param        end         query     string  true   "End date (YYYY-MM-DD)"
+// @Param        product_id  query     int     false  "Only price the readings of this product"
+// @Param        period      query     string  false  "Period to break costs down by: day (default) or month"
+// @Success      200         {object}  costcalc.TOUCost
+// @Failure      400         {object}  map[string]string
+// @Failure      401         {object}  map[string]string
+// @Failure      404         {object}  map[string]string
+// @Failure      422         {object}  map[string]string
+// @Failure      500         {object}  map[string]string
+// @Router       /readings/cost [get]
+// @Security     BearerAuth
+func GetReadingsCost(c *gin.Context) {
+	filter, ok := readingFilter(c)
+	if !ok {
+		return
+	}
+	scheduleID, ok := scheduleParam(c)
+	if !ok {
+		return
+	}
+	if scheduleID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "schedule is required"})
+		return
+	}
+	granularity, err := costcalc.ParseGranularity(c.Query("period"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	schedule, ok := loadSchedule(c, storageInstance, scheduleID)
+	if !ok {
+		return
+	}
+
+	readings, err := storageInstance.GetReadings(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	intervals := make([]costcalc.Interval, 0, len(readings))
+	for _, r := range readings {
+		interval, err := schedule.ReadingInterval(r)
+		if err != nil {
+			writeScheduleError(c, err)
+			return
+		}
+		intervals = append(intervals, interval)
+	}
+
+	cost, err := schedule.Apply(intervals, granularity)
+	if err != nil {
+		writeScheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, cost)
+}
+
+// ExportReadings godoc
+// @Summary      Export readings as CSV
+// @Description  Export the readings recorded between two dates as CSV. columns selects and orders the columns from
+// @Description  id, product_id, name, quantity, date, recorded_at, interval_seconds, energy_consumed, energy_unit, cost and currency.
+// @Description  cost and currency price each reading under the tariff schedule given in schedule.
+// @Tags         readings
+// @Produce      text/csv
+// @Param        start       query     string  true   "Start date (YYYY-MM-DD)"
+// @Param        end         query     string  true   "End date (YYYY-MM-DD)"
+// @Param        product_id  query     int     false  "Only export the readings of this product"
+// @Param        columns     query     string  false  "Comma-separated columns (default: every column except cost and currency)"
+// @Param        schedule    query     int     false  "Tariff schedule ID, required for the cost column"
+// @Param        unit        query     string  false  "Unit to render energy_consumed in, e.g. Wh or MJ"
+// @Success      200         {string}  string  "CSV file"
+// @Failure      400         {object}  map[string]string
+// @Failure      401         {object}  map[string]string
+// @Failure      404         {object}  map[string]string
+// @Failure      422         {object}  map[string]string
+// @Failure      500         {object}  map[string]string
+// @Router       /readings/export [get]
+// @Security     BearerAuth
+func ExportReadings(c *gin.Context) {
+	filter, ok := readingFilter(c)
+	if !ok {
+		return
+	}
+	columns, ok := readingExportColumnsParam(c)
+	if !ok {
+		return
+	}
+	scheduleID, ok := scheduleParam(c)
+	if !ok {
+		return
+	}
+	withCost := containsAny(columns, "cost", "currency")
+	if withCost && scheduleID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "schedule is required for the cost and currency columns"})
+		return
+	}
+	unit, ok := energyUnitParam(c)
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	var schedule *costcalc.Schedule
+	if withCost {
+		if schedule, ok = loadSchedule(c, storageInstance, scheduleID); !ok {
+			return
+		}
+	}
+
+	readings, err := storageInstance.GetReadings(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Costs are computed from the stored kWh before the energy is rendered in another unit
+	costs := make([]string, len(readings))
+	if schedule != nil {
+		for i, r := range readings {
+			interval, err := schedule.ReadingInterval(r)
+			if err != nil {
+				writeScheduleError(c, err)
+				return
+			}
+			cost, err := schedule.Cost(interval)
+			if err != nil {
+				writeScheduleError(c, err)
+				return
+			}
+			costs[i] = strconv.FormatFloat(cost.Major(), 'f', money.Exponent(cost.Currency), 64)
+		}
+	}
+	if !renderReadingEnergy(c, readings, unit) {
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=readings_%s_%s.csv", filter.Start, filter.End))
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write(columns)
+	for i, r := range readings {
+		record := make([]string, len(columns))
+		for j, column := range columns {
+			record[j] = readingExportField(r, column, costs[i], schedule)
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+}
+
+// readingExportField formats one column of a reading for export
+func readingExportField(r models.Reading, column, cost string, schedule *costcalc.Schedule) string {
+	switch column {
+	case "id":
+		return strconv.FormatInt(r.ID, 10)
+	case "product_id":
+		if r.ProductID == nil {
+			return ""
+		}
+		return strconv.FormatInt(*r.ProductID, 10)
+	case "name":
+		return r.Name
+	case "quantity":
+		return strconv.Itoa(r.Quantity)
+	case "date":
+		return r.Date
+	case "recorded_at":
+		if r.RecordedAt == nil {
+			return ""
+		}
+		return r.RecordedAt.UTC().Format(time.RFC3339)
+	case "interval_seconds":
+		if r.IntervalSeconds == nil {
+			return ""
+		}
+		return strconv.Itoa(*r.IntervalSeconds)
+	case "energy_consumed":
+		return strconv.FormatFloat(r.EnergyConsumed, 'f', -1, 64)
+	case "energy_unit":
+		return r.EnergyUnit
+	case "cost":
+		return cost
+	case "currency":
+		if schedule == nil {
+			return ""
+		}
+		return schedule.Currency
+	default:
+		return ""
+	}
+}
+
+// readingFilter builds a reading filter from the start, end and product_id query parameters,
+// responding with 400 if any is invalid
+func readingFilter(c *gin.Context) (storage.ReadingFilter, bool) {
+	filter := storage.ReadingFilter{Start: c.Query("start"), End: c.Query("end")}
+	if _, err := time.Parse(models.ReadingDateLayout, filter.Start); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start must be a date in YYYY-MM-DD format"})
+		return filter, false
+	}
+	if _, err := time.Parse(models.ReadingDateLayout, filter.End); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end must be a date in YYYY-MM-DD format"})
+		return filter, false
+	}
+	if value := c.Query("product_id"); value != "" {
+		productID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || productID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "product_id must be a product ID"})
+			return filter, false
+		}
+		filter.ProductID = &productID
+	}
+	return filter, true
+}
+
+// readingExportColumnsParam parses the ?columns= query parameter, responding with 400 on unknown columns
+func readingExportColumnsParam(c *gin.Context) ([]string, bool) {
+	value := c.Query("columns")
+	if value == "" {
+		return defaultReadingExportColumns, true
+	}
+
+	var columns []string
+	for _, column := range strings.Split(value, ",") {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if !containsAny(readingExportColumns, column) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("unknown column %q: supported columns are %s", column, strings.Join(readingExportColumns, ", ")),
+			})
+			return nil, false
+		}
+		columns = append(columns, column)
+	}
+	return columns, true
+}
+
+// containsAny reports whether values contains any of the wanted strings
+func containsAny(values []string, wanted ...string) bool {
+	for _, value := range values {
+		for _, w := range wanted {
+			if value == w {
+				return true
+			}
+		}
+	}
+	return false
+}
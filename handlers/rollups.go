@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"product-tracker/config"
+	"product-tracker/models"
+	"product-tracker/rollups"
+	"product-tracker/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetReadingsAggregate godoc
+// @Summary      Aggregate readings per day, week or month
+// @Description  Get reading totals per local day, ISO week or calendar month from the rollups maintained on ingestion.
+// @Description  Every period overlapping start to end is returned whole. Interval readings count towards the local day of
+// @Description  recorded_at, other readings towards their date. tz must be one of the configured rollup timezones and
+// @Description  defaults to the first. Periods without readings are left out unless fill is zero or null.
+// @Description  Readings of products in the trash are excluded.
+// @Tags         readings
+// @Produce      json
+// @Param        interval    query     string  true   "Period length"  Enums(day, week, month)
+// @Param        tz          query     string  false  "IANA timezone of the periods, e.g. Europe/Berlin"
+// @Param        start       query     string  true   "Start date (YYYY-MM-DD)"
+// @Param        end         query     string  true   "End date (YYYY-MM-DD)"
+// @Param        product_id  query     int     false  "Only aggregate the readings of this product"
+// @Param        fill        query     string  false  "Report periods without readings with zero or null totals"  Enums(zero, null)
+// @Success      200         {object}  models.ReadingAggregate
+// @Failure      400         {object}  map[string]string
+// @Failure      401         {object}  map[string]string
+// @Failure      500         {object}  map[string]string
+// @Router       /readings/aggregate [get]
+// @Security     BearerAuth
+func GetReadingsAggregate(c *gin.Context) {
+	interval, err := rollups.ParseInterval(c.Query("interval"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	fill, err := rollups.ParseFill(c.Query("fill"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	readings, ok := readingFilter(c)
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	timezone := c.DefaultQuery("tz", cfg.Rollups.Timezones[0])
+	if err := rollups.CheckTimezone(timezone, cfg.Rollups.Timezones); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// readingFilter has validated both dates
+	start, _ := time.Parse(models.ReadingDateLayout, readings.Start)
+	end, _ := time.Parse(models.ReadingDateLayout, readings.End)
+	if end.Before(start) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end must not be before start"})
+		return
+	}
+	filter := storage.RollupFilter{
+		Timezone:  timezone,
+		Interval:  interval,
+		Start:     interval.Truncate(start),
+		End:       end,
+		ProductID: readings.ProductID,
+	}
+
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	periods, err := storageInstance.GetReadingAggregates(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	periods, err = rollups.FillPeriods(periods, interval, filter.Start, filter.End, fill)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ReadingAggregate{
+		Interval:  string(interval),
+		Timezone:  timezone,
+		Start:     filter.Start.Format(models.ReadingDateLayout),
+		End:       readings.End,
+		ProductID: readings.ProductID,
+		Periods:   periods,
+	})
+}
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"product-tracker/config"
+	"product-tracker/costcalc"
+	"product-tracker/models"
+	"product-tracker/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TariffScheduleRequest represents the time-of-use tariff schedule request structure
+// @Description Time-of-use tariff: bands by weekday and local time, plus holidays priced like another weekday
+type TariffScheduleRequest struct {
+	Name     string                 `json:"name" example:"Peak / off-peak" binding:"required,max=255"`
+	Currency string                 `json:"currency" example:"EUR" binding:"required"`
+	Timezone string                 `json:"timezone" example:"Europe/Berlin" binding:"required"`
+	Bands    []models.TariffBand    `json:"bands" binding:"required,min=1"`
+	Holidays []models.TariffHoliday `json:"holidays,omitempty"`
+}
+
+// toModel converts the request to a tariff schedule
+func (r TariffScheduleRequest) toModel() *models.TariffSchedule {
+	return &models.TariffSchedule{
+		Name:     r.Name,
+		Currency: r.Currency,
+		Timezone: r.Timezone,
+		Bands:    r.Bands,
+		Holidays: r.Holidays,
+	}
+}
+
+// GetTariffSchedules godoc
+// @Summary      List tariff schedules
+// @Description  Get every time-of-use tariff schedule
+// @Tags         tariffs
+// @Produce      json
+// @Success      200  {array}   models.TariffSchedule
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /tariff-schedules [get]
+// @Security     BearerAuth
+func GetTariffSchedules(c *gin.Context) {
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	schedules, err := storageInstance.GetTariffSchedules(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedules)
+}
+
+// GetTariffSchedule godoc
+// @Summary      Get a tariff schedule
+// @Description  Get a single time-of-use tariff schedule
+// @Tags         tariffs
+// @Produce      json
+// @Param        id   path      int  true  "Tariff schedule ID"
+// @Success      200  {object}  models.TariffSchedule
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /tariff-schedules/{id} [get]
+// @Security     BearerAuth
+func GetTariffSchedule(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	schedule, err := storageInstance.GetTariffSchedule(c.Request.Context(), id)
+	if err != nil {
+		writeScheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// CreateTariffSchedule godoc
+// @Summary      Create a tariff schedule
+// @Description  Create a time-of-use tariff schedule. Bands must cover every minute of the week exactly once. Superadmin only, as the data is shared by every organization.
+// @Tags         tariffs
+// @Accept       json
+// @Produce      json
+// @Param        schedule  body      TariffScheduleRequest  true  "Tariff schedule object"
+// @Success      201       {object}  models.TariffSchedule
+// @Failure      400       {object}  map[string]string
+// @Failure      401       {object}  map[string]string
+// @Failure      403       {object}  map[string]string
+// @Failure      409       {object}  map[string]string
+// @Failure      500       {object}  map[string]string
+// @Router       /tariff-schedules [post]
+// @Security     BearerAuth
+func CreateTariffSchedule(c *gin.Context) {
+	var request TariffScheduleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	schedule := request.toModel()
+	if err := storageInstance.CreateTariffSchedule(c.Request.Context(), schedule); err != nil {
+		writeScheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, schedule)
+}
+
+// UpdateTariffSchedule godoc
+// @Summary      Update a tariff schedule
+// @Description  Replace a time-of-use tariff schedule. Superadmin only, as the data is shared by every organization.
+// @Tags         tariffs
+// @Accept       json
+// @Produce      json
+// @Param        id        path      int                    true  "Tariff schedule ID"
+// @Param        schedule  body      TariffScheduleRequest  true  "Tariff schedule object"
+// @Success      200       {object}  models.TariffSchedule
+// @Failure      400       {object}  map[string]string
+// @Failure      401       {object}  map[string]string
+// @Failure      403       {object}  map[string]string
+// @Failure      404       {object}  map[string]string
+// @Failure      409       {object}  map[string]string
+// @Failure      500       {object}  map[string]string
+// @Router       /tariff-schedules/{id} [put]
+// @Security     BearerAuth
+func UpdateTariffSchedule(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var request TariffScheduleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	schedule := request.toModel()
+	schedule.ID = id
+	if err := storageInstance.UpdateTariffSchedule(c.Request.Context(), schedule); err != nil {
+		writeScheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// DeleteTariffSchedule godoc
+// @Summary      Delete a tariff schedule
+// @Description  Delete a time-of-use tariff schedule. Superadmin only, as the data is shared by every organization.
+// @Tags         tariffs
+// @Produce      json
+// @Param        id   path      int  true  "Tariff schedule ID"
+// @Success      204
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /tariff-schedules/{id} [delete]
+// @Security     BearerAuth
+func DeleteTariffSchedule(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	if err := storageInstance.DeleteTariffSchedule(c.Request.Context(), id); err != nil {
+		writeScheduleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// scheduleParam parses the ?schedule= query parameter, responding with 400 if it is invalid.
+// It returns 0 when the parameter is missing.
+func scheduleParam(c *gin.Context) (int64, bool) {
+	value := c.Query("schedule")
+	if value == "" {
+		return 0, true
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "schedule must be a tariff schedule ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// loadSchedule fetches and parses a tariff schedule, responding with an error if that fails
+func loadSchedule(c *gin.Context, s *storage.Storage, id int64) (*costcalc.Schedule, bool) {
+	stored, err := s.GetTariffSchedule(c.Request.Context(), id)
+	if err != nil {
+		writeScheduleError(c, err)
+		return nil, false
+	}
+	schedule, err := costcalc.ScheduleFromModel(stored)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return schedule, true
+}
+
+// writeScheduleError maps errors of tariff schedule operations to HTTP responses
+func writeScheduleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tariff schedule not found"})
+	case errors.Is(err, storage.ErrDuplicate):
+		c.JSON(http.StatusConflict, gin.H{"error": "A tariff schedule with this name already exists"})
+	case errors.Is(err, costcalc.ErrInvalidSchedule):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, costcalc.ErrInvalidInterval):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
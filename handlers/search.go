@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"product-tracker/config"
+	"product-tracker/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultSearchLimit is the number of search results returned when no limit is given
+const defaultSearchLimit = 20
+
+// SearchProducts godoc
+// @Summary      Search products
+// @Description  Full-text search over product names and descriptions, ranked with name matches weighing more than
+// @Description  description matches. Names similar to q despite typos also match, below search.min_similarity
+// @Description  trigram word similarity they do not. q accepts quoted phrases, "or" and a leading "-" to exclude a word.
+// @Description  Each result carries its name and a snippet of its description, HTML-escaped with the matched words
+// @Description  wrapped in <mark> tags. Products in the trash are excluded unless an admin sets include_deleted.
+// @Tags         products
+// @Produce      json
+// @Param        q                query     string  true   "Search text"
+// @Param        limit            query     int     false  "Maximum number of results, up to search.max_results (default 20)"
+// @Param        include_deleted  query     bool    false  "Also return soft-deleted products (admin only)"
+// @Param        category         query     int     false  "Only return products in this category or its descendants"
+// @Param        tag              query     string  false  "Only return products carrying this tag"
+// @Param        rating           query     string  false  "Only return products rated in one of these comma-separated classes, e.g. A,B"
+// @Param        unit             query     string  false  "Unit to render energy_consumption in, e.g. kWh/month or W"
+// @Param        currency         query     string  false  "Currency to render prices in, e.g. EUR"
+// @Param        date             query     string  false  "Date of the exchange rates (YYYY-MM-DD, default today)"
+// @Success      200              {array}   models.SearchResult
+// @Failure      400              {object}  map[string]string
+// @Failure      401              {object}  map[string]string
+// @Failure      403              {object}  map[string]string
+// @Failure      422              {object}  map[string]string
+// @Failure      500              {object}  map[string]string
+// @Router       /search [get]
+// @Security     BearerAuth
+func SearchProducts(c *gin.Context) {
+	text := strings.TrimSpace(c.Query("q"))
+	if text == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q parameter is required"})
+		return
+	}
+
+	cfg := config.GetConfig()
+	limit := min(defaultSearchLimit, cfg.Search.MaxResults)
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > cfg.Search.MaxResults {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(cfg.Search.MaxResults)})
+			return
+		}
+		limit = parsed
+	}
+
+	filter, ok := productFilter(c)
+	if !ok {
+		return
+	}
+
+	unit, ok := energyUnitParam(c)
+	if !ok {
+		return
+	}
+
+	currency, date, ok := currencyParams(c)
+	if !ok {
+		return
+	}
+
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	results, err := storageInstance.SearchProducts(c.Request.Context(), storage.SearchQuery{
+		Text:          text,
+		Filter:        filter,
+		MinSimilarity: cfg.Search.MinSimilarity,
+		Limit:         limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	converter := storageInstance.NewFXConverter(date)
+	for i := range results {
+		if !renderEnergy(c, &results[i].Product, unit) || !renderPrice(c, converter, &results[i].Product, currency) {
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, results)
+}
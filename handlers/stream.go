@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"product-tracker/config"
+	"product-tracker/storage"
+	"product-tracker/stream"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// StreamEvents godoc
+// @Summary      Stream live changes
+// @Description  Push readings.ingested, product.created, product.updated and product.deleted events as Server-Sent Events.
+// @Description  Reading batches are sent as arrays of readings, product events as the product. Each event ID can be sent back
+// @Description  in the Last-Event-ID header when reconnecting to receive the events missed meanwhile; a resync event is sent
+// @Description  first when they are no longer available, after which the client should reload what it displays.
+// @Tags         stream
+// @Produce      text/event-stream
+// @Param        product_id     query     string  false  "Only stream events of these products (comma-separated IDs)"
+// @Param        category       query     int     false  "Only stream events of products in this category or its subcategories"
+// @Param        Last-Event-ID  header    string  false  "ID of the last event received, to resume after it"
+// @Param        last_event_id  query     string  false  "Same as the Last-Event-ID header, for clients that cannot set headers"
+// @Success      200            {string}  string  "Event stream"
+// @Failure      400            {object}  map[string]string
+// @Failure      401            {object}  map[string]string
+// @Failure      500            {object}  map[string]string
+// @Failure      503            {object}  map[string]string
+// @Router       /stream [get]
+// @Security     BearerAuth
+func StreamEvents(c *gin.Context) {
+	filter := stream.Filter{TenantID: c.GetInt64("tenantID")}
+	if value := c.Query("product_id"); value != "" {
+		filter.ProductIDs = make(map[int64]bool)
+		for _, part := range strings.Split(value, ",") {
+			productID, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+			if err != nil || productID <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "product_id must be a comma-separated list of product IDs"})
+				return
+			}
+			filter.ProductIDs[productID] = true
+		}
+	}
+	if value := c.Query("category"); value != "" {
+		categoryID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || categoryID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "category must be a category ID"})
+			return
+		}
+
+		cfg := config.GetConfig()
+		storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+			return
+		}
+		ids, err := storageInstance.CategoryTreeIDs(c.Request.Context(), categoryID)
+		// The connection is not needed while streaming
+		storageInstance.Close()
+		if errors.Is(err, storage.ErrInvalidCategory) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "category does not exist"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		filter.CategoryIDs = make(map[int64]bool, len(ids))
+		for _, id := range ids {
+			filter.CategoryIDs[id] = true
+		}
+	}
+
+	hub := stream.Default()
+	if hub == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Event stream is not available"})
+		return
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	client, backlog, complete := hub.Subscribe(lastEventID)
+	defer hub.Unsubscribe(client)
+
+	// The stream outlives the server's write timeout
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	if !complete {
+		writeStreamEvent(c, filter, stream.Event{Type: stream.EventResync})
+	}
+	for _, event := range backlog {
+		writeStreamEvent(c, filter, event)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(config.GetConfig().Stream.Heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-client.Done():
+			// Dropped for falling behind; the client reconnects and resumes from the replay buffer
+			return
+		case event := <-client.Events():
+			writeStreamEvent(c, filter, event)
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+// writeStreamEvent sends the part of an event the filter keeps, if any
+func writeStreamEvent(c *gin.Context, filter stream.Filter, event stream.Event) {
+	data, ok := filter.Apply(event)
+	if !ok {
+		return
+	}
+	c.Render(-1, sse.Event{Id: event.ID, Event: event.Type, Data: data})
+}
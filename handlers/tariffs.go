@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"product-tracker/config"
+	"product-tracker/costcalc"
+	"product-tracker/models"
+	"product-tracker/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TariffRequest represents the tariff request structure
+// @Description Tariff with the rates that apply over consecutive date ranges. Prices are in major units of the currency.
+type TariffRequest struct {
+	Name     string              `json:"name" example:"Standard variable" binding:"required,max=255"`
+	Currency string              `json:"currency" example:"EUR" binding:"required"`
+	Rates    []TariffRateRequest `json:"rates" binding:"required,min=1,dive"`
+}
+
+// TariffRateRequest represents a rate in a tariff request
+// @Description Flat price per kWh and daily standing charge; effective_to is inclusive and may be omitted for an open-ended rate
+type TariffRateRequest struct {
+	UnitRate       string  `json:"unit_rate" example:"0.2834" binding:"required"`
+	StandingCharge string  `json:"standing_charge,omitempty" example:"0.4512"`
+	EffectiveFrom  string  `json:"effective_from" example:"2024-01-01" binding:"required"`
+	EffectiveTo    *string `json:"effective_to,omitempty" example:"2024-12-31"`
+}
+
+// toModel converts the request to a tariff
+func (r TariffRequest) toModel() *models.Tariff {
+	tariff := &models.Tariff{Name: r.Name, Currency: r.Currency}
+	for _, rate := range r.Rates {
+		tariff.Rates = append(tariff.Rates, models.TariffRate{
+			UnitRate:       rate.UnitRate,
+			StandingCharge: rate.StandingCharge,
+			EffectiveFrom:  rate.EffectiveFrom,
+			EffectiveTo:    rate.EffectiveTo,
+		})
+	}
+	return tariff
+}
+
+// GetTariffs godoc
+// @Summary      List tariffs
+// @Description  Get every tariff with its rates
+// @Tags         tariffs
+// @Produce      json
+// @Success      200  {array}   models.Tariff
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /tariffs [get]
+// @Security     BearerAuth
+func GetTariffs(c *gin.Context) {
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	tariffs, err := storageInstance.GetTariffs(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tariffs)
+}
+
+// GetTariff godoc
+// @Summary      Get a tariff
+// @Description  Get a single tariff with its rates
+// @Tags         tariffs
+// @Produce      json
+// @Param        id   path      int  true  "Tariff ID"
+// @Success      200  {object}  models.Tariff
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /tariffs/{id} [get]
+// @Security     BearerAuth
+func GetTariff(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	tariff, err := storageInstance.GetTariff(c.Request.Context(), id)
+	if err != nil {
+		writeTariffError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tariff)
+}
+
+// CreateTariff godoc
+// @Summary      Create a tariff
+// @Description  Create a tariff. Rates must not overlap. Prices are stored with up to 6 decimal places. Superadmin only, as the data is shared by every organization.
+// @Tags         tariffs
+// @Accept       json
+// @Produce      json
+// @Param        tariff  body      TariffRequest  true  "Tariff object"
+// @Success      201     {object}  models.Tariff
+// @Failure      400     {object}  map[string]string
+// @Failure      401     {object}  map[string]string
+// @Failure      403     {object}  map[string]string
+// @Failure      409     {object}  map[string]string
+// @Failure      500     {object}  map[string]string
+// @Router       /tariffs [post]
+// @Security     BearerAuth
+func CreateTariff(c *gin.Context) {
+	var request TariffRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	tariff := request.toModel()
+	if err := storageInstance.CreateTariff(c.Request.Context(), tariff); err != nil {
+		writeTariffError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, tariff)
+}
+
+// UpdateTariff godoc
+// @Summary      Update a tariff
+// @Description  Replace the name, currency and rates of a tariff. Superadmin only, as the data is shared by every organization.
+// @Tags         tariffs
+// @Accept       json
+// @Produce      json
+// @Param        id      path      int            true  "Tariff ID"
+// @Param        tariff  body      TariffRequest  true  "Tariff object"
+// @Success      200     {object}  models.Tariff
+// @Failure      400     {object}  map[string]string
+// @Failure      401     {object}  map[string]string
+// @Failure      403     {object}  map[string]string
+// @Failure      404     {object}  map[string]string
+// @Failure      409     {object}  map[string]string
+// @Failure      500     {object}  map[string]string
+// @Router       /tariffs/{id} [put]
+// @Security     BearerAuth
+func UpdateTariff(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var request TariffRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	tariff := request.toModel()
+	tariff.ID = id
+	if err := storageInstance.UpdateTariff(c.Request.Context(), tariff); err != nil {
+		writeTariffError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tariff)
+}
+
+// DeleteTariff godoc
+// @Summary      Delete a tariff
+// @Description  Delete a tariff and its rates. Tariffs used by a cost budget cannot be deleted. Superadmin only, as the data is shared by every organization.
+// @Tags         tariffs
+// @Produce      json
+// @Param        id   path      int  true  "Tariff ID"
+// @Success      204
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /tariffs/{id} [delete]
+// @Security     BearerAuth
+func DeleteTariff(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	if err := storageInstance.DeleteTariff(c.Request.Context(), id); err != nil {
+		writeTariffError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetProductCost godoc
+// @Summary      Project the running cost of a product
+// @Description  Project the energy cost of a product under a tariff, broken down by year. Energy is spread evenly over each year
+// @Description  and priced at the rate in effect on each day; years running past the last rate reuse it and are marked extrapolated.
+// @Description  usage_hours scales the stored consumption from the product's duty cycle, or from continuous use if it has none.
+// @Tags         products
+// @Produce      json
+// @Param        id           path      int     true   "Product ID"
+// @Param        tariff       query     int     true   "Tariff ID"
+// @Param        years        query     int     false  "Number of years to project (1-50, default 1)"
+// @Param        usage_hours  query     number  false  "Hours of use per day (0-24)"
+// @Param        start        query     string  false  "First day of the projection (YYYY-MM-DD, default today)"
+// @Success      200          {object}  costcalc.Projection
+// @Failure      400          {object}  map[string]string
+// @Failure      401          {object}  map[string]string
+// @Failure      404          {object}  map[string]string
+// @Failure      422          {object}  map[string]string
+// @Failure      500          {object}  map[string]string
+// @Router       /product/{id}/cost [get]
+// @Security     BearerAuth
+func GetProductCost(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	tariffID, err := strconv.ParseInt(c.Query("tariff"), 10, 64)
+	if err != nil || tariffID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tariff must be a tariff ID"})
+		return
+	}
+
+	usage := costcalc.Usage{Years: 1, Start: time.Now().UTC()}
+	if value := c.Query("years"); value != "" {
+		if usage.Years, err = strconv.Atoi(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "years must be an integer"})
+			return
+		}
+	}
+	if value := c.Query("start"); value != "" {
+		if usage.Start, err = time.Parse(models.TariffDateLayout, value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start must be a date in YYYY-MM-DD format"})
+			return
+		}
+	}
+	var usageHours *float64
+	if value := c.Query("usage_hours"); value != "" {
+		hours, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(hours) || math.IsInf(hours, 0) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "usage_hours must be a number"})
+			return
+		}
+		usageHours = &hours
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	product, err := storageInstance.GetProduct(c.Request.Context(), id)
+	if err != nil {
+		writeProductError(c, err)
+		return
+	}
+	stored, err := storageInstance.GetTariff(c.Request.Context(), tariffID)
+	if err != nil {
+		writeTariffError(c, err)
+		return
+	}
+
+	tariff, err := costcalc.FromModel(stored)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if usage.AnnualKWh, err = costcalc.AnnualConsumption(product, usageHours); err != nil {
+		writeTariffError(c, err)
+		return
+	}
+	projection, err := costcalc.Project(tariff, usage)
+	if err != nil {
+		writeTariffError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, projection)
+}
+
+// writeTariffError maps errors of tariff operations and cost projections to HTTP responses
+func writeTariffError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tariff not found"})
+	case errors.Is(err, storage.ErrDuplicate):
+		c.JSON(http.StatusConflict, gin.H{"error": "A tariff with this name already exists"})
+	case errors.Is(err, storage.ErrTariffInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": "The tariff is used by a budget"})
+	case errors.Is(err, costcalc.ErrInvalidTariff), errors.Is(err, costcalc.ErrInvalidUsage):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, costcalc.ErrNoRate):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
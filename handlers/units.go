@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"product-tracker/models"
+	"product-tracker/units"
+
+	"github.com/gin-gonic/gin"
+)
+
+// energyUnitParam parses the ?unit= query parameter, responding with 400 if the unit is unsupported.
+// It returns nil when no unit was requested so that values are rendered in their canonical unit.
+func energyUnitParam(c *gin.Context) (*units.Unit, bool) {
+	value := c.Query("unit")
+	if value == "" {
+		return nil, true
+	}
+	unit, err := units.Parse(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return &unit, true
+}
+
+// renderProductEnergy converts the energy consumption of products to unit in place
+func renderProductEnergy(c *gin.Context, products []models.Product, unit *units.Unit) bool {
+	for i := range products {
+		if !renderEnergy(c, &products[i], unit) {
+			return false
+		}
+	}
+	return true
+}
+
+// renderEnergy converts the energy consumption of a product to unit in place, responding with 400
+// if the conversion is impossible. Conversions across dimensions use the duty cycle or cycles per
+// year submitted with the product.
+func renderEnergy(c *gin.Context, product *models.Product, unit *units.Unit) bool {
+	if unit == nil {
+		return true
+	}
+
+	var opts units.Options
+	if product.EnergyInput != nil {
+		opts = product.EnergyInput.Options
+	}
+	energy, err := units.ConvertSymbols(product.EnergyConsumption, product.EnergyUnit, unit.Symbol, opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Product " + strconv.FormatInt(product.ID, 10) + ": " + err.Error()})
+		return false
+	}
+	product.EnergyConsumption = energy
+	product.EnergyUnit = unit.Symbol
+	return true
+}
+
+// renderReadingEnergy converts the energy consumed by readings to unit in place
+func renderReadingEnergy(c *gin.Context, readings []models.Reading, unit *units.Unit) bool {
+	if unit == nil {
+		return true
+	}
+	for i := range readings {
+		energy, err := units.ConvertSymbols(readings[i].EnergyConsumed, readings[i].EnergyUnit, unit.Symbol, units.Options{})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return false
+		}
+		readings[i].EnergyConsumed = energy
+		readings[i].EnergyUnit = unit.Symbol
+	}
+	return true
+}
@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"product-tracker/config"
+	"product-tracker/models"
+	"product-tracker/storage"
+	"product-tracker/webhooks"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookRequest represents the webhook request structure
+// @Description Subscription of an http or https endpoint to events. A signing secret of at least 16 characters is generated
+// @Description when omitted on creation and kept when omitted on update.
+type WebhookRequest struct {
+	URL         string   `json:"url" example:"https://procurement.example.com/hooks/products" binding:"required,max=2048"`
+	Secret      string   `json:"secret,omitempty" binding:"max=255"`
+	Events      []string `json:"events" example:"product.created,product.updated" binding:"required,min=1"`
+	Active      *bool    `json:"active,omitempty" example:"true"`
+	Description string   `json:"description,omitempty" example:"Procurement sync" binding:"max=1000"`
+}
+
+// toModel converts the request to a webhook, active unless stated otherwise
+func (r WebhookRequest) toModel() *models.Webhook {
+	active := r.Active == nil || *r.Active
+	return &models.Webhook{URL: r.URL, Secret: r.Secret, Events: r.Events, Active: active, Description: r.Description}
+}
+
+// GetWebhooks godoc
+// @Summary      List webhooks
+// @Description  Get every webhook subscription, without secrets. Admin only.
+// @Tags         webhooks
+// @Produce      json
+// @Success      200  {array}   models.Webhook
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /webhooks [get]
+// @Security     BearerAuth
+func GetWebhooks(c *gin.Context) {
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	hooks, err := storageInstance.GetWebhooks(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, hooks)
+}
+
+// GetWebhook godoc
+// @Summary      Get a webhook
+// @Description  Get a single webhook subscription, without its secret. Admin only.
+// @Tags         webhooks
+// @Produce      json
+// @Param        id   path      int  true  "Webhook ID"
+// @Success      200  {object}  models.Webhook
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /webhooks/{id} [get]
+// @Security     BearerAuth
+func GetWebhook(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	hook, err := storageInstance.GetWebhook(c.Request.Context(), id)
+	if err != nil {
+		writeWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, hook)
+}
+
+// CreateWebhook godoc
+// @Summary      Create a webhook
+// @Description  Subscribe an endpoint to product.created, product.updated, product.deleted and readings.ingested events.
+// @Description  Each delivery is a POST of the event as JSON, signed in the X-Webhook-Signature header with the hex HMAC-SHA256
+// @Description  of "<X-Webhook-Timestamp>.<body>". The secret is only returned in this response. Admin only.
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        webhook  body      WebhookRequest  true  "Webhook object"
+// @Success      201      {object}  models.Webhook
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      403      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /webhooks [post]
+// @Security     BearerAuth
+func CreateWebhook(c *gin.Context) {
+	var request WebhookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	hook := request.toModel()
+	if err := storageInstance.CreateWebhook(c.Request.Context(), hook); err != nil {
+		writeWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, hook)
+}
+
+// UpdateWebhook godoc
+// @Summary      Update a webhook
+// @Description  Replace the URL, events, state and description of a webhook, and its secret if one is given. Deliveries of an
+// @Description  inactive webhook stay queued until it is activated again. Admin only.
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        id       path      int             true  "Webhook ID"
+// @Param        webhook  body      WebhookRequest  true  "Webhook object"
+// @Success      200      {object}  models.Webhook
+// @Failure      400      {object}  map[string]string
+// @Failure      401      {object}  map[string]string
+// @Failure      403      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /webhooks/{id} [put]
+// @Security     BearerAuth
+func UpdateWebhook(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var request WebhookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	hook := request.toModel()
+	hook.ID = id
+	if err := storageInstance.UpdateWebhook(c.Request.Context(), hook); err != nil {
+		writeWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, hook)
+}
+
+// DeleteWebhook godoc
+// @Summary      Delete a webhook
+// @Description  Delete a webhook with its deliveries. Admin only.
+// @Tags         webhooks
+// @Produce      json
+// @Param        id   path      int  true  "Webhook ID"
+// @Success      204
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /webhooks/{id} [delete]
+// @Security     BearerAuth
+func DeleteWebhook(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	if err := storageInstance.DeleteWebhook(c.Request.Context(), id); err != nil {
+		writeWebhookError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetWebhookDeliveries godoc
+// @Summary      List the deliveries of a webhook
+// @Description  Get the delivery log of a webhook, newest first, with the outcome of the latest attempt. Admin only.
+// @Tags         webhooks
+// @Produce      json
+// @Param        id     path      int     true   "Webhook ID"
+// @Param        state  query     string  false  "Only return deliveries in this state"  Enums(pending, succeeded, dead)
+// @Param        limit  query     int     false  "Maximum number of deliveries, up to 1000 (default 100)"
+// @Success      200    {array}   models.WebhookDelivery
+// @Failure      400    {object}  map[string]string
+// @Failure      401    {object}  map[string]string
+// @Failure      403    {object}  map[string]string
+// @Failure      404    {object}  map[string]string
+// @Failure      500    {object}  map[string]string
+// @Router       /webhooks/{id}/deliveries [get]
+// @Security     BearerAuth
+func GetWebhookDeliveries(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var filter storage.DeliveryFilter
+	switch state := c.Query("state"); state {
+	case "", webhooks.StatePending, webhooks.StateSucceeded, webhooks.StateDead:
+		filter.State = state
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "state must be pending, succeeded or dead"})
+		return
+	}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > storage.MaxDeliveryLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(storage.MaxDeliveryLimit)})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	deliveries, err := storageInstance.GetWebhookDeliveries(c.Request.Context(), id, filter)
+	if err != nil {
+		writeWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// GetWebhookDelivery godoc
+// @Summary      Get a webhook delivery
+// @Description  Get a delivery of a webhook with the log of its attempts. Admin only.
+// @Tags         webhooks
+// @Produce      json
+// @Param        id           path      int  true  "Webhook ID"
+// @Param        delivery_id  path      int  true  "Delivery ID"
+// @Success      200          {object}  models.WebhookDelivery
+// @Failure      400          {object}  map[string]string
+// @Failure      401          {object}  map[string]string
+// @Failure      403          {object}  map[string]string
+// @Failure      404          {object}  map[string]string
+// @Failure      500          {object}  map[string]string
+// @Router       /webhooks/{id}/deliveries/{delivery_id} [get]
+// @Security     BearerAuth
+func GetWebhookDelivery(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := parseIDParam(c, "delivery_id")
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	delivery, err := storageInstance.GetWebhookDelivery(c.Request.Context(), id, deliveryID)
+	if err != nil {
+		writeDeliveryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+// RedeliverWebhookDelivery godoc
+// @Summary      Redeliver a webhook delivery
+// @Description  Queue a delivery again right away with a fresh set of attempts, for instance once a dead receiver is back.
+// @Description  The original payload and event ID are sent. Admin only.
+// @Tags         webhooks
+// @Produce      json
+// @Param        id           path      int  true  "Webhook ID"
+// @Param        delivery_id  path      int  true  "Delivery ID"
+// @Success      202          {object}  models.WebhookDelivery
+// @Failure      400          {object}  map[string]string
+// @Failure      401          {object}  map[string]string
+// @Failure      403          {object}  map[string]string
+// @Failure      404          {object}  map[string]string
+// @Failure      500          {object}  map[string]string
+// @Router       /webhooks/{id}/deliveries/{delivery_id}/redeliver [post]
+// @Security     BearerAuth
+func RedeliverWebhookDelivery(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := parseIDParam(c, "delivery_id")
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	storageInstance, err := storage.NewStorage(cfg, tenantScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		return
+	}
+	defer storageInstance.Close()
+
+	delivery, err := storageInstance.RedeliverWebhookDelivery(c.Request.Context(), id, deliveryID)
+	if err != nil {
+		writeDeliveryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+// writeWebhookError maps errors of webhook operations to HTTP responses
+func writeWebhookError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+	case errors.Is(err, webhooks.ErrInvalidWebhook):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// writeDeliveryError maps errors of webhook delivery operations to HTTP responses
+func writeDeliveryError(c *gin.Context, err error) {
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook delivery not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
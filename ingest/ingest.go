@@ -0,0 +1,200 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"product-tracker/models"
+	"product-tracker/storage"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ErrInvalidConfig is returned for topic filters, mappings or templates that cannot be used
+var ErrInvalidConfig = errors.New("invalid MQTT ingestion configuration")
+
+// ErrUnmapped is returned for messages whose topic is not mapped to a product
+var ErrUnmapped = errors.New("topic is not mapped to a product")
+
+// DefaultTemplate reads messages such as {"energy": 12.5, "timestamp": "2024-03-01T10:00:00Z", "interval": 60},
+// with the energy in Wh over the interval in seconds starting at the timestamp
+const DefaultTemplate = `{
+	"name": {{json .Topic}},
+	"quantity": 1,
+	"energy_consumed": {{json .Payload.energy}},
+	"energy_unit": "Wh",
+	"recorded_at": {{json .Payload.timestamp}},
+	"interval_seconds": {{json .Payload.interval}}
+}`
+
+// ValidateTopicFilter checks that filter is a valid MQTT topic filter: + stands for a whole level and # for all
+// remaining levels
+func ValidateTopicFilter(filter string) error {
+	if filter == "" {
+		return fmt.Errorf("%w: topic filter is empty", ErrInvalidConfig)
+	}
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		if strings.Contains(level, "#") && (level != "#" || i != len(levels)-1) {
+			return fmt.Errorf("%w: # must be the last level of %q", ErrInvalidConfig, filter)
+		}
+		if strings.Contains(level, "+") && level != "+" {
+			return fmt.Errorf("%w: + must be a whole level of %q", ErrInvalidConfig, filter)
+		}
+	}
+	return nil
+}
+
+// MatchTopic reports whether a topic matches a topic filter
+func MatchTopic(filter, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) || (level != "+" && level != topicLevels[i]) {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
+
+// Route assigns the messages of a topic filter to a product
+type Route struct {
+	Topic     string
+	ProductID int64
+}
+
+// Mapping resolves the product of a message from its topic, using the first matching route
+type Mapping []Route
+
+// Validate checks the topic filters and product IDs of the routes
+func (m Mapping) Validate() error {
+	for _, route := range m {
+		if err := ValidateTopicFilter(route.Topic); err != nil {
+			return err
+		}
+		if route.ProductID <= 0 {
+			return fmt.Errorf("%w: topic %q must map to a product ID", ErrInvalidConfig, route.Topic)
+		}
+	}
+	return nil
+}
+
+// Product returns the product of the first route matching topic
+func (m Mapping) Product(topic string) (int64, bool) {
+	for _, route := range m {
+		if MatchTopic(route.Topic, topic) {
+			return route.ProductID, true
+		}
+	}
+	return 0, false
+}
+
+// Message is a message received from the broker
+type Message struct {
+	Topic    string
+	Payload  []byte
+	Received time.Time
+}
+
+// templateData is what templates render a message from
+type templateData struct {
+	// Topic and Levels are the topic and its levels
+	Topic  string
+	Levels []string
+	// ProductID is the product the topic is mapped to, or 0
+	ProductID int64
+	// Payload is the decoded JSON payload
+	Payload interface{}
+	// Received is when the message arrived
+	Received time.Time
+}
+
+// templateFuncs are available to templates
+var templateFuncs = template.FuncMap{
+	// json encodes a value, rendering missing payload fields as null
+	"json": func(v interface{}) (string, error) {
+		encoded, err := json.Marshal(v)
+		return string(encoded), err
+	},
+	// unix converts seconds since the epoch, as found in many meter payloads, to a time
+	"unix": func(v interface{}) (time.Time, error) {
+		seconds, ok := v.(float64)
+		if !ok {
+			return time.Time{}, fmt.Errorf("unix expects a number, got %T", v)
+		}
+		return time.Unix(0, int64(seconds*float64(time.Second))).UTC(), nil
+	},
+}
+
+// Converter turns messages into readings
+type Converter struct {
+	mapping  Mapping
+	template *template.Template
+	validate *validator.Validate
+}
+
+// NewConverter returns a converter using mapping and the given reading template, or DefaultTemplate if empty
+func NewConverter(mapping Mapping, text string) (*Converter, error) {
+	if err := mapping.Validate(); err != nil {
+		return nil, err
+	}
+	if text == "" {
+		text = DefaultTemplate
+	}
+	tmpl, err := template.New("reading").Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	return &Converter{mapping: mapping, template: tmpl, validate: validator.New()}, nil
+}
+
+// Convert renders a message into a reading. The product comes from the mapping, or from a product_id rendered
+// by the template for topics the mapping does not cover. Readings without a date are dated by the end of their
+// interval, or by the arrival of the message, in UTC.
+func (c *Converter) Convert(m Message) (storage.Product, error) {
+	var reading storage.Product
+	data := templateData{Topic: m.Topic, Levels: strings.Split(m.Topic, "/"), Received: m.Received}
+	if err := json.Unmarshal(m.Payload, &data.Payload); err != nil {
+		return reading, fmt.Errorf("payload is not JSON: %w", err)
+	}
+	productID, mapped := c.mapping.Product(m.Topic)
+	data.ProductID = productID
+
+	var rendered bytes.Buffer
+	if err := c.template.Execute(&rendered, data); err != nil {
+		return reading, fmt.Errorf("failed to render reading: %w", err)
+	}
+	if err := json.Unmarshal(rendered.Bytes(), &reading); err != nil {
+		return reading, fmt.Errorf("rendered reading is not valid: %w", err)
+	}
+
+	if mapped {
+		reading.ProductID = &productID
+	}
+	if reading.ProductID == nil || *reading.ProductID <= 0 {
+		return reading, ErrUnmapped
+	}
+	if reading.Date == "" {
+		reading.Date = TakenAt(reading, m.Received).UTC().Format(models.ReadingDateLayout)
+	}
+	if err := c.validate.Struct(reading); err != nil {
+		return reading, fmt.Errorf("rendered reading is not valid: %w", err)
+	}
+	return reading, nil
+}
+
+// TakenAt returns when a reading was taken: the end of its interval, or received for readings without one
+func TakenAt(reading storage.Product, received time.Time) time.Time {
+	if reading.RecordedAt == nil || reading.IntervalSeconds == nil {
+		return received
+	}
+	return reading.RecordedAt.Add(time.Duration(*reading.IntervalSeconds) * time.Second)
+}
@@ -0,0 +1,165 @@
+package ingest
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"meters/a/energy", "meters/a/energy", true},
+		{"meters/a/energy", "meters/b/energy", false},
+		{"meters/+/energy", "meters/b/energy", true},
+		{"meters/+/energy", "meters/b/power", false},
+		{"meters/+", "meters/b/energy", false},
+		{"meters/+/+", "meters/b", false},
+		{"meters/#", "meters", true},
+		{"meters/#", "meters/b/energy", true},
+		{"#", "meters/b/energy", true},
+		{"meters/a", "meters/a/energy", false},
+		{"meters/a/energy", "meters/a", false},
+		{"+/+", "/energy", true},
+	}
+	for _, tt := range tests {
+		if got := MatchTopic(tt.filter, tt.topic); got != tt.want {
+			t.Errorf("MatchTopic(%q, %q) = %v, want %v", tt.filter, tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestConverterConvert(t *testing.T) {
+	mapping := Mapping{{Topic: "meters/+/energy", ProductID: 7}}
+	received := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+	siteTemplate := `{
+		"product_id": {{index .Levels 1}},
+		"name": "site",
+		"quantity": 1,
+		"energy_consumed": {{json .Payload.kwh}},
+		"date": {{json ((unix .Payload.ts).Format "2006-01-02")}}
+	}`
+
+	tests := []struct {
+		name     string
+		template string
+		topic    string
+		payload  string
+		// product, energy and date are checked on success; err is matched with errors.Is when set
+		product int64
+		energy  float64
+		date    string
+		err     error
+		fails   bool
+	}{
+		{
+			name:    "default template dates by the end of the interval",
+			topic:   "meters/a/energy",
+			payload: `{"energy": 12.5, "timestamp": "2024-03-01T23:59:30Z", "interval": 60}`,
+			product: 7, energy: 12.5, date: "2024-03-02",
+		},
+		{
+			name:    "default template without interval dates by arrival",
+			topic:   "meters/a/energy",
+			payload: `{"energy": 3}`,
+			product: 7, energy: 3, date: "2024-03-05",
+		},
+		{
+			name:    "unmapped topic",
+			topic:   "other/a/energy",
+			payload: `{"energy": 3}`,
+			err:     ErrUnmapped,
+		},
+		{
+			name:     "product from the template",
+			template: siteTemplate,
+			topic:    "site/42/energy",
+			payload:  `{"kwh": 1.25, "ts": 1709251200}`,
+			product:  42, energy: 1.25, date: "2024-03-01",
+		},
+		{
+			name:     "mapping wins over the template",
+			template: siteTemplate,
+			topic:    "meters/42/energy",
+			payload:  `{"kwh": 1.25, "ts": 1709251200}`,
+			product:  7, energy: 1.25, date: "2024-03-01",
+		},
+		{
+			name:    "payload is not JSON",
+			topic:   "meters/a/energy",
+			payload: `12.5 kWh`,
+			fails:   true,
+		},
+		{
+			name:    "missing energy",
+			topic:   "meters/a/energy",
+			payload: `{"timestamp": "2024-03-01T10:00:00Z", "interval": 60}`,
+			fails:   true,
+		},
+		{
+			name:    "interval without timestamp",
+			topic:   "meters/a/energy",
+			payload: `{"energy": 3, "interval": 60}`,
+			fails:   true,
+		},
+		{
+			name:     "unix of a string",
+			template: siteTemplate,
+			topic:    "site/42/energy",
+			payload:  `{"kwh": 1.25, "ts": "yesterday"}`,
+			fails:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converter, err := NewConverter(mapping, tt.template)
+			if err != nil {
+				t.Fatalf("NewConverter failed: %v", err)
+			}
+			reading, err := converter.Convert(Message{Topic: tt.topic, Payload: []byte(tt.payload), Received: received})
+			switch {
+			case tt.err != nil:
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("Convert returned %v, want %v", err, tt.err)
+				}
+				return
+			case tt.fails:
+				if err == nil {
+					t.Fatalf("Convert succeeded with %+v, want an error", reading)
+				}
+				return
+			case err != nil:
+				t.Fatalf("Convert failed: %v", err)
+			}
+			if reading.ProductID == nil || *reading.ProductID != tt.product {
+				t.Errorf("product = %v, want %d", reading.ProductID, tt.product)
+			}
+			if reading.EnergyConsumed != tt.energy {
+				t.Errorf("energy = %v, want %v", reading.EnergyConsumed, tt.energy)
+			}
+			if reading.Date != tt.date {
+				t.Errorf("date = %q, want %q", reading.Date, tt.date)
+			}
+		})
+	}
+}
+
+func TestNewConverterRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		mapping  Mapping
+		template string
+	}{
+		{"wildcard inside a level", Mapping{{Topic: "meters/a+/energy", ProductID: 1}}, ""},
+		{"# before the last level", Mapping{{Topic: "meters/#/energy", ProductID: 1}}, ""},
+		{"missing product", Mapping{{Topic: "meters/+/energy"}}, ""},
+		{"unparsable template", nil, "{{.Payload"},
+	}
+	for _, tt := range tests {
+		if _, err := NewConverter(tt.mapping, tt.template); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: NewConverter returned %v, want %v", tt.name, err, ErrInvalidConfig)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"product-tracker/config"
+	"product-tracker/metrics"
+	"product-tracker/storage"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+var (
+	messagesTotal = metrics.NewCounter("product_tracker_mqtt_messages_total",
+		"MQTT messages handled, by result: ingested, unmapped or rejected", "result")
+	ingestionLag = metrics.NewHistogram("product_tracker_mqtt_ingestion_lag_seconds",
+		"Time from a reading being taken to its commit",
+		[]float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 900, 3600})
+	lastIngestionLag = metrics.NewGauge("product_tracker_mqtt_last_ingestion_lag_seconds",
+		"Ingestion lag of the most recently committed reading")
+	connected = metrics.NewGauge("product_tracker_mqtt_connected",
+		"Whether the subscriber is connected to the broker")
+)
+
+// received is a converted message waiting for its batch to be committed
+type received struct {
+	msg     mqtt.Message
+	reading storage.Product
+	at      time.Time
+}
+
+// readingStore is where a subscriber commits readings
+type readingStore interface {
+	InsertProducts(ctx context.Context, products []storage.Product) ([]int64, error)
+	Ping(ctx context.Context) error
+}
+
+// Subscriber writes the readings published to an MQTT broker in batches. Messages are acknowledged once their
+// reading is committed or rejected, and the broker keeps the session of cfg.ClientID, so QoS 1 and 2 messages not
+// yet committed when the connection or the process is lost are delivered again.
+type Subscriber struct {
+	cfg       config.MQTTConfig
+	store     readingStore
+	converter *Converter
+	messages  chan mqtt.Message
+}
+
+// ValidateConfig checks the broker settings, subscriptions, mapping and template of cfg
+func ValidateConfig(cfg config.MQTTConfig) error {
+	if len(cfg.Topics) == 0 {
+		return fmt.Errorf("%w: topics must not be empty", ErrInvalidConfig)
+	}
+	for _, topic := range cfg.Topics {
+		if err := ValidateTopicFilter(topic); err != nil {
+			return err
+		}
+	}
+	if cfg.ClientID == "" {
+		return fmt.Errorf("%w: client_id must not be empty", ErrInvalidConfig)
+	}
+	if cfg.QoS < 0 || cfg.QoS > 2 {
+		return fmt.Errorf("%w: qos must be 0, 1 or 2", ErrInvalidConfig)
+	}
+	if cfg.BatchSize < 1 || cfg.BatchInterval <= 0 || cfg.MaxReconnectInterval <= 0 {
+		return fmt.Errorf("%w: batch_size, batch_interval and max_reconnect_interval must be positive", ErrInvalidConfig)
+	}
+	_, err := NewConverter(mapping(cfg), cfg.Template)
+	return err
+}
+
+// mapping returns the topic mapping of cfg
+func mapping(cfg config.MQTTConfig) Mapping {
+	routes := make(Mapping, len(cfg.Products))
+	for i, p := range cfg.Products {
+		routes[i] = Route{Topic: p.Topic, ProductID: p.ProductID}
+	}
+	return routes
+}
+
+// NewSubscriber returns a subscriber writing to store
+func NewSubscriber(store *storage.Storage, cfg config.MQTTConfig) (*Subscriber, error) {
+	return newSubscriber(store, cfg)
+}
+
+// newSubscriber returns a subscriber writing to any store
+func newSubscriber(store readingStore, cfg config.MQTTConfig) (*Subscriber, error) {
+	if err := ValidateConfig(cfg); err != nil {
+		return nil, err
+	}
+	converter, err := NewConverter(mapping(cfg), cfg.Template)
+	if err != nil {
+		return nil, err
+	}
+	return &Subscriber{
+		cfg:       cfg,
+		store:     store,
+		converter: converter,
+		messages:  make(chan mqtt.Message, cfg.BatchSize),
+	}, nil
+}
+
+// Run connects to the broker and ingests messages until ctx is cancelled. The client reconnects and subscribes
+// again on its own, backing off up to cfg.MaxReconnectInterval.
+func (s *Subscriber) Run(ctx context.Context) {
+	opts := mqtt.NewClientOptions().
+		AddBroker(s.cfg.BrokerURL).
+		SetClientID(s.cfg.ClientID).
+		SetUsername(s.cfg.Username).
+		SetPassword(s.cfg.Password).
+		SetCleanSession(false).
+		SetAutoAckDisabled(true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(time.Second).
+		SetMaxReconnectInterval(s.cfg.MaxReconnectInterval).
+		SetOnConnectHandler(s.subscribe).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			connected.Set(0)
+			log.Printf("❌ Lost connection to MQTT broker: %v", err)
+		}).
+		SetReconnectingHandler(func(mqtt.Client, *mqtt.ClientOptions) {
+			log.Printf("Reconnecting to MQTT broker %s", s.cfg.BrokerURL)
+		})
+
+	client := mqtt.NewClient(opts)
+	// With retries enabled the token only completes once connected, which the handlers report
+	client.Connect()
+	defer client.Disconnect(250)
+
+	s.ingest(ctx)
+}
+
+// subscribe subscribes to every topic on each connection, as the broker may not have kept the session
+func (s *Subscriber) subscribe(client mqtt.Client) {
+	connected.Set(1)
+	log.Printf("✅ Connected to MQTT broker %s", s.cfg.BrokerURL)
+
+	filters := make(map[string]byte, len(s.cfg.Topics))
+	for _, topic := range s.cfg.Topics {
+		filters[topic] = byte(s.cfg.QoS)
+	}
+	// Blocking the client while batches are committed holds back the broker rather than piling up messages
+	token := client.SubscribeMultiple(filters, func(_ mqtt.Client, msg mqtt.Message) {
+		s.messages <- msg
+	})
+	go func() {
+		if token.Wait() && token.Error() != nil {
+			log.Printf("❌ Failed to subscribe to MQTT topics: %v", token.Error())
+		}
+	}()
+}
+
+// ingest collects messages into batches, committing each when full or after cfg.BatchInterval
+func (s *Subscriber) ingest(ctx context.Context) {
+	var batch []received
+	timer := time.NewTimer(s.cfg.BatchInterval)
+	timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Uncommitted messages were not acknowledged and are delivered again
+			return
+		case msg := <-s.messages:
+			now := time.Now()
+			reading, err := s.converter.Convert(Message{Topic: msg.Topic(), Payload: msg.Payload(), Received: now})
+			if err != nil {
+				s.reject(msg, err)
+				continue
+			}
+			batch = append(batch, received{msg: msg, reading: reading, at: now})
+			if len(batch) == 1 {
+				timer.Reset(s.cfg.BatchInterval)
+			}
+			if len(batch) < s.cfg.BatchSize {
+				continue
+			}
+			timer.Stop()
+		case <-timer.C:
+		}
+
+		s.commit(ctx, batch)
+		batch = nil
+	}
+}
+
+// commit writes a batch of readings. When the database is unavailable the batch is retried with backoff; when a
+// reading is at fault the batch is split so that only the faulty readings are rejected.
+func (s *Subscriber) commit(ctx context.Context, batch []received) {
+	readings := make([]storage.Product, len(batch))
+	for i, r := range batch {
+		readings[i] = r.reading
+	}
+
+	delay := time.Second
+	for {
+		_, err := s.store.InsertProducts(ctx, readings)
+		if err == nil {
+			s.ingested(batch)
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if pingErr := s.store.Ping(ctx); pingErr == nil {
+			if len(batch) == 1 {
+				s.reject(batch[0].msg, err)
+				return
+			}
+			half := len(batch) / 2
+			s.commit(ctx, batch[:half])
+			s.commit(ctx, batch[half:])
+			return
+		}
+
+		log.Printf("❌ Failed to commit %d MQTT reading(s), retrying in %s: %v", len(batch), delay, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, s.cfg.MaxReconnectInterval)
+	}
+}
+
+// ingested acknowledges committed messages and records their lag
+func (s *Subscriber) ingested(batch []received) {
+	now := time.Now()
+	for _, r := range batch {
+		r.msg.Ack()
+		lag := now.Sub(TakenAt(r.reading, r.at)).Seconds()
+		ingestionLag.Observe(lag)
+		lastIngestionLag.Set(lag)
+	}
+	messagesTotal.Add(float64(len(batch)), "ingested")
+}
+
+// reject acknowledges a message that cannot be ingested, as delivering it again would not help
+func (s *Subscriber) reject(msg mqtt.Message, err error) {
+	msg.Ack()
+	if errors.Is(err, ErrUnmapped) {
+		messagesTotal.Inc("unmapped")
+	} else {
+		messagesTotal.Inc("rejected")
+	}
+	log.Printf("❌ Dropped MQTT message on %s: %v", msg.Topic(), err)
+}
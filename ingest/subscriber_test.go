@@ -0,0 +1,174 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"product-tracker/config"
+	"product-tracker/storage"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+)
+
+// memoryStore records the batches a subscriber commits
+type memoryStore struct {
+	mu      sync.Mutex
+	batches [][]storage.Product
+	commits chan struct{}
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{commits: make(chan struct{}, 100)}
+}
+
+func (m *memoryStore) InsertProducts(_ context.Context, products []storage.Product) ([]int64, error) {
+	m.mu.Lock()
+	m.batches = append(m.batches, append([]storage.Product(nil), products...))
+	m.mu.Unlock()
+	m.commits <- struct{}{}
+	return make([]int64, len(products)), nil
+}
+
+func (m *memoryStore) Ping(context.Context) error {
+	return nil
+}
+
+// energies returns the energy of the readings of every batch committed so far
+func (m *memoryStore) energies() [][]float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result [][]float64
+	for _, batch := range m.batches {
+		var energies []float64
+		for _, reading := range batch {
+			energies = append(energies, reading.EnergyConsumed)
+		}
+		result = append(result, energies)
+	}
+	return result
+}
+
+// waitForCommits waits until n batches have been committed in total
+func (m *memoryStore) waitForCommits(t *testing.T, n int) {
+	t.Helper()
+	for {
+		m.mu.Lock()
+		committed := len(m.batches)
+		m.mu.Unlock()
+		if committed >= n {
+			return
+		}
+		select {
+		case <-m.commits:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %d batches, got %v", n, m.energies())
+		}
+	}
+}
+
+// startBroker runs an embedded MQTT broker for the duration of the test and returns its URL
+func startBroker(t *testing.T) (*mochi.Server, string) {
+	t.Helper()
+	server := mochi.New(&mochi.Options{InlineClient: true})
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	listener := listeners.NewTCP(listeners.Config{ID: "tcp", Address: "127.0.0.1:0"})
+	if err := server.AddListener(listener); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server, "tcp://" + listener.Address()
+}
+
+// runSubscriber runs a subscriber against the broker until the returned function is called
+func runSubscriber(t *testing.T, server *mochi.Server, cfg config.MQTTConfig, store readingStore) func() {
+	t.Helper()
+	subscriber, err := newSubscriber(store, cfg)
+	if err != nil {
+		t.Fatalf("newSubscriber failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		subscriber.Run(ctx)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(server.Topics.Subscribers("meters/a/energy").Subscriptions) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the subscription")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stop := func() {
+		cancel()
+		<-done
+	}
+	t.Cleanup(stop)
+	return sync.OnceFunc(stop)
+}
+
+// publish sends a reading of energy Wh to the broker
+func publish(t *testing.T, server *mochi.Server, payload string) {
+	t.Helper()
+	if err := server.Publish("meters/a/energy", []byte(payload), false, 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func reading(energy float64) string {
+	return fmt.Sprintf(`{"energy": %v, "timestamp": "2024-03-01T10:00:00Z", "interval": 60}`, energy)
+}
+
+func TestSubscriberBatchesAndAcknowledgesCommittedMessages(t *testing.T) {
+	server, url := startBroker(t)
+	cfg := config.MQTTConfig{
+		BrokerURL:            url,
+		ClientID:             "product-tracker-test",
+		Topics:               []string{"meters/+/energy"},
+		QoS:                  1,
+		Products:             []config.MQTTProductMapping{{Topic: "meters/+/energy", ProductID: 7}},
+		BatchSize:            2,
+		BatchInterval:        time.Hour,
+		MaxReconnectInterval: time.Second,
+	}
+
+	// A full batch is committed at once, while the reading after it waits for the next one
+	store := newMemoryStore()
+	stop := runSubscriber(t, server, cfg, store)
+	publish(t, server, `not json`)
+	for _, energy := range []float64{1, 2, 3} {
+		publish(t, server, reading(energy))
+	}
+	store.waitForCommits(t, 1)
+	time.Sleep(200 * time.Millisecond)
+	if got := store.energies(); len(got) != 1 || len(got[0]) != 2 || got[0][0] != 1 || got[0][1] != 2 {
+		t.Fatalf("committed %v, want one batch of [1 2]", got)
+	}
+	stop()
+
+	// The session is resumed with only the uncommitted reading, as the others and the rejected message were
+	// acknowledged. A partial batch is committed once the interval passes.
+	cfg.BatchSize = 10
+	cfg.BatchInterval = 50 * time.Millisecond
+	resumed := newMemoryStore()
+	runSubscriber(t, server, cfg, resumed)
+	resumed.waitForCommits(t, 1)
+	publish(t, server, reading(4))
+	resumed.waitForCommits(t, 2)
+	time.Sleep(200 * time.Millisecond)
+	if got := resumed.energies(); len(got) != 2 || len(got[0]) != 1 || got[0][0] != 3 || len(got[1]) != 1 || got[1][0] != 4 {
+		t.Fatalf("committed %v after resuming, want [[3] [4]]", got)
+	}
+}
@@ -0,0 +1,56 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"product-tracker/storage"
+)
+
+// anomalyBatchSize is the number of readings checked per transaction
+const anomalyBatchSize = 500
+
+// StartAnomalyDetection checks readings that were not checked on ingestion, such as those recorded before
+// anomaly detection was enabled, every interval until ctx is cancelled
+func StartAnomalyDetection(ctx context.Context, s *storage.Storage, interval time.Duration) {
+	if interval <= 0 {
+		log.Println("Anomaly detection job disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			detectAnomalies(ctx, s)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// detectAnomalies checks unchecked readings batch by batch until none are left
+func detectAnomalies(ctx context.Context, s *storage.Storage) {
+	total, found := 0, 0
+	for ctx.Err() == nil {
+		checked, anomalies, err := s.DetectAnomalies(ctx, anomalyBatchSize)
+		if err != nil {
+			log.Printf("❌ Failed to detect anomalies: %v", err)
+			return
+		}
+		total += checked
+		found += anomalies
+		if checked < anomalyBatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		log.Printf("🔎 Checked %d reading(s) for anomalies, found %d", total, found)
+	}
+}
@@ -0,0 +1,38 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"product-tracker/storage"
+)
+
+// StartBudgetSweep re-evaluates every budget each interval until ctx is cancelled, so that alerts follow
+// changes that are not readings, such as tariff updates, products moved to the trash and new months
+func StartBudgetSweep(ctx context.Context, s *storage.Storage, interval time.Duration) {
+	if interval <= 0 {
+		log.Println("Budget sweep job disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			evaluated, err := s.EvaluateBudgets(ctx, time.Now())
+			if err != nil {
+				log.Printf("❌ Failed to evaluate budgets: %v", err)
+			} else if evaluated > 0 {
+				log.Printf("💰 Evaluated %d budget(s)", evaluated)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,25 @@
+package jobs
+
+import (
+	"context"
+	"log"
+
+	"product-tracker/config"
+	"product-tracker/ingest"
+	"product-tracker/storage"
+)
+
+// StartMQTTIngestion subscribes to the readings published on the broker of cfg until ctx is cancelled
+func StartMQTTIngestion(ctx context.Context, s *storage.Storage, cfg config.MQTTConfig) error {
+	if cfg.BrokerURL == "" {
+		log.Println("MQTT ingestion disabled")
+		return nil
+	}
+
+	subscriber, err := ingest.NewSubscriber(s, cfg)
+	if err != nil {
+		return err
+	}
+	go subscriber.Run(ctx)
+	return nil
+}
@@ -0,0 +1,62 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"product-tracker/config"
+	"product-tracker/outbox"
+	"product-tracker/storage"
+)
+
+// outboxPurgeInterval is how often published events older than the retention are removed
+const outboxPurgeInterval = time.Hour
+
+// StartOutboxDispatcher publishes outbox events to sinks every cfg.PollInterval until ctx is cancelled, and
+// removes published events once they are older than cfg.Retention
+func StartOutboxDispatcher(ctx context.Context, s *storage.Storage, sinks []outbox.Sink, cfg config.OutboxConfig) {
+	if cfg.PollInterval <= 0 {
+		log.Println("Outbox dispatcher disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(cfg.PollInterval)
+		defer ticker.Stop()
+		var lastPurge time.Time
+
+		for {
+			dispatchOutbox(ctx, s, sinks, cfg)
+			if cfg.Retention > 0 && time.Since(lastPurge) >= outboxPurgeInterval {
+				lastPurge = time.Now()
+				if _, err := s.PurgeOutbox(ctx, lastPurge.Add(-cfg.Retention)); err != nil {
+					log.Printf("❌ Failed to purge outbox: %v", err)
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// dispatchOutbox publishes due events batch by batch until none are left
+func dispatchOutbox(ctx context.Context, s *storage.Storage, sinks []outbox.Sink, cfg config.OutboxConfig) {
+	for ctx.Err() == nil {
+		published, failed, err := s.DispatchOutbox(ctx, cfg.BatchSize, sinks, cfg.MaxBackoff)
+		if err != nil {
+			log.Printf("❌ Failed to dispatch outbox events: %v", err)
+			return
+		}
+		if failed > 0 {
+			log.Printf("❌ Failed to publish %d outbox event(s); they will be retried", failed)
+		}
+		if published+failed < cfg.BatchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"product-tracker/storage"
+)
+
+// StartTrashPurge permanently removes products that have been in the trash longer than
+// retention, checking every interval until ctx is cancelled
+func StartTrashPurge(ctx context.Context, s *storage.Storage, interval, retention time.Duration) {
+	if interval <= 0 || retention <= 0 {
+		log.Println("Trash purge disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			purgeTrash(ctx, s, retention)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// purgeTrash runs a single purge pass
+func purgeTrash(ctx context.Context, s *storage.Storage, retention time.Duration) {
+	purged, err := s.PurgeDeletedProducts(ctx, time.Now().Add(-retention))
+	if err != nil {
+		log.Printf("❌ Failed to purge trash: %v", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("🗑️ Purged %d product(s) from the trash", purged)
+	}
+}
@@ -0,0 +1,113 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"product-tracker/cache"
+	"product-tracker/db"
+	"product-tracker/storage"
+	"product-tracker/stream"
+	"product-tracker/webhooks"
+
+	"github.com/lib/pq"
+)
+
+// streamPingInterval is how often the listener connection is checked while no notification arrives
+const streamPingInterval = time.Minute
+
+// StartEventStream listens for the events announced by the notify outbox sink and publishes them to hub until
+// ctx is cancelled. Clients are told to resync whenever the connection was lost, as events may have been missed.
+// The events also invalidate the responses cached by this instance for writes made by other instances.
+func StartEventStream(ctx context.Context, dbConfig *db.DBConfig, s *storage.Storage, hub *stream.Hub) error {
+	listener := pq.NewListener(db.DSN(dbConfig), time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("❌ Event stream listener: %v", err)
+		}
+	})
+	if err := listener.Listen(storage.EventChannel); err != nil {
+		listener.Close()
+		return err
+	}
+
+	go func() {
+		defer listener.Close()
+		ticker := time.NewTicker(streamPingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case n := <-listener.Notify:
+				if n == nil {
+					// The connection was re-established; notifications sent meanwhile are lost
+					hub.Resync()
+					cache.Purge()
+					continue
+				}
+				publishStreamEvent(ctx, s, hub, n.Extra)
+			case <-ticker.C:
+				if err := listener.Ping(); err != nil {
+					log.Printf("❌ Event stream listener ping failed: %v", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// publishStreamEvent loads an announced outbox event and publishes it to hub. Cache invalidations only drop
+// the cached responses they name.
+func publishStreamEvent(ctx context.Context, s *storage.Storage, hub *stream.Hub, payload string) {
+	if tags, ok := storage.InvalidatedTags(payload); ok {
+		cache.Invalidate(tags...)
+		return
+	}
+	id, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		log.Printf("❌ Invalid event stream notification %q", payload)
+		return
+	}
+	event, err := s.StreamEvent(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		// Purged before it could be loaded
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to load stream event %d: %v", id, err)
+		hub.Resync()
+		cache.Purge()
+		return
+	}
+	if event != nil {
+		invalidateCache(*event)
+		hub.Publish(*event)
+	}
+}
+
+// invalidateCache drops the cached responses that may show the products or readings of an event
+func invalidateCache(event stream.Event) {
+	switch event.Type {
+	case webhooks.EventProductCreated:
+		for _, item := range event.Items {
+			cache.ProductChanged(*item.ProductID)
+		}
+	case webhooks.EventProductUpdated, webhooks.EventProductDeleted:
+		// Restoring a product from the trash is announced as an update
+		for _, item := range event.Items {
+			cache.ProductVisibilityChanged(*item.ProductID)
+		}
+	case webhooks.EventReadingsIngested:
+		var productIDs []int64
+		for _, item := range event.Items {
+			if item.ProductID != nil {
+				productIDs = append(productIDs, *item.ProductID)
+			}
+		}
+		cache.ReadingsChanged(productIDs)
+	}
+}
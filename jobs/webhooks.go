@@ -0,0 +1,88 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"product-tracker/config"
+	"product-tracker/storage"
+	"product-tracker/webhooks"
+)
+
+// webhookBatchSize is the number of deliveries claimed at a time
+const webhookBatchSize = 100
+
+// StartWebhookDelivery sends due webhook deliveries every cfg.PollInterval until ctx is cancelled, retrying failed
+// ones with exponential backoff until they run out of attempts
+func StartWebhookDelivery(ctx context.Context, s *storage.Storage, cfg config.WebhooksConfig) {
+	if cfg.PollInterval <= 0 {
+		log.Println("Webhook delivery job disabled")
+		return
+	}
+
+	sender := webhooks.NewSender(cfg.Timeout, cfg.AllowPrivateNetworks)
+	go func() {
+		ticker := time.NewTicker(cfg.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			deliverWebhooks(ctx, s, sender, cfg)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// deliverWebhooks sends claimed deliveries batch by batch until none are due
+func deliverWebhooks(ctx context.Context, s *storage.Storage, sender *webhooks.Sender, cfg config.WebhooksConfig) {
+	// The lease outlasts every request of a batch, which run cfg.Concurrency at a time
+	lease := cfg.Timeout*time.Duration(webhookBatchSize/cfg.Concurrency+1) + time.Minute
+	for ctx.Err() == nil {
+		messages, err := s.ClaimWebhookDeliveries(ctx, webhookBatchSize, lease)
+		if err != nil {
+			log.Printf("❌ Failed to claim webhook deliveries: %v", err)
+			return
+		}
+
+		var wg sync.WaitGroup
+		slots := make(chan struct{}, cfg.Concurrency)
+		for _, m := range messages {
+			wg.Add(1)
+			slots <- struct{}{}
+			go func(m webhooks.Message) {
+				defer wg.Done()
+				defer func() { <-slots }()
+				deliverWebhook(ctx, s, sender, cfg, m)
+			}(m)
+		}
+		wg.Wait()
+
+		if len(messages) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+// deliverWebhook sends one delivery and records the outcome
+func deliverWebhook(ctx context.Context, s *storage.Storage, sender *webhooks.Sender, cfg config.WebhooksConfig, m webhooks.Message) {
+	attempt := sender.Send(ctx, m)
+
+	var retryAt *time.Time
+	if made := m.Attempts + 1; !attempt.Succeeded() && made < cfg.MaxAttempts {
+		next := time.Now().Add(webhooks.Backoff(made, cfg.InitialBackoff, cfg.MaxBackoff))
+		retryAt = &next
+	}
+	if err := s.RecordWebhookAttempt(ctx, m.DeliveryID, attempt, retryAt); err != nil {
+		log.Printf("❌ Failed to record webhook delivery %d: %v", m.DeliveryID, err)
+		return
+	}
+	if !attempt.Succeeded() && retryAt == nil {
+		log.Printf("❌ Webhook delivery %d to %s is dead after %d attempts: %s", m.DeliveryID, m.URL, cfg.MaxAttempts, attempt.Err)
+	}
+}
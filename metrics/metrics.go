@@ -0,0 +1,229 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// collector is a metric that can write itself in the Prometheus text exposition format
+type collector interface {
+	name() string
+	write(w io.Writer)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[string]collector)
+)
+
+// register adds a metric to the registry, panicking on duplicate names as these are programming errors
+func register(c collector) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[c.name()]; ok {
+		panic(fmt.Sprintf("metric %s registered twice", c.name()))
+	}
+	registry[c.name()] = c
+}
+
+// WriteText writes every registered metric in the Prometheus text exposition format, sorted by name
+func WriteText(w io.Writer) {
+	registryMu.Lock()
+	collectors := make([]collector, 0, len(registry))
+	for _, c := range registry {
+		collectors = append(collectors, c)
+	}
+	registryMu.Unlock()
+
+	sort.Slice(collectors, func(i, j int) bool { return collectors[i].name() < collectors[j].name() })
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// header writes the HELP and TYPE lines of a metric
+func header(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, strings.ReplaceAll(help, "\n", " "), name, kind)
+}
+
+// labelPairs renders label names and values as {a="x",b="y"}, or nothing without labels
+func labelPairs(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + strconv.Quote(values[i])
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// formatValue renders a sample value
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// vector holds the values of a metric per combination of label values
+type vector struct {
+	metricName string
+	help       string
+	kind       string
+	labels     []string
+	mu         sync.Mutex
+	values     map[string]float64
+	keys       map[string][]string
+}
+
+func newVector(name, help, kind string, labels []string) *vector {
+	return &vector{
+		metricName: name,
+		help:       help,
+		kind:       kind,
+		labels:     labels,
+		values:     make(map[string]float64),
+		keys:       make(map[string][]string),
+	}
+}
+
+func (v *vector) name() string {
+	return v.metricName
+}
+
+// update applies f to the value of the given label values
+func (v *vector) update(labelValues []string, f func(float64) float64) {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metric %s takes %d label values, got %d", v.metricName, len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if _, ok := v.keys[key]; !ok {
+		v.keys[key] = append([]string(nil), labelValues...)
+	}
+	v.values[key] = f(v.values[key])
+}
+
+func (v *vector) write(w io.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	header(w, v.metricName, v.help, v.kind)
+	keys := make([]string, 0, len(v.values))
+	for key := range v.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) == 0 && len(v.labels) == 0 {
+		fmt.Fprintf(w, "%s 0\n", v.metricName)
+	}
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s%s %s\n", v.metricName, labelPairs(v.labels, v.keys[key]), formatValue(v.values[key]))
+	}
+}
+
+// Counter is a value that only goes up, optionally split by labels
+type Counter struct {
+	v *vector
+}
+
+// NewCounter registers a counter with the given label names
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{v: newVector(name, help, "counter", labels)}
+	register(c.v)
+	return c
+}
+
+// Add increases the counter of the given label values by delta, which must not be negative
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic("counter cannot decrease")
+	}
+	c.v.update(labelValues, func(v float64) float64 { return v + delta })
+}
+
+// Inc increases the counter of the given label values by one
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Gauge is a value that can go up and down, optionally split by labels
+type Gauge struct {
+	v *vector
+}
+
+// NewGauge registers a gauge with the given label names
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{v: newVector(name, help, "gauge", labels)}
+	register(g.v)
+	return g
+}
+
+// Set sets the gauge of the given label values
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.v.update(labelValues, func(float64) float64 { return value })
+}
+
+// Add changes the gauge of the given label values by delta
+func (g *Gauge) Add(delta float64, labelValues ...string) {
+	g.v.update(labelValues, func(v float64) float64 { return v + delta })
+}
+
+// Histogram counts observations in cumulative buckets
+type Histogram struct {
+	metricName string
+	help       string
+	bounds     []float64
+	mu         sync.Mutex
+	counts     []uint64
+	count      uint64
+	sum        float64
+}
+
+// NewHistogram registers a histogram with the given bucket upper bounds, in increasing order
+func NewHistogram(name, help string, bounds []float64) *Histogram {
+	if !sort.Float64sAreSorted(bounds) {
+		panic(fmt.Sprintf("buckets of histogram %s are not sorted", name))
+	}
+	h := &Histogram{metricName: name, help: help, bounds: bounds, counts: make([]uint64, len(bounds))}
+	register(h)
+	return h
+}
+
+// Observe adds an observation
+func (h *Histogram) Observe(value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, bound := range h.bounds {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+func (h *Histogram) name() string {
+	return h.metricName
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	header(w, h.metricName, h.help, "histogram")
+	for i, bound := range h.bounds {
+		fmt.Fprintf(w, "%s_bucket{le=%q} %d\n", h.metricName, formatValue(bound), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.metricName, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", h.metricName, formatValue(h.sum))
+	fmt.Fprintf(w, "%s_count %d\n", h.metricName, h.count)
+}
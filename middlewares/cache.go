@@ -0,0 +1,226 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"product-tracker/cache"
+	"product-tracker/config"
+	"product-tracker/metrics"
+	"product-tracker/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+var cacheRequestsTotal = metrics.NewCounter("product_tracker_cache_requests_total",
+	"Requests to cached routes, by route and result: hit, miss or bypass", "route", "result")
+
+// CacheTags returns the tags of the data a response is built from
+type CacheTags func(c *gin.Context) []string
+
+// StaticTags tags every response of a route with the same tags
+func StaticTags(tags ...string) CacheTags {
+	return func(*gin.Context) []string { return tags }
+}
+
+// ProductTags tags the response about the product of the id path parameter
+func ProductTags(c *gin.Context) []string {
+	return []string{cache.ProductTag(paramID(c.Param("id"))), cache.TagAnyProduct}
+}
+
+// ReadingTags tags a response about readings with the product of the product_id query parameter, or with all
+// readings when it is not given
+func ReadingTags(c *gin.Context) []string {
+	if value := c.Query("product_id"); value != "" {
+		return []string{cache.ReadingsTag(paramID(value))}
+	}
+	return []string{cache.TagReadings}
+}
+
+// paramID parses an ID parameter; invalid IDs are rejected by the handler, whose response is not cached
+func paramID(value string) int64 {
+	id, _ := strconv.ParseInt(value, 10, 64)
+	return id
+}
+
+// CacheResponse serves the successful responses of a read route from the default cache, keyed by route, path
+// and query parameters and the organization and role of the request. Responses carry an ETag, honouring If-None-Match with 304,
+// and an X-Cache header telling whether they were a HIT, a MISS or a BYPASS. An admin can bypass the cache with
+// Cache-Control: no-cache, which refreshes the cached response. It must run after AuthMiddleware.
+func CacheResponse(tags CacheTags) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		store := cache.Default()
+		if store == nil {
+			c.Next()
+			return
+		}
+
+		route := c.FullPath()
+		key := cacheKey(c)
+		bypass := utils.HasRole(c.GetString("role"), utils.RoleAdmin) &&
+			strings.Contains(strings.ToLower(c.GetHeader("Cache-Control")), "no-cache")
+		if !bypass {
+			if entry := store.Get(key); entry != nil {
+				cacheRequestsTotal.Inc(route, "hit")
+				writeCached(c, entry)
+				return
+			}
+		}
+
+		result := "miss"
+		if bypass {
+			result = "bypass"
+		}
+		cacheRequestsTotal.Inc(route, result)
+
+		since := store.Generation()
+		writer := &bufferedWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Header("X-Cache", strings.ToUpper(result))
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		if writer.Status() != http.StatusOK {
+			writer.flush()
+			return
+		}
+
+		body := writer.body.Bytes()
+		etag := writer.Header().Get("ETag")
+		if etag == "" {
+			sum := sha256.Sum256(body)
+			etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+			writer.Header().Set("ETag", etag)
+		}
+		header := writer.Header().Clone()
+		header.Del("X-Request-Id")
+		header.Del("X-Cache")
+		store.Set(key, &cache.Entry{Status: http.StatusOK, Header: header, Body: body, ETag: etag, Tags: tags(c)}, since)
+
+		setCacheControl(c)
+		if matchesETag(c, etag) {
+			c.Writer.WriteHeader(http.StatusNotModified)
+			c.Writer.WriteHeaderNow()
+			return
+		}
+		writer.flush()
+	}
+}
+
+// cacheKey identifies a response by route, path and query parameters, organization and role. Query parameters
+// are sorted so that their order does not matter.
+func cacheKey(c *gin.Context) string {
+	var key strings.Builder
+	fmt.Fprintf(&key, "%d\x00%s\x00%s", c.GetInt64("tenantID"), c.GetString("role"), c.FullPath())
+	for _, param := range c.Params {
+		fmt.Fprintf(&key, "\x00%s=%s", param.Key, param.Value)
+	}
+	query := c.Request.URL.Query()
+	for _, values := range query {
+		sort.Strings(values)
+	}
+	key.WriteString("?" + query.Encode())
+	return key.String()
+}
+
+// writeCached writes a cached response, or 304 if the client holds it already
+func writeCached(c *gin.Context, entry *cache.Entry) {
+	for name, values := range entry.Header {
+		c.Writer.Header()[name] = append([]string(nil), values...)
+	}
+	c.Header("X-Cache", "HIT")
+	setCacheControl(c)
+	if matchesETag(c, entry.ETag) {
+		c.AbortWithStatus(http.StatusNotModified)
+		return
+	}
+	c.Status(entry.Status)
+	c.Writer.Write(entry.Body)
+	c.Abort()
+}
+
+// setCacheControl lets clients and shared caches know how long they may reuse a response. Responses are private
+// as they depend on the token.
+func setCacheControl(c *gin.Context) {
+	if maxAge := config.GetConfig().Cache.MaxAge; maxAge > 0 {
+		c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds())))
+		return
+	}
+	c.Header("Cache-Control", "private, no-cache")
+}
+
+// matchesETag reports whether If-None-Match matches etag
+func matchesETag(c *gin.Context, etag string) bool {
+	header := c.GetHeader("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedWriter holds back the body of a response so that it can be cached before it is sent
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status  int
+	written bool
+	body    bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if code > 0 && !w.written {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	w.written = true
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	w.written = true
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *bufferedWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.written
+}
+
+// flush sends the held back response
+func (w *bufferedWriter) flush() {
+	w.ResponseWriter.WriteHeader(w.Status())
+	if w.body.Len() > 0 {
+		w.ResponseWriter.Write(w.body.Bytes())
+	} else {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
@@ -0,0 +1,225 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"product-tracker/cache"
+	"product-tracker/config"
+	"product-tracker/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cachedRouter serves cached product routes whose handlers count their calls. Requests name their organization
+// and role in the X-Test-Tenant and X-Test-Role headers instead of a token. during runs inside the handlers, to
+// act while a response is being built.
+func cachedRouter(t *testing.T, during func()) (*gin.Engine, *int) {
+	t.Helper()
+	if _, err := config.LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	cache.SetDefault(cache.New(100, time.Minute))
+	t.Cleanup(func() { cache.SetDefault(nil) })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		tenantID, _ := strconv.ParseInt(c.GetHeader("X-Test-Tenant"), 10, 64)
+		c.Set("tenantID", tenantID)
+		c.Set("role", c.GetHeader("X-Test-Role"))
+	})
+
+	calls := 0
+	handler := func(c *gin.Context) {
+		calls++
+		if during != nil {
+			during()
+		}
+		c.JSON(http.StatusOK, gin.H{"tenant": c.GetInt64("tenantID"), "id": c.Param("id"), "call": calls})
+	}
+	router.GET("/products", CacheResponse(StaticTags(cache.TagProducts)), handler)
+	router.GET("/products/:id", CacheResponse(ProductTags), handler)
+	router.GET("/missing", CacheResponse(StaticTags(cache.TagProducts)), func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not Found"})
+	})
+	return router, &calls
+}
+
+// get requests path as role of tenant with extra headers given as name, value pairs
+func get(router *gin.Engine, path string, tenant int64, role string, headers ...string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	request.Header.Set("X-Test-Tenant", strconv.FormatInt(tenant, 10))
+	request.Header.Set("X-Test-Role", role)
+	for i := 0; i+1 < len(headers); i += 2 {
+		request.Header.Set(headers[i], headers[i+1])
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestCacheResponseHitsAndMisses(t *testing.T) {
+	router, calls := cachedRouter(t, nil)
+
+	first := get(router, "/products?b=2&a=1", 1, utils.RoleUser)
+	if first.Code != http.StatusOK || first.Header().Get("X-Cache") != "MISS" || *calls != 1 {
+		t.Fatalf("first request: %d %s after %d calls, want a 200 MISS", first.Code, first.Header().Get("X-Cache"), *calls)
+	}
+	etag := first.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("first request has no ETag")
+	}
+
+	// Query parameters in another order hit the same entry
+	second := get(router, "/products?a=1&b=2", 1, utils.RoleUser)
+	if second.Header().Get("X-Cache") != "HIT" || second.Body.String() != first.Body.String() || *calls != 1 {
+		t.Fatalf("second request: %s %s after %d calls, want a HIT of %s", second.Header().Get("X-Cache"), second.Body, *calls, first.Body)
+	}
+	if second.Header().Get("ETag") != etag {
+		t.Errorf("cached ETag = %s, want %s", second.Header().Get("ETag"), etag)
+	}
+
+	if other := get(router, "/products?a=1&b=3", 1, utils.RoleUser); other.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("other query parameters: X-Cache = %s, want MISS", other.Header().Get("X-Cache"))
+	}
+
+	if notModified := get(router, "/products?a=1&b=2", 1, utils.RoleUser, "If-None-Match", etag); notModified.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match with the ETag returned %d, want %d", notModified.Code, http.StatusNotModified)
+	}
+
+	// Failed responses are not cached
+	get(router, "/missing", 1, utils.RoleUser)
+	if missing := get(router, "/missing", 1, utils.RoleUser); missing.Code != http.StatusNotFound || missing.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("repeated 404: %d %s, want a 404 MISS", missing.Code, missing.Header().Get("X-Cache"))
+	}
+}
+
+func TestCacheResponseSeparatesRoles(t *testing.T) {
+	router, calls := cachedRouter(t, nil)
+
+	for _, role := range []string{utils.RoleUser, utils.RoleAdmin} {
+		if r := get(router, "/products", 1, role); r.Header().Get("X-Cache") != "MISS" {
+			t.Errorf("first request as %s: X-Cache = %s, want MISS", role, r.Header().Get("X-Cache"))
+		}
+	}
+	for _, role := range []string{utils.RoleUser, utils.RoleAdmin} {
+		if r := get(router, "/products", 1, role); r.Header().Get("X-Cache") != "HIT" {
+			t.Errorf("second request as %s: X-Cache = %s, want HIT", role, r.Header().Get("X-Cache"))
+		}
+	}
+	if *calls != 2 {
+		t.Errorf("handler called %d times, want once per role", *calls)
+	}
+}
+
+func TestCacheResponseSeparatesTenants(t *testing.T) {
+	router, calls := cachedRouter(t, nil)
+
+	for _, tenant := range []int64{1, 2} {
+		if r := get(router, "/products/7", tenant, utils.RoleUser); r.Header().Get("X-Cache") != "MISS" {
+			t.Errorf("first request of organization %d: X-Cache = %s, want MISS", tenant, r.Header().Get("X-Cache"))
+		}
+	}
+	for _, tenant := range []int64{1, 2} {
+		r := get(router, "/products/7", tenant, utils.RoleUser)
+		if want := fmt.Sprintf(`"tenant":%d`, tenant); r.Header().Get("X-Cache") != "HIT" || !strings.Contains(r.Body.String(), want) {
+			t.Errorf("second request of organization %d: %s %s, want a HIT with %s", tenant, r.Header().Get("X-Cache"), r.Body, want)
+		}
+	}
+	if *calls != 2 {
+		t.Errorf("handler called %d times, want once per organization", *calls)
+	}
+}
+
+func TestCacheResponseInvalidatesTags(t *testing.T) {
+	router, calls := cachedRouter(t, nil)
+	paths := []string{"/products", "/products/1", "/products/2"}
+	for _, path := range paths {
+		get(router, path, 1, utils.RoleUser)
+	}
+
+	tests := []struct {
+		name       string
+		invalidate func()
+		// misses lists the paths expected to be built again
+		misses map[string]bool
+	}{
+		{"product changed", func() { cache.ProductChanged(1) }, map[string]bool{"/products": true, "/products/1": true}},
+		{"readings changed", func() { cache.ReadingsChanged([]int64{1, 2}) }, map[string]bool{}},
+		{"all products changed", cache.AllProductsChanged, map[string]bool{"/products": true, "/products/1": true, "/products/2": true}},
+		{"purge", cache.Purge, map[string]bool{"/products": true, "/products/1": true, "/products/2": true}},
+	}
+	for _, tt := range tests {
+		tt.invalidate()
+		for _, path := range paths {
+			want := "HIT"
+			if tt.misses[path] {
+				want = "MISS"
+			}
+			if r := get(router, path, 1, utils.RoleUser); r.Header().Get("X-Cache") != want {
+				t.Errorf("%s: %s X-Cache = %s, want %s", tt.name, path, r.Header().Get("X-Cache"), want)
+			}
+		}
+	}
+	if *calls != 3+2+3+3 {
+		t.Errorf("handler called %d times, want %d", *calls, 3+2+3+3)
+	}
+}
+
+func TestCacheResponseSkipsResponsesBuiltDuringInvalidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		tags   []string
+		cached bool
+	}{
+		// The response may have read the product before the write that invalidated it
+		{"own tag invalidated", []string{cache.TagProducts}, false},
+		{"other tag invalidated", []string{cache.TagReadings}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invalidate := true
+			router, _ := cachedRouter(t, func() {
+				if invalidate {
+					cache.Invalidate(tt.tags...)
+					invalidate = false
+				}
+			})
+			get(router, "/products", 1, utils.RoleUser)
+			want := "MISS"
+			if tt.cached {
+				want = "HIT"
+			}
+			if r := get(router, "/products", 1, utils.RoleUser); r.Header().Get("X-Cache") != want {
+				t.Errorf("X-Cache = %s, want %s", r.Header().Get("X-Cache"), want)
+			}
+		})
+	}
+}
+
+func TestCacheResponseAdminBypass(t *testing.T) {
+	router, calls := cachedRouter(t, nil)
+	get(router, "/products", 1, utils.RoleAdmin)
+	get(router, "/products", 1, utils.RoleUser)
+
+	// Users cannot bypass the cache
+	if r := get(router, "/products", 1, utils.RoleUser, "Cache-Control", "no-cache"); r.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("user with no-cache: X-Cache = %s, want HIT", r.Header().Get("X-Cache"))
+	}
+
+	bypassed := get(router, "/products", 1, utils.RoleAdmin, "Cache-Control", "No-Cache")
+	if bypassed.Header().Get("X-Cache") != "BYPASS" || *calls != 3 {
+		t.Fatalf("admin with no-cache: %s after %d calls, want a BYPASS", bypassed.Header().Get("X-Cache"), *calls)
+	}
+	// The bypass refreshed the cached response
+	if r := get(router, "/products", 1, utils.RoleAdmin); r.Header().Get("X-Cache") != "HIT" || r.Body.String() != bypassed.Body.String() {
+		t.Errorf("admin after the bypass: %s %s, want a HIT of %s", r.Header().Get("X-Cache"), r.Body, bypassed.Body)
+	}
+}
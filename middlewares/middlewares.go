@@ -17,14 +17,33 @@ func AuthMiddleware() gin.HandlerFunc {
             return
         }
 
-        userID, err := utils.ExtractUserIDFromToken(tokenString)
+        claims, err := utils.ValidateToken(tokenString)
         if err != nil {
             abortWithError(c, http.StatusUnauthorized, err.Error())
             return
         }
 
-        // Store the user ID in the context for use in handlers
-        c.Set("userID", userID)
+        // Store the user ID and role in the context for use in handlers
+        c.Set("userID", claims.UserID)
+        c.Set("role", claims.Role)
+
+        // Store the organization the request acts on, auditing access to other organizations
+        if !setTenant(c, claims) {
+            return
+        }
+
+        c.Next()
+    }
+}
+
+// RequireRole rejects requests whose token does not carry the given role.
+// Superadmins pass every role check. It must run after AuthMiddleware.
+func RequireRole(role string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if !utils.HasRole(c.GetString("role"), role) {
+            abortWithError(c, http.StatusForbidden, "Insufficient permissions")
+            return
+        }
 
         c.Next()
     }
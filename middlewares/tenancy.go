@@ -0,0 +1,94 @@
+package middlewares
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"product-tracker/config"
+	"product-tracker/metrics"
+	"product-tracker/models"
+	"product-tracker/storage"
+	"product-tracker/utils"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+// TenantHeader selects the organization a superadmin request acts on, instead of the one of the token
+const TenantHeader = "X-Tenant-ID"
+
+var crossTenantRequestsTotal = metrics.NewCounter("product_tracker_cross_tenant_requests_total",
+	"Requests to another organization than the one of the token, by result: allowed, forbidden or failed", "result")
+
+// setTenant stores the organization the request acts on as "tenantID", responding with an error and returning
+// false when it cannot. Tokens must carry a tenant_id claim, and only superadmins may select another organization
+// with TenantHeader. Every such request is written to the audit log before it is handled, and refused if it
+// cannot be.
+func setTenant(c *gin.Context, claims *utils.TokenClaims) bool {
+	var requested int64
+	if value := c.GetHeader(TenantHeader); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id <= 0 {
+			abortWithError(c, http.StatusBadRequest, "Invalid "+TenantHeader+" header")
+			return false
+		}
+		requested = id
+	}
+
+	tenantID, crossTenant, err := utils.ResolveTenant(claims, requested)
+	switch {
+	case errors.Is(err, utils.ErrTenantNotFound):
+		abortWithError(c, http.StatusUnauthorized, err.Error())
+		return false
+	case errors.Is(err, utils.ErrCrossTenant):
+		crossTenantRequestsTotal.Inc("forbidden")
+		abortWithError(c, http.StatusForbidden, err.Error())
+		return false
+	}
+
+	if crossTenant {
+		if status, ok := auditTenantAccess(c, claims, tenantID); !ok {
+			crossTenantRequestsTotal.Inc("failed")
+			if status == http.StatusNotFound {
+				abortWithError(c, status, "Organization not found")
+			} else {
+				abortWithError(c, status, "Failed to audit cross-tenant access")
+			}
+			return false
+		}
+		crossTenantRequestsTotal.Inc("allowed")
+	}
+
+	c.Set("tenantID", tenantID)
+	return true
+}
+
+// auditTenantAccess records a request of a superadmin to another organization, returning the status to respond
+// with if it could not be recorded
+func auditTenantAccess(c *gin.Context, claims *utils.TokenClaims, tenantID int64) (int, bool) {
+	storageInstance, err := storage.NewStorage(config.GetConfig(), storage.AllTenants)
+	if err != nil {
+		log.Printf("❌ Failed to connect to database to audit tenant access: %v", err)
+		return http.StatusInternalServerError, false
+	}
+	defer storageInstance.Close()
+
+	access := &models.TenantAccess{
+		UserID:       claims.UserID,
+		HomeTenantID: claims.TenantID,
+		TenantID:     tenantID,
+		Method:       c.Request.Method,
+		Path:         c.Request.URL.RequestURI(),
+		RequestID:    requestid.Get(c),
+	}
+	if err := storageInstance.RecordTenantAccess(c.Request.Context(), access); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return http.StatusNotFound, false
+		}
+		log.Printf("❌ Failed to audit tenant access: %v", err)
+		return http.StatusInternalServerError, false
+	}
+	return 0, true
+}
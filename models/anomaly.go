@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Anomaly represents a reading whose energy consumption lies outside the range expected from the
+// preceding readings of its product
+type Anomaly struct {
+	ID        int64  `json:"id"`
+	ReadingID int64  `json:"reading_id"`
+	ProductID int64  `json:"product_id"`
+	Method    string `json:"method" example:"robust_z"`
+	// Score is the signed distance of the reading from its baseline in spreads
+	Score        float64 `json:"score" example:"7.2"`
+	EnergyKWh    float64 `json:"energy_kwh" example:"4.8"`
+	ExpectedLow  float64 `json:"expected_low" example:"0.9"`
+	ExpectedHigh float64 `json:"expected_high" example:"1.6"`
+	// BaselineSize is the number of preceding readings the expected range was computed from
+	BaselineSize   int        `json:"baseline_size" example:"30"`
+	Date           string     `json:"date" example:"2024-01-15"`
+	RecordedAt     *time.Time `json:"recorded_at,omitempty"`
+	DetectedAt     time.Time  `json:"detected_at"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy *uint      `json:"acknowledged_by,omitempty"`
+	Note           string     `json:"note"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// Budget is a monthly limit on the energy use or energy cost of a product, a category with its subcategories,
+// or the whole portfolio. Alerts are raised as usage crosses each threshold, in percent of the limit.
+type Budget struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name" example:"Cold storage"`
+	Scope      string `json:"scope" example:"category" enums:"product,category,portfolio"`
+	ProductID  *int64 `json:"product_id,omitempty"`
+	CategoryID *int64 `json:"category_id,omitempty" example:"3"`
+	Metric     string `json:"metric" example:"energy" enums:"energy,cost"`
+	// Limit is in kWh for energy budgets and in major units of Currency for cost budgets
+	Limit      float64 `json:"limit" example:"1200"`
+	LimitMinor *int64  `json:"limit_minor,omitempty"`
+	Currency   *string `json:"currency,omitempty" example:"EUR"`
+	// TariffID prices the energy of cost budgets; standing charges are not included
+	TariffID *int64 `json:"tariff_id,omitempty"`
+	// Timezone is the rollup timezone the budget months are counted in
+	Timezone   string    `json:"timezone" example:"UTC"`
+	Thresholds []int     `json:"thresholds" example:"80,100"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// BudgetStatus is the month-to-date usage of a budget. Projected extrapolates the month-to-date run rate to
+// the whole month and is nil early in the month.
+type BudgetStatus struct {
+	BudgetID         int64     `json:"budget_id"`
+	PeriodStart      string    `json:"period_start" example:"2024-06-01"`
+	PeriodEnd        string    `json:"period_end" example:"2024-06-30"`
+	Unit             string    `json:"unit" example:"kWh"`
+	Used             float64   `json:"used" example:"964.2"`
+	Limit            float64   `json:"limit" example:"1200"`
+	Percent          float64   `json:"percent" example:"80.35"`
+	Projected        *float64  `json:"projected,omitempty" example:"1446.3"`
+	ProjectedPercent *float64  `json:"projected_percent,omitempty" example:"120.53"`
+	EvaluatedAt      time.Time `json:"evaluated_at"`
+}
+
+// Alert records a budget condition in one month. An alert fires when its condition starts to hold and is resolved
+// when it stops holding or the month ends; it is reused, rather than duplicated, if the condition holds again.
+type Alert struct {
+	ID          int64  `json:"id"`
+	BudgetID    int64  `json:"budget_id"`
+	BudgetName  string `json:"budget_name" example:"Cold storage"`
+	Kind        string `json:"kind" example:"threshold" enums:"threshold,projected_overrun"`
+	Threshold   *int   `json:"threshold,omitempty" example:"80"`
+	PeriodStart string `json:"period_start" example:"2024-06-01"`
+	State       string `json:"state" example:"firing" enums:"firing,resolved"`
+	// Unit, Used, Limit, Percent and Projected are those of the latest evaluation that changed or confirmed the state
+	Unit            string     `json:"unit" example:"kWh"`
+	Used            float64    `json:"used" example:"964.2"`
+	Limit           float64    `json:"limit" example:"1200"`
+	Percent         float64    `json:"percent" example:"80.35"`
+	Projected       *float64   `json:"projected,omitempty" example:"1446.3"`
+	FireCount       int        `json:"fire_count" example:"1"`
+	FiredAt         time.Time  `json:"fired_at"`
+	ResolvedAt      *time.Time `json:"resolved_at,omitempty"`
+	LastEvaluatedAt time.Time  `json:"last_evaluated_at"`
+	AcknowledgedAt  *time.Time `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy  *uint      `json:"acknowledged_by,omitempty"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Category represents a node in the product category tree
+type Category struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	ParentID  *int64     `json:"parent_id,omitempty"`
+	Children  []Category `json:"children,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Tag represents a free-form label attached to products
+type Tag struct {
+	ID           int64     `json:"id"`
+	Name         string    `json:"name"`
+	ProductCount int       `json:"product_count"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// CategoryStats represents reading statistics for the products of a category.
+// A nil CategoryID groups products without a category.
+type CategoryStats struct {
+	CategoryID    *int64  `json:"category_id"`
+	CategoryName  string  `json:"category_name"`
+	TotalProducts int     `json:"total_products"`
+	TotalQuantity int     `json:"total_quantity"`
+	TotalEnergy   float64 `json:"total_energy"`
+	AverageEnergy float64 `json:"avg_energy"`
+}
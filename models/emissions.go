@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// EmissionFactor is the location-based grid emission factor of a region for a year, in kg CO2e per kWh.
+// Every change to a factor is stored as a new version so that past reports can be reproduced.
+type EmissionFactor struct {
+	Region    string    `json:"region" example:"DE"`
+	Year      int       `json:"year" example:"2023"`
+	Factor    string    `json:"factor" example:"0.380000"`
+	Version   int       `json:"version" example:"1"`
+	Source    string    `json:"source,omitempty" example:"uba"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ReadingEmissions is the CO2e attributed to a single reading
+type ReadingEmissions struct {
+	ReadingID     int64   `json:"reading_id"`
+	ProductID     *int64  `json:"product_id,omitempty"`
+	Date          string  `json:"date"`
+	Region        string  `json:"region" example:"DE"`
+	EnergyKWh     float64 `json:"energy_kwh" example:"12.5"`
+	Factor        string  `json:"factor" example:"0.380000"`
+	FactorYear    int     `json:"factor_year" example:"2023"`
+	FactorVersion int     `json:"factor_version" example:"1"`
+	CO2eKg        float64 `json:"co2e_kg" example:"4.75"`
+}
+
+// PeriodEmissions totals the emissions of the readings of one day, month or year
+type PeriodEmissions struct {
+	Period    string  `json:"period" example:"2024-03"`
+	Readings  int     `json:"readings" example:"31"`
+	EnergyKWh float64 `json:"energy_kwh" example:"387.5"`
+	CO2eKg    float64 `json:"co2e_kg" example:"147.25"`
+}
+
+// EmissionsReport aggregates reading emissions per period. FactorsAsOf and Factors identify the factor
+// versions used, so running the report again with the same as_of gives the same figures.
+type EmissionsReport struct {
+	FactorsAsOf time.Time         `json:"factors_as_of"`
+	Period      string            `json:"period" example:"month"`
+	Readings    int               `json:"readings" example:"31"`
+	EnergyKWh   float64           `json:"energy_kwh" example:"387.5"`
+	CO2eKg      float64           `json:"co2e_kg" example:"147.25"`
+	Periods     []PeriodEmissions `json:"periods"`
+	Factors     []EmissionFactor  `json:"factors"`
+}
+
+// ProductEmissions reports the emissions of a product: an annual estimate from its rated consumption
+// and the emissions of its readings
+type ProductEmissions struct {
+	ProductID       int64           `json:"product_id"`
+	Region          string          `json:"region" example:"DE"`
+	AnnualEnergyKWh float64         `json:"annual_energy_kwh" example:"438"`
+	AnnualCO2eKg    float64         `json:"annual_co2e_kg" example:"166.44"`
+	AnnualFactor    EmissionFactor  `json:"annual_factor"`
+	Readings        EmissionsReport `json:"readings"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "product-tracker/units"
+
+// EnergyInput records an energy figure exactly as it was submitted, before conversion to the canonical unit
+type EnergyInput struct {
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+	units.Options
+}
+
+// Convert converts the submitted figure to the unit with the given symbol
+func (in EnergyInput) Convert(to string) (float64, error) {
+	return units.ConvertSymbols(in.Value, in.Unit, to, in.Options)
+}
@@ -0,0 +1,35 @@
+package models
+
+// ForecastPoint is the projected consumption of one period with its prediction interval
+type ForecastPoint struct {
+	PeriodStart string  `json:"period_start" example:"2024-07-01"`
+	EnergyKWh   float64 `json:"energy_kwh" example:"310.5"`
+	LowerKWh    float64 `json:"lower_kwh" example:"280.1"`
+	UpperKWh    float64 `json:"upper_kwh" example:"340.9"`
+}
+
+// ModelForecast is the forecast of one model with its backtest accuracy.
+// Error is set instead of Points when the history is too short for the model.
+type ModelForecast struct {
+	Model  string          `json:"model" example:"holt_winters"`
+	MAPE   *float64        `json:"mape" example:"6.25"`
+	MAE    *float64        `json:"mae" example:"18.4"`
+	Points []ForecastPoint `json:"points,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// Forecast represents consumption projections of a product or of the whole portfolio
+type Forecast struct {
+	ProductID *int64 `json:"product_id,omitempty"`
+	Interval  string `json:"interval" example:"month"`
+	Timezone  string `json:"timezone" example:"UTC"`
+	// Level is the coverage of the prediction intervals in percent
+	Level int `json:"level" example:"95"`
+	// HistoryStart and HistoryEnd bound the periods the models were fitted to
+	HistoryStart   string `json:"history_start" example:"2021-07-01"`
+	HistoryEnd     string `json:"history_end" example:"2024-06-30"`
+	HistoryPeriods int    `json:"history_periods" example:"36"`
+	// Best is the model with the lowest backtest MAPE
+	Best   string          `json:"best" example:"holt_winters"`
+	Models []ModelForecast `json:"models"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"product-tracker/money"
+)
+
+// FXRateDateLayout is the layout of FXRate.EffectiveDate
+const FXRateDateLayout = "2006-01-02"
+
+// FXRate is the price of one unit of Base in units of Quote, valid from EffectiveDate until the next rate for the pair
+type FXRate struct {
+	Base          string    `json:"base" example:"EUR"`
+	Quote         string    `json:"quote" example:"USD"`
+	Rate          string    `json:"rate" example:"1.0842"`
+	EffectiveDate string    `json:"effective_date" example:"2024-01-01"`
+	Source        string    `json:"source,omitempty" example:"ecb"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// PriceStats represents price aggregates of products converted to a single reporting currency
+type PriceStats struct {
+	Currency      string      `json:"currency" example:"USD"`
+	Date          string      `json:"date" example:"2024-01-01"`
+	TotalProducts int         `json:"total_products"`
+	TotalPrice    money.Money `json:"total_price"`
+	AveragePrice  money.Money `json:"avg_price"`
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// History actions recorded for product writes
+const (
+	HistoryActionSnapshot = "snapshot"
+	HistoryActionCreate   = "create"
+	HistoryActionUpdate   = "update"
+	HistoryActionDelete   = "delete"
+	HistoryActionRestore  = "restore"
+	HistoryActionPurge    = "purge"
+)
+
+// ProductHistoryEntry represents one recorded write to a product.
+// Before is nil for creations; After holds the state once the write completed and is nil for purges.
+type ProductHistoryEntry struct {
+	ID        int64     `json:"id"`
+	ProductID int64     `json:"product_id"`
+	Action    string    `json:"action"`
+	ActorID   uint      `json:"actor_id"`
+	RequestID string    `json:"request_id"`
+	Before    *Product  `json:"before,omitempty"`
+	After     *Product  `json:"after,omitempty"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// FieldChange represents the before and after value of a single product field
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+// untrackedFields are bookkeeping fields left out of history diffs
+var untrackedFields = map[string]bool{
+	"id":         true,
+	"version":    true,
+	"created_at": true,
+	"updated_at": true,
+}
+
+// Diff returns the fields whose values differ between Before and After, keyed by their JSON names
+func (e *ProductHistoryEntry) Diff() []FieldChange {
+	before := productFields(e.Before)
+	after := productFields(e.After)
+
+	var changes []FieldChange
+	for _, field := range productFieldNames() {
+		if untrackedFields[field] {
+			continue
+		}
+		b, a := before[field], after[field]
+		if reflect.DeepEqual(b, a) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: field, Before: b, After: a})
+	}
+	return changes
+}
+
+// productFields flattens a product into a map keyed by JSON field name
+func productFields(p *Product) map[string]any {
+	fields := map[string]any{}
+	if p == nil {
+		return fields
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return fields
+	}
+	_ = json.Unmarshal(data, &fields)
+	return fields
+}
+
+// productFieldNames returns the JSON field names of Product in declaration order
+func productFieldNames() []string {
+	t := reflect.TypeOf(Product{})
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
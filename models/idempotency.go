@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// IdempotencyRecord represents a stored response for an Idempotency-Key.
+// A StatusCode of zero means the original request is still in flight.
+type IdempotencyRecord struct {
+	UserID       uint
+	Route        string
+	Key          string
+	RequestHash  string
+	StatusCode   int
+	ResponseBody []byte
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+}
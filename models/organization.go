@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Organization is a tenant owning its products, readings, categories, budgets and webhooks
+type Organization struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TenantAccess records a request a user made to the data of an organization other than their own
+type TenantAccess struct {
+	ID           int64     `json:"id"`
+	UserID       uint      `json:"user_id"`
+	HomeTenantID int64     `json:"home_tenant_id"`
+	TenantID     int64     `json:"tenant_id"`
+	Method       string    `json:"method"`
+	Path         string    `json:"path"`
+	RequestID    string    `json:"request_id,omitempty"`
+	AccessedAt   time.Time `json:"accessed_at"`
+}
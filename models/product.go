@@ -1,14 +1,43 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"product-tracker/money"
+)
 
 // Product represents a product in the system
 type Product struct {
-	ID                int64     `json:"id"`
-	Name              string    `json:"name"`
-	Description       string    `json:"description"`
-	Price             float64   `json:"price"`
-	EnergyConsumption float64   `json:"energy_consumption"`
-	CreatedAt         time.Time `json:"created_at"`
-	UpdatedAt         time.Time `json:"updated_at"`
+	ID                int64              `json:"id"`
+	TenantID          int64              `json:"tenant_id"`
+	Name              string             `json:"name"`
+	Model             string             `json:"model"`
+	Description       string             `json:"description"`
+	Price             float64            `json:"price"`
+	PriceMinor        int64              `json:"price_minor"`
+	Currency          string             `json:"currency"`
+	EnergyConsumption float64            `json:"energy_consumption"`
+	EnergyUnit        string             `json:"energy_unit"`
+	EnergyInput       *EnergyInput       `json:"energy_input,omitempty"`
+	CategoryID        *int64             `json:"category_id,omitempty"`
+	Tags              []string           `json:"tags"`
+	Region            *string            `json:"region,omitempty"`
+	Attributes        map[string]float64 `json:"attributes,omitempty"`
+	Rating            *Rating            `json:"rating,omitempty"`
+	Version           int64              `json:"version"`
+	CreatedAt         time.Time          `json:"created_at"`
+	UpdatedAt         time.Time          `json:"updated_at"`
+	DeletedAt         *time.Time         `json:"deleted_at,omitempty"`
+}
+
+// PriceMoney returns the price of the product in minor units of its currency
+func (p *Product) PriceMoney() money.Money {
+	return money.Money{Amount: p.PriceMinor, Currency: p.Currency}
+}
+
+// SetPrice sets the price of the product, keeping Price in major units for display
+func (p *Product) SetPrice(m money.Money) {
+	p.PriceMinor = m.Amount
+	p.Currency = m.Currency
+	p.Price = m.Major()
 }
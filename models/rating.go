@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// RatingScheme assigns efficiency classes to the products of a category and of its subcategories that have no
+// scheme of their own. Every change to a scheme creates a new version; earlier versions are kept so that
+// stored ratings can be explained.
+type RatingScheme struct {
+	ID         int64  `json:"id"`
+	CategoryID int64  `json:"category_id" example:"3"`
+	Name       string `json:"name" example:"Refrigerators 2024"`
+	// CapacityAttribute is the product attribute energy consumption is divided by, e.g. volume_l
+	CapacityAttribute string `json:"capacity_attribute" example:"volume_l"`
+	// Classes are ordered from most to least efficient
+	Classes   []RatingClass `json:"classes"`
+	Version   int           `json:"version" example:"1"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// RatingClass is an efficiency class and the highest energy index it admits
+type RatingClass struct {
+	Class string `json:"class" example:"A"`
+	// MaxIndex is the highest energy index of the class in kWh/year per unit of capacity.
+	// It is omitted on the last class, which takes every higher index.
+	MaxIndex *string `json:"max_index,omitempty" example:"0.25"`
+}
+
+// Rating is the efficiency class stored with a product
+type Rating struct {
+	Class string `json:"class" example:"B"`
+	// Index is the energy consumption in kWh/year divided by the capacity attribute
+	Index         float64 `json:"index" example:"0.31"`
+	SchemeID      int64   `json:"scheme_id" example:"1"`
+	SchemeVersion int     `json:"scheme_version" example:"2"`
+}
+
+// RatingExplanation shows how the rating of a product was derived
+type RatingExplanation struct {
+	ProductID         int64    `json:"product_id"`
+	Rating            *Rating  `json:"rating"`
+	EnergyConsumption float64  `json:"energy_consumption" example:"272"`
+	Capacity          *float64 `json:"capacity" example:"380"`
+	// Scheme is the scheme version the rating was computed with
+	Scheme *RatingScheme `json:"scheme,omitempty"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// ReadingDateLayout is the layout of Reading.Date
+const ReadingDateLayout = "2006-01-02"
+
+// Reading represents an energy consumption reading recorded in product_tracker.
+// Interval readings carry the start of the metering interval and its length; other readings cover the whole of Date.
+type Reading struct {
+	ID              int64        `json:"id"`
+	ProductID       *int64       `json:"product_id,omitempty"`
+	Name            string       `json:"name"`
+	Quantity        int          `json:"quantity"`
+	EnergyConsumed  float64      `json:"energy_consumed"`
+	EnergyUnit      string       `json:"energy_unit"`
+	EnergyInput     *EnergyInput `json:"energy_input,omitempty"`
+	Date            string       `json:"date"`
+	RecordedAt      *time.Time   `json:"recorded_at,omitempty"`
+	IntervalSeconds *int         `json:"interval_seconds,omitempty" example:"900"`
+	// Region is the grid region of the reading, or of its product when the reading has none
+	Region *string `json:"region,omitempty" example:"DE"`
+}
+
+// ReadingStats holds the totals of the readings of a product
+type ReadingStats struct {
+	Readings         int64   `json:"readings" example:"96"`
+	Quantity         int64   `json:"quantity" example:"96"`
+	EnergyKWh        float64 `json:"energy_kwh" example:"12.5"`
+	AverageEnergyKWh float64 `json:"avg_energy_kwh" example:"0.13"`
+}
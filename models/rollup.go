@@ -0,0 +1,21 @@
+package models
+
+// ReadingPeriod holds the totals of the readings recorded in one day, week or month.
+// Totals are null for periods without readings when the aggregate is filled with nulls.
+type ReadingPeriod struct {
+	// PeriodStart is the first local day of the period
+	PeriodStart string   `json:"period_start" example:"2024-01-01"`
+	Readings    *int64   `json:"readings" example:"96"`
+	Quantity    *int64   `json:"quantity" example:"96"`
+	EnergyKWh   *float64 `json:"energy_kwh" example:"12.5"`
+}
+
+// ReadingAggregate represents readings rolled up per period in a timezone
+type ReadingAggregate struct {
+	Interval  string          `json:"interval" example:"day"`
+	Timezone  string          `json:"timezone" example:"Europe/Berlin"`
+	Start     string          `json:"start" example:"2024-01-01"`
+	End       string          `json:"end" example:"2024-01-31"`
+	ProductID *int64          `json:"product_id,omitempty"`
+	Periods   []ReadingPeriod `json:"periods"`
+}
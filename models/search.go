@@ -0,0 +1,11 @@
+package models
+
+// SearchResult is a product matching a search, with the parts of its name and description that matched
+// wrapped in <mark> tags. The rest of the text is HTML-escaped.
+type SearchResult struct {
+	Product Product `json:"product"`
+	// Score ranks the results: the weighted full-text rank plus the trigram similarity of the name
+	Score              float64 `json:"score" example:"0.87"`
+	NameHighlight      string  `json:"name_highlight" example:"<mark>Fridge</mark> Cooler 3000"`
+	DescriptionSnippet string  `json:"description_snippet" example:"Energy-saving <mark>fridge</mark> with freezer"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// TariffDateLayout is the layout of the effective dates of tariff rates
+const TariffDateLayout = "2006-01-02"
+
+// Tariff is an electricity tariff made of rates that apply over consecutive date ranges
+type Tariff struct {
+	ID        int64        `json:"id"`
+	Name      string       `json:"name"`
+	Currency  string       `json:"currency"`
+	Rates     []TariffRate `json:"rates"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// TariffRate is a flat price per kWh plus a daily standing charge, in major units of the tariff currency.
+// It applies from EffectiveFrom until EffectiveTo inclusive, or indefinitely when EffectiveTo is nil.
+type TariffRate struct {
+	ID             int64   `json:"id"`
+	UnitRate       string  `json:"unit_rate" example:"0.2834"`
+	StandingCharge string  `json:"standing_charge" example:"0.4512"`
+	EffectiveFrom  string  `json:"effective_from" example:"2024-01-01"`
+	EffectiveTo    *string `json:"effective_to,omitempty" example:"2024-12-31"`
+}
+
+// TariffSchedule is a time-of-use tariff that prices energy by the band in effect at each moment.
+// Bands are defined in local time of Timezone and must cover every minute of the week exactly once.
+type TariffSchedule struct {
+	ID        int64           `json:"id"`
+	Name      string          `json:"name"`
+	Currency  string          `json:"currency"`
+	Timezone  string          `json:"timezone" example:"Europe/Berlin"`
+	Bands     []TariffBand    `json:"bands"`
+	Holidays  []TariffHoliday `json:"holidays"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// TariffBand prices the hours from Start until End (exclusive, "24:00" for midnight) on Days.
+// Several entries may share a name to describe a band spanning midnight; they must share the unit rate.
+type TariffBand struct {
+	Name     string   `json:"name" example:"peak"`
+	UnitRate string   `json:"unit_rate" example:"0.3412"`
+	Days     []string `json:"days" example:"mon,tue,wed,thu,fri"`
+	Start    string   `json:"start" example:"07:00"`
+	End      string   `json:"end" example:"23:00"`
+}
+
+// TariffHoliday prices a public holiday with the bands of another weekday, typically "sun"
+type TariffHoliday struct {
+	Date     string `json:"date" example:"2024-12-25"`
+	Name     string `json:"name,omitempty" example:"Christmas Day"`
+	PricedAs string `json:"priced_as" example:"sun"`
+}
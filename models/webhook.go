@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook is a subscription of an HTTP endpoint to product and reading events. The signing secret is only
+// returned when the webhook is created.
+type Webhook struct {
+	ID          int64     `json:"id"`
+	URL         string    `json:"url" example:"https://procurement.example.com/hooks/products"`
+	Secret      string    `json:"secret,omitempty"`
+	Events      []string  `json:"events" example:"product.created,product.updated"`
+	Active      bool      `json:"active" example:"true"`
+	Description string    `json:"description" example:"Procurement sync"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery is one event queued for one webhook. Pending deliveries are retried until they succeed or
+// run out of attempts and become dead.
+type WebhookDelivery struct {
+	ID        int64  `json:"id"`
+	WebhookID int64  `json:"webhook_id"`
+	EventID   string `json:"event_id" example:"1842"`
+	Event     string `json:"event" example:"product.updated"`
+	// Payload is the JSON body posted to the webhook
+	Payload       json.RawMessage  `json:"payload" swaggertype:"object"`
+	State         string           `json:"state" example:"pending" enums:"pending,succeeded,dead"`
+	Attempts      int              `json:"attempts" example:"2"`
+	NextAttemptAt *time.Time       `json:"next_attempt_at,omitempty"`
+	LastStatus    *int             `json:"last_status,omitempty" example:"503"`
+	LastError     *string          `json:"last_error,omitempty" example:"receiver answered 503 Service Unavailable"`
+	CreatedAt     time.Time        `json:"created_at"`
+	DeliveredAt   *time.Time       `json:"delivered_at,omitempty"`
+	AttemptLog    []WebhookAttempt `json:"attempt_log,omitempty"`
+}
+
+// WebhookAttempt is a logged delivery attempt
+type WebhookAttempt struct {
+	ID          int64     `json:"id"`
+	StatusCode  *int      `json:"status_code,omitempty" example:"503"`
+	Response    string    `json:"response"`
+	Error       string    `json:"error"`
+	DurationMs  int64     `json:"duration_ms" example:"182"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
+
+// WebhookEvent is the JSON body posted to webhooks
+type WebhookEvent struct {
+	ID        string      `json:"id" example:"1842"`
+	Type      string      `json:"type" example:"product.updated"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// ReadingsIngested is the data of a readings.ingested event
+type ReadingsIngested struct {
+	Count      int     `json:"count" example:"96"`
+	ReadingIDs []int64 `json:"reading_ids"`
+	ProductIDs []int64 `json:"product_ids"`
+}
@@ -0,0 +1,177 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// Custom errors for currency parsing and arithmetic
+var (
+	ErrUnknownCurrency  = errors.New("unknown currency")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	ErrInvalidAmount    = errors.New("invalid amount")
+	ErrInvalidRate      = errors.New("invalid exchange rate")
+)
+
+// minorUnits maps supported ISO 4217 currency codes to the number of decimal places of their minor unit
+var minorUnits = map[string]int{
+	"AUD": 2, "BGN": 2, "BHD": 3, "BRL": 2, "CAD": 2, "CHF": 2, "CLP": 0, "CNY": 2,
+	"CZK": 2, "DKK": 2, "EUR": 2, "GBP": 2, "HKD": 2, "HUF": 2, "IDR": 2, "ILS": 2,
+	"INR": 2, "ISK": 0, "JPY": 0, "KRW": 0, "KWD": 3, "MXN": 2, "MYR": 2, "NOK": 2,
+	"NZD": 2, "OMR": 3, "PHP": 2, "PLN": 2, "RON": 2, "SAR": 2, "SEK": 2, "SGD": 2,
+	"THB": 2, "TRY": 2, "TWD": 2, "UAH": 2, "USD": 2, "VND": 0, "ZAR": 2,
+}
+
+// Money is an amount in the minor unit of a currency, e.g. cents for USD
+type Money struct {
+	Amount   int64  `json:"amount" example:"9999"`
+	Currency string `json:"currency" example:"EUR"`
+}
+
+// ParseCurrency normalizes an ISO 4217 currency code and checks that it is supported
+func ParseCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if _, ok := minorUnits[code]; !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownCurrency, code)
+	}
+	return code, nil
+}
+
+// Currencies returns the supported currency codes in sorted order
+func Currencies() []string {
+	codes := make([]string, 0, len(minorUnits))
+	for code := range minorUnits {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Exponent returns the number of decimal places of the minor unit of a supported currency
+func Exponent(currency string) int {
+	return minorUnits[currency]
+}
+
+// FromMajor converts an amount in major units, e.g. 99.99 EUR, to Money.
+// Amounts with more decimal places than the currency allows are rejected rather than rounded.
+func FromMajor(amount float64, currency string) (Money, error) {
+	currency, err := ParseCurrency(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return Money{}, fmt.Errorf("%w: not a number", ErrInvalidAmount)
+	}
+
+	scaled := amount * math.Pow10(Exponent(currency))
+	minor := math.Round(scaled)
+	if math.Abs(scaled-minor) > 1e-6 {
+		return Money{}, fmt.Errorf("%w: %s allows at most %d decimal places", ErrInvalidAmount, currency, Exponent(currency))
+	}
+	if math.Abs(minor) > math.MaxInt64/2 {
+		return Money{}, fmt.Errorf("%w: out of range", ErrInvalidAmount)
+	}
+	return Money{Amount: int64(minor), Currency: currency}, nil
+}
+
+// Major returns the amount in major units. Use it for display only.
+func (m Money) Major() float64 {
+	return float64(m.Amount) / math.Pow10(Exponent(m.Currency))
+}
+
+// String formats the amount with the decimal places of its currency, e.g. "99.99 EUR"
+func (m Money) String() string {
+	return fmt.Sprintf("%.*f %s", Exponent(m.Currency), m.Major(), m.Currency)
+}
+
+// Add returns the sum of two amounts in the same currency
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+// ParseRate parses a decimal exchange rate such as "1.0842", rejecting zero and negative rates
+func ParseRate(value string) (*big.Rat, error) {
+	rate, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok || rate.Sign() <= 0 {
+		return nil, fmt.Errorf("%w %q", ErrInvalidRate, value)
+	}
+	return rate, nil
+}
+
+// Convert converts m to another currency at rate, the price of one major unit of m.Currency
+// in major units of to. The result is rounded half away from zero to the minor unit of to.
+func Convert(m Money, to string, rate *big.Rat) (Money, error) {
+	to, err := ParseCurrency(to)
+	if err != nil {
+		return Money{}, err
+	}
+	if m.Currency == to {
+		return m, nil
+	}
+	if rate == nil || rate.Sign() <= 0 {
+		return Money{}, ErrInvalidRate
+	}
+
+	value := new(big.Rat).SetInt64(m.Amount)
+	value.Mul(value, rate)
+	value.Mul(value, pow10Rat(Exponent(to)-Exponent(m.Currency)))
+
+	amount, err := roundRat(value)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: amount, Currency: to}, nil
+}
+
+// FromRat converts an exact amount in major units to Money, rounding half away from zero to the minor unit
+func FromRat(amount *big.Rat, currency string) (Money, error) {
+	currency, err := ParseCurrency(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	value := new(big.Rat).Mul(amount, pow10Rat(Exponent(currency)))
+	minor, err := roundRat(value)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: minor, Currency: currency}, nil
+}
+
+// pow10Rat returns 10^exp as a rational number
+func pow10Rat(exp int) *big.Rat {
+	p := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(exp))), nil)
+	if exp < 0 {
+		return new(big.Rat).SetFrac(big.NewInt(1), p)
+	}
+	return new(big.Rat).SetInt(p)
+}
+
+// roundRat rounds a rational number half away from zero to an int64
+func roundRat(r *big.Rat) (int64, error) {
+	num := new(big.Int).Abs(r.Num())
+	q, rem := new(big.Int).QuoRem(num, r.Denom(), new(big.Int))
+	if rem.Mul(rem, big.NewInt(2)).Cmp(r.Denom()) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if r.Sign() < 0 {
+		q.Neg(q)
+	}
+	if !q.IsInt64() {
+		return 0, fmt.Errorf("%w: out of range", ErrInvalidAmount)
+	}
+	return q.Int64(), nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
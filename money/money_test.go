@@ -0,0 +1,163 @@
+package money
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestFromMajor(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		currency string
+		want     Money
+		err      error
+	}{
+		{99.99, "EUR", Money{9999, "EUR"}, nil},
+		{99.99, " eur ", Money{9999, "EUR"}, nil},
+		{-5.25, "USD", Money{-525, "USD"}, nil},
+		// Binary floating point cannot hold 0.1 + 0.2 exactly
+		{0.1 + 0.2, "EUR", Money{30, "EUR"}, nil},
+		{1.005, "EUR", Money{}, ErrInvalidAmount},
+		{1500, "JPY", Money{1500, "JPY"}, nil},
+		{1500.5, "JPY", Money{}, ErrInvalidAmount},
+		{1.234, "KWD", Money{1234, "KWD"}, nil},
+		{1.2345, "KWD", Money{}, ErrInvalidAmount},
+		{math.NaN(), "EUR", Money{}, ErrInvalidAmount},
+		{math.Inf(1), "EUR", Money{}, ErrInvalidAmount},
+		{1e300, "EUR", Money{}, ErrInvalidAmount},
+		{1, "XXX", Money{}, ErrUnknownCurrency},
+	}
+	for _, tt := range tests {
+		got, err := FromMajor(tt.amount, tt.currency)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("FromMajor(%v, %q) returned %v, want %v", tt.amount, tt.currency, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("FromMajor(%v, %q) = %+v, want %+v", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		m    Money
+		want string
+	}{
+		{Money{9999, "EUR"}, "99.99 EUR"},
+		{Money{-5, "EUR"}, "-0.05 EUR"},
+		{Money{1500, "JPY"}, "1500 JPY"},
+		{Money{1234, "KWD"}, "1.234 KWD"},
+	}
+	for _, tt := range tests {
+		if got := tt.m.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.m, got, tt.want)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name string
+		m    Money
+		to   string
+		rate string
+		want int64
+		err  error
+	}{
+		{"rounded down", Money{100, "EUR"}, "USD", "1.0842", 108, nil},
+		{"rounded up", Money{100, "EUR"}, "USD", "1.0862", 109, nil},
+		// Halves are rounded away from zero rather than to even
+		{"half a cent", Money{1, "EUR"}, "USD", "0.5", 1, nil},
+		{"two and a half cents", Money{5, "EUR"}, "USD", "0.5", 3, nil},
+		{"negative half", Money{-5, "EUR"}, "USD", "0.5", -3, nil},
+		{"to no decimals", Money{9999, "EUR"}, "JPY", "161.5", 16148, nil},
+		{"half a yen", Money{100, "EUR"}, "JPY", "150.5", 151, nil},
+		{"from no decimals", Money{1000, "JPY"}, "EUR", "0.0062", 620, nil},
+		{"to three decimals", Money{1000, "JPY"}, "KWD", "0.00205", 2050, nil},
+		{"half a fils", Money{1, "JPY"}, "KWD", "0.0005", 1, nil},
+		{"from three decimals", Money{2, "KWD"}, "EUR", "2.5", 1, nil},
+		{"below half a cent", Money{1, "KWD"}, "EUR", "2.5", 0, nil},
+		{"same currency", Money{9999, "EUR"}, "eur", "", 9999, nil},
+		{"missing rate", Money{100, "EUR"}, "USD", "", 0, ErrInvalidRate},
+		{"unknown currency", Money{100, "EUR"}, "XXX", "1", 0, ErrUnknownCurrency},
+	}
+	for _, tt := range tests {
+		var rate *big.Rat
+		if tt.rate != "" {
+			var err error
+			if rate, err = ParseRate(tt.rate); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := Convert(tt.m, tt.to, rate)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: Convert returned %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err == nil && (got.Amount != tt.want || got.Currency != normalized(tt.to)) {
+			t.Errorf("%s: Convert(%+v, %s, %s) = %+v, want %d", tt.name, tt.m, tt.to, tt.rate, got, tt.want)
+		}
+	}
+}
+
+func normalized(code string) string {
+	code, _ = ParseCurrency(code)
+	return code
+}
+
+func TestFromRat(t *testing.T) {
+	tests := []struct {
+		amount   *big.Rat
+		currency string
+		want     int64
+	}{
+		{big.NewRat(1, 3), "EUR", 33},
+		{big.NewRat(2, 3), "EUR", 67},
+		{big.NewRat(5, 1000), "EUR", 1},
+		{big.NewRat(-5, 1000), "EUR", -1},
+		{big.NewRat(15, 1000), "EUR", 2},
+		{big.NewRat(1, 2), "JPY", 1},
+		{big.NewRat(5, 10000), "KWD", 1},
+		{big.NewRat(4, 10000), "KWD", 0},
+	}
+	for _, tt := range tests {
+		got, err := FromRat(tt.amount, tt.currency)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Amount != tt.want || got.Currency != tt.currency {
+			t.Errorf("FromRat(%s, %s) = %+v, want %d", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+
+	huge := new(big.Rat).SetInt(new(big.Int).Lsh(big.NewInt(1), 80))
+	if _, err := FromRat(huge, "EUR"); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("FromRat of 2^80 returned %v, want %v", err, ErrInvalidAmount)
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	for _, value := range []string{" 1.0842 ", "161.5", "0.00205"} {
+		if _, err := ParseRate(value); err != nil {
+			t.Errorf("ParseRate(%q) failed: %v", value, err)
+		}
+	}
+	for _, value := range []string{"", "0", "-1.08", "abc"} {
+		if _, err := ParseRate(value); !errors.Is(err, ErrInvalidRate) {
+			t.Errorf("ParseRate(%q) returned %v, want %v", value, err, ErrInvalidRate)
+		}
+	}
+}
+
+func TestAdd(t *testing.T) {
+	sum, err := Money{9999, "EUR"}.Add(Money{1, "EUR"})
+	if err != nil || sum != (Money{10000, "EUR"}) {
+		t.Errorf("Add = %+v, %v, want 10000 EUR", sum, err)
+	}
+	if _, err := (Money{1, "EUR"}).Add(Money{1, "USD"}); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Add across currencies returned %v, want %v", err, ErrCurrencyMismatch)
+	}
+}
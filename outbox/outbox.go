@@ -0,0 +1,81 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrUnknownSink is returned for sink names that are not registered
+var ErrUnknownSink = errors.New("unknown outbox sink")
+
+// Aggregate types events are ordered within
+const (
+	AggregateProduct  = "product"
+	AggregateReadings = "readings"
+	// AggregateCache orders the cache invalidations of a tenant
+	AggregateCache = "cache"
+)
+
+// Event is a domain event recorded in the outbox in the transaction of the write it describes
+type Event struct {
+	// ID increases in commit order within an aggregate and identifies the event to sinks
+	ID            int64
+	AggregateType string
+	AggregateID   string
+	Type          string
+	Payload       json.RawMessage
+	CreatedAt     time.Time
+	// Attempts is the number of earlier failed dispatches
+	Attempts int
+	// TenantID is the organization the event belongs to; only its webhooks and stream clients receive it
+	TenantID int64
+}
+
+// Sink publishes outbox events. Events may be published more than once, after a failure or a crash between
+// publishing and marking them done, so sinks should be idempotent on the event ID.
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, event Event) error
+}
+
+// Publish hands an event to every sink, returning the errors of those that failed
+func Publish(ctx context.Context, sinks []Sink, event Event) error {
+	var errs []error
+	for _, sink := range sinks {
+		if err := sink.Publish(ctx, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Backoff returns the delay before dispatching an event again after the given number of failed attempts,
+// doubling from one second up to maxDelay
+func Backoff(attempts int, maxDelay time.Duration) time.Duration {
+	delay := time.Second
+	for i := 1; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
+
+// LogSink writes every event to the standard logger
+type LogSink struct{}
+
+// Name returns "log"
+func (LogSink) Name() string {
+	return "log"
+}
+
+// Publish logs the event
+func (LogSink) Publish(_ context.Context, event Event) error {
+	log.Printf("📤 Event %d %s on %s %s", event.ID, event.Type, event.AggregateType, event.AggregateID)
+	return nil
+}
@@ -0,0 +1,128 @@
+package rating
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"product-tracker/models"
+)
+
+// Custom errors for rating schemes and product attributes
+var (
+	ErrInvalidScheme    = errors.New("invalid rating scheme")
+	ErrInvalidAttribute = errors.New("invalid product attribute")
+)
+
+// maxClassLength is the longest class name accepted, long enough for "A+++"
+const maxClassLength = 8
+
+// attributePattern matches attribute names such as "volume_l" or "screen_area_dm2"
+var attributePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// NormalizeAttributes checks the names and values of product attributes, returning an empty map for nil
+func NormalizeAttributes(attributes map[string]float64) (map[string]float64, error) {
+	normalized := make(map[string]float64, len(attributes))
+	for name, value := range attributes {
+		if !attributePattern.MatchString(name) {
+			return nil, fmt.Errorf("%w %q: use up to 64 lower-case letters, digits and underscores", ErrInvalidAttribute, name)
+		}
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return nil, fmt.Errorf("%w %q: value must be a finite number", ErrInvalidAttribute, name)
+		}
+		normalized[name] = value
+	}
+	return normalized, nil
+}
+
+// class is a parsed efficiency class
+type class struct {
+	name string
+	// max is the highest index of the class; the last class is open-ended
+	max float64
+}
+
+// Scheme is a validated rating scheme ready to rate products
+type Scheme struct {
+	ID        int64
+	Version   int
+	Attribute string
+	classes   []class
+}
+
+// FromModel parses and validates a rating scheme. Class thresholds must increase strictly, and every class
+// but the last needs one.
+func FromModel(m *models.RatingScheme) (*Scheme, error) {
+	attribute := strings.TrimSpace(m.CapacityAttribute)
+	if !attributePattern.MatchString(attribute) {
+		return nil, fmt.Errorf("%w: capacity_attribute %q is not a valid attribute name", ErrInvalidScheme, attribute)
+	}
+	if len(m.Classes) < 2 {
+		return nil, fmt.Errorf("%w: at least two classes are required", ErrInvalidScheme)
+	}
+
+	scheme := &Scheme{ID: m.ID, Version: m.Version, Attribute: attribute}
+	seen := map[string]bool{}
+	for i, c := range m.Classes {
+		name := strings.TrimSpace(c.Class)
+		if name == "" || len(name) > maxClassLength || strings.ContainsAny(name, " \t,") {
+			return nil, fmt.Errorf("%w: class %d must have a name of up to %d characters without spaces or commas",
+				ErrInvalidScheme, i+1, maxClassLength)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%w: class %s is listed twice", ErrInvalidScheme, name)
+		}
+		seen[name] = true
+
+		last := i == len(m.Classes)-1
+		if last != (c.MaxIndex == nil) {
+			return nil, fmt.Errorf("%w: every class but the last needs max_index and the last must not have one", ErrInvalidScheme)
+		}
+		parsed := class{name: name, max: math.Inf(1)}
+		if !last {
+			value, err := strconv.ParseFloat(strings.TrimSpace(*c.MaxIndex), 64)
+			if err != nil || value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+				return nil, fmt.Errorf("%w: max_index of class %s must be a non-negative number", ErrInvalidScheme, name)
+			}
+			if i > 0 && value <= scheme.classes[i-1].max {
+				return nil, fmt.Errorf("%w: max_index must increase from class to class", ErrInvalidScheme)
+			}
+			parsed.max = value
+		}
+		scheme.classes = append(scheme.classes, parsed)
+	}
+	return scheme, nil
+}
+
+// Classes returns the classes of the scheme in their stored form
+func (s *Scheme) Classes() []models.RatingClass {
+	classes := make([]models.RatingClass, len(s.classes))
+	for i, c := range s.classes {
+		classes[i].Class = c.name
+		if !math.IsInf(c.max, 1) {
+			max := strconv.FormatFloat(c.max, 'f', -1, 64)
+			classes[i].MaxIndex = &max
+		}
+	}
+	return classes
+}
+
+// Rate returns the class of a product consuming energyKWh per year. The product is unrated, and nil is
+// returned, when it lacks a positive capacity attribute.
+func (s *Scheme) Rate(energyKWh float64, attributes map[string]float64) *models.Rating {
+	capacity, ok := attributes[s.Attribute]
+	if !ok || capacity <= 0 {
+		return nil
+	}
+	// Indices are stored with six decimals, so rate the rounded value to keep stored ratings reproducible
+	index := math.Round(energyKWh/capacity*1e6) / 1e6
+	for _, c := range s.classes {
+		if index <= c.max {
+			return &models.Rating{Class: c.name, Index: index, SchemeID: s.ID, SchemeVersion: s.Version}
+		}
+	}
+	return nil
+}
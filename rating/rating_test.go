@@ -0,0 +1,47 @@
+package rating
+
+import (
+	"errors"
+	"testing"
+
+	"product-tracker/models"
+)
+
+func TestFromModelValidatesMaxIndex(t *testing.T) {
+	tests := []struct {
+		name  string
+		first string
+		// second is the threshold of the middle class
+		second string
+		valid  bool
+	}{
+		{"increasing", "0.25", "0.5", true},
+		{"equal", "0.25", "0.25", false},
+		{"decreasing", "0.5", "0.25", false},
+		{"negative", "-1", "0.5", false},
+		{"NaN first", "NaN", "0.5", false},
+		{"NaN second", "0.25", "NaN", false},
+		{"infinite", "0.25", "Inf", false},
+		{"not a number", "0.25", "high", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, second := tt.first, tt.second
+			m := &models.RatingScheme{
+				CapacityAttribute: "volume_l",
+				Classes: []models.RatingClass{
+					{Class: "A", MaxIndex: &first},
+					{Class: "B", MaxIndex: &second},
+					{Class: "C"},
+				},
+			}
+			_, err := FromModel(m)
+			if tt.valid && err != nil {
+				t.Fatalf("FromModel failed: %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidScheme) {
+				t.Fatalf("FromModel returned %v, want %v", err, ErrInvalidScheme)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package rollups
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"product-tracker/models"
+)
+
+// Custom errors for rollup queries
+var (
+	ErrInvalidInterval = errors.New("invalid interval")
+	ErrInvalidFill     = errors.New("invalid fill")
+	ErrUnknownTimezone = errors.New("timezone has no rollups")
+	ErrTooManyPeriods  = errors.New("too many periods")
+)
+
+// MaxPeriods is the largest number of periods an aggregate query returns
+const MaxPeriods = 5000
+
+// Interval is the length of the periods readings are rolled up into
+type Interval string
+
+const (
+	// Day rolls readings up per local calendar day
+	Day Interval = "day"
+	// Week rolls readings up per ISO week starting on Monday
+	Week Interval = "week"
+	// Month rolls readings up per calendar month
+	Month Interval = "month"
+)
+
+// Intervals lists every interval that is maintained, in the form Postgres date_trunc accepts
+var Intervals = []Interval{Day, Week, Month}
+
+// ParseInterval parses an interval name
+func ParseInterval(value string) (Interval, error) {
+	switch i := Interval(strings.ToLower(strings.TrimSpace(value))); i {
+	case Day, Week, Month:
+		return i, nil
+	default:
+		return "", fmt.Errorf("%w %q: use %s, %s or %s", ErrInvalidInterval, value, Day, Week, Month)
+	}
+}
+
+// Truncate returns the first day of the period containing day
+func (i Interval) Truncate(day time.Time) time.Time {
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	switch i {
+	case Week:
+		// time.Weekday counts from Sunday; ISO weeks start on Monday
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case Month:
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+// Next returns the first day of the period after the one starting on start
+func (i Interval) Next(start time.Time) time.Time {
+	return i.Add(start, 1)
+}
+
+// Add returns the first day of the period n periods after the one starting on start; n may be negative
+func (i Interval) Add(start time.Time, n int) time.Time {
+	switch i {
+	case Week:
+		return start.AddDate(0, 0, 7*n)
+	case Month:
+		return start.AddDate(0, n, 0)
+	default:
+		return start.AddDate(0, 0, n)
+	}
+}
+
+// Fill says how periods without readings are reported
+type Fill string
+
+const (
+	// FillNone leaves periods without readings out
+	FillNone Fill = ""
+	// FillZero reports periods without readings with zero totals
+	FillZero Fill = "zero"
+	// FillNull reports periods without readings with null totals
+	FillNull Fill = "null"
+)
+
+// ParseFill parses a fill mode; an empty value means FillNone
+func ParseFill(value string) (Fill, error) {
+	switch f := Fill(strings.ToLower(strings.TrimSpace(value))); f {
+	case FillNone, FillZero, FillNull:
+		return f, nil
+	default:
+		return "", fmt.Errorf("%w %q: use %s or %s", ErrInvalidFill, value, FillZero, FillNull)
+	}
+}
+
+// ValidateTimezones checks that timezones is a non-empty list of distinct, loadable timezones
+func ValidateTimezones(timezones []string) error {
+	if len(timezones) == 0 {
+		return errors.New("at least one timezone is required")
+	}
+	seen := map[string]bool{}
+	for _, tz := range timezones {
+		if _, err := time.LoadLocation(tz); err != nil || tz == "" || tz == "Local" {
+			return fmt.Errorf("unknown timezone %q", tz)
+		}
+		if seen[tz] {
+			return fmt.Errorf("timezone %q is listed twice", tz)
+		}
+		seen[tz] = true
+	}
+	return nil
+}
+
+// CheckTimezone returns ErrUnknownTimezone unless tz is one of the maintained timezones
+func CheckTimezone(tz string, timezones []string) error {
+	for _, maintained := range timezones {
+		if tz == maintained {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q is not one of %s", ErrUnknownTimezone, tz, strings.Join(timezones, ", "))
+}
+
+// Periods returns the start of every period from the one containing start to the one containing end
+func Periods(interval Interval, start, end time.Time) ([]time.Time, error) {
+	var periods []time.Time
+	for day := interval.Truncate(start); !day.After(end); day = interval.Next(day) {
+		if len(periods) == MaxPeriods {
+			return nil, fmt.Errorf("%w: the range spans more than %d periods", ErrTooManyPeriods, MaxPeriods)
+		}
+		periods = append(periods, day)
+	}
+	return periods, nil
+}
+
+// FillPeriods returns the stored periods, sorted by start, with the gaps between start and end filled as
+// requested. Stored periods must be sorted and lie within the range.
+func FillPeriods(stored []models.ReadingPeriod, interval Interval, start, end time.Time, fill Fill) ([]models.ReadingPeriod, error) {
+	if fill == FillNone {
+		return stored, nil
+	}
+	periods, err := Periods(interval, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	filled := make([]models.ReadingPeriod, 0, len(periods))
+	next := 0
+	for _, day := range periods {
+		key := day.Format(models.ReadingDateLayout)
+		if next < len(stored) && stored[next].PeriodStart == key {
+			filled = append(filled, stored[next])
+			next++
+			continue
+		}
+		period := models.ReadingPeriod{PeriodStart: key}
+		if fill == FillZero {
+			var readings, quantity int64
+			var energy float64
+			period.Readings, period.Quantity, period.EnergyKWh = &readings, &quantity, &energy
+		}
+		filled = append(filled, period)
+	}
+	return filled, nil
+}
@@ -0,0 +1,142 @@
+package rollups
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"product-tracker/models"
+)
+
+func date(value string) time.Time {
+	day, err := time.Parse(models.ReadingDateLayout, value)
+	if err != nil {
+		panic(err)
+	}
+	return day
+}
+
+func TestTruncateAndNext(t *testing.T) {
+	tests := []struct {
+		interval    Interval
+		day         string
+		start, next string
+	}{
+		{Day, "2024-03-31", "2024-03-31", "2024-04-01"},
+		{Week, "2024-03-31", "2024-03-25", "2024-04-01"},
+		{Week, "2024-04-01", "2024-04-01", "2024-04-08"},
+		// ISO week 1 of 2025 starts in 2024
+		{Week, "2025-01-01", "2024-12-30", "2025-01-06"},
+		{Month, "2024-02-29", "2024-02-01", "2024-03-01"},
+		{Month, "2024-12-31", "2024-12-01", "2025-01-01"},
+	}
+	for _, tt := range tests {
+		start := tt.interval.Truncate(date(tt.day).Add(15 * time.Hour))
+		if got := start.Format(models.ReadingDateLayout); got != tt.start {
+			t.Errorf("%s.Truncate(%s) = %s, want %s", tt.interval, tt.day, got, tt.start)
+		}
+		if got := tt.interval.Next(start).Format(models.ReadingDateLayout); got != tt.next {
+			t.Errorf("%s.Next(%s) = %s, want %s", tt.interval, tt.start, got, tt.next)
+		}
+	}
+}
+
+func TestParseIntervalAndFill(t *testing.T) {
+	if i, err := ParseInterval(" Week "); err != nil || i != Week {
+		t.Errorf("ParseInterval(%q) = %q, %v, want %q", " Week ", i, err, Week)
+	}
+	if _, err := ParseInterval("hour"); !errors.Is(err, ErrInvalidInterval) {
+		t.Errorf("ParseInterval(%q) returned %v, want %v", "hour", err, ErrInvalidInterval)
+	}
+	if f, err := ParseFill(""); err != nil || f != FillNone {
+		t.Errorf("ParseFill(%q) = %q, %v, want none", "", f, err)
+	}
+	if f, err := ParseFill("ZERO"); err != nil || f != FillZero {
+		t.Errorf("ParseFill(%q) = %q, %v, want %q", "ZERO", f, err, FillZero)
+	}
+	if _, err := ParseFill("previous"); !errors.Is(err, ErrInvalidFill) {
+		t.Errorf("ParseFill(%q) returned %v, want %v", "previous", err, ErrInvalidFill)
+	}
+}
+
+func TestPeriods(t *testing.T) {
+	periods, err := Periods(Week, date("2024-03-27"), date("2024-04-10"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2024-03-25", "2024-04-01", "2024-04-08"}
+	if len(periods) != len(want) {
+		t.Fatalf("Periods = %v, want %v", periods, want)
+	}
+	for i := range want {
+		if got := periods[i].Format(models.ReadingDateLayout); got != want[i] {
+			t.Errorf("period %d = %s, want %s", i, got, want[i])
+		}
+	}
+
+	if _, err := Periods(Day, date("2000-01-01"), date("2024-01-01")); !errors.Is(err, ErrTooManyPeriods) {
+		t.Errorf("Periods over 24 years of days returned %v, want %v", err, ErrTooManyPeriods)
+	}
+}
+
+func TestFillPeriods(t *testing.T) {
+	readings, quantity, energy := int64(4), int64(4), 2.5
+	stored := []models.ReadingPeriod{{PeriodStart: "2024-03-02", Readings: &readings, Quantity: &quantity, EnergyKWh: &energy}}
+	tests := []struct {
+		fill Fill
+		// want lists the period starts; only 2024-03-02 has readings
+		want []string
+	}{
+		{FillNone, []string{"2024-03-02"}},
+		{FillZero, []string{"2024-03-01", "2024-03-02", "2024-03-03"}},
+		{FillNull, []string{"2024-03-01", "2024-03-02", "2024-03-03"}},
+	}
+	for _, tt := range tests {
+		filled, err := FillPeriods(stored, Day, date("2024-03-01"), date("2024-03-03"), tt.fill)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(filled) != len(tt.want) {
+			t.Fatalf("fill %q: got %d periods, want %v", tt.fill, len(filled), tt.want)
+		}
+		for i, p := range filled {
+			if p.PeriodStart != tt.want[i] {
+				t.Errorf("fill %q: period %d starts %s, want %s", tt.fill, i, p.PeriodStart, tt.want[i])
+			}
+			switch {
+			case p.PeriodStart == "2024-03-02":
+				if p.EnergyKWh == nil || *p.EnergyKWh != 2.5 {
+					t.Errorf("fill %q: stored period lost its energy: %+v", tt.fill, p)
+				}
+			case tt.fill == FillZero:
+				if p.Readings == nil || *p.Readings != 0 || p.EnergyKWh == nil || *p.EnergyKWh != 0 {
+					t.Errorf("fill %q: gap %s = %+v, want zero totals", tt.fill, p.PeriodStart, p)
+				}
+			case tt.fill == FillNull:
+				if p.Readings != nil || p.Quantity != nil || p.EnergyKWh != nil {
+					t.Errorf("fill %q: gap %s = %+v, want null totals", tt.fill, p.PeriodStart, p)
+				}
+			}
+		}
+	}
+}
+
+func TestTimezones(t *testing.T) {
+	for _, timezones := range [][]string{{"UTC"}, {"UTC", "Europe/Berlin"}} {
+		if err := ValidateTimezones(timezones); err != nil {
+			t.Errorf("ValidateTimezones(%v) failed: %v", timezones, err)
+		}
+	}
+	for _, timezones := range [][]string{nil, {""}, {"Local"}, {"Mars/Olympus"}, {"UTC", "UTC"}} {
+		if err := ValidateTimezones(timezones); err == nil {
+			t.Errorf("ValidateTimezones(%v) succeeded, want an error", timezones)
+		}
+	}
+
+	if err := CheckTimezone("Europe/Berlin", []string{"UTC", "Europe/Berlin"}); err != nil {
+		t.Errorf("CheckTimezone of a maintained timezone failed: %v", err)
+	}
+	if err := CheckTimezone("Asia/Tokyo", []string{"UTC"}); !errors.Is(err, ErrUnknownTimezone) {
+		t.Errorf("CheckTimezone of another timezone returned %v, want %v", err, ErrUnknownTimezone)
+	}
+}
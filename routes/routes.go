@@ -3,9 +3,11 @@ package routes
 import (
 	"fmt"
 	"net/http"
+	"product-tracker/cache"
 	"product-tracker/config"
 	"product-tracker/handlers"
 	"product-tracker/middlewares"
+	"product-tracker/utils"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -94,8 +96,162 @@ func SetupRoutes(router *gin.Engine) {
 		product := v1.Group("/product")
 		{
 			product.POST("/insert", middlewares.AuthMiddleware(), handlers.ImportProduct)
-			product.GET("/list", middlewares.AuthMiddleware(), handlers.GetProducts)
-			product.GET("/list/:name", middlewares.AuthMiddleware(), handlers.GetProductsByName)
+			product.GET("/list", middlewares.AuthMiddleware(), middlewares.CacheResponse(middlewares.StaticTags(cache.TagProducts)), handlers.GetProducts)
+			product.GET("/list/:name", middlewares.AuthMiddleware(), middlewares.CacheResponse(middlewares.StaticTags(cache.TagProducts)), handlers.GetProductsByName)
+			product.GET("/stats", middlewares.AuthMiddleware(), middlewares.CacheResponse(middlewares.StaticTags(cache.TagProducts, cache.TagReadings)), handlers.GetProductStats)
+			product.GET("/:id", middlewares.AuthMiddleware(), middlewares.CacheResponse(middlewares.ProductTags), handlers.GetProduct)
+			product.PUT("/:id", middlewares.AuthMiddleware(), handlers.UpdateProduct)
+			product.GET("/:id/history", middlewares.AuthMiddleware(), handlers.GetProductHistory)
+			product.GET("/:id/cost", middlewares.AuthMiddleware(), handlers.GetProductCost)
+			product.GET("/:id/emissions", middlewares.AuthMiddleware(), handlers.GetProductEmissions)
+			product.GET("/:id/rating", middlewares.AuthMiddleware(), handlers.GetProductRating)
+			product.GET("/:id/forecast", middlewares.AuthMiddleware(), handlers.GetProductForecast)
+			product.DELETE("/:id", middlewares.AuthMiddleware(), handlers.DeleteProduct)
+			product.POST("/:id/restore", middlewares.AuthMiddleware(), handlers.RestoreProduct)
+		}
+
+		// Category routes
+		categories := v1.Group("/categories")
+		{
+			categories.GET("", middlewares.AuthMiddleware(), handlers.GetCategories)
+			categories.POST("", middlewares.AuthMiddleware(), handlers.CreateCategory)
+			categories.GET("/:id", middlewares.AuthMiddleware(), handlers.GetCategory)
+			categories.PUT("/:id", middlewares.AuthMiddleware(), handlers.UpdateCategory)
+			categories.DELETE("/:id", middlewares.AuthMiddleware(), handlers.DeleteCategory)
+		}
+
+		// Tag routes
+		tags := v1.Group("/tags")
+		{
+			tags.GET("", middlewares.AuthMiddleware(), handlers.GetTags)
+			tags.POST("", middlewares.AuthMiddleware(), handlers.CreateTag)
+			tags.PUT("/:id", middlewares.AuthMiddleware(), handlers.RenameTag)
+			tags.DELETE("/:id", middlewares.AuthMiddleware(), handlers.DeleteTag)
+		}
+
+		// Rating scheme routes
+		ratingSchemes := v1.Group("/rating-schemes")
+		{
+			ratingSchemes.GET("", middlewares.AuthMiddleware(), handlers.GetRatingSchemes)
+			ratingSchemes.POST("", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleAdmin), handlers.CreateRatingScheme)
+			ratingSchemes.GET("/:id", middlewares.AuthMiddleware(), handlers.GetRatingScheme)
+			ratingSchemes.PUT("/:id", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleAdmin), handlers.UpdateRatingScheme)
+			ratingSchemes.DELETE("/:id", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleAdmin), handlers.DeleteRatingScheme)
+			ratingSchemes.GET("/:id/versions/:version", middlewares.AuthMiddleware(), handlers.GetRatingSchemeVersion)
+		}
+
+		// Exchange rate routes
+		fx := v1.Group("/fx")
+		{
+			fx.GET("/rates", middlewares.AuthMiddleware(), handlers.GetFXRates)
+			fx.POST("/rates", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleSuperAdmin), handlers.ImportFXRates)
+		}
+
+		// Emissions routes
+		emissions := v1.Group("/emissions")
+		{
+			emissions.GET("/factors", middlewares.AuthMiddleware(), handlers.GetEmissionFactors)
+			emissions.POST("/factors", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleSuperAdmin), handlers.ImportEmissionFactors)
+			emissions.GET("/report", middlewares.AuthMiddleware(), handlers.GetEmissionsReport)
+		}
+
+		// Tariff routes
+		tariffs := v1.Group("/tariffs")
+		{
+			tariffs.GET("", middlewares.AuthMiddleware(), handlers.GetTariffs)
+			tariffs.POST("", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleSuperAdmin), handlers.CreateTariff)
+			tariffs.GET("/:id", middlewares.AuthMiddleware(), handlers.GetTariff)
+			tariffs.PUT("/:id", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleSuperAdmin), handlers.UpdateTariff)
+			tariffs.DELETE("/:id", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleSuperAdmin), handlers.DeleteTariff)
+		}
+
+		// Tariff schedule routes
+		schedules := v1.Group("/tariff-schedules")
+		{
+			schedules.GET("", middlewares.AuthMiddleware(), handlers.GetTariffSchedules)
+			schedules.POST("", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleSuperAdmin), handlers.CreateTariffSchedule)
+			schedules.GET("/:id", middlewares.AuthMiddleware(), handlers.GetTariffSchedule)
+			schedules.PUT("/:id", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleSuperAdmin), handlers.UpdateTariffSchedule)
+			schedules.DELETE("/:id", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleSuperAdmin), handlers.DeleteTariffSchedule)
+		}
+
+		// Comparison routes
+		v1.POST("/compare", middlewares.AuthMiddleware(), handlers.CompareProducts)
+
+		// Forecast routes
+		v1.GET("/forecast", middlewares.AuthMiddleware(), handlers.GetPortfolioForecast)
+
+		// Anomaly routes
+		anomalies := v1.Group("/anomalies")
+		{
+			anomalies.GET("", middlewares.AuthMiddleware(), handlers.GetAnomalies)
+			anomalies.POST("/:id/acknowledge", middlewares.AuthMiddleware(), handlers.AcknowledgeAnomaly)
+		}
+
+		// Budget routes
+		budgets := v1.Group("/budgets")
+		{
+			budgets.GET("", middlewares.AuthMiddleware(), handlers.GetBudgets)
+			budgets.POST("", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleAdmin), handlers.CreateBudget)
+			budgets.GET("/:id", middlewares.AuthMiddleware(), handlers.GetBudget)
+			budgets.PUT("/:id", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleAdmin), handlers.UpdateBudget)
+			budgets.DELETE("/:id", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleAdmin), handlers.DeleteBudget)
+			budgets.GET("/:id/status", middlewares.AuthMiddleware(), handlers.GetBudgetStatus)
+		}
+
+		// Metrics routes
+		v1.GET("/metrics", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleAdmin), handlers.GetMetrics)
+
+		// Stream routes
+		v1.GET("/stream", middlewares.AuthMiddleware(), handlers.StreamEvents)
+
+		// Search routes
+		v1.GET("/search", middlewares.AuthMiddleware(), middlewares.CacheResponse(middlewares.StaticTags(cache.TagProducts)), handlers.SearchProducts)
+
+		// GraphQL routes
+		v1.POST("/graphql", middlewares.AuthMiddleware(), handlers.GraphQL)
+
+		// Alert routes
+		alerts := v1.Group("/alerts")
+		{
+			alerts.GET("", middlewares.AuthMiddleware(), handlers.GetAlerts)
+			alerts.POST("/:id/acknowledge", middlewares.AuthMiddleware(), handlers.AcknowledgeAlert)
+		}
+
+		// Webhook routes
+		hooks := v1.Group("/webhooks")
+		{
+			hooks.GET("", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleAdmin), handlers.GetWebhooks)
+			hooks.POST("", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleAdmin), handlers.CreateWebhook)
+			hooks.GET("/:id", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleAdmin), handlers.GetWebhook)
+			hooks.PUT("/:id", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleAdmin), handlers.UpdateWebhook)
+			hooks.DELETE("/:id", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleAdmin), handlers.DeleteWebhook)
+			hooks.GET("/:id/deliveries", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleAdmin), handlers.GetWebhookDeliveries)
+			hooks.GET("/:id/deliveries/:delivery_id", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleAdmin), handlers.GetWebhookDelivery)
+			hooks.POST("/:id/deliveries/:delivery_id/redeliver", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleAdmin), handlers.RedeliverWebhookDelivery)
+		}
+
+		// Organization routes
+		organizations := v1.Group("/organizations")
+		{
+			organizations.GET("", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleSuperAdmin), handlers.GetOrganizations)
+			organizations.POST("", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleSuperAdmin), handlers.CreateOrganization)
+			organizations.GET("/access-log", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleSuperAdmin), handlers.GetTenantAccessLog)
+			organizations.GET("/:id", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleSuperAdmin), handlers.GetOrganization)
+			organizations.PUT("/:id", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleSuperAdmin), handlers.RenameOrganization)
+		}
+
+		// Trash routes
+		v1.GET("/trash", middlewares.AuthMiddleware(), middlewares.RequireRole(utils.RoleAdmin), handlers.GetTrash)
+
+		// Readings routes
+		readings := v1.Group("/readings")
+		{
+			readings.GET("/list", middlewares.AuthMiddleware(), middlewares.CacheResponse(middlewares.ReadingTags), handlers.GetReadings)
+			readings.GET("/cost", middlewares.AuthMiddleware(), handlers.GetReadingsCost)
+			readings.GET("/export", middlewares.AuthMiddleware(), handlers.ExportReadings)
+			readings.GET("/emissions", middlewares.AuthMiddleware(), handlers.GetReadingsEmissions)
+			readings.GET("/aggregate", middlewares.AuthMiddleware(), middlewares.CacheResponse(middlewares.ReadingTags), handlers.GetReadingsAggregate)
 		}
 	}
 }
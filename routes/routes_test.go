@@ -0,0 +1,46 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"product-tracker/config"
+	"product-tracker/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTrashRequiresAdmin(t *testing.T) {
+	if _, err := config.LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	SetupRoutes(router)
+
+	opts := utils.DefaultTokenOptions()
+	opts.Role = utils.RoleUser
+	opts.TenantID = 1
+	token, err := utils.GenerateToken(1, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, authorization := range []string{"", "Bearer " + token} {
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/trash", nil)
+		if authorization != "" {
+			request.Header.Set("Authorization", authorization)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		want := http.StatusForbidden
+		if authorization == "" {
+			want = http.StatusUnauthorized
+		}
+		if recorder.Code != want {
+			t.Errorf("GET /api/v1/trash with %q returned %d, want %d", authorization, recorder.Code, want)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"product-tracker/anomaly"
+	"product-tracker/config"
+	"product-tracker/models"
+	"time"
+)
+
+// Anomaly listing limits
+const (
+	DefaultAnomalyLimit = 100
+	MaxAnomalyLimit     = 1000
+)
+
+// anomalyColumns selects an anomaly a joined with its reading t
+const anomalyColumns = "a.id, a.reading_id, a.product_id, a.method, a.score, a.energy_kwh, a.expected_low, a.expected_high, " +
+	"a.baseline_size, t.date, t.recorded_at, a.detected_at, a.acknowledged_at, a.acknowledged_by, a.note"
+
+// readingTimeColumn orders readings in time; readings without recorded_at sort at the start of their date
+const readingTimeColumn = "COALESCE(t.recorded_at, t.date::timestamptz)"
+
+// NewDetector builds the anomaly detector configured in cfg
+func NewDetector(cfg *config.Config) (anomaly.Detector, error) {
+	return anomaly.New(cfg.Anomalies.Method, anomaly.Options{
+		Sensitivity: cfg.Anomalies.Sensitivity,
+		Window:      cfg.Anomalies.Window,
+		MinHistory:  cfg.Anomalies.MinHistory,
+		Alpha:       cfg.Anomalies.Alpha,
+	})
+}
+
+// checkReadings scores readings against the preceding readings of their product with the same interval length,
+// records the anomalous ones and marks all of them checked. Unacknowledged anomalies are left out of baselines so
+// that a run of faulty readings does not become the new normal. It returns the number of anomalies found.
+func (s *Storage) checkReadings(ctx context.Context, tx *sql.Tx, readingIDs []int64) (int, error) {
+	if s.detector == nil {
+		return 0, nil
+	}
+
+	baselineQuery := fmt.Sprintf(`
+		SELECT t.energy_consumed
+		FROM product_tracker t
+		WHERE t.product_id = $1 AND t.interval_seconds IS NOT DISTINCT FROM $2
+			AND (%[1]s, t.id) < ($3::timestamptz, $4::bigint)
+			AND NOT EXISTS (SELECT 1 FROM anomalies a WHERE a.reading_id = t.id AND a.acknowledged_at IS NULL)
+		ORDER BY %[1]s DESC, t.id DESC
+		LIMIT $5`, readingTimeColumn)
+
+	found := 0
+	for _, id := range readingIDs {
+		var productID sql.NullInt64
+		var intervalSeconds sql.NullInt64
+		var energy float64
+		var at time.Time
+		err := tx.QueryRowContext(ctx, fmt.Sprintf(`
+			SELECT t.product_id, t.interval_seconds, t.energy_consumed, %s
+			FROM product_tracker t WHERE t.id = $1`, readingTimeColumn), id).Scan(&productID, &intervalSeconds, &energy, &at)
+		if err != nil {
+			return 0, fmt.Errorf("failed to load reading %d: %w", id, err)
+		}
+
+		// Baselines are per product, so readings without one are only marked checked
+		if productID.Valid {
+			history, err := readingBaseline(ctx, tx, baselineQuery, productID.Int64, intervalSeconds, at, id, s.detector.Window())
+			if err != nil {
+				return 0, err
+			}
+			if result, ok := s.detector.Detect(history, energy); ok && result.Anomalous {
+				if _, err := tx.ExecContext(ctx, `
+					INSERT INTO anomalies (reading_id, product_id, method, score, energy_kwh, expected_low, expected_high, baseline_size)
+					VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+					ON CONFLICT (reading_id) DO NOTHING`,
+					id, productID.Int64, s.detector.Method(), result.Score, energy,
+					result.ExpectedLow, result.ExpectedHigh, len(history)); err != nil {
+					return 0, fmt.Errorf("failed to record anomaly: %w", err)
+				}
+				found++
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE product_tracker SET anomaly_checked_at = NOW() WHERE id = $1", id); err != nil {
+			return 0, fmt.Errorf("failed to mark reading checked: %w", err)
+		}
+	}
+	return found, nil
+}
+
+// readingBaseline returns up to window energy figures preceding a reading, oldest first
+func readingBaseline(ctx context.Context, tx *sql.Tx, query string, productID int64, intervalSeconds sql.NullInt64,
+	at time.Time, readingID int64, window int) ([]float64, error) {
+	rows, err := tx.QueryContext(ctx, query, productID, intervalSeconds, at, readingID, window)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reading baseline: %w", err)
+	}
+	defer rows.Close()
+
+	var history []float64
+	for rows.Next() {
+		var energy float64
+		if err := rows.Scan(&energy); err != nil {
+			return nil, fmt.Errorf("failed to scan reading baseline: %w", err)
+		}
+		history = append(history, energy)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reading baseline: %w", err)
+	}
+
+	// The query returns the newest readings first
+	for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+		history[i], history[j] = history[j], history[i]
+	}
+	return history, nil
+}
+
+// DetectAnomalies checks up to limit readings that have not been checked yet, oldest first, and returns the
+// number checked and the number of anomalies found. Readings claimed by a concurrent run are skipped.
+func (s *Storage) DetectAnomalies(ctx context.Context, limit int) (checked, found int, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(`
+		SELECT t.id FROM product_tracker t
+		WHERE t.anomaly_checked_at IS NULL
+		ORDER BY %s, t.id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`, readingTimeColumn), limit)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to query unchecked readings: %w", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("failed to scan unchecked reading: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, fmt.Errorf("error iterating unchecked readings: %w", err)
+	}
+
+	found, err = s.checkReadings(ctx, tx, ids)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(ids), found, nil
+}
+
+// AnomalyFilter narrows down a listing of anomalies
+type AnomalyFilter struct {
+	ProductID *int64
+	// Acknowledged keeps only acknowledged or only open anomalies when set
+	Acknowledged *bool
+	// Start and End bound the reading date, inclusive, when set
+	Start string
+	End   string
+	Limit int
+}
+
+// GetAnomalies retrieves the anomalies matching filter, most recently detected first.
+// Anomalies of products in the trash are hidden.
+func (s *Storage) GetAnomalies(ctx context.Context, filter AnomalyFilter) ([]models.Anomaly, error) {
+	conditions := []string{"p.deleted_at IS NULL"}
+	var args []interface{}
+	if filter.ProductID != nil {
+		args = append(args, *filter.ProductID)
+		conditions = append(conditions, fmt.Sprintf("a.product_id = $%d", len(args)))
+	}
+	if filter.Acknowledged != nil {
+		if *filter.Acknowledged {
+			conditions = append(conditions, "a.acknowledged_at IS NOT NULL")
+		} else {
+			conditions = append(conditions, "a.acknowledged_at IS NULL")
+		}
+	}
+	if filter.Start != "" {
+		args = append(args, filter.Start)
+		conditions = append(conditions, fmt.Sprintf("t.date >= $%d", len(args)))
+	}
+	if filter.End != "" {
+		args = append(args, filter.End)
+		conditions = append(conditions, fmt.Sprintf("t.date <= $%d", len(args)))
+	}
+	limit := filter.Limit
+	if limit <= 0 || limit > MaxAnomalyLimit {
+		limit = DefaultAnomalyLimit
+	}
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM anomalies a
+		JOIN product_tracker t ON t.id = a.reading_id
+		JOIN products p ON p.id = a.product_id
+		%s
+		ORDER BY a.detected_at DESC, a.id DESC
+		LIMIT $%d`, anomalyColumns, whereClause(conditions), len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query anomalies: %w", err)
+	}
+	defer rows.Close()
+
+	anomalies := []models.Anomaly{}
+	for rows.Next() {
+		a, err := scanAnomaly(rows)
+		if err != nil {
+			return nil, err
+		}
+		anomalies = append(anomalies, *a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating anomalies: %w", err)
+	}
+	return anomalies, nil
+}
+
+// AcknowledgeAnomaly marks an anomaly as seen by userID. Acknowledging again keeps the first acknowledgement
+// and only replaces the note when one is given. Acknowledged anomalies count towards baselines again.
+func (s *Storage) AcknowledgeAnomaly(ctx context.Context, id int64, userID uint, note string) (*models.Anomaly, error) {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE anomalies SET
+			acknowledged_at = COALESCE(acknowledged_at, NOW()),
+			acknowledged_by = COALESCE(acknowledged_by, $2),
+			note = CASE WHEN $3 = '' THEN note ELSE $3 END
+		WHERE id = $1`, id, int64(userID), note)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acknowledge anomaly: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	} else if affected == 0 {
+		return nil, ErrNotFound
+	}
+
+	row := s.db.QueryRowContext(ctx, fmt.Sprintf(`
+		SELECT %s
+		FROM anomalies a
+		JOIN product_tracker t ON t.id = a.reading_id
+		WHERE a.id = $1`, anomalyColumns), id)
+	return scanAnomaly(row)
+}
+
+// scanAnomaly scans a row selected with anomalyColumns
+func scanAnomaly(row rowScanner) (*models.Anomaly, error) {
+	var a models.Anomaly
+	var date time.Time
+	var acknowledgedBy sql.NullInt64
+	err := row.Scan(&a.ID, &a.ReadingID, &a.ProductID, &a.Method, &a.Score, &a.EnergyKWh, &a.ExpectedLow, &a.ExpectedHigh,
+		&a.BaselineSize, &date, &a.RecordedAt, &a.DetectedAt, &a.AcknowledgedAt, &acknowledgedBy, &a.Note)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan anomaly: %w", err)
+	}
+	a.Date = date.Format(models.ReadingDateLayout)
+	if acknowledgedBy.Valid {
+		by := uint(acknowledgedBy.Int64)
+		a.AcknowledgedBy = &by
+	}
+	return &a, nil
+}
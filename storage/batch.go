@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"product-tracker/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Batch loads read the data of many products in one query, so that nested GraphQL fields do not issue a query
+// per product
+
+// GetProductsByIDs retrieves the products with the given IDs, in ID order. Products in the trash and unknown IDs
+// are left out.
+func (s *Storage) GetProductsByIDs(ctx context.Context, ids []int64) ([]models.Product, error) {
+	query := `
+		SELECT ` + productColumns + `
+		FROM products
+		WHERE id = ANY($1) AND deleted_at IS NULL
+		ORDER BY id`
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query products: %w", err)
+	}
+	defer rows.Close()
+
+	return s.scanProducts(rows)
+}
+
+// GetReadingsByProducts retrieves up to limit readings per product with an ID above afterID, ordered by ID.
+// An empty Start or End of filter leaves the dates open on that side; its ProductID is ignored.
+func (s *Storage) GetReadingsByProducts(ctx context.Context, productIDs []int64, filter ReadingFilter, afterID int64, limit int) (map[int64][]models.Reading, error) {
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM (
+			SELECT t.*, ROW_NUMBER() OVER (PARTITION BY t.product_id ORDER BY t.id) AS position
+			FROM %s t
+			WHERE t.product_id = ANY($1) AND t.id > $2
+				AND ($3 = '' OR t.date >= $3::date) AND ($4 = '' OR t.date <= $4::date)
+		) t
+		%s
+		WHERE t.position <= $5 AND %s
+		ORDER BY t.product_id, t.id`, readingColumns, tableName, visibleReadingsJoin, visibleReadingsCondition)
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(productIDs), afterID, filter.Start, filter.End, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query readings: %w", err)
+	}
+	defer rows.Close()
+
+	readings, err := scanReadings(rows)
+	if err != nil {
+		return nil, err
+	}
+	byProduct := make(map[int64][]models.Reading, len(productIDs))
+	for _, r := range readings {
+		byProduct[*r.ProductID] = append(byProduct[*r.ProductID], r)
+	}
+	return byProduct, nil
+}
+
+// GetReadingAggregatesByProducts sums the rollups matching filter per product and period, ordered by period start.
+// Its ProductID is ignored. Periods without readings and products in the trash are left out.
+func (s *Storage) GetReadingAggregatesByProducts(ctx context.Context, filter RollupFilter, productIDs []int64) (map[int64][]models.ReadingPeriod, error) {
+	query := `
+		SELECT r.product_id, r.period_start, r.readings, r.quantity, r.energy_kwh
+		FROM reading_rollups r
+		JOIN products p ON p.id = r.product_id
+		WHERE r.timezone = $1 AND r.granularity = $2 AND r.period_start >= $3 AND r.period_start <= $4
+			AND r.product_id = ANY($5) AND p.deleted_at IS NULL
+		ORDER BY r.product_id, r.period_start`
+
+	rows, err := s.db.QueryContext(ctx, query, filter.Timezone, string(filter.Interval),
+		filter.Start.Format(models.ReadingDateLayout), filter.End.Format(models.ReadingDateLayout), pq.Array(productIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reading rollups: %w", err)
+	}
+	defer rows.Close()
+
+	byProduct := make(map[int64][]models.ReadingPeriod, len(productIDs))
+	for rows.Next() {
+		var productID int64
+		var periodStart time.Time
+		var readings, quantity int64
+		var energy float64
+		if err := rows.Scan(&productID, &periodStart, &readings, &quantity, &energy); err != nil {
+			return nil, fmt.Errorf("failed to scan reading rollup: %w", err)
+		}
+		byProduct[productID] = append(byProduct[productID], models.ReadingPeriod{
+			PeriodStart: periodStart.Format(models.ReadingDateLayout),
+			Readings:    &readings,
+			Quantity:    &quantity,
+			EnergyKWh:   &energy,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reading rollups: %w", err)
+	}
+	return byProduct, nil
+}
+
+// GetReadingStatsByProducts totals the readings of each product. Products without readings are left out.
+func (s *Storage) GetReadingStatsByProducts(ctx context.Context, productIDs []int64) (map[int64]models.ReadingStats, error) {
+	query := fmt.Sprintf(`
+		SELECT t.product_id, COUNT(*), COALESCE(SUM(t.quantity), 0), COALESCE(SUM(t.energy_consumed), 0),
+			COALESCE(AVG(t.energy_consumed), 0)
+		FROM %s t
+		WHERE t.product_id = ANY($1)
+		GROUP BY t.product_id`, tableName)
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(productIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reading stats: %w", err)
+	}
+	defer rows.Close()
+
+	byProduct := make(map[int64]models.ReadingStats, len(productIDs))
+	for rows.Next() {
+		var productID int64
+		var stats models.ReadingStats
+		if err := rows.Scan(&productID, &stats.Readings, &stats.Quantity, &stats.EnergyKWh, &stats.AverageEnergyKWh); err != nil {
+			return nil, fmt.Errorf("failed to scan reading stats: %w", err)
+		}
+		byProduct[productID] = stats
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reading stats: %w", err)
+	}
+	return byProduct, nil
+}
@@ -0,0 +1,516 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"product-tracker/budget"
+	"product-tracker/costcalc"
+	"product-tracker/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// budgetColumns selects a budget row
+const budgetColumns = "id, name, scope, product_id, category_id, metric, limit_value, limit_minor, currency, tariff_id, " +
+	"timezone, thresholds, created_at, updated_at"
+
+// alertColumns selects an alert a joined with its budget b
+const alertColumns = "a.id, a.budget_id, b.name, a.kind, a.threshold, a.period_start, a.state, a.unit, a.used, a.limit_value, " +
+	"a.percent, a.projected, a.fire_count, a.fired_at, a.resolved_at, a.last_evaluated_at, a.acknowledged_at, a.acknowledged_by"
+
+// prepareBudget validates a budget and the product, category and tariff it refers to
+func prepareBudget(ctx context.Context, tx *sql.Tx, b *models.Budget) error {
+	currency := ""
+	if b.Metric == budget.MetricCost && b.TariffID != nil {
+		err := tx.QueryRowContext(ctx, "SELECT currency FROM tariffs WHERE id = $1", *b.TariffID).Scan(&currency)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: tariff %d does not exist", budget.ErrInvalidBudget, *b.TariffID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load tariff: %w", err)
+		}
+	}
+	if err := budget.Normalize(b, currency); err != nil {
+		return err
+	}
+
+	if b.ProductID != nil {
+		var exists bool
+		if err := tx.QueryRowContext(ctx,
+			"SELECT EXISTS (SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NULL)", *b.ProductID,
+		).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to check product: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("%w: product %d does not exist", budget.ErrInvalidBudget, *b.ProductID)
+		}
+	}
+	return validateCategory(ctx, tx, b.CategoryID)
+}
+
+// CreateBudget stores a new budget, filling in its ID, thresholds and timestamps
+func (s *Storage) CreateBudget(ctx context.Context, b *models.Budget) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := prepareBudget(ctx, tx, b); err != nil {
+		return err
+	}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO budgets (name, scope, product_id, category_id, metric, limit_value, limit_minor, currency, tariff_id,
+			timezone, thresholds)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at, updated_at`,
+		b.Name, b.Scope, b.ProductID, b.CategoryID, b.Metric, b.Limit, b.LimitMinor, b.Currency, b.TariffID,
+		b.Timezone, pq.Array(b.Thresholds),
+	).Scan(&b.ID, &b.CreatedAt, &b.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert budget: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// UpdateBudget replaces a budget. Alerts of thresholds it no longer has are resolved on its next evaluation.
+func (s *Storage) UpdateBudget(ctx context.Context, b *models.Budget) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := prepareBudget(ctx, tx, b); err != nil {
+		return err
+	}
+	err = tx.QueryRowContext(ctx, `
+		UPDATE budgets
+		SET name = $2, scope = $3, product_id = $4, category_id = $5, metric = $6, limit_value = $7, limit_minor = $8,
+			currency = $9, tariff_id = $10, timezone = $11, thresholds = $12, updated_at = NOW()
+		WHERE id = $1
+		RETURNING created_at, updated_at`,
+		b.ID, b.Name, b.Scope, b.ProductID, b.CategoryID, b.Metric, b.Limit, b.LimitMinor, b.Currency, b.TariffID,
+		b.Timezone, pq.Array(b.Thresholds),
+	).Scan(&b.CreatedAt, &b.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update budget: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// DeleteBudget removes a budget and its alerts
+func (s *Storage) DeleteBudget(ctx context.Context, id int64) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM budgets WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete budget: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetBudget retrieves a single budget
+func (s *Storage) GetBudget(ctx context.Context, id int64) (*models.Budget, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+budgetColumns+" FROM budgets WHERE id = $1", id)
+	return scanBudget(row)
+}
+
+// GetBudgets retrieves every budget ordered by name
+func (s *Storage) GetBudgets(ctx context.Context) ([]models.Budget, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+budgetColumns+" FROM budgets ORDER BY name, id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query budgets: %w", err)
+	}
+	defer rows.Close()
+
+	budgets := []models.Budget{}
+	for rows.Next() {
+		b, err := scanBudget(rows)
+		if err != nil {
+			return nil, err
+		}
+		budgets = append(budgets, *b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating budgets: %w", err)
+	}
+	return budgets, nil
+}
+
+// scanBudget scans a row selected with budgetColumns
+func scanBudget(row rowScanner) (*models.Budget, error) {
+	var b models.Budget
+	var productID, categoryID, limitMinor, tariffID sql.NullInt64
+	var currency sql.NullString
+	var thresholds pq.Int64Array
+	err := row.Scan(&b.ID, &b.Name, &b.Scope, &productID, &categoryID, &b.Metric, &b.Limit, &limitMinor, &currency,
+		&tariffID, &b.Timezone, &thresholds, &b.CreatedAt, &b.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan budget: %w", err)
+	}
+	if productID.Valid {
+		b.ProductID = &productID.Int64
+	}
+	if categoryID.Valid {
+		b.CategoryID = &categoryID.Int64
+	}
+	if limitMinor.Valid {
+		b.LimitMinor = &limitMinor.Int64
+	}
+	if currency.Valid {
+		b.Currency = &currency.String
+	}
+	if tariffID.Valid {
+		b.TariffID = &tariffID.Int64
+	}
+	b.Thresholds = make([]int, len(thresholds))
+	for i, t := range thresholds {
+		b.Thresholds[i] = int(t)
+	}
+	return &b, nil
+}
+
+// budgetStatus computes the month-to-date status of a budget from the daily rollups of its timezone.
+// Readings of products in the trash do not count.
+func (s *Storage) budgetStatus(ctx context.Context, tx *sql.Tx, b *models.Budget, now time.Time) (*models.BudgetStatus, []budget.Condition, error) {
+	location, err := time.LoadLocation(b.Timezone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("budget %d has an unknown timezone %q", b.ID, b.Timezone)
+	}
+	period := budget.CurrentPeriod(now, location)
+
+	conditions := []string{
+		"r.timezone = $1", "r.granularity = 'day'", "r.period_start >= $2", "r.period_start <= $3",
+		"(r.product_id IS NULL OR p.deleted_at IS NULL)",
+	}
+	args := []interface{}{b.Timezone, period.Start.Format(models.ReadingDateLayout), period.End.Format(models.ReadingDateLayout)}
+	// Only the readings of the tenant of the budget count, when evaluated for all tenants
+	args = append(args, b.ID)
+	conditions = append(conditions, fmt.Sprintf("r.tenant_id = (SELECT tenant_id FROM budgets WHERE id = $%d)", len(args)))
+	switch b.Scope {
+	case budget.ScopeProduct:
+		args = append(args, *b.ProductID)
+		conditions = append(conditions, fmt.Sprintf("r.product_id = $%d", len(args)))
+	case budget.ScopeCategory:
+		args = append(args, *b.CategoryID)
+		conditions = append(conditions, fmt.Sprintf("p.category_id IN (%s)", categoryTreeQuery(len(args))))
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT r.period_start, SUM(r.energy_kwh)
+		FROM reading_rollups r
+		LEFT JOIN products p ON p.id = r.product_id
+		`+whereClause(conditions)+`
+		GROUP BY r.period_start
+		ORDER BY r.period_start`, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query budget usage: %w", err)
+	}
+	var usage []budget.DayUsage
+	for rows.Next() {
+		var u budget.DayUsage
+		if err := rows.Scan(&u.Day, &u.KWh); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("failed to scan budget usage: %w", err)
+		}
+		usage = append(usage, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating budget usage: %w", err)
+	}
+
+	var tariff *costcalc.Tariff
+	if b.TariffID != nil {
+		stored, err := s.GetTariff(ctx, *b.TariffID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load the tariff of budget %d: %w", b.ID, err)
+		}
+		if tariff, err = costcalc.FromModel(stored); err != nil {
+			return nil, nil, err
+		}
+	}
+	return budget.Evaluate(b, period, usage, tariff, s.projectionMinDays, now)
+}
+
+// GetBudgetStatus computes the month-to-date status of a budget without changing its alerts
+func (s *Storage) GetBudgetStatus(ctx context.Context, id int64, now time.Time) (*models.BudgetStatus, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	b, err := scanBudget(tx.QueryRowContext(ctx, "SELECT "+budgetColumns+" FROM budgets WHERE id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	status, _, err := s.budgetStatus(ctx, tx, b, now)
+	return status, err
+}
+
+// EvaluateBudget computes the status of a budget and fires or resolves its alerts accordingly. An alert is kept
+// per budget, month, kind and threshold: a condition that holds again reopens its alert instead of adding one.
+// Firing alerts of earlier months are resolved.
+func (s *Storage) EvaluateBudget(ctx context.Context, id int64, now time.Time) (*models.BudgetStatus, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the budget so that concurrent evaluations apply their transitions one after the other
+	b, err := scanBudget(tx.QueryRowContext(ctx, "SELECT "+budgetColumns+" FROM budgets WHERE id = $1 FOR UPDATE", id))
+	if err != nil {
+		return nil, err
+	}
+	status, conditions, err := s.budgetStatus(ctx, tx, b, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE alerts SET state = 'resolved', resolved_at = NOW(), last_evaluated_at = NOW()
+		WHERE budget_id = $1 AND state = 'firing'
+			AND (period_start < $2 OR (kind = 'threshold' AND NOT threshold = ANY($3)))`,
+		b.ID, status.PeriodStart, pq.Array(b.Thresholds)); err != nil {
+		return nil, fmt.Errorf("failed to resolve stale alerts: %w", err)
+	}
+
+	for _, condition := range conditions {
+		if err := applyAlertCondition(ctx, tx, status, condition); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return status, nil
+}
+
+// applyAlertCondition fires, refreshes or resolves the alert of one condition
+func applyAlertCondition(ctx context.Context, tx *sql.Tx, status *models.BudgetStatus, condition budget.Condition) error {
+	if condition.Firing {
+		// A resolved alert that fires again is reopened and needs acknowledging again
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO alerts (budget_id, kind, threshold, period_start, state, unit, used, limit_value, percent, projected)
+			VALUES ($1, $2, $3, $4, 'firing', $5, $6, $7, $8, $9)
+			ON CONFLICT (budget_id, period_start, kind, threshold) DO UPDATE SET
+				state = 'firing',
+				unit = EXCLUDED.unit, used = EXCLUDED.used, limit_value = EXCLUDED.limit_value,
+				percent = EXCLUDED.percent, projected = EXCLUDED.projected,
+				fire_count = alerts.fire_count + CASE WHEN alerts.state = 'resolved' THEN 1 ELSE 0 END,
+				fired_at = CASE WHEN alerts.state = 'resolved' THEN NOW() ELSE alerts.fired_at END,
+				resolved_at = NULL,
+				acknowledged_at = CASE WHEN alerts.state = 'resolved' THEN NULL ELSE alerts.acknowledged_at END,
+				acknowledged_by = CASE WHEN alerts.state = 'resolved' THEN NULL ELSE alerts.acknowledged_by END,
+				last_evaluated_at = NOW()`,
+			status.BudgetID, condition.Kind, condition.Threshold, status.PeriodStart, status.Unit, status.Used,
+			status.Limit, status.Percent, status.Projected)
+		if err != nil {
+			return fmt.Errorf("failed to fire alert: %w", err)
+		}
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		UPDATE alerts SET
+			state = 'resolved', resolved_at = NOW(), last_evaluated_at = NOW(),
+			used = $5, percent = $6, projected = $7
+		WHERE budget_id = $1 AND kind = $2 AND threshold = $3 AND period_start = $4 AND state = 'firing'`,
+		status.BudgetID, condition.Kind, condition.Threshold, status.PeriodStart, status.Used, status.Percent, status.Projected)
+	if err != nil {
+		return fmt.Errorf("failed to resolve alert: %w", err)
+	}
+	return nil
+}
+
+// EvaluateBudgets evaluates every budget and returns the number evaluated. A budget that fails to evaluate,
+// for instance for lack of a tariff rate, is logged and skipped.
+func (s *Storage) EvaluateBudgets(ctx context.Context, now time.Time) (int, error) {
+	return s.evaluateBudgets(ctx, "SELECT id FROM budgets ORDER BY id", now)
+}
+
+// evaluateBudgetsForProducts evaluates the budgets covering new readings of the given products: their product
+// and category budgets and every portfolio budget. Failures are logged since the readings are already stored.
+func (s *Storage) evaluateBudgetsForProducts(ctx context.Context, productIDs []int64) {
+	_, err := s.evaluateBudgets(ctx, `
+		WITH RECURSIVE ancestors AS (
+			SELECT category_id AS id FROM products WHERE id = ANY($1) AND category_id IS NOT NULL
+			UNION
+			SELECT c.parent_id FROM categories c JOIN ancestors a ON c.id = a.id WHERE c.parent_id IS NOT NULL
+		)
+		SELECT id FROM budgets
+		WHERE (scope = 'portfolio' AND tenant_id IN (SELECT tenant_id FROM products WHERE id = ANY($1)))
+			OR product_id = ANY($1) OR category_id IN (SELECT id FROM ancestors)
+		ORDER BY id`, time.Now(), pq.Array(productIDs))
+	if err != nil {
+		log.Printf("❌ Failed to evaluate budgets after ingestion: %v", err)
+	}
+}
+
+// evaluateBudgets evaluates the budgets whose IDs query selects
+func (s *Storage) evaluateBudgets(ctx context.Context, query string, now time.Time, args ...interface{}) (int, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query budgets: %w", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan budget: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating budgets: %w", err)
+	}
+
+	evaluated := 0
+	for _, id := range ids {
+		if _, err := s.EvaluateBudget(ctx, id, now); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			log.Printf("❌ Failed to evaluate budget %d: %v", id, err)
+			continue
+		}
+		evaluated++
+	}
+	return evaluated, nil
+}
+
+// AlertFilter narrows down a listing of alerts
+type AlertFilter struct {
+	BudgetID *int64
+	// State keeps only firing or only resolved alerts when set
+	State string
+	// Acknowledged keeps only acknowledged or only unacknowledged alerts when set
+	Acknowledged *bool
+}
+
+// GetAlerts retrieves the alerts matching filter, most recently fired first
+func (s *Storage) GetAlerts(ctx context.Context, filter AlertFilter) ([]models.Alert, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.BudgetID != nil {
+		args = append(args, *filter.BudgetID)
+		conditions = append(conditions, fmt.Sprintf("a.budget_id = $%d", len(args)))
+	}
+	if filter.State != "" {
+		args = append(args, filter.State)
+		conditions = append(conditions, fmt.Sprintf("a.state = $%d", len(args)))
+	}
+	if filter.Acknowledged != nil {
+		if *filter.Acknowledged {
+			conditions = append(conditions, "a.acknowledged_at IS NOT NULL")
+		} else {
+			conditions = append(conditions, "a.acknowledged_at IS NULL")
+		}
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+alertColumns+`
+		FROM alerts a
+		JOIN budgets b ON b.id = a.budget_id
+		`+whereClause(conditions)+`
+		ORDER BY a.fired_at DESC, a.id DESC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alerts: %w", err)
+	}
+	defer rows.Close()
+
+	alerts := []models.Alert{}
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, *a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating alerts: %w", err)
+	}
+	return alerts, nil
+}
+
+// AcknowledgeAlert marks an alert as seen by userID. Acknowledging again keeps the first acknowledgement.
+func (s *Storage) AcknowledgeAlert(ctx context.Context, id int64, userID uint) (*models.Alert, error) {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE alerts SET acknowledged_at = COALESCE(acknowledged_at, NOW()), acknowledged_by = COALESCE(acknowledged_by, $2)
+		WHERE id = $1`, id, int64(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to acknowledge alert: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	} else if affected == 0 {
+		return nil, ErrNotFound
+	}
+
+	row := s.db.QueryRowContext(ctx, `
+		SELECT `+alertColumns+`
+		FROM alerts a
+		JOIN budgets b ON b.id = a.budget_id
+		WHERE a.id = $1`, id)
+	return scanAlert(row)
+}
+
+// scanAlert scans a row selected with alertColumns
+func scanAlert(row rowScanner) (*models.Alert, error) {
+	var a models.Alert
+	var threshold int
+	var periodStart time.Time
+	var projected sql.NullFloat64
+	var acknowledgedBy sql.NullInt64
+	err := row.Scan(&a.ID, &a.BudgetID, &a.BudgetName, &a.Kind, &threshold, &periodStart, &a.State, &a.Unit, &a.Used,
+		&a.Limit, &a.Percent, &projected, &a.FireCount, &a.FiredAt, &a.ResolvedAt, &a.LastEvaluatedAt,
+		&a.AcknowledgedAt, &acknowledgedBy)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan alert: %w", err)
+	}
+	if a.Kind == budget.KindThreshold {
+		a.Threshold = &threshold
+	}
+	a.PeriodStart = periodStart.Format(models.ReadingDateLayout)
+	if projected.Valid {
+		a.Projected = &projected.Float64
+	}
+	if acknowledgedBy.Valid {
+		by := uint(acknowledgedBy.Int64)
+		a.AcknowledgedBy = &by
+	}
+	return &a, nil
+}
@@ -0,0 +1,542 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"product-tracker/cache"
+	"product-tracker/models"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// maxTagLength is the longest tag name accepted
+const maxTagLength = 64
+
+// Postgres error codes raised by constraint violations
+const (
+	foreignKeyViolation = "23503"
+	uniqueViolation     = "23505"
+)
+
+// categoryTreeQuery returns a query selecting the IDs of the category bound to
+// placeholder n and all of its descendants
+func categoryTreeQuery(n int) string {
+	return fmt.Sprintf(`
+		WITH RECURSIVE tree AS (
+			SELECT id FROM categories WHERE id = $%d
+			UNION ALL
+			SELECT c.id FROM categories c JOIN tree ON c.parent_id = tree.id
+		)
+		SELECT id FROM tree`, n)
+}
+
+// validateCategory checks that a product's category exists
+func validateCategory(ctx context.Context, tx *sql.Tx, categoryID *int64) error {
+	if categoryID == nil {
+		return nil
+	}
+
+	var exists bool
+	err := tx.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1)", *categoryID,
+	).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check category: %w", err)
+	}
+	if !exists {
+		return ErrInvalidCategory
+	}
+	return nil
+}
+
+// normalizeTag trims and lowercases a tag name
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// NormalizeTags trims, lowercases and de-duplicates tag names, rejecting empty or overly long ones
+func NormalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = normalizeTag(tag)
+		if tag == "" || len(tag) > maxTagLength {
+			return nil, fmt.Errorf("%w: tags must be between 1 and %d characters", ErrInvalidTag, maxTagLength)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized, nil
+}
+
+// setProductTags replaces the tags of a product, creating missing tags on the fly.
+// It returns the normalized, sorted tag names.
+func setProductTags(ctx context.Context, tx *sql.Tx, productID int64, tags []string) ([]string, error) {
+	tags, err := NormalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM product_tags WHERE product_id = $1", productID); err != nil {
+		return nil, fmt.Errorf("failed to clear product tags: %w", err)
+	}
+	if len(tags) == 0 {
+		return []string{}, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO tags (tenant_id, name)
+		SELECT p.tenant_id, unnest($2::text[]) FROM products p WHERE p.id = $1
+		ON CONFLICT (tenant_id, name) DO NOTHING`,
+		productID, pq.Array(tags),
+	); err != nil {
+		return nil, fmt.Errorf("failed to create tags: %w", err)
+	}
+
+	var sorted []string
+	err = tx.QueryRowContext(ctx, `
+		WITH inserted AS (
+			INSERT INTO product_tags (product_id, tag_id)
+			SELECT $1, tg.id FROM tags tg JOIN products p ON p.id = $1 AND p.tenant_id = tg.tenant_id
+			WHERE tg.name = ANY($2)
+			RETURNING tag_id
+		)
+		SELECT COALESCE(array_agg(tg.name ORDER BY tg.name), '{}')
+		FROM inserted JOIN tags tg ON tg.id = inserted.tag_id`,
+		productID, pq.Array(tags),
+	).Scan(pq.Array(&sorted))
+	if err != nil {
+		return nil, fmt.Errorf("failed to tag product: %w", err)
+	}
+	return sorted, nil
+}
+
+// CreateCategory inserts a new category
+func (s *Storage) CreateCategory(ctx context.Context, category *models.Category) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := validateCategory(ctx, tx, category.ParentID); err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO categories (name, parent_id)
+		VALUES ($1, $2)
+		RETURNING id, created_at, updated_at`,
+		category.Name, category.ParentID,
+	).Scan(&category.ID, &category.CreatedAt, &category.UpdatedAt)
+	if err != nil {
+		return categoryWriteError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// UpdateCategory renames or moves a category, refusing moves below one of its own descendants
+func (s *Storage) UpdateCategory(ctx context.Context, category *models.Category) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := validateCategory(ctx, tx, category.ParentID); err != nil {
+		return err
+	}
+
+	if category.ParentID != nil {
+		var cycle bool
+		err := tx.QueryRowContext(ctx,
+			"SELECT $2 IN ("+categoryTreeQuery(1)+")", category.ID, *category.ParentID,
+		).Scan(&cycle)
+		if err != nil {
+			return fmt.Errorf("failed to check category tree: %w", err)
+		}
+		if cycle {
+			return ErrCategoryCycle
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		UPDATE categories
+		SET name = $2, parent_id = $3, updated_at = NOW()
+		WHERE id = $1
+		RETURNING created_at, updated_at`,
+		category.ID, category.Name, category.ParentID,
+	).Scan(&category.CreatedAt, &category.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return categoryWriteError(err)
+	}
+
+	// A move can change which rating scheme applies below the category
+	if _, err := rerateCategoryTree(ctx, tx, category.ID); err != nil {
+		return err
+	}
+
+	if err := recordInvalidation(ctx, tx, cache.AllProductsTags()...); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	cache.AllProductsChanged()
+	return nil
+}
+
+// DeleteCategory removes a category without subcategories. Its products become uncategorized and unrated,
+// each as a new version, and its rating scheme is deleted.
+func (s *Storage) DeleteCategory(ctx context.Context, id int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var hasChildren bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM categories WHERE parent_id = $1)", id,
+	).Scan(&hasChildren)
+	if err != nil {
+		return fmt.Errorf("failed to check subcategories: %w", err)
+	}
+	if hasChildren {
+		return ErrCategoryNotEmpty
+	}
+
+	products, err := lockProducts(ctx, tx, "category_id = $1", id)
+	if err != nil {
+		return err
+	}
+	for _, p := range products {
+		err := reviseProduct(ctx, tx, p, `category_id = NULL, rating_class = NULL, rating_index = NULL,
+			rating_scheme_id = NULL, rating_scheme_version = NULL`)
+		if err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM categories WHERE id = $1", id)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+			return ErrCategoryNotEmpty
+		}
+		return fmt.Errorf("failed to delete category: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	if err := recordInvalidation(ctx, tx, cache.AllProductsTags()...); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	cache.AllProductsChanged()
+	return nil
+}
+
+// GetCategory retrieves a category with its direct subcategories
+func (s *Storage) GetCategory(ctx context.Context, id int64) (*models.Category, error) {
+	categories, err := s.queryCategories(ctx, `
+		SELECT id, name, parent_id, created_at, updated_at
+		FROM categories
+		WHERE id = $1 OR parent_id = $1
+		ORDER BY name`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var category *models.Category
+	var children []models.Category
+	for i := range categories {
+		if categories[i].ID == id {
+			category = &categories[i]
+		} else {
+			children = append(children, categories[i])
+		}
+	}
+	if category == nil {
+		return nil, ErrNotFound
+	}
+	category.Children = children
+	return category, nil
+}
+
+// GetCategories retrieves the category tree, returning the root categories with their descendants nested
+func (s *Storage) GetCategories(ctx context.Context) ([]models.Category, error) {
+	categories, err := s.queryCategories(ctx, `
+		SELECT id, name, parent_id, created_at, updated_at
+		FROM categories
+		ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	return buildCategoryTree(categories, nil), nil
+}
+
+// buildCategoryTree nests the categories below parentID, keeping their order
+func buildCategoryTree(categories []models.Category, parentID *int64) []models.Category {
+	var tree []models.Category
+	for _, category := range categories {
+		if (parentID == nil) != (category.ParentID == nil) {
+			continue
+		}
+		if parentID != nil && *parentID != *category.ParentID {
+			continue
+		}
+		category.Children = buildCategoryTree(categories, &category.ID)
+		tree = append(tree, category)
+	}
+	return tree
+}
+
+// queryCategories runs a query selecting category rows
+func (s *Storage) queryCategories(ctx context.Context, query string, args ...any) ([]models.Category, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query categories: %w", err)
+	}
+	defer rows.Close()
+
+	var categories []models.Category
+	for rows.Next() {
+		var category models.Category
+		if err := rows.Scan(
+			&category.ID,
+			&category.Name,
+			&category.ParentID,
+			&category.CreatedAt,
+			&category.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan category: %w", err)
+		}
+		categories = append(categories, category)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating categories: %w", err)
+	}
+	return categories, nil
+}
+
+// categoryWriteError maps constraint violations on categories to storage errors
+func categoryWriteError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return ErrDuplicate
+	}
+	return fmt.Errorf("failed to write category: %w", err)
+}
+
+// CreateTag inserts a new tag
+func (s *Storage) CreateTag(ctx context.Context, tag *models.Tag) error {
+	tags, err := NormalizeTags([]string{tag.Name})
+	if err != nil {
+		return err
+	}
+	tag.Name = tags[0]
+
+	err = s.db.QueryRowContext(ctx,
+		"INSERT INTO tags (name) VALUES ($1) RETURNING id, created_at", tag.Name,
+	).Scan(&tag.ID, &tag.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return ErrDuplicate
+		}
+		return fmt.Errorf("failed to create tag: %w", err)
+	}
+	return nil
+}
+
+// RenameTag changes the name of a tag on every product carrying it, each of which gets a new version
+func (s *Storage) RenameTag(ctx context.Context, tag *models.Tag) error {
+	tags, err := NormalizeTags([]string{tag.Name})
+	if err != nil {
+		return err
+	}
+	tag.Name = tags[0]
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	products, err := lockProducts(ctx, tx, taggedProductsCondition, tag.ID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		UPDATE tags SET name = $2 WHERE id = $1
+		RETURNING created_at, (SELECT COUNT(*) FROM product_tags WHERE tag_id = $1)`,
+		tag.ID, tag.Name,
+	).Scan(&tag.CreatedAt, &tag.ProductCount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return ErrDuplicate
+		}
+		return fmt.Errorf("failed to rename tag: %w", err)
+	}
+
+	for _, p := range products {
+		if err := reviseProduct(ctx, tx, p, ""); err != nil {
+			return err
+		}
+	}
+
+	if err := recordInvalidation(ctx, tx, cache.AllProductsTags()...); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	cache.AllProductsChanged()
+	return nil
+}
+
+// DeleteTag removes a tag from every product, each of which gets a new version, and deletes it
+func (s *Storage) DeleteTag(ctx context.Context, id int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	products, err := lockProducts(ctx, tx, taggedProductsCondition, id)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM tags WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete tag: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	for _, p := range products {
+		if err := reviseProduct(ctx, tx, p, ""); err != nil {
+			return err
+		}
+	}
+
+	if err := recordInvalidation(ctx, tx, cache.AllProductsTags()...); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	cache.AllProductsChanged()
+	return nil
+}
+
+// taggedProductsCondition matches the products carrying the tag with the ID given as $1
+const taggedProductsCondition = "id IN (SELECT product_id FROM product_tags WHERE tag_id = $1)"
+
+// GetTags retrieves every tag with the number of products carrying it
+func (s *Storage) GetTags(ctx context.Context) ([]models.Tag, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT tg.id, tg.name, tg.created_at, COUNT(pt.product_id)
+		FROM tags tg
+		LEFT JOIN product_tags pt ON pt.tag_id = tg.id
+		GROUP BY tg.id
+		ORDER BY tg.name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []models.Tag
+	for rows.Next() {
+		var tag models.Tag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.CreatedAt, &tag.ProductCount); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tags: %w", err)
+	}
+	return tags, nil
+}
+
+// GetProductStatsByCategory retrieves reading statistics grouped by the category of their product
+func (s *Storage) GetProductStatsByCategory(ctx context.Context) ([]models.CategoryStats, error) {
+	query := fmt.Sprintf(`
+		SELECT
+			c.id,
+			COALESCE(c.name, ''),
+			COUNT(*) as total_products,
+			COALESCE(SUM(t.quantity), 0) as total_quantity,
+			COALESCE(SUM(t.energy_consumed), 0) as total_energy,
+			COALESCE(AVG(t.energy_consumed), 0) as avg_energy
+		FROM %s t
+		%s
+		LEFT JOIN categories c ON c.id = p.category_id
+		WHERE %s
+		GROUP BY c.id, c.name
+		ORDER BY c.name NULLS LAST`, tableName, visibleReadingsJoin, visibleReadingsCondition)
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product stats by category: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []models.CategoryStats
+	for rows.Next() {
+		var row models.CategoryStats
+		if err := rows.Scan(
+			&row.CategoryID,
+			&row.CategoryName,
+			&row.TotalProducts,
+			&row.TotalQuantity,
+			&row.TotalEnergy,
+			&row.AverageEnergy,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan category stats: %w", err)
+		}
+		stats = append(stats, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating category stats: %w", err)
+	}
+	return stats, nil
+}
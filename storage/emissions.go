@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"product-tracker/emissions"
+	"product-tracker/models"
+	"time"
+)
+
+// normalizeRegion validates an optional region code, returning it in upper case
+func normalizeRegion(region *string) (*string, error) {
+	if region == nil || *region == "" {
+		return nil, nil
+	}
+	normalized, err := emissions.ParseRegion(*region)
+	if err != nil {
+		return nil, err
+	}
+	return &normalized, nil
+}
+
+// ImportEmissionFactors stores emission factors as new versions. A factor identical to the current version
+// for its region and year is left alone and returned with that version. The import is all or nothing.
+func (s *Storage) ImportEmissionFactors(ctx context.Context, factors []models.EmissionFactor) error {
+	for i := range factors {
+		if err := emissions.NormalizeFactor(&factors[i]); err != nil {
+			return fmt.Errorf("factor %d: %w", i+1, err)
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Serialize imports so that concurrent ones cannot claim the same version
+	if _, err := tx.ExecContext(ctx, "LOCK TABLE emission_factors IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return fmt.Errorf("failed to lock emission factors: %w", err)
+	}
+
+	for i := range factors {
+		f := &factors[i]
+		err := tx.QueryRowContext(ctx, `
+			WITH current AS (
+				SELECT version, factor, source, created_at
+				FROM emission_factors
+				WHERE region = $1 AND year = $2
+				ORDER BY version DESC
+				LIMIT 1
+			), inserted AS (
+				INSERT INTO emission_factors (region, year, version, factor, source)
+				SELECT $1, $2, COALESCE((SELECT version FROM current), 0) + 1, $3, $4
+				WHERE NOT EXISTS (SELECT 1 FROM current WHERE factor = $3::numeric AND source = $4)
+				RETURNING version, created_at
+			)
+			SELECT version, created_at FROM inserted
+			UNION ALL
+			SELECT version, created_at FROM current WHERE NOT EXISTS (SELECT 1 FROM inserted)`,
+			f.Region, f.Year, f.Factor, f.Source,
+		).Scan(&f.Version, &f.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to store emission factor: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// EmissionFactorFilter narrows down a listing of emission factors
+type EmissionFactorFilter struct {
+	Region string
+	Year   int
+	// AsOf keeps the versions recorded at or before this time; zero means now
+	AsOf time.Time
+	// AllVersions returns every version instead of the latest one per region and year
+	AllVersions bool
+}
+
+// GetEmissionFactors retrieves emission factors ordered by region and year
+func (s *Storage) GetEmissionFactors(ctx context.Context, filter EmissionFactorFilter) ([]models.EmissionFactor, error) {
+	asOf := filter.AsOf
+	if asOf.IsZero() {
+		asOf = time.Now()
+	}
+
+	distinct := "DISTINCT ON (region, year)"
+	if filter.AllVersions {
+		distinct = ""
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+distinct+` region, year, factor::text, version, source, created_at
+		FROM emission_factors
+		WHERE ($1 = '' OR region = $1) AND ($2 = 0 OR year = $2) AND created_at <= $3
+		ORDER BY region, year, version DESC`,
+		filter.Region, filter.Year, asOf,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query emission factors: %w", err)
+	}
+	defer rows.Close()
+
+	var factors []models.EmissionFactor
+	for rows.Next() {
+		var f models.EmissionFactor
+		if err := rows.Scan(&f.Region, &f.Year, &f.Factor, &f.Version, &f.Source, &f.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan emission factor: %w", err)
+		}
+		factors = append(factors, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating emission factors: %w", err)
+	}
+	return factors, nil
+}
+
+// NewEmissionFactors loads the emission factors in effect at asOf for lookups; zero asOf means now
+func (s *Storage) NewEmissionFactors(ctx context.Context, asOf time.Time) (*emissions.Factors, error) {
+	if asOf.IsZero() {
+		asOf = time.Now().UTC()
+	}
+	factors, err := s.GetEmissionFactors(ctx, EmissionFactorFilter{AsOf: asOf})
+	if err != nil {
+		return nil, err
+	}
+	return emissions.NewFactors(factors, asOf)
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"product-tracker/models"
+	"product-tracker/units"
+)
+
+// marshalEnergyInput encodes a submitted energy figure as a JSONB parameter, using NULL when it is missing
+func marshalEnergyInput(in *models.EnergyInput) (sql.NullString, error) {
+	if in == nil {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(in)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to encode energy input: %w", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// unmarshalEnergyInput decodes a submitted energy figure, returning nil for SQL NULL
+func unmarshalEnergyInput(data []byte) (*models.EnergyInput, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var in models.EnergyInput
+	if err := json.Unmarshal(data, &in); err != nil {
+		return nil, fmt.Errorf("failed to decode energy input: %w", err)
+	}
+	return &in, nil
+}
+
+// canonicalReadingEnergy converts the energy of a reading to kWh, returning the submitted figure for audit
+func canonicalReadingEnergy(p Product) (float64, sql.NullString, error) {
+	if p.EnergyUnit == "" {
+		return p.EnergyConsumed, sql.NullString{}, nil
+	}
+
+	input := &models.EnergyInput{Value: p.EnergyConsumed, Unit: p.EnergyUnit}
+	energy, err := input.Convert(units.ReadingCanonical)
+	if err != nil {
+		return 0, sql.NullString{}, fmt.Errorf("invalid energy for reading %q: %w", p.Name, err)
+	}
+
+	encoded, err := marshalEnergyInput(input)
+	if err != nil {
+		return 0, sql.NullString{}, err
+	}
+	return energy, encoded, nil
+}
@@ -0,0 +1,268 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"product-tracker/cache"
+	"product-tracker/models"
+	"product-tracker/money"
+	"time"
+)
+
+var (
+	// ErrNoFXRate is returned when no exchange rate is known for a currency pair at a date
+	ErrNoFXRate = errors.New("no exchange rate")
+	// ErrInvalidFXRate is returned when an exchange rate to store is malformed
+	ErrInvalidFXRate = errors.New("invalid exchange rate")
+)
+
+// UpsertFXRates stores exchange rates, replacing any rate already recorded for the same pair and date.
+// Rates are validated first so that a bad row leaves the table untouched.
+func (s *Storage) UpsertFXRates(ctx context.Context, rates []models.FXRate) error {
+	for i := range rates {
+		if err := normalizeFXRate(&rates[i]); err != nil {
+			return fmt.Errorf("%w %d: %v", ErrInvalidFXRate, i+1, err)
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO fx_rates (base_currency, quote_currency, rate, effective_date, source)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (base_currency, quote_currency, effective_date)
+		DO UPDATE SET rate = EXCLUDED.rate, source = EXCLUDED.source, created_at = NOW()
+		RETURNING created_at`
+
+	for i := range rates {
+		r := &rates[i]
+		if err := tx.QueryRowContext(ctx, query,
+			r.Base, r.Quote, r.Rate, r.EffectiveDate, r.Source,
+		).Scan(&r.CreatedAt); err != nil {
+			return fmt.Errorf("failed to store exchange rate: %w", err)
+		}
+	}
+
+	if err := recordInvalidation(ctx, tx, cache.AllProductsTags()...); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	// Prices may be rendered in other currencies at the new rates
+	cache.AllProductsChanged()
+	return nil
+}
+
+// normalizeFXRate validates an exchange rate and normalizes its currency codes
+func normalizeFXRate(r *models.FXRate) error {
+	var err error
+	if r.Base, err = money.ParseCurrency(r.Base); err != nil {
+		return err
+	}
+	if r.Quote, err = money.ParseCurrency(r.Quote); err != nil {
+		return err
+	}
+	if r.Base == r.Quote {
+		return fmt.Errorf("%w: base and quote currency are both %s", money.ErrInvalidRate, r.Base)
+	}
+	rate, err := money.ParseRate(r.Rate)
+	if err != nil {
+		return err
+	}
+	r.Rate = rate.FloatString(12)
+	if _, err := time.Parse(models.FXRateDateLayout, r.EffectiveDate); err != nil {
+		return fmt.Errorf("effective_date must be a date in YYYY-MM-DD format")
+	}
+	return nil
+}
+
+// FXRateFilter narrows down a listing of exchange rates
+type FXRateFilter struct {
+	Base  string
+	Quote string
+	// Date keeps only the rate in effect on that date for each pair
+	Date *time.Time
+}
+
+// GetFXRates retrieves exchange rates ordered by pair and effective date
+func (s *Storage) GetFXRates(ctx context.Context, filter FXRateFilter) ([]models.FXRate, error) {
+	query := `
+		SELECT base_currency, quote_currency, rate::text, effective_date, source, created_at
+		FROM fx_rates
+		WHERE ($1 = '' OR base_currency = $1) AND ($2 = '' OR quote_currency = $2)
+		ORDER BY base_currency, quote_currency, effective_date`
+	args := []any{filter.Base, filter.Quote}
+	if filter.Date != nil {
+		query = `
+			SELECT DISTINCT ON (base_currency, quote_currency)
+				base_currency, quote_currency, rate::text, effective_date, source, created_at
+			FROM fx_rates
+			WHERE ($1 = '' OR base_currency = $1) AND ($2 = '' OR quote_currency = $2) AND effective_date <= $3
+			ORDER BY base_currency, quote_currency, effective_date DESC`
+		args = append(args, *filter.Date)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exchange rates: %w", err)
+	}
+	defer rows.Close()
+
+	var rates []models.FXRate
+	for rows.Next() {
+		var (
+			r    models.FXRate
+			date time.Time
+		)
+		if err := rows.Scan(&r.Base, &r.Quote, &r.Rate, &date, &r.Source, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan exchange rate: %w", err)
+		}
+		r.EffectiveDate = date.Format(models.FXRateDateLayout)
+		rates = append(rates, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating exchange rates: %w", err)
+	}
+	return rates, nil
+}
+
+// GetFXRate returns the rate converting one unit of from into to that is in effect on date.
+// A rate recorded for the opposite direction is inverted when no direct rate exists.
+func (s *Storage) GetFXRate(ctx context.Context, from, to string, date time.Time) (*big.Rat, error) {
+	var base, value string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT base_currency, rate::text
+		FROM fx_rates
+		WHERE ((base_currency = $1 AND quote_currency = $2) OR (base_currency = $2 AND quote_currency = $1))
+			AND effective_date <= $3
+		ORDER BY effective_date DESC, base_currency = $1 DESC
+		LIMIT 1`,
+		from, to, date,
+	).Scan(&base, &value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w from %s to %s on %s", ErrNoFXRate, from, to, date.Format(models.FXRateDateLayout))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exchange rate: %w", err)
+	}
+
+	rate, err := money.ParseRate(value)
+	if err != nil {
+		return nil, err
+	}
+	if base != from {
+		rate.Inv(rate)
+	}
+	return rate, nil
+}
+
+// FXConverter converts amounts at the rates in effect on a date, looking each pair up once
+type FXConverter struct {
+	lookup func(ctx context.Context, from, to string, date time.Time) (*big.Rat, error)
+	date   time.Time
+	rates  map[[2]string]*big.Rat
+}
+
+// NewFXConverter returns a converter using the rates in effect on date
+func (s *Storage) NewFXConverter(date time.Time) *FXConverter {
+	return &FXConverter{lookup: s.GetFXRate, date: date, rates: map[[2]string]*big.Rat{}}
+}
+
+// Convert converts m to the currency to
+func (c *FXConverter) Convert(ctx context.Context, m money.Money, to string) (money.Money, error) {
+	if m.Currency == to {
+		return m, nil
+	}
+
+	pair := [2]string{m.Currency, to}
+	rate, ok := c.rates[pair]
+	if !ok {
+		var err error
+		if rate, err = c.lookup(ctx, m.Currency, to, c.date); err != nil {
+			return money.Money{}, err
+		}
+		c.rates[pair] = rate
+	}
+	return money.Convert(m, to, rate)
+}
+
+// GetPriceStats totals the prices of the products that are not in the trash, converted to currency
+// at the rates in effect on date
+func (s *Storage) GetPriceStats(ctx context.Context, currency string, date time.Time) (*models.PriceStats, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT currency, COUNT(*), SUM(price_minor)
+		FROM products
+		WHERE deleted_at IS NULL
+		GROUP BY currency`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query price totals: %w", err)
+	}
+	defer rows.Close()
+
+	type subtotal struct {
+		count int
+		total money.Money
+	}
+	var subtotals []subtotal
+	for rows.Next() {
+		var st subtotal
+		if err := rows.Scan(&st.total.Currency, &st.count, &st.total.Amount); err != nil {
+			return nil, fmt.Errorf("failed to scan price totals: %w", err)
+		}
+		subtotals = append(subtotals, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating price totals: %w", err)
+	}
+
+	stats := &models.PriceStats{
+		Currency:     currency,
+		Date:         date.Format(models.FXRateDateLayout),
+		TotalPrice:   money.Money{Currency: currency},
+		AveragePrice: money.Money{Currency: currency},
+	}
+	converter := s.NewFXConverter(date)
+	for _, st := range subtotals {
+		converted, err := converter.Convert(ctx, st.total, currency)
+		if err != nil {
+			return nil, err
+		}
+		if stats.TotalPrice, err = stats.TotalPrice.Add(converted); err != nil {
+			return nil, err
+		}
+		stats.TotalProducts += st.count
+	}
+	if stats.TotalProducts > 0 {
+		stats.AveragePrice.Amount = divRound(stats.TotalPrice.Amount, int64(stats.TotalProducts))
+	}
+	return stats, nil
+}
+
+// divRound divides a by b, rounding half away from zero
+func divRound(a, b int64) int64 {
+	q, r := a/b, a%b
+	if 2*abs64(r) >= b {
+		if a < 0 {
+			q--
+		} else {
+			q++
+		}
+	}
+	return q
+}
+
+func abs64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
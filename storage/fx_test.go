@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"product-tracker/models"
+	"product-tracker/money"
+)
+
+func TestNormalizeFXRate(t *testing.T) {
+	tests := []struct {
+		rate models.FXRate
+		want string
+		ok   bool
+	}{
+		{models.FXRate{Base: "eur", Quote: " usd", Rate: "1.0842", EffectiveDate: "2024-03-01"}, "1.084200000000", true},
+		{models.FXRate{Base: "EUR", Quote: "JPY", Rate: "161.5", EffectiveDate: "2024-03-01"}, "161.500000000000", true},
+		{models.FXRate{Base: "EUR", Quote: "EUR", Rate: "1", EffectiveDate: "2024-03-01"}, "", false},
+		{models.FXRate{Base: "EUR", Quote: "XXX", Rate: "1", EffectiveDate: "2024-03-01"}, "", false},
+		{models.FXRate{Base: "EUR", Quote: "USD", Rate: "0", EffectiveDate: "2024-03-01"}, "", false},
+		{models.FXRate{Base: "EUR", Quote: "USD", Rate: "1.08", EffectiveDate: "01/03/2024"}, "", false},
+	}
+	for _, tt := range tests {
+		r := tt.rate
+		err := normalizeFXRate(&r)
+		if (err == nil) != tt.ok {
+			t.Errorf("normalizeFXRate(%+v) returned %v, want ok %v", tt.rate, err, tt.ok)
+			continue
+		}
+		if err == nil && (r.Rate != tt.want || r.Base != "EUR") {
+			t.Errorf("normalizeFXRate(%+v) gave %+v, want an EUR rate of %s", tt.rate, r, tt.want)
+		}
+	}
+}
+
+// fakeRates looks rates up in a map, counting the lookups
+type fakeRates struct {
+	rates   map[string]string
+	lookups int
+}
+
+func (f *fakeRates) lookup(_ context.Context, from, to string, date time.Time) (*big.Rat, error) {
+	f.lookups++
+	value, ok := f.rates[from+to]
+	if !ok {
+		return nil, fmt.Errorf("%w from %s to %s on %s", ErrNoFXRate, from, to, date.Format(models.FXRateDateLayout))
+	}
+	return money.ParseRate(value)
+}
+
+func TestFXConverter(t *testing.T) {
+	rates := &fakeRates{rates: map[string]string{"EURUSD": "1.0842", "EURJPY": "161.5"}}
+	converter := &FXConverter{lookup: rates.lookup, date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), rates: map[[2]string]*big.Rat{}}
+	ctx := context.Background()
+
+	tests := []struct {
+		m    money.Money
+		to   string
+		want money.Money
+		err  error
+	}{
+		{money.Money{Amount: 9999, Currency: "EUR"}, "USD", money.Money{Amount: 10841, Currency: "USD"}, nil},
+		{money.Money{Amount: 100, Currency: "EUR"}, "USD", money.Money{Amount: 108, Currency: "USD"}, nil},
+		{money.Money{Amount: 9999, Currency: "EUR"}, "JPY", money.Money{Amount: 16148, Currency: "JPY"}, nil},
+		{money.Money{Amount: 9999, Currency: "EUR"}, "EUR", money.Money{Amount: 9999, Currency: "EUR"}, nil},
+		{money.Money{Amount: 9999, Currency: "EUR"}, "GBP", money.Money{}, ErrNoFXRate},
+		{money.Money{Amount: 9999, Currency: "EUR"}, "GBP", money.Money{}, ErrNoFXRate},
+	}
+	for _, tt := range tests {
+		got, err := converter.Convert(ctx, tt.m, tt.to)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Convert(%+v, %s) returned %v, want %v", tt.m, tt.to, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Convert(%+v, %s) = %+v, want %+v", tt.m, tt.to, got, tt.want)
+		}
+	}
+	// EUR/USD and EUR/JPY are looked up once each; the missing EUR/GBP rate is looked up again
+	if rates.lookups != 4 {
+		t.Errorf("looked rates up %d times, want 4", rates.lookups)
+	}
+}
+
+func TestDivRound(t *testing.T) {
+	tests := []struct {
+		a, b, want int64
+	}{
+		{10, 4, 3},
+		{9, 4, 2},
+		{-10, 4, -3},
+		{-9, 4, -2},
+		{5, 2, 3},
+		{7, 7, 1},
+		{0, 3, 0},
+	}
+	for _, tt := range tests {
+		if got := divRound(tt.a, tt.b); got != tt.want {
+			t.Errorf("divRound(%d, %d) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"product-tracker/models"
+	"time"
+)
+
+type actorKey struct{}
+
+// Actor identifies who performed a write and on behalf of which request
+type Actor struct {
+	UserID    uint
+	RequestID string
+}
+
+// WithActor returns a context carrying the actor recorded in the product history
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor stored in ctx, or the zero Actor for system writes
+func ActorFromContext(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorKey{}).(Actor)
+	return actor
+}
+
+// recordHistory appends a history entry for a product write within tx
+func recordHistory(ctx context.Context, tx *sql.Tx, action string, before, after *models.Product) error {
+	var productID, tenantID int64
+	if after != nil {
+		productID, tenantID = after.ID, after.TenantID
+	} else if before != nil {
+		productID, tenantID = before.ID, before.TenantID
+	}
+
+	beforeJSON, err := marshalSnapshot(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := marshalSnapshot(after)
+	if err != nil {
+		return err
+	}
+
+	actor := ActorFromContext(ctx)
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO product_history (product_id, tenant_id, action, actor_id, request_id, before, after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		productID, tenantID, action, actor.UserID, actor.RequestID, beforeJSON, afterJSON,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record product history: %w", err)
+	}
+	return nil
+}
+
+// marshalSnapshot encodes a product snapshot as a JSONB parameter, using NULL for a missing product
+func marshalSnapshot(p *models.Product) (sql.NullString, error) {
+	if p == nil {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to encode product snapshot: %w", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// GetProductHistory retrieves every recorded write to a product, oldest first
+func (s *Storage) GetProductHistory(ctx context.Context, productID int64) ([]models.ProductHistoryEntry, error) {
+	query := `
+		SELECT id, product_id, action, actor_id, request_id, before, after, changed_at
+		FROM product_history
+		WHERE product_id = $1
+		ORDER BY changed_at, id`
+
+	rows, err := s.db.QueryContext(ctx, query, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query product history: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []models.ProductHistoryEntry
+	for rows.Next() {
+		entry, err := scanHistoryEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating product history: %w", err)
+	}
+	return entries, nil
+}
+
+// GetProductAsOf reconstructs the state of a product at the given time from its history.
+// It returns ErrNotFound if the product did not exist at that time.
+func (s *Storage) GetProductAsOf(ctx context.Context, productID int64, asOf time.Time) (*models.Product, error) {
+	query := `
+		SELECT id, product_id, action, actor_id, request_id, before, after, changed_at
+		FROM product_history
+		WHERE product_id = $1 AND changed_at <= $2
+		ORDER BY changed_at DESC, id DESC
+		LIMIT 1`
+
+	entry, err := scanHistoryEntry(s.db.QueryRowContext(ctx, query, productID, asOf))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The product was purged or sitting in the trash at that time
+	if entry.After == nil || entry.After.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+	return entry.After, nil
+}
+
+// scanHistoryEntry scans a product_history row and decodes its snapshots
+func scanHistoryEntry(row rowScanner) (*models.ProductHistoryEntry, error) {
+	var (
+		entry         models.ProductHistoryEntry
+		before, after []byte
+	)
+	err := row.Scan(
+		&entry.ID,
+		&entry.ProductID,
+		&entry.Action,
+		&entry.ActorID,
+		&entry.RequestID,
+		&before,
+		&after,
+		&entry.ChangedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan product history: %w", err)
+	}
+
+	if entry.Before, err = unmarshalSnapshot(before); err != nil {
+		return nil, err
+	}
+	if entry.After, err = unmarshalSnapshot(after); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// unmarshalSnapshot decodes a product snapshot, returning nil for SQL NULL
+func unmarshalSnapshot(data []byte) (*models.Product, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var p models.Product
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to decode product snapshot: %w", err)
+	}
+	return &p, nil
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"product-tracker/models"
+	"time"
+)
+
+// ReserveIdempotencyKey claims an Idempotency-Key for a request.
+// It returns nil when the key was free and is now reserved, or the existing record otherwise.
+// Expired records, and reservations older than lease whose request never completed, are discarded so the key
+// can be reused.
+func (s *Storage) ReserveIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord, lease time.Duration) (*models.IdempotencyRecord, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND route = $2 AND key = $3
+			AND (expires_at <= NOW() OR (status_code = 0 AND created_at <= NOW() - make_interval(secs => $4)))`,
+		record.UserID, record.Route, record.Key, lease.Seconds(),
+	); err != nil {
+		return nil, fmt.Errorf("failed to discard expired idempotency key: %w", err)
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO idempotency_keys (user_id, route, key, request_hash, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (tenant_id, user_id, route, key) DO NOTHING
+		RETURNING created_at`,
+		record.UserID, record.Route, record.Key, record.RequestHash, record.ExpiresAt,
+	).Scan(&record.CreatedAt)
+	if err == nil {
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+
+	existing := &models.IdempotencyRecord{
+		UserID: record.UserID,
+		Route:  record.Route,
+		Key:    record.Key,
+	}
+	err = tx.QueryRowContext(ctx, `
+		SELECT request_hash, status_code, response_body, created_at, expires_at
+		FROM idempotency_keys
+		WHERE user_id = $1 AND route = $2 AND key = $3`,
+		record.UserID, record.Route, record.Key,
+	).Scan(&existing.RequestHash, &existing.StatusCode, &existing.ResponseBody, &existing.CreatedAt, &existing.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load idempotency key: %w", err)
+	}
+
+	return existing, nil
+}
+
+// CompleteIdempotencyKey stores the response of a request so that retries can replay it. It returns ErrNotFound
+// if the reservation was taken over by a retry after its lease ended.
+func (s *Storage) CompleteIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE idempotency_keys
+		SET status_code = $4, response_body = $5
+		WHERE user_id = $1 AND route = $2 AND key = $3 AND created_at = $6 AND status_code = 0`,
+		record.UserID, record.Route, record.Key, record.StatusCode, record.ResponseBody, record.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey removes a reservation so the request can be retried, unless a retry took it over
+func (s *Storage) ReleaseIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) error {
+	_, err := s.db.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND route = $2 AND key = $3 AND created_at = $4 AND status_code = 0`,
+		record.UserID, record.Route, record.Key, record.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
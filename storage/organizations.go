@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"product-tracker/models"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// ErrInvalidOrganization is returned for organization names that are empty or too long
+var ErrInvalidOrganization = errors.New("organization name must be between 1 and 200 characters")
+
+// maxOrganizationNameLength bounds organization names
+const maxOrganizationNameLength = 200
+
+// Limits on the number of audit log entries returned at once
+const (
+	DefaultTenantAccessLimit = 100
+	MaxTenantAccessLimit     = 1000
+)
+
+// normalizeOrganization trims the name of an organization and checks its length
+func normalizeOrganization(org *models.Organization) error {
+	org.Name = strings.TrimSpace(org.Name)
+	if org.Name == "" || len(org.Name) > maxOrganizationNameLength {
+		return ErrInvalidOrganization
+	}
+	return nil
+}
+
+// organizationWriteError maps a failed organization write to ErrDuplicate for names already taken
+func organizationWriteError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return ErrDuplicate
+	}
+	return fmt.Errorf("failed to write organization: %w", err)
+}
+
+// CreateOrganization inserts a new organization. It needs AllTenants storage.
+func (s *Storage) CreateOrganization(ctx context.Context, org *models.Organization) error {
+	if err := normalizeOrganization(org); err != nil {
+		return err
+	}
+	err := s.db.QueryRowContext(ctx,
+		"INSERT INTO organizations (name) VALUES ($1) RETURNING id, created_at", org.Name,
+	).Scan(&org.ID, &org.CreatedAt)
+	if err != nil {
+		return organizationWriteError(err)
+	}
+	return nil
+}
+
+// RenameOrganization changes the name of an organization
+func (s *Storage) RenameOrganization(ctx context.Context, org *models.Organization) error {
+	if err := normalizeOrganization(org); err != nil {
+		return err
+	}
+	err := s.db.QueryRowContext(ctx,
+		"UPDATE organizations SET name = $2 WHERE id = $1 RETURNING created_at", org.ID, org.Name,
+	).Scan(&org.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return organizationWriteError(err)
+	}
+	return nil
+}
+
+// GetOrganization retrieves an organization by ID
+func (s *Storage) GetOrganization(ctx context.Context, id int64) (*models.Organization, error) {
+	var org models.Organization
+	err := s.db.QueryRowContext(ctx,
+		"SELECT id, name, created_at FROM organizations WHERE id = $1", id,
+	).Scan(&org.ID, &org.Name, &org.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query organization: %w", err)
+	}
+	return &org, nil
+}
+
+// GetOrganizations retrieves the organizations in scope, ordered by ID
+func (s *Storage) GetOrganizations(ctx context.Context) ([]models.Organization, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, name, created_at FROM organizations ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query organizations: %w", err)
+	}
+	defer rows.Close()
+
+	orgs := []models.Organization{}
+	for rows.Next() {
+		var org models.Organization
+		if err := rows.Scan(&org.ID, &org.Name, &org.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan organization: %w", err)
+		}
+		orgs = append(orgs, org)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating organizations: %w", err)
+	}
+	return orgs, nil
+}
+
+// RecordTenantAccess writes a cross-tenant request to the audit log. It returns ErrNotFound if the accessed
+// organization does not exist.
+func (s *Storage) RecordTenantAccess(ctx context.Context, access *models.TenantAccess) error {
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO tenant_access_audit (user_id, home_tenant_id, tenant_id, method, path, request_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, accessed_at`,
+		access.UserID, access.HomeTenantID, access.TenantID, access.Method, access.Path, access.RequestID,
+	).Scan(&access.ID, &access.AccessedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to record tenant access: %w", err)
+	}
+	return nil
+}
+
+// GetTenantAccessLog retrieves up to limit cross-tenant requests, most recent first, optionally only those to
+// one organization
+func (s *Storage) GetTenantAccessLog(ctx context.Context, tenantID *int64, limit int) ([]models.TenantAccess, error) {
+	if limit <= 0 || limit > MaxTenantAccessLimit {
+		limit = DefaultTenantAccessLimit
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, user_id, home_tenant_id, tenant_id, method, path, request_id, accessed_at
+		FROM tenant_access_audit
+		WHERE $1::bigint IS NULL OR tenant_id = $1
+		ORDER BY accessed_at DESC, id DESC
+		LIMIT $2`, tenantID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tenant access log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.TenantAccess{}
+	for rows.Next() {
+		var e models.TenantAccess
+		if err := rows.Scan(&e.ID, &e.UserID, &e.HomeTenantID, &e.TenantID, &e.Method, &e.Path, &e.RequestID, &e.AccessedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan tenant access: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tenant access log: %w", err)
+	}
+	return entries, nil
+}
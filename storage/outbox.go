@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"product-tracker/outbox"
+	"time"
+)
+
+// recordEvent writes a domain event to the outbox within tx, so that it is committed or rolled back together
+// with the write it describes. The event belongs to the tenant of the data it describes.
+func recordEvent(ctx context.Context, tx *sql.Tx, tenantID int64, aggregateType string, aggregateID int64, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", event, err)
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO outbox (tenant_id, aggregate_type, aggregate_id, event, payload)
+		VALUES ($1, $2, $3, $4, $5)`,
+		tenantID, aggregateType, fmt.Sprint(aggregateID), event, string(payload))
+	if err != nil {
+		return fmt.Errorf("failed to record %s event: %w", event, err)
+	}
+	return nil
+}
+
+// DispatchOutbox publishes up to limit due outbox events to sinks and returns how many were published and how
+// many failed. Only the oldest unpublished event of each aggregate is eligible, so the events of an aggregate are
+// published in order, and claimed rows stay locked until the batch is done, so that concurrent dispatchers skip
+// them. A failed event is retried after a backoff of up to maxBackoff and holds back the later events of its
+// aggregate until then.
+func (s *Storage) DispatchOutbox(ctx context.Context, limit int, sinks []outbox.Sink, maxBackoff time.Duration) (int, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, tenant_id, aggregate_type, aggregate_id, event, payload, created_at, attempts
+		FROM outbox o
+		WHERE published_at IS NULL AND next_attempt_at <= NOW()
+			AND NOT EXISTS (
+				SELECT 1 FROM outbox e
+				WHERE e.aggregate_type = o.aggregate_type AND e.aggregate_id = o.aggregate_id
+					AND e.published_at IS NULL AND e.id < o.id
+			)
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`, limit)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+	var events []outbox.Event
+	for rows.Next() {
+		var e outbox.Event
+		var payload []byte
+		if err := rows.Scan(&e.ID, &e.TenantID, &e.AggregateType, &e.AggregateID, &e.Type, &payload, &e.CreatedAt, &e.Attempts); err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		e.Payload = payload
+		events = append(events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, fmt.Errorf("error iterating outbox events: %w", err)
+	}
+
+	published, failed := 0, 0
+	for _, e := range events {
+		if publishErr := outbox.Publish(ctx, sinks, e); publishErr != nil {
+			failed++
+			retryAt := time.Now().Add(outbox.Backoff(e.Attempts+1, maxBackoff))
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE outbox SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+				WHERE id = $1`, e.ID, publishErr.Error(), retryAt); err != nil {
+				return 0, 0, fmt.Errorf("failed to reschedule outbox event: %w", err)
+			}
+			continue
+		}
+		published++
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE outbox SET published_at = NOW(), last_error = NULL WHERE id = $1", e.ID); err != nil {
+			return 0, 0, fmt.Errorf("failed to mark outbox event published: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return published, failed, nil
+}
+
+// PurgeOutbox removes events published before the given time
+func (s *Storage) PurgeOutbox(ctx context.Context, publishedBefore time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM outbox WHERE published_at < $1", publishedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge outbox: %w", err)
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return purged, nil
+}
+
+// recordInvalidation writes an EventCacheInvalidated event to the outbox within tx, so that every server instance
+// drops its cached responses carrying any of the tags once the write is committed. It is meant for writes that
+// change many products at once, or what their responses show, without product events of their own.
+func recordInvalidation(ctx context.Context, tx *sql.Tx, tags ...string) error {
+	var tenantID sql.NullInt64
+	if err := tx.QueryRowContext(ctx, "SELECT current_tenant()").Scan(&tenantID); err != nil {
+		return fmt.Errorf("failed to look up tenant: %w", err)
+	}
+	if !tenantID.Valid {
+		return ErrNoTenant
+	}
+	return recordEvent(ctx, tx, tenantID.Int64, outbox.AggregateCache, tenantID.Int64, EventCacheInvalidated,
+		cacheInvalidation{Tags: tags})
+}
+
+// OutboxSinkNames lists the sinks that can be configured for the outbox dispatcher
+var OutboxSinkNames = []string{"webhooks", "notify", "log"}
+
+// ValidateOutboxSinks checks that every configured sink exists and is listed once
+func ValidateOutboxSinks(names []string) error {
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		known := false
+		for _, n := range OutboxSinkNames {
+			known = known || n == name
+		}
+		if !known {
+			return fmt.Errorf("%w: %q", outbox.ErrUnknownSink, name)
+		}
+		if seen[name] {
+			return fmt.Errorf("sink %q is listed twice", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// OutboxSinks builds the named sinks
+func (s *Storage) OutboxSinks(names []string) ([]outbox.Sink, error) {
+	if err := ValidateOutboxSinks(names); err != nil {
+		return nil, err
+	}
+	sinks := make([]outbox.Sink, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case "webhooks":
+			sinks = append(sinks, webhookSink{s: s})
+		case "notify":
+			sinks = append(sinks, notifySink{s: s})
+		case "log":
+			sinks = append(sinks, outbox.LogSink{})
+		}
+	}
+	return sinks, nil
+}
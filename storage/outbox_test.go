@@ -0,0 +1,23 @@
+package storage
+
+import "testing"
+
+func TestOutboxSinksBuildsEveryName(t *testing.T) {
+	for _, name := range OutboxSinkNames {
+		sinks, err := (&Storage{}).OutboxSinks([]string{name})
+		if err != nil {
+			t.Fatalf("OutboxSinks(%q) failed: %v", name, err)
+		}
+		if len(sinks) != 1 || sinks[0].Name() != name {
+			t.Fatalf("OutboxSinks(%q) built %v, want one %q sink", name, sinks, name)
+		}
+	}
+}
+
+func TestOutboxSinksRejectsUnknownAndDuplicateNames(t *testing.T) {
+	for _, names := range [][]string{{"kafka"}, {"log", "log"}} {
+		if _, err := (&Storage{}).OutboxSinks(names); err == nil {
+			t.Errorf("OutboxSinks(%v) succeeded, want an error", names)
+		}
+	}
+}
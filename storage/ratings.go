@@ -0,0 +1,387 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"product-tracker/cache"
+	"product-tracker/models"
+	"product-tracker/rating"
+
+	"github.com/lib/pq"
+)
+
+// ratingSchemeColumns lists the columns selected for rating schemes
+const ratingSchemeColumns = "id, category_id, name, capacity_attribute, classes, version, created_at, updated_at"
+
+// nearestSchemeQuery selects the rating scheme of the category bound to $1 or of its nearest ancestor having one
+const nearestSchemeQuery = `
+	WITH RECURSIVE ancestors AS (
+		SELECT id, parent_id, 0 AS depth FROM categories WHERE id = $1
+		UNION ALL
+		SELECT c.id, c.parent_id, a.depth + 1 FROM categories c JOIN ancestors a ON c.id = a.parent_id
+	)
+	SELECT rs.id, rs.category_id, rs.name, rs.capacity_attribute, rs.classes, rs.version, rs.created_at, rs.updated_at
+	FROM ancestors a JOIN rating_schemes rs ON rs.category_id = a.id
+	ORDER BY a.depth
+	LIMIT 1`
+
+// normalizeRatingScheme validates a rating scheme and rewrites its attribute and classes in their stored form
+func normalizeRatingScheme(scheme *models.RatingScheme) error {
+	parsed, err := rating.FromModel(scheme)
+	if err != nil {
+		return err
+	}
+	scheme.CapacityAttribute = parsed.Attribute
+	scheme.Classes = parsed.Classes()
+	return nil
+}
+
+// rateProduct validates the attributes of a product and computes its rating under the scheme of its category.
+// It returns the attributes encoded as a JSONB parameter.
+func rateProduct(ctx context.Context, tx *sql.Tx, product *models.Product) (string, error) {
+	attributes, err := rating.NormalizeAttributes(product.Attributes)
+	if err != nil {
+		return "", err
+	}
+	product.Attributes = attributes
+
+	data, err := json.Marshal(attributes)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode product attributes: %w", err)
+	}
+
+	product.Rating = nil
+	if product.CategoryID != nil {
+		scheme, err := schemeForCategory(ctx, tx, *product.CategoryID)
+		if err != nil {
+			return "", err
+		}
+		if scheme != nil {
+			product.Rating = scheme.Rate(product.EnergyConsumption, attributes)
+		}
+	}
+	return string(data), nil
+}
+
+// ratingValues returns the column values of a rating, all NULL for an unrated product
+func ratingValues(r *models.Rating) (sql.NullString, sql.NullFloat64, sql.NullInt64, sql.NullInt64) {
+	if r == nil {
+		return sql.NullString{}, sql.NullFloat64{}, sql.NullInt64{}, sql.NullInt64{}
+	}
+	return sql.NullString{String: r.Class, Valid: true},
+		sql.NullFloat64{Float64: r.Index, Valid: true},
+		sql.NullInt64{Int64: r.SchemeID, Valid: true},
+		sql.NullInt64{Int64: int64(r.SchemeVersion), Valid: true}
+}
+
+// schemeForCategory returns the rating scheme that applies to a category, or nil if there is none
+func schemeForCategory(ctx context.Context, tx *sql.Tx, categoryID int64) (*rating.Scheme, error) {
+	stored, err := scanRatingScheme(tx.QueryRowContext(ctx, nearestSchemeQuery, categoryID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rating.FromModel(stored)
+}
+
+// rerateCategoryTree recomputes the ratings of the products in a category and its descendants, including
+// those in the trash, and returns how many ratings changed. Every rerated product gets a new version and a
+// history entry, like any other update.
+func rerateCategoryTree(ctx context.Context, tx *sql.Tx, categoryID int64) (int64, error) {
+	products, err := lockProducts(ctx, tx, "category_id IN ("+categoryTreeQuery(1)+")", categoryID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Products of the same category share a scheme, so look each category up once
+	schemes := map[int64]*rating.Scheme{}
+	var changed int64
+	for _, p := range products {
+		scheme, ok := schemes[*p.CategoryID]
+		if !ok {
+			if scheme, err = schemeForCategory(ctx, tx, *p.CategoryID); err != nil {
+				return 0, err
+			}
+			schemes[*p.CategoryID] = scheme
+		}
+		var r *models.Rating
+		if scheme != nil {
+			r = scheme.Rate(p.EnergyConsumption, p.Attributes)
+		}
+
+		ratingClass, ratingIndex, schemeID, schemeVersion := ratingValues(r)
+		oldClass, oldIndex, oldSchemeID, oldSchemeVersion := ratingValues(p.Rating)
+		if ratingClass == oldClass && ratingIndex == oldIndex && schemeID == oldSchemeID &&
+			schemeVersion == oldSchemeVersion {
+			continue
+		}
+
+		err := reviseProduct(ctx, tx, p,
+			"rating_class = $2, rating_index = $3, rating_scheme_id = $4, rating_scheme_version = $5",
+			ratingClass, ratingIndex, schemeID, schemeVersion)
+		if err != nil {
+			return 0, err
+		}
+		changed++
+	}
+	return changed, nil
+}
+
+// CreateRatingScheme inserts the rating scheme of a category and rates the products it covers.
+// It returns how many product ratings changed.
+func (s *Storage) CreateRatingScheme(ctx context.Context, scheme *models.RatingScheme) (int64, error) {
+	if err := normalizeRatingScheme(scheme); err != nil {
+		return 0, err
+	}
+	classes, err := json.Marshal(scheme.Classes)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode rating classes: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO rating_schemes (category_id, name, capacity_attribute, classes)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, version, created_at, updated_at`,
+		scheme.CategoryID, scheme.Name, scheme.CapacityAttribute, string(classes),
+	).Scan(&scheme.ID, &scheme.Version, &scheme.CreatedAt, &scheme.UpdatedAt)
+	if err != nil {
+		return 0, ratingSchemeWriteError(err)
+	}
+
+	changed, err := saveRatingSchemeVersion(ctx, tx, scheme, string(classes))
+	if err != nil {
+		return 0, err
+	}
+
+	if err := recordInvalidation(ctx, tx, cache.AllProductsTags()...); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	cache.AllProductsChanged()
+	return changed, nil
+}
+
+// UpdateRatingScheme replaces the name, capacity attribute and classes of a rating scheme as a new version
+// and rerates the products it covers. The category of a scheme cannot change.
+// It returns how many product ratings changed.
+func (s *Storage) UpdateRatingScheme(ctx context.Context, scheme *models.RatingScheme) (int64, error) {
+	if err := normalizeRatingScheme(scheme); err != nil {
+		return 0, err
+	}
+	classes, err := json.Marshal(scheme.Classes)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode rating classes: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		UPDATE rating_schemes
+		SET name = $2, capacity_attribute = $3, classes = $4, version = version + 1, updated_at = NOW()
+		WHERE id = $1
+		RETURNING category_id, version, created_at, updated_at`,
+		scheme.ID, scheme.Name, scheme.CapacityAttribute, string(classes),
+	).Scan(&scheme.CategoryID, &scheme.Version, &scheme.CreatedAt, &scheme.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, ratingSchemeWriteError(err)
+	}
+
+	changed, err := saveRatingSchemeVersion(ctx, tx, scheme, string(classes))
+	if err != nil {
+		return 0, err
+	}
+
+	if err := recordInvalidation(ctx, tx, cache.AllProductsTags()...); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	cache.AllProductsChanged()
+	return changed, nil
+}
+
+// saveRatingSchemeVersion records the current version of a scheme and rerates the products it covers
+func saveRatingSchemeVersion(ctx context.Context, tx *sql.Tx, scheme *models.RatingScheme, classes string) (int64, error) {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO rating_scheme_versions (scheme_id, version, name, capacity_attribute, classes, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		scheme.ID, scheme.Version, scheme.Name, scheme.CapacityAttribute, classes, scheme.UpdatedAt,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record rating scheme version: %w", err)
+	}
+	return rerateCategoryTree(ctx, tx, scheme.CategoryID)
+}
+
+// DeleteRatingScheme removes a rating scheme and its versions. The products it covered fall back to the
+// scheme of an ancestor category, if any, or become unrated. It returns how many product ratings changed.
+func (s *Storage) DeleteRatingScheme(ctx context.Context, id int64) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var categoryID int64
+	err = tx.QueryRowContext(ctx, "DELETE FROM rating_schemes WHERE id = $1 RETURNING category_id", id).Scan(&categoryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete rating scheme: %w", err)
+	}
+
+	changed, err := rerateCategoryTree(ctx, tx, categoryID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := recordInvalidation(ctx, tx, cache.AllProductsTags()...); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	cache.AllProductsChanged()
+	return changed, nil
+}
+
+// GetRatingScheme retrieves the current version of a rating scheme
+func (s *Storage) GetRatingScheme(ctx context.Context, id int64) (*models.RatingScheme, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+ratingSchemeColumns+" FROM rating_schemes WHERE id = $1", id)
+	scheme, err := scanRatingScheme(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return scheme, err
+}
+
+// GetRatingSchemeVersion retrieves a version of a rating scheme as it was when created
+func (s *Storage) GetRatingSchemeVersion(ctx context.Context, id int64, version int) (*models.RatingScheme, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT v.scheme_id, rs.category_id, v.name, v.capacity_attribute, v.classes, v.version, v.created_at, v.created_at
+		FROM rating_scheme_versions v JOIN rating_schemes rs ON rs.id = v.scheme_id
+		WHERE v.scheme_id = $1 AND v.version = $2`, id, version)
+	scheme, err := scanRatingScheme(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return scheme, err
+}
+
+// GetRatingSchemes retrieves the current version of every rating scheme ordered by name
+func (s *Storage) GetRatingSchemes(ctx context.Context) ([]models.RatingScheme, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+ratingSchemeColumns+" FROM rating_schemes ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rating schemes: %w", err)
+	}
+	defer rows.Close()
+
+	var schemes []models.RatingScheme
+	for rows.Next() {
+		scheme, err := scanRatingScheme(rows)
+		if err != nil {
+			return nil, err
+		}
+		schemes = append(schemes, *scheme)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rating schemes: %w", err)
+	}
+	return schemes, nil
+}
+
+// ExplainProductRating returns the rating of a product together with the inputs and the scheme version it was
+// computed from
+func (s *Storage) ExplainProductRating(ctx context.Context, productID int64) (*models.RatingExplanation, error) {
+	product, err := s.GetProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	explanation := &models.RatingExplanation{
+		ProductID:         product.ID,
+		Rating:            product.Rating,
+		EnergyConsumption: product.EnergyConsumption,
+	}
+	if product.Rating == nil {
+		return explanation, nil
+	}
+
+	scheme, err := s.GetRatingSchemeVersion(ctx, product.Rating.SchemeID, product.Rating.SchemeVersion)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if scheme != nil {
+		explanation.Scheme = scheme
+		if capacity, ok := product.Attributes[scheme.CapacityAttribute]; ok {
+			explanation.Capacity = &capacity
+		}
+	}
+	return explanation, nil
+}
+
+// scanRatingScheme scans a row selected with ratingSchemeColumns
+func scanRatingScheme(row rowScanner) (*models.RatingScheme, error) {
+	var (
+		scheme  models.RatingScheme
+		classes []byte
+	)
+	if err := row.Scan(
+		&scheme.ID,
+		&scheme.CategoryID,
+		&scheme.Name,
+		&scheme.CapacityAttribute,
+		&classes,
+		&scheme.Version,
+		&scheme.CreatedAt,
+		&scheme.UpdatedAt,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan rating scheme: %w", err)
+	}
+	if err := json.Unmarshal(classes, &scheme.Classes); err != nil {
+		return nil, fmt.Errorf("failed to decode rating classes: %w", err)
+	}
+	return &scheme, nil
+}
+
+// ratingSchemeWriteError maps constraint violations on rating schemes to storage errors
+func ratingSchemeWriteError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case uniqueViolation:
+			return ErrDuplicate
+		case foreignKeyViolation:
+			return ErrInvalidCategory
+		}
+	}
+	return fmt.Errorf("failed to write rating scheme: %w", err)
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"product-tracker/models"
+	"product-tracker/rollups"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// rollupUpsertQuery adds readings to the rollups of every timezone in $1 and granularity in $2.
+// A reading belongs to the local day of recorded_at when it has one and to its date otherwise.
+// The first placeholder selects the readings and the second the periods to write.
+const rollupUpsertQuery = `
+	INSERT INTO reading_rollups (tenant_id, timezone, granularity, period_start, product_id, readings, quantity, energy_kwh)
+	SELECT tenant_id, timezone, granularity, period_start, product_id, COUNT(*), SUM(quantity), SUM(energy_consumed)
+	FROM (
+		SELECT z.tz AS timezone, g.granularity,
+			date_trunc(g.granularity, (CASE WHEN t.recorded_at IS NULL THEN t.date
+				ELSE (t.recorded_at AT TIME ZONE z.tz)::date END)::timestamp)::date AS period_start,
+			t.tenant_id, t.product_id, t.quantity, t.energy_consumed
+		FROM product_tracker t
+		CROSS JOIN unnest($1::text[]) AS z (tz)
+		CROSS JOIN unnest($2::text[]) AS g (granularity)
+		WHERE %s
+	) r
+	WHERE %s
+	GROUP BY tenant_id, timezone, granularity, period_start, product_id
+	ON CONFLICT (tenant_id, timezone, granularity, period_start, (COALESCE(product_id, 0))) DO UPDATE SET
+		readings   = reading_rollups.readings + EXCLUDED.readings,
+		quantity   = reading_rollups.quantity + EXCLUDED.quantity,
+		energy_kwh = reading_rollups.energy_kwh + EXCLUDED.energy_kwh,
+		updated_at = NOW()`
+
+// rollupGranularities returns the maintained intervals as a Postgres text array
+func rollupGranularities() interface{} {
+	granularities := make([]string, len(rollups.Intervals))
+	for i, interval := range rollups.Intervals {
+		granularities[i] = string(interval)
+	}
+	return pq.Array(granularities)
+}
+
+// addToRollups adds freshly inserted readings to the rollups of the configured timezones
+func (s *Storage) addToRollups(ctx context.Context, tx *sql.Tx, readingIDs []int64) error {
+	if len(readingIDs) == 0 || len(s.rollupTimezones) == 0 {
+		return nil
+	}
+	query := fmt.Sprintf(rollupUpsertQuery, "t.id = ANY($3)", "TRUE")
+	if _, err := tx.ExecContext(ctx, query,
+		pq.Array(s.rollupTimezones), rollupGranularities(), pq.Array(readingIDs)); err != nil {
+		return fmt.Errorf("failed to update reading rollups: %w", err)
+	}
+	return nil
+}
+
+// RebuildRollups recomputes the rollups of the given timezones for every period overlapping start to end, both
+// local dates, and returns the number of rollup rows written. Inserts wait for the rebuild to finish.
+func (s *Storage) RebuildRollups(ctx context.Context, start, end time.Time, timezones []string) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Block concurrent inserts so that no reading is counted twice or missed
+	if _, err := tx.ExecContext(ctx, "LOCK TABLE reading_rollups IN EXCLUSIVE MODE"); err != nil {
+		return 0, fmt.Errorf("failed to lock reading rollups: %w", err)
+	}
+
+	startDate, endDate := start.Format(models.ReadingDateLayout), end.Format(models.ReadingDateLayout)
+	periods := "period_start >= date_trunc(granularity, $3::date::timestamp)::date AND period_start <= $4::date"
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM reading_rollups WHERE timezone = ANY($1) AND granularity = ANY($2) AND "+periods,
+		pq.Array(timezones), rollupGranularities(), startDate, endDate); err != nil {
+		return 0, fmt.Errorf("failed to clear reading rollups: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, fmt.Sprintf(rollupUpsertQuery, "TRUE", periods),
+		pq.Array(timezones), rollupGranularities(), startDate, endDate)
+	if err != nil {
+		return 0, fmt.Errorf("failed to rebuild reading rollups: %w", err)
+	}
+	written, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return written, nil
+}
+
+// RollupFilter selects the rollups an aggregate is read from
+type RollupFilter struct {
+	Timezone string
+	Interval rollups.Interval
+	// Start and End bound the period starts, inclusive
+	Start time.Time
+	End   time.Time
+	// ProductID keeps only the rollups of one product when set
+	ProductID *int64
+}
+
+// GetReadingAggregates sums the rollups matching filter per period, ordered by period start.
+// Periods without readings are left out and rollups of products in the trash are excluded.
+func (s *Storage) GetReadingAggregates(ctx context.Context, filter RollupFilter) ([]models.ReadingPeriod, error) {
+	conditions := []string{
+		"r.timezone = $1", "r.granularity = $2", "r.period_start >= $3", "r.period_start <= $4",
+		"(r.product_id IS NULL OR p.deleted_at IS NULL)",
+	}
+	args := []interface{}{
+		filter.Timezone, string(filter.Interval),
+		filter.Start.Format(models.ReadingDateLayout), filter.End.Format(models.ReadingDateLayout),
+	}
+	if filter.ProductID != nil {
+		args = append(args, *filter.ProductID)
+		conditions = append(conditions, fmt.Sprintf("r.product_id = $%d", len(args)))
+	}
+
+	query := `
+		SELECT r.period_start, SUM(r.readings), SUM(r.quantity), SUM(r.energy_kwh)
+		FROM reading_rollups r
+		LEFT JOIN products p ON p.id = r.product_id
+		` + whereClause(conditions) + `
+		GROUP BY r.period_start
+		ORDER BY r.period_start`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reading rollups: %w", err)
+	}
+	defer rows.Close()
+
+	periods := []models.ReadingPeriod{}
+	for rows.Next() {
+		var periodStart time.Time
+		var readings, quantity int64
+		var energy float64
+		if err := rows.Scan(&periodStart, &readings, &quantity, &energy); err != nil {
+			return nil, fmt.Errorf("failed to scan reading rollup: %w", err)
+		}
+		periods = append(periods, models.ReadingPeriod{
+			PeriodStart: periodStart.Format(models.ReadingDateLayout),
+			Readings:    &readings,
+			Quantity:    &quantity,
+			EnergyKWh:   &energy,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reading rollups: %w", err)
+	}
+	return periods, nil
+}
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"product-tracker/costcalc"
+	"product-tracker/models"
+
+	"github.com/lib/pq"
+)
+
+// scheduleColumns lists the columns selected for tariff schedules
+const scheduleColumns = "id, name, currency, timezone, bands, holidays, created_at, updated_at"
+
+// normalizeSchedule validates a tariff schedule and rewrites its currency and timezone in their stored form
+func normalizeSchedule(schedule *models.TariffSchedule) error {
+	parsed, err := costcalc.ScheduleFromModel(schedule)
+	if err != nil {
+		return err
+	}
+	schedule.Currency = parsed.Currency
+	schedule.Timezone = parsed.Location.String()
+	if schedule.Holidays == nil {
+		schedule.Holidays = []models.TariffHoliday{}
+	}
+	return nil
+}
+
+// marshalSchedule encodes the bands and holidays of a schedule as JSONB parameters
+func marshalSchedule(schedule *models.TariffSchedule) (string, string, error) {
+	bands, err := json.Marshal(schedule.Bands)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode tariff bands: %w", err)
+	}
+	holidays, err := json.Marshal(schedule.Holidays)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode tariff holidays: %w", err)
+	}
+	return string(bands), string(holidays), nil
+}
+
+// CreateTariffSchedule inserts a time-of-use tariff schedule
+func (s *Storage) CreateTariffSchedule(ctx context.Context, schedule *models.TariffSchedule) error {
+	if err := normalizeSchedule(schedule); err != nil {
+		return err
+	}
+	bands, holidays, err := marshalSchedule(schedule)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.QueryRowContext(ctx, `
+		INSERT INTO tariff_schedules (name, currency, timezone, bands, holidays)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at`,
+		schedule.Name, schedule.Currency, schedule.Timezone, bands, holidays,
+	).Scan(&schedule.ID, &schedule.CreatedAt, &schedule.UpdatedAt)
+	if err != nil {
+		return scheduleWriteError(err)
+	}
+	return nil
+}
+
+// UpdateTariffSchedule replaces a time-of-use tariff schedule
+func (s *Storage) UpdateTariffSchedule(ctx context.Context, schedule *models.TariffSchedule) error {
+	if err := normalizeSchedule(schedule); err != nil {
+		return err
+	}
+	bands, holidays, err := marshalSchedule(schedule)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.QueryRowContext(ctx, `
+		UPDATE tariff_schedules
+		SET name = $2, currency = $3, timezone = $4, bands = $5, holidays = $6, updated_at = NOW()
+		WHERE id = $1
+		RETURNING created_at, updated_at`,
+		schedule.ID, schedule.Name, schedule.Currency, schedule.Timezone, bands, holidays,
+	).Scan(&schedule.CreatedAt, &schedule.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return scheduleWriteError(err)
+	}
+	return nil
+}
+
+// DeleteTariffSchedule removes a time-of-use tariff schedule
+func (s *Storage) DeleteTariffSchedule(ctx context.Context, id int64) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM tariff_schedules WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete tariff schedule: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetTariffSchedule retrieves a time-of-use tariff schedule
+func (s *Storage) GetTariffSchedule(ctx context.Context, id int64) (*models.TariffSchedule, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+scheduleColumns+" FROM tariff_schedules WHERE id = $1", id)
+	schedule, err := scanSchedule(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return schedule, err
+}
+
+// GetTariffSchedules retrieves every time-of-use tariff schedule ordered by name
+func (s *Storage) GetTariffSchedules(ctx context.Context) ([]models.TariffSchedule, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+scheduleColumns+" FROM tariff_schedules ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tariff schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var schedules []models.TariffSchedule
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, *schedule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tariff schedules: %w", err)
+	}
+	return schedules, nil
+}
+
+// scanSchedule scans a row selected with scheduleColumns
+func scanSchedule(row rowScanner) (*models.TariffSchedule, error) {
+	var (
+		schedule        models.TariffSchedule
+		bands, holidays []byte
+	)
+	if err := row.Scan(
+		&schedule.ID,
+		&schedule.Name,
+		&schedule.Currency,
+		&schedule.Timezone,
+		&bands,
+		&holidays,
+		&schedule.CreatedAt,
+		&schedule.UpdatedAt,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan tariff schedule: %w", err)
+	}
+	if err := json.Unmarshal(bands, &schedule.Bands); err != nil {
+		return nil, fmt.Errorf("failed to decode tariff bands: %w", err)
+	}
+	if err := json.Unmarshal(holidays, &schedule.Holidays); err != nil {
+		return nil, fmt.Errorf("failed to decode tariff holidays: %w", err)
+	}
+	return &schedule, nil
+}
+
+// scheduleWriteError maps constraint violations on tariff schedules to storage errors
+func scheduleWriteError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return ErrDuplicate
+	}
+	return fmt.Errorf("failed to write tariff schedule: %w", err)
+}
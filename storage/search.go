@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"product-tracker/models"
+	"strconv"
+)
+
+// SearchQuery is a ranked product search
+type SearchQuery struct {
+	// Text is searched for in the name and description, in the syntax of web search engines: quoted phrases,
+	// "or" and a leading "-" to exclude a word
+	Text   string
+	Filter ProductFilter
+	// MinSimilarity is the trigram word similarity a name needs to match when the full text does not
+	MinSimilarity float64
+	Limit         int
+}
+
+// headlineOptions mark the matched words in search highlights
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>"
+
+// escapedHTML returns an expression escaping the HTML special characters of a text column, so that the only tags
+// of a highlight are those ts_headline adds
+func escapedHTML(column string) string {
+	return fmt.Sprintf("replace(replace(replace(%s, '&', '&amp;'), '<', '&lt;'), '>', '&gt;')", column)
+}
+
+// SearchProducts returns the products whose name or description match the full-text search, or whose name is
+// similar to the text despite typos, best first. Matches in the name weigh more than matches in the description.
+// Both searches are served by indexes on products.
+func (s *Storage) SearchProducts(ctx context.Context, query SearchQuery) ([]models.SearchResult, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The threshold of the <% operator is a setting, which the trigram index honours unlike a similarity condition
+	if _, err := tx.ExecContext(ctx, "SELECT set_config('pg_trgm.word_similarity_threshold', $1, true)",
+		strconv.FormatFloat(query.MinSimilarity, 'f', -1, 64)); err != nil {
+		return nil, fmt.Errorf("failed to set similarity threshold: %w", err)
+	}
+
+	conditions, args := query.Filter.conditions([]any{query.Text, query.Limit})
+	conditions = append(conditions, "(search_vector @@ q.query OR $1 <% name)")
+	statement := fmt.Sprintf(`
+		SELECT %s,
+			ts_rank(search_vector, q.query) + word_similarity($1, name) AS score,
+			ts_headline('english', %s, q.query, '%s, HighlightAll=TRUE'),
+			ts_headline('english', %s, q.query, '%s, MinWords=10, MaxWords=30, MaxFragments=2')
+		FROM products, websearch_to_tsquery('english', $1) AS q (query)
+		%s
+		ORDER BY score DESC, id
+		LIMIT $2`, productColumns, escapedHTML("name"), headlineOptions, escapedHTML("description"), headlineOptions,
+		whereClause(conditions))
+
+	rows, err := tx.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search products: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.SearchResult{}
+	for rows.Next() {
+		var result models.SearchResult
+		product, err := scanProduct(searchRow{rows: rows, result: &result})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		result.Product = *product
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating search results: %w", err)
+	}
+	return results, nil
+}
+
+// searchRow scans a search result row, passing the product columns to scanProduct and the rest to the result
+type searchRow struct {
+	rows   *sql.Rows
+	result *models.SearchResult
+}
+
+// Scan scans the row into the product destinations followed by the score and highlights
+func (r searchRow) Scan(dest ...any) error {
+	dest = append(dest, &r.result.Score, &r.result.NameHighlight, &r.result.DescriptionSnippet)
+	return r.rows.Scan(dest...)
+}
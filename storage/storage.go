@@ -3,53 +3,140 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"product-tracker/anomaly"
+	"product-tracker/cache"
 	"product-tracker/config"
 	"product-tracker/db"
 	"product-tracker/models"
+	"product-tracker/outbox"
+	"product-tracker/units"
+	"product-tracker/webhooks"
+	"strings"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
+var (
+	ErrNotFound          = errors.New("record not found")
+	ErrInvalidNaturalKey = errors.New("invalid natural key")
+	ErrVersionMismatch   = errors.New("version mismatch")
+	ErrVersionRequired   = errors.New("expected version required")
+	ErrInvalidCategory   = errors.New("category does not exist")
+	ErrInvalidTag        = errors.New("invalid tag")
+	ErrCategoryCycle     = errors.New("category cannot be its own ancestor")
+	ErrCategoryNotEmpty  = errors.New("category has subcategories")
+	ErrDuplicate         = errors.New("record already exists")
+	ErrInvalidInterval   = errors.New("recorded_at and interval_seconds must be given together")
+	ErrTariffInUse       = errors.New("tariff is used by a budget")
+	ErrInvalidProduct    = errors.New("product does not exist")
+)
+
+// AnyVersion disables the optimistic concurrency check on product writes
+const AnyVersion int64 = 0
+
+// VersionFunc returns the version a write to an existing product must apply to, once the product it matched is
+// known, or an error to refuse the write
+type VersionFunc func(id int64) (int64, error)
+
 // Table and column constants
 const (
-	tableName = "product_tracker"
-	columns   = "name, quantity, energy_consumed, date"
+	tableName      = "product_tracker"
+	columns        = "product_id, name, quantity, energy_consumed, energy_input, date, recorded_at, interval_seconds, region"
+	readingColumns = "t.id, t.product_id, t.name, t.quantity, t.energy_consumed, t.energy_input, t.date, " +
+		"t.recorded_at, t.interval_seconds, COALESCE(t.region, p.region)"
+	productColumns = "id, tenant_id, name, model, description, price_minor, currency, energy_consumption, energy_input, category_id, " +
+		productTagsColumn + ", region, attributes, rating_class, rating_index, rating_scheme_id, rating_scheme_version, " +
+		"version, created_at, updated_at, deleted_at"
+)
+
+// productTagsColumn selects the sorted tag names of the product row being read
+const productTagsColumn = `COALESCE((
+		SELECT array_agg(tg.name ORDER BY tg.name)
+		FROM product_tags pt JOIN tags tg ON tg.id = pt.tag_id
+		WHERE pt.product_id = products.id
+	), '{}')`
+
+// visibleReadings joins readings to their product and hides those linked to a soft-deleted product
+const (
+	visibleReadingsJoin      = "LEFT JOIN products p ON p.id = t.product_id"
+	visibleReadingsCondition = "(t.product_id IS NULL OR p.deleted_at IS NULL)"
 )
 
-// Product represents a product record in the database
+// Product represents a product record in the database.
+// EnergyConsumed is expressed in EnergyUnit and converted to kWh on insert; an empty unit means kWh.
+// Interval readings set RecordedAt and IntervalSeconds together; without them a reading covers the whole Date.
 type Product struct {
-	Name           string  `json:"name" validate:"required"`
-	Quantity       int     `json:"quantity" validate:"required,min=0"`
-	EnergyConsumed float64 `json:"energy_consumed" validate:"required,min=0"`
-	Date           string  `json:"date" validate:"required,datetime=2006-01-02"`
+	ProductID       *int64     `json:"product_id,omitempty"`
+	Name            string     `json:"name" validate:"required"`
+	Quantity        int        `json:"quantity" validate:"required,min=0"`
+	EnergyConsumed  float64    `json:"energy_consumed" validate:"required,min=0"`
+	EnergyUnit      string     `json:"energy_unit"`
+	Date            string     `json:"date" validate:"required,datetime=2006-01-02"`
+	RecordedAt      *time.Time `json:"recorded_at,omitempty" validate:"required_with=IntervalSeconds"`
+	IntervalSeconds *int       `json:"interval_seconds,omitempty" validate:"omitempty,min=1,required_with=RecordedAt"`
+	Region          *string    `json:"region,omitempty"`
 }
 
 // Storage represents the database storage layer
 type Storage struct {
 	db *sql.DB
+	// rollupTimezones lists the timezones reading rollups are maintained in on insert
+	rollupTimezones []string
+	// detector checks inserted readings for anomalies
+	detector anomaly.Detector
+	// projectionMinDays is the number of days of a month after which budgets project month-end usage
+	projectionMinDays float64
 }
 
-// NewStorage creates a new storage instance
-func NewStorage(cfg *config.Config) (*Storage, error) {
-	dbConfig := &db.DBConfig{
+// DBConfig returns the database settings of cfg
+func DBConfig(cfg *config.Config) *db.DBConfig {
+	return &db.DBConfig{
 		Host:     cfg.Database.Host,
 		Port:     cfg.Database.Port,
 		User:     cfg.Database.User,
 		Password: cfg.Database.Password,
 		DbName:   cfg.Database.DbName,
 	}
+}
+
+// NewStorage creates a new storage instance confined to scope
+func NewStorage(cfg *config.Config, scope Scope) (*Storage, error) {
+	dbConfig := DBConfig(cfg)
+	options, err := scope.options()
+	if err != nil {
+		return nil, err
+	}
+	dbConfig.Options = options
 
 	if err := db.ValidateConfig(dbConfig); err != nil {
 		return nil, fmt.Errorf("invalid database configuration: %v", err)
 	}
 
+	detector, err := NewDetector(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid anomaly detection configuration: %w", err)
+	}
+
 	database, err := db.NewDB(dbConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %v", err)
 	}
 
-	return &Storage{db: database}, nil
+	return &Storage{
+		db:                database,
+		rollupTimezones:   cfg.Rollups.Timezones,
+		detector:          detector,
+		projectionMinDays: cfg.Budgets.ProjectionMinDays,
+	}, nil
+}
+
+// Ping checks that the database can be reached
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
 }
 
 // Close closes the database connection
@@ -60,29 +147,512 @@ func (s *Storage) Close() error {
 	return nil
 }
 
+// Migrate applies any pending schema migrations
+func (s *Storage) Migrate(ctx context.Context) error {
+	return db.Migrate(ctx, s.db)
+}
+
 // InsertProduct inserts a new product into the database
 func (s *Storage) InsertProduct(ctx context.Context, product *models.Product) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertProduct(ctx, tx, product); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	cache.ProductChanged(product.ID)
+
+	return nil
+}
+
+// insertProduct inserts a product and records its creation in the history
+func insertProduct(ctx context.Context, tx *sql.Tx, product *models.Product) error {
+	if err := validateCategory(ctx, tx, product.CategoryID); err != nil {
+		return err
+	}
+
+	energyInput, err := marshalEnergyInput(product.EnergyInput)
+	if err != nil {
+		return err
+	}
+	attributes, err := rateProduct(ctx, tx, product)
+	if err != nil {
+		return err
+	}
+
 	query := `
-		INSERT INTO products (name, description, price, energy_consumption)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, created_at, updated_at`
+		INSERT INTO products (name, model, description, price_minor, currency, energy_consumption, energy_input, category_id, region,
+			attributes, rating_class, rating_index, rating_scheme_id, rating_scheme_version)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING id, tenant_id, version, created_at, updated_at`
 
-	return s.db.QueryRowContext(ctx, query,
+	ratingClass, ratingIndex, schemeID, schemeVersion := ratingValues(product.Rating)
+
+	err = tx.QueryRowContext(ctx, query,
 		product.Name,
+		product.Model,
 		product.Description,
-		product.Price,
+		product.PriceMinor,
+		product.Currency,
 		product.EnergyConsumption,
-	).Scan(&product.ID, &product.CreatedAt, &product.UpdatedAt)
+		energyInput,
+		product.CategoryID,
+		product.Region,
+		attributes,
+		ratingClass,
+		ratingIndex,
+		schemeID,
+		schemeVersion,
+	).Scan(&product.ID, &product.TenantID, &product.Version, &product.CreatedAt, &product.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert product: %w", err)
+	}
+	product.EnergyUnit = units.ProductCanonical
+
+	if product.Tags, err = setProductTags(ctx, tx, product.ID, product.Tags); err != nil {
+		return err
+	}
+
+	if err := recordHistory(ctx, tx, models.HistoryActionCreate, nil, product); err != nil {
+		return err
+	}
+	return recordEvent(ctx, tx, product.TenantID, outbox.AggregateProduct, product.ID, webhooks.EventProductCreated, product)
+}
+
+// UpdateProduct replaces the editable fields of an existing product.
+// Unless expectedVersion is AnyVersion, the update only applies if the stored version matches
+// and ErrVersionMismatch is returned otherwise.
+func (s *Storage) UpdateProduct(ctx context.Context, product *models.Product, expectedVersion int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := getProductForUpdate(ctx, tx, product.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := updateProduct(ctx, tx, before, product, expectedVersion); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	cache.ProductChanged(product.ID)
+
+	return nil
+}
+
+// updateProduct writes product over the locked row before and records the change in the history.
+// The version check is part of the UPDATE so that it cannot race with other writers.
+func updateProduct(ctx context.Context, tx *sql.Tx, before, product *models.Product, expectedVersion int64) error {
+	if err := validateCategory(ctx, tx, product.CategoryID); err != nil {
+		return err
+	}
+
+	energyInput, err := marshalEnergyInput(product.EnergyInput)
+	if err != nil {
+		return err
+	}
+	attributes, err := rateProduct(ctx, tx, product)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE products
+		SET name = $2, model = $3, description = $4, price_minor = $5, currency = $6, energy_consumption = $7,
+			energy_input = $8, category_id = $9, region = $10, attributes = $11, rating_class = $12, rating_index = $13,
+			rating_scheme_id = $14, rating_scheme_version = $15, version = version + 1, updated_at = NOW()
+		WHERE id = $1 AND ($16::bigint = 0 OR version = $16)
+		RETURNING ` + productColumns
+
+	ratingClass, ratingIndex, schemeID, schemeVersion := ratingValues(product.Rating)
+
+	after, err := scanProduct(tx.QueryRowContext(ctx, query,
+		before.ID,
+		product.Name,
+		product.Model,
+		product.Description,
+		product.PriceMinor,
+		product.Currency,
+		product.EnergyConsumption,
+		energyInput,
+		product.CategoryID,
+		product.Region,
+		attributes,
+		ratingClass,
+		ratingIndex,
+		schemeID,
+		schemeVersion,
+		expectedVersion,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrVersionMismatch
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update product: %w", err)
+	}
+
+	if after.Tags, err = setProductTags(ctx, tx, after.ID, product.Tags); err != nil {
+		return err
+	}
+	*product = *after
+
+	if err := recordHistory(ctx, tx, models.HistoryActionUpdate, before, product); err != nil {
+		return err
+	}
+	return recordEvent(ctx, tx, product.TenantID, outbox.AggregateProduct, product.ID, webhooks.EventProductUpdated, product)
+}
+
+// getProductForUpdate loads a product and locks its row until the transaction ends
+func getProductForUpdate(ctx context.Context, tx *sql.Tx, id int64) (*models.Product, error) {
+	query := "SELECT " + productColumns + " FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE"
+
+	product, err := scanProduct(tx.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load product: %w", err)
+	}
+	return product, nil
+}
+
+// lockProducts loads the products matching condition, including those in the trash, and locks their rows
+// until the transaction ends
+func lockProducts(ctx context.Context, tx *sql.Tx, condition string, args ...interface{}) ([]*models.Product, error) {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT "+productColumns+" FROM products WHERE "+condition+" ORDER BY id FOR UPDATE", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock products: %w", err)
+	}
+	defer rows.Close()
+
+	var products []*models.Product
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan product: %w", err)
+		}
+		products = append(products, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating products: %w", err)
+	}
+	return products, nil
+}
+
+// reviseProduct applies a change that follows from another write, such as a new rating scheme, to a product
+// locked by the caller. set lists the column assignments, with arguments from $2; it may be empty when the
+// change was made to related rows, such as a renamed tag. The version is bumped and the change recorded in the
+// history like any update. Products in the trash are not announced, since their deletion was.
+func reviseProduct(ctx context.Context, tx *sql.Tx, before *models.Product, set string, args ...interface{}) error {
+	assignments := "version = version + 1, updated_at = NOW()"
+	if set != "" {
+		assignments = set + ", " + assignments
+	}
+	after, err := scanProduct(tx.QueryRowContext(ctx,
+		"UPDATE products SET "+assignments+" WHERE id = $1 RETURNING "+productColumns,
+		append([]interface{}{before.ID}, args...)...,
+	))
+	if err != nil {
+		return fmt.Errorf("failed to update product: %w", err)
+	}
+
+	if err := recordHistory(ctx, tx, models.HistoryActionUpdate, before, after); err != nil {
+		return err
+	}
+	if after.DeletedAt != nil {
+		return nil
+	}
+	return recordEvent(ctx, tx, after.TenantID, outbox.AggregateProduct, after.ID, webhooks.EventProductUpdated, after)
+}
+
+// naturalKeyColumns maps the columns allowed in a product natural key to their values
+var naturalKeyColumns = map[string]func(p *models.Product) string{
+	"name":  func(p *models.Product) string { return p.Name },
+	"model": func(p *models.Product) string { return p.Model },
+}
+
+// ValidateNaturalKey checks that every column of a natural key can be used for upserts
+func ValidateNaturalKey(key []string) error {
+	if len(key) == 0 {
+		return ErrInvalidNaturalKey
+	}
+	for _, column := range key {
+		if _, ok := naturalKeyColumns[column]; !ok {
+			return fmt.Errorf("%w: unsupported column %q", ErrInvalidNaturalKey, column)
+		}
+	}
+	return nil
+}
+
+// UpsertProduct inserts a product or updates the existing one sharing the same natural key, at the version
+// expectedVersion returns for it. It reports whether a new row was created.
+func (s *Storage) UpsertProduct(ctx context.Context, product *models.Product, key []string, expectedVersion VersionFunc) (bool, error) {
+	if err := ValidateNaturalKey(key); err != nil {
+		return false, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	conditions := make([]string, len(key))
+	parts := make([]string, len(key))
+	values := make([]any, len(key))
+	for i, column := range key {
+		conditions[i] = fmt.Sprintf("%s = $%d", column, i+1)
+		parts[i] = naturalKeyColumns[column](product)
+		values[i] = parts[i]
+	}
+
+	// There is no unique index on a configurable key, so serialize writers on the key itself
+	lockKey := "products:" + strings.Join(parts, "\x1f")
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", lockKey); err != nil {
+		return false, fmt.Errorf("failed to lock natural key: %w", err)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s FROM products
+		WHERE %s AND deleted_at IS NULL
+		ORDER BY id
+		LIMIT 1
+		FOR UPDATE`, productColumns, strings.Join(conditions, " AND "))
+
+	before, err := scanProduct(tx.QueryRowContext(ctx, query, values...))
+	created := errors.Is(err, sql.ErrNoRows)
+	if err != nil && !created {
+		return false, fmt.Errorf("failed to look up product by natural key: %w", err)
+	}
+
+	if created {
+		err = insertProduct(ctx, tx, product)
+	} else {
+		var version int64
+		if version, err = expectedVersion(before.ID); err == nil {
+			err = updateProduct(ctx, tx, before, product, version)
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	cache.ProductChanged(product.ID)
+
+	return created, nil
+}
+
+// DeleteProduct soft-deletes a product. It stays in the trash until restored or purged.
+// expectedVersion works as in UpdateProduct.
+func (s *Storage) DeleteProduct(ctx context.Context, id, expectedVersion int64) error {
+	return s.setProductDeleted(ctx, id, true, expectedVersion)
+}
+
+// RestoreProduct takes a soft-deleted product out of the trash.
+// expectedVersion works as in UpdateProduct.
+func (s *Storage) RestoreProduct(ctx context.Context, id, expectedVersion int64) error {
+	return s.setProductDeleted(ctx, id, false, expectedVersion)
+}
+
+// setProductDeleted moves a product in or out of the trash and records the change in the history
+func (s *Storage) setProductDeleted(ctx context.Context, id int64, deleted bool, expectedVersion int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := scanProduct(tx.QueryRowContext(ctx,
+		"SELECT "+productColumns+" FROM products WHERE id = $1 AND (deleted_at IS NULL) = $2 FOR UPDATE",
+		id, deleted,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load product: %w", err)
+	}
+
+	action := models.HistoryActionRestore
+	deletedAt := "NULL"
+	if deleted {
+		action = models.HistoryActionDelete
+		deletedAt = "NOW()"
+	}
+
+	query := `
+		UPDATE products
+		SET deleted_at = ` + deletedAt + `, version = version + 1, updated_at = NOW()
+		WHERE id = $1 AND ($2::bigint = 0 OR version = $2)
+		RETURNING ` + productColumns
+
+	after, err := scanProduct(tx.QueryRowContext(ctx, query, id, expectedVersion))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrVersionMismatch
+	}
+	if err != nil {
+		return fmt.Errorf("failed to %s product: %w", action, err)
+	}
+
+	if err := recordHistory(ctx, tx, action, before, after); err != nil {
+		return err
+	}
+	// A product restored from the trash is announced as updated, with deleted_at cleared
+	event := webhooks.EventProductUpdated
+	if deleted {
+		event = webhooks.EventProductDeleted
+	}
+	if err := recordEvent(ctx, tx, after.TenantID, outbox.AggregateProduct, id, event, after); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	cache.ProductVisibilityChanged(id)
+
+	return nil
+}
+
+// GetDeletedProducts retrieves the products currently in the trash, most recently deleted first
+func (s *Storage) GetDeletedProducts(ctx context.Context) ([]models.Product, error) {
+	query := `
+		SELECT ` + productColumns + `
+		FROM products
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deleted products: %w", err)
+	}
+	defer rows.Close()
+
+	return s.scanProducts(rows)
+}
+
+// PurgeDeletedProducts permanently removes products deleted before the given time,
+// together with their readings. The purge is recorded in the product history.
+func (s *Storage) PurgeDeletedProducts(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	payload, err := json.Marshal(cacheInvalidation{Tags: []string{cache.TagProducts}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode cache invalidation: %w", err)
+	}
+
+	// Each tenant whose trash shrank gets one cache invalidation event
+	query := `
+		WITH purged AS (
+			DELETE FROM products
+			WHERE deleted_at IS NOT NULL AND deleted_at < $1
+			RETURNING id, tenant_id
+		), history AS (
+			INSERT INTO product_history (product_id, tenant_id, action)
+			SELECT id, tenant_id, $2 FROM purged
+		), events AS (
+			INSERT INTO outbox (tenant_id, aggregate_type, aggregate_id, event, payload)
+			SELECT DISTINCT tenant_id, $3, tenant_id::text, $4, $5::jsonb FROM purged
+		)
+		SELECT COUNT(*) FROM purged`
+
+	var purged int64
+	err = s.db.QueryRowContext(ctx, query, deletedBefore, models.HistoryActionPurge,
+		outbox.AggregateCache, EventCacheInvalidated, string(payload),
+	).Scan(&purged)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted products: %w", err)
+	}
+	if purged > 0 {
+		// Products in the trash are only listed to admins; their readings are hidden already
+		cache.Invalidate(cache.TagProducts)
+	}
+	return purged, nil
+}
+
+// ProductFilter narrows down product listings
+type ProductFilter struct {
+	// IncludeDeleted also returns products that are in the trash
+	IncludeDeleted bool
+	// CategoryID keeps products in the category or any of its descendants
+	CategoryID int64
+	// Tag keeps products carrying the tag
+	Tag string
+	// RatingClasses keeps products rated in any of the classes
+	RatingClasses []string
+}
+
+// conditions returns the SQL conditions for the filter, appending their arguments to args
+func (f ProductFilter) conditions(args []any) ([]string, []any) {
+	var conditions []string
+	if !f.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	if f.CategoryID != 0 {
+		args = append(args, f.CategoryID)
+		conditions = append(conditions, fmt.Sprintf(
+			"category_id IN (%s)", categoryTreeQuery(len(args))))
+	}
+	if f.Tag != "" {
+		args = append(args, normalizeTag(f.Tag))
+		conditions = append(conditions, fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM product_tags pt JOIN tags tg ON tg.id = pt.tag_id
+			WHERE pt.product_id = products.id AND tg.name = $%d)`, len(args)))
+	}
+	if len(f.RatingClasses) > 0 {
+		args = append(args, pq.Array(f.RatingClasses))
+		conditions = append(conditions, fmt.Sprintf("rating_class = ANY($%d)", len(args)))
+	}
+	return conditions, args
+}
+
+// whereClause joins conditions into a WHERE clause, or returns an empty string if there are none
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
+
+// GetProduct retrieves a single product by ID. Products in the trash are not returned.
+func (s *Storage) GetProduct(ctx context.Context, id int64) (*models.Product, error) {
+	query := "SELECT " + productColumns + " FROM products WHERE id = $1 AND deleted_at IS NULL"
+
+	product, err := scanProduct(s.db.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query product: %w", err)
+	}
+	return product, nil
 }
 
 // GetProducts retrieves all products from the database
-func (s *Storage) GetProducts(ctx context.Context) ([]models.Product, error) {
+func (s *Storage) GetProducts(ctx context.Context, filter ProductFilter) ([]models.Product, error) {
+	conditions, args := filter.conditions(nil)
 	query := `
-		SELECT id, name, description, price, energy_consumption, created_at, updated_at
+		SELECT ` + productColumns + `
 		FROM products
+		` + whereClause(conditions) + `
 		ORDER BY created_at DESC`
 
-	rows, err := s.db.QueryContext(ctx, query)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query products: %v", err)
 	}
@@ -91,15 +661,38 @@ func (s *Storage) GetProducts(ctx context.Context) ([]models.Product, error) {
 	return s.scanProducts(rows)
 }
 
+// GetProductsPage retrieves up to limit products matching filter with an ID above afterID, ordered by ID, for
+// keyset pagination
+func (s *Storage) GetProductsPage(ctx context.Context, filter ProductFilter, afterID int64, limit int) ([]models.Product, error) {
+	conditions, args := filter.conditions([]any{afterID, limit})
+	conditions = append(conditions, "id > $1")
+	query := `
+		SELECT ` + productColumns + `
+		FROM products
+		` + whereClause(conditions) + `
+		ORDER BY id
+		LIMIT $2`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query products: %w", err)
+	}
+	defer rows.Close()
+
+	return s.scanProducts(rows)
+}
+
 // GetProductsByName retrieves products by name from the database
-func (s *Storage) GetProductsByName(ctx context.Context, name string) ([]models.Product, error) {
+func (s *Storage) GetProductsByName(ctx context.Context, name string, filter ProductFilter) ([]models.Product, error) {
+	conditions, args := filter.conditions([]any{"%" + name + "%"})
+	conditions = append(conditions, "name ILIKE $1")
 	query := `
-		SELECT id, name, description, price, energy_consumption, created_at, updated_at
+		SELECT ` + productColumns + `
 		FROM products
-		WHERE name ILIKE $1
+		` + whereClause(conditions) + `
 		ORDER BY created_at DESC`
 
-	rows, err := s.db.QueryContext(ctx, query, "%"+name+"%")
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query products by name: %v", err)
 	}
@@ -108,24 +701,76 @@ func (s *Storage) GetProductsByName(ctx context.Context, name string) ([]models.
 	return s.scanProducts(rows)
 }
 
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanProduct scans a single row selected with productColumns
+func scanProduct(row rowScanner) (*models.Product, error) {
+	var (
+		p             models.Product
+		energyInput   []byte
+		attributes    []byte
+		ratingClass   sql.NullString
+		ratingIndex   sql.NullFloat64
+		schemeID      sql.NullInt64
+		schemeVersion sql.NullInt64
+	)
+	err := row.Scan(
+		&p.ID,
+		&p.TenantID,
+		&p.Name,
+		&p.Model,
+		&p.Description,
+		&p.PriceMinor,
+		&p.Currency,
+		&p.EnergyConsumption,
+		&energyInput,
+		&p.CategoryID,
+		pq.Array(&p.Tags),
+		&p.Region,
+		&attributes,
+		&ratingClass,
+		&ratingIndex,
+		&schemeID,
+		&schemeVersion,
+		&p.Version,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+		&p.DeletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	p.SetPrice(p.PriceMoney())
+	p.EnergyUnit = units.ProductCanonical
+	if p.EnergyInput, err = unmarshalEnergyInput(energyInput); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(attributes, &p.Attributes); err != nil {
+		return nil, fmt.Errorf("failed to decode product attributes: %w", err)
+	}
+	if ratingClass.Valid {
+		p.Rating = &models.Rating{
+			Class:         ratingClass.String,
+			Index:         ratingIndex.Float64,
+			SchemeID:      schemeID.Int64,
+			SchemeVersion: int(schemeVersion.Int64),
+		}
+	}
+	return &p, nil
+}
+
 // scanProducts scans rows into Product structs
 func (s *Storage) scanProducts(rows *sql.Rows) ([]models.Product, error) {
 	var products []models.Product
 	for rows.Next() {
-		var p models.Product
-		err := rows.Scan(
-			&p.ID,
-			&p.Name,
-			&p.Description,
-			&p.Price,
-			&p.EnergyConsumption,
-			&p.CreatedAt,
-			&p.UpdatedAt,
-		)
+		p, err := scanProduct(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan product: %v", err)
 		}
-		products = append(products, p)
+		products = append(products, *p)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating products: %v", err)
@@ -133,50 +778,216 @@ func (s *Storage) scanProducts(rows *sql.Rows) ([]models.Product, error) {
 	return products, nil
 }
 
-// InsertProducts inserts multiple products in a transaction
-func (s *Storage) InsertProducts(ctx context.Context, products []Product) error {
+// InsertProducts inserts multiple products in a transaction and returns their IDs in order
+func (s *Storage) InsertProducts(ctx context.Context, products []Product) ([]int64, error) {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES ($1, $2, $3, $4)", tableName, columns)
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, tenant_id", tableName, columns)
 
+	ids := make([]int64, 0, len(products))
+	var productIDs []int64
+	// Readings belong to the tenant of their product, so a batch may span tenants when written for all of them
+	var tenants []int64
+	tenantReadings := make(map[int64][]int64)
+	tenantProducts := make(map[int64][]int64)
 	for _, p := range products {
-		result, err := tx.ExecContext(ctx, query, p.Name, p.Quantity, p.EnergyConsumed, p.Date)
+		energyConsumed, energyInput, err := canonicalReadingEnergy(p)
 		if err != nil {
-			return fmt.Errorf("failed to insert product: %w", err)
+			return nil, err
 		}
 
-		rowsAffected, err := result.RowsAffected()
+		if (p.RecordedAt == nil) != (p.IntervalSeconds == nil) {
+			return nil, ErrInvalidInterval
+		}
+		region, err := normalizeRegion(p.Region)
 		if err != nil {
-			return fmt.Errorf("failed to get rows affected: %w", err)
+			return nil, err
 		}
 
-		if rowsAffected == 0 {
-			return db.ErrDBNoRowsEffected
+		var id, tenantID int64
+		err = tx.QueryRowContext(ctx, query,
+			p.ProductID, p.Name, p.Quantity, energyConsumed, energyInput, p.Date, p.RecordedAt, p.IntervalSeconds, region).Scan(&id, &tenantID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, db.ErrDBNoRowsEffected
+		}
+		if err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+				return nil, ErrInvalidProduct
+			}
+			return nil, fmt.Errorf("failed to insert product: %w", err)
+		}
+		ids = append(ids, id)
+		if _, ok := tenantReadings[tenantID]; !ok {
+			tenants = append(tenants, tenantID)
+		}
+		tenantReadings[tenantID] = append(tenantReadings[tenantID], id)
+		if p.ProductID != nil {
+			productIDs = append(productIDs, *p.ProductID)
+			tenantProducts[tenantID] = append(tenantProducts[tenantID], *p.ProductID)
+		}
+	}
+
+	// Rolling up in the same transaction keeps rollups in step with readings, late ones included
+	if err := s.addToRollups(ctx, tx, ids); err != nil {
+		return nil, err
+	}
+	if _, err := s.checkReadings(ctx, tx, ids); err != nil {
+		return nil, err
+	}
+	for _, tenantID := range tenants {
+		readingIDs := tenantReadings[tenantID]
+		ingested := models.ReadingsIngested{
+			Count: len(readingIDs), ReadingIDs: readingIDs, ProductIDs: distinctIDs(tenantProducts[tenantID]),
+		}
+		if err := recordEvent(ctx, tx, tenantID, outbox.AggregateReadings, readingIDs[0], webhooks.EventReadingsIngested, ingested); err != nil {
+			return nil, err
 		}
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	cache.ReadingsChanged(distinctIDs(productIDs))
 
-	return nil
+	// Budgets are evaluated once the readings are visible to their own transactions
+	s.evaluateBudgetsForProducts(ctx, productIDs)
+
+	return ids, nil
+}
+
+// distinctIDs returns ids without repetitions, in order of first appearance
+func distinctIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	distinct := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			distinct = append(distinct, id)
+		}
+	}
+	return distinct
+}
+
+// GetProductsByDateRange retrieves readings within a date range.
+// Readings linked to a soft-deleted product are hidden.
+func (s *Storage) GetProductsByDateRange(ctx context.Context, startDate, endDate string) ([]models.Reading, error) {
+	return s.GetReadings(ctx, ReadingFilter{Start: startDate, End: endDate})
+}
+
+// ReadingFilter narrows down a listing of readings
+type ReadingFilter struct {
+	// Start and End bound the reading date, inclusive
+	Start string
+	End   string
+	// ProductID keeps only the readings of one product when set
+	ProductID *int64
 }
 
-// GetProductsByDateRange retrieves products within a date range
-func (s *Storage) GetProductsByDateRange(ctx context.Context, startDate, endDate string) ([]models.Product, error) {
-	query := fmt.Sprintf("SELECT %s FROM %s WHERE date BETWEEN $1 AND $2 ORDER BY date", columns, tableName)
+// GetReadings retrieves the readings matching filter ordered by date and recording time.
+// Readings linked to a soft-deleted product are hidden.
+func (s *Storage) GetReadings(ctx context.Context, filter ReadingFilter) ([]models.Reading, error) {
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s t
+		%s
+		WHERE t.date BETWEEN $1 AND $2 AND ($3::bigint IS NULL OR t.product_id = $3) AND %s
+		ORDER BY t.date, t.recorded_at NULLS FIRST, t.id`, readingColumns, tableName, visibleReadingsJoin, visibleReadingsCondition)
 
-	rows, err := s.db.QueryContext(ctx, query, startDate, endDate)
+	rows, err := s.db.QueryContext(ctx, query, filter.Start, filter.End, filter.ProductID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query products: %w", err)
 	}
 	defer rows.Close()
 
-	return s.scanProducts(rows)
+	return scanReadings(rows)
+}
+
+// GetReading retrieves a single reading by ID. Readings linked to a soft-deleted product are hidden.
+func (s *Storage) GetReading(ctx context.Context, id int64) (*models.Reading, error) {
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s t
+		%s
+		WHERE t.id = $1 AND %s`, readingColumns, tableName, visibleReadingsJoin, visibleReadingsCondition)
+
+	rows, err := s.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reading: %w", err)
+	}
+	defer rows.Close()
+
+	readings, err := scanReadings(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(readings) == 0 {
+		return nil, ErrNotFound
+	}
+	return &readings[0], nil
+}
+
+// GetReadingsPage retrieves up to limit readings matching filter with an ID above afterID, ordered by ID, for
+// keyset pagination. Empty Start or End leave the date range open on that side.
+// Readings linked to a soft-deleted product are hidden.
+func (s *Storage) GetReadingsPage(ctx context.Context, filter ReadingFilter, afterID int64, limit int) ([]models.Reading, error) {
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s t
+		%s
+		WHERE t.id > $1 AND ($2 = '' OR t.date >= $2::date) AND ($3 = '' OR t.date <= $3::date)
+			AND ($4::bigint IS NULL OR t.product_id = $4) AND %s
+		ORDER BY t.id
+		LIMIT $5`, readingColumns, tableName, visibleReadingsJoin, visibleReadingsCondition)
+
+	rows, err := s.db.QueryContext(ctx, query, afterID, filter.Start, filter.End, filter.ProductID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query readings: %w", err)
+	}
+	defer rows.Close()
+
+	return scanReadings(rows)
+}
+
+// scanReadings scans rows selected with readingColumns into Reading structs
+func scanReadings(rows *sql.Rows) ([]models.Reading, error) {
+	var readings []models.Reading
+	for rows.Next() {
+		var (
+			r           models.Reading
+			energyInput []byte
+			date        time.Time
+		)
+		err := rows.Scan(
+			&r.ID,
+			&r.ProductID,
+			&r.Name,
+			&r.Quantity,
+			&r.EnergyConsumed,
+			&energyInput,
+			&date,
+			&r.RecordedAt,
+			&r.IntervalSeconds,
+			&r.Region,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan reading: %w", err)
+		}
+		r.Date = date.Format(models.ReadingDateLayout)
+		r.EnergyUnit = units.ReadingCanonical
+		if r.EnergyInput, err = unmarshalEnergyInput(energyInput); err != nil {
+			return nil, err
+		}
+		readings = append(readings, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating readings: %w", err)
+	}
+	return readings, nil
 }
 
 // GetProductStats retrieves statistics about products
@@ -184,10 +995,12 @@ func (s *Storage) GetProductStats(ctx context.Context) (map[string]interface{},
 	query := fmt.Sprintf(`
 		SELECT 
 			COUNT(*) as total_products,
-			SUM(quantity) as total_quantity,
-			SUM(energy_consumed) as total_energy,
-			AVG(energy_consumed) as avg_energy
-		FROM %s`, tableName)
+			COALESCE(SUM(t.quantity), 0) as total_quantity,
+			COALESCE(SUM(t.energy_consumed), 0) as total_energy,
+			COALESCE(AVG(t.energy_consumed), 0) as avg_energy
+		FROM %s t
+		%s
+		WHERE %s`, tableName, visibleReadingsJoin, visibleReadingsCondition)
 
 	var stats struct {
 		TotalProducts int     `db:"total_products"`
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"product-tracker/models"
+	"product-tracker/outbox"
+	"product-tracker/stream"
+	"product-tracker/units"
+	"product-tracker/webhooks"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// EventChannel is the notification channel the notify sink announces published outbox events on
+const EventChannel = "product_tracker_events"
+
+// EventCacheInvalidated is the outbox event of writes that drop cached responses on every server instance. It is
+// neither delivered to webhooks nor streamed.
+const EventCacheInvalidated = "cache.invalidated"
+
+// invalidationPrefix starts the notifications of EventCacheInvalidated events, followed by the tags to drop
+const invalidationPrefix = "invalidate "
+
+// cacheInvalidation is the payload of EventCacheInvalidated events
+type cacheInvalidation struct {
+	Tags []string `json:"tags"`
+}
+
+// notifySink is the outbox sink announcing each event on EventChannel, so that every server instance can push it
+// to its stream clients. Only the event ID is sent, as notification payloads are limited to 8000 bytes, except
+// for cache invalidations, which carry their tags so that they need not be loaded.
+type notifySink struct {
+	s *Storage
+}
+
+// Name returns "notify"
+func (notifySink) Name() string {
+	return "notify"
+}
+
+// Publish announces the event
+func (n notifySink) Publish(ctx context.Context, event outbox.Event) error {
+	payload := strconv.FormatInt(event.ID, 10)
+	if event.Type == EventCacheInvalidated {
+		var invalidation cacheInvalidation
+		if err := json.Unmarshal(event.Payload, &invalidation); err != nil {
+			return fmt.Errorf("failed to decode cache invalidation: %w", err)
+		}
+		payload = invalidationPrefix + strings.Join(invalidation.Tags, " ")
+	}
+	if _, err := n.s.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", EventChannel, payload); err != nil {
+		return fmt.Errorf("failed to notify: %w", err)
+	}
+	return nil
+}
+
+// InvalidatedTags returns the cache tags announced by a notification on EventChannel, and false if it announces
+// an event to load with StreamEvent instead
+func InvalidatedTags(payload string) ([]string, bool) {
+	tags, ok := strings.CutPrefix(payload, invalidationPrefix)
+	if !ok {
+		return nil, false
+	}
+	return strings.Fields(tags), true
+}
+
+// StreamEvent loads an outbox event as a stream event. Reading batches are expanded to the readings that are
+// still visible. It returns nil for event types that are not streamed.
+func (s *Storage) StreamEvent(ctx context.Context, id int64) (*stream.Event, error) {
+	var eventType string
+	var payload []byte
+	var tenantID int64
+	err := s.db.QueryRowContext(ctx, "SELECT event, payload, tenant_id FROM outbox WHERE id = $1", id).
+		Scan(&eventType, &payload, &tenantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load outbox event: %w", err)
+	}
+
+	event := &stream.Event{ID: strconv.FormatInt(id, 10), Type: eventType, TenantID: tenantID}
+	switch eventType {
+	case webhooks.EventProductCreated, webhooks.EventProductUpdated, webhooks.EventProductDeleted:
+		var product models.Product
+		if err := json.Unmarshal(payload, &product); err != nil {
+			return nil, fmt.Errorf("failed to decode product event: %w", err)
+		}
+		event.Items = []stream.Item{{ProductID: &product.ID, CategoryID: product.CategoryID, Data: json.RawMessage(payload)}}
+	case webhooks.EventReadingsIngested:
+		var ingested models.ReadingsIngested
+		if err := json.Unmarshal(payload, &ingested); err != nil {
+			return nil, fmt.Errorf("failed to decode readings event: %w", err)
+		}
+		event.Batch = true
+		if event.Items, err = s.streamReadings(ctx, ingested.ReadingIDs); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, nil
+	}
+	return event, nil
+}
+
+// streamReadings loads readings with the category of their product, skipping those of products in the trash
+func (s *Storage) streamReadings(ctx context.Context, ids []int64) ([]stream.Item, error) {
+	query := fmt.Sprintf(`
+		SELECT %s, p.category_id
+		FROM %s t
+		%s
+		WHERE t.id = ANY($1) AND %s
+		ORDER BY t.id`, readingColumns, tableName, visibleReadingsJoin, visibleReadingsCondition)
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query readings: %w", err)
+	}
+	defer rows.Close()
+
+	var items []stream.Item
+	for rows.Next() {
+		var (
+			r           models.Reading
+			energyInput []byte
+			date        time.Time
+			categoryID  sql.NullInt64
+		)
+		if err := rows.Scan(&r.ID, &r.ProductID, &r.Name, &r.Quantity, &r.EnergyConsumed, &energyInput, &date,
+			&r.RecordedAt, &r.IntervalSeconds, &r.Region, &categoryID); err != nil {
+			return nil, fmt.Errorf("failed to scan reading: %w", err)
+		}
+		r.Date = date.Format(models.ReadingDateLayout)
+		r.EnergyUnit = units.ReadingCanonical
+		if r.EnergyInput, err = unmarshalEnergyInput(energyInput); err != nil {
+			return nil, err
+		}
+		item := stream.Item{ProductID: r.ProductID, Data: r}
+		if categoryID.Valid {
+			item.CategoryID = &categoryID.Int64
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating readings: %w", err)
+	}
+	return items, nil
+}
+
+// CategoryTreeIDs returns the IDs of a category and all of its descendants
+func (s *Storage) CategoryTreeIDs(ctx context.Context, id int64) ([]int64, error) {
+	rows, err := s.db.QueryContext(ctx, categoryTreeQuery(1), id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query category tree: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var categoryID int64
+		if err := rows.Scan(&categoryID); err != nil {
+			return nil, fmt.Errorf("failed to scan category: %w", err)
+		}
+		ids = append(ids, categoryID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating categories: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil, ErrInvalidCategory
+	}
+	return ids, nil
+}
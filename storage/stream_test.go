@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInvalidatedTags(t *testing.T) {
+	tests := []struct {
+		payload string
+		tags    []string
+		ok      bool
+	}{
+		{"42", nil, false},
+		{"invalidate products product:*", []string{"products", "product:*"}, true},
+		{"invalidate products", []string{"products"}, true},
+		{"invalidate ", []string{}, true},
+	}
+	for _, tt := range tests {
+		tags, ok := InvalidatedTags(tt.payload)
+		if ok != tt.ok || (ok && !reflect.DeepEqual(tags, tt.tags)) {
+			t.Errorf("InvalidatedTags(%q) = %v, %v, want %v, %v", tt.payload, tags, ok, tt.tags, tt.ok)
+		}
+	}
+}
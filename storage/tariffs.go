@@ -0,0 +1,225 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"product-tracker/costcalc"
+	"product-tracker/models"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// normalizeTariff validates a tariff and rewrites its currency code and prices in their stored form
+func normalizeTariff(tariff *models.Tariff) error {
+	parsed, err := costcalc.FromModel(tariff)
+	if err != nil {
+		return err
+	}
+	tariff.Currency = parsed.Currency
+	for i := range tariff.Rates {
+		r := &tariff.Rates[i]
+		for _, price := range []*string{&r.UnitRate, &r.StandingCharge} {
+			value, ok := new(big.Rat).SetString(strings.TrimSpace(*price))
+			if !ok {
+				value = new(big.Rat)
+			}
+			*price = value.FloatString(6)
+		}
+	}
+	return nil
+}
+
+// CreateTariff inserts a tariff with its rates
+func (s *Storage) CreateTariff(ctx context.Context, tariff *models.Tariff) error {
+	if err := normalizeTariff(tariff); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO tariffs (name, currency)
+		VALUES ($1, $2)
+		RETURNING id, created_at, updated_at`,
+		tariff.Name, tariff.Currency,
+	).Scan(&tariff.ID, &tariff.CreatedAt, &tariff.UpdatedAt)
+	if err != nil {
+		return tariffWriteError(err)
+	}
+
+	if err := insertTariffRates(ctx, tx, tariff); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// UpdateTariff renames a tariff and replaces all of its rates
+func (s *Storage) UpdateTariff(ctx context.Context, tariff *models.Tariff) error {
+	if err := normalizeTariff(tariff); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		UPDATE tariffs
+		SET name = $2, currency = $3, updated_at = NOW()
+		WHERE id = $1
+		RETURNING created_at, updated_at`,
+		tariff.ID, tariff.Name, tariff.Currency,
+	).Scan(&tariff.CreatedAt, &tariff.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return tariffWriteError(err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM tariff_rates WHERE tariff_id = $1", tariff.ID); err != nil {
+		return fmt.Errorf("failed to clear tariff rates: %w", err)
+	}
+	if err := insertTariffRates(ctx, tx, tariff); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// insertTariffRates stores the rates of a tariff, filling in their IDs
+func insertTariffRates(ctx context.Context, tx *sql.Tx, tariff *models.Tariff) error {
+	for i := range tariff.Rates {
+		r := &tariff.Rates[i]
+		if err := tx.QueryRowContext(ctx, `
+			INSERT INTO tariff_rates (tariff_id, unit_rate, standing_charge, effective_from, effective_to)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id`,
+			tariff.ID, r.UnitRate, r.StandingCharge, r.EffectiveFrom, r.EffectiveTo,
+		).Scan(&r.ID); err != nil {
+			return fmt.Errorf("failed to store tariff rate: %w", err)
+		}
+	}
+	return nil
+}
+
+// DeleteTariff removes a tariff and its rates
+func (s *Storage) DeleteTariff(ctx context.Context, id int64) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM tariffs WHERE id = $1", id)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+			return ErrTariffInUse
+		}
+		return fmt.Errorf("failed to delete tariff: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetTariff retrieves a tariff with its rates ordered by effective date
+func (s *Storage) GetTariff(ctx context.Context, id int64) (*models.Tariff, error) {
+	tariffs, err := s.queryTariffs(ctx, "WHERE t.id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(tariffs) == 0 {
+		return nil, ErrNotFound
+	}
+	return &tariffs[0], nil
+}
+
+// GetTariffs retrieves every tariff with its rates, ordered by name
+func (s *Storage) GetTariffs(ctx context.Context) ([]models.Tariff, error) {
+	return s.queryTariffs(ctx, "")
+}
+
+// queryTariffs selects tariffs matching the where clause together with their rates
+func (s *Storage) queryTariffs(ctx context.Context, where string, args ...any) ([]models.Tariff, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT t.id, t.name, t.currency, t.created_at, t.updated_at,
+			r.id, r.unit_rate::text, r.standing_charge::text, r.effective_from, r.effective_to
+		FROM tariffs t
+		LEFT JOIN tariff_rates r ON r.tariff_id = t.id
+		`+where+`
+		ORDER BY t.name, t.id, r.effective_from`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tariffs: %w", err)
+	}
+	defer rows.Close()
+
+	var tariffs []models.Tariff
+	for rows.Next() {
+		var (
+			tariff         models.Tariff
+			rateID         sql.NullInt64
+			unitRate       sql.NullString
+			standingCharge sql.NullString
+			effectiveFrom  sql.NullTime
+			effectiveTo    sql.NullTime
+		)
+		if err := rows.Scan(
+			&tariff.ID, &tariff.Name, &tariff.Currency, &tariff.CreatedAt, &tariff.UpdatedAt,
+			&rateID, &unitRate, &standingCharge, &effectiveFrom, &effectiveTo,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan tariff: %w", err)
+		}
+
+		if n := len(tariffs); n == 0 || tariffs[n-1].ID != tariff.ID {
+			tariff.Rates = []models.TariffRate{}
+			tariffs = append(tariffs, tariff)
+		}
+		if !rateID.Valid {
+			continue
+		}
+		rate := models.TariffRate{
+			ID:             rateID.Int64,
+			UnitRate:       unitRate.String,
+			StandingCharge: standingCharge.String,
+			EffectiveFrom:  effectiveFrom.Time.Format(models.TariffDateLayout),
+		}
+		if effectiveTo.Valid {
+			to := effectiveTo.Time.Format(models.TariffDateLayout)
+			rate.EffectiveTo = &to
+		}
+		last := &tariffs[len(tariffs)-1]
+		last.Rates = append(last.Rates, rate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tariffs: %w", err)
+	}
+	return tariffs, nil
+}
+
+// tariffWriteError maps constraint violations on tariffs to storage errors
+func tariffWriteError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return ErrDuplicate
+	}
+	return fmt.Errorf("failed to write tariff: %w", err)
+}
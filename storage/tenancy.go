@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNoTenant is returned when storage is opened without a tenant scope
+var ErrNoTenant = errors.New("no tenant scope given")
+
+// Scope selects the organizations a storage instance can see. Row-level security policies enforce it in the
+// database, so every query is confined to the scope without having to filter on the tenant.
+type Scope struct {
+	tenantID   int64
+	allTenants bool
+}
+
+// TenantScope confines storage to the data of one organization. Rows it creates belong to that organization.
+func TenantScope(tenantID int64) Scope {
+	return Scope{tenantID: tenantID}
+}
+
+// AllTenants gives storage access to the data of every organization, for background jobs, migrations and
+// the administration of organizations and the audit log. Rows it creates must name their tenant.
+var AllTenants = Scope{allTenants: true}
+
+// options returns the connection parameters the row-level security policies read the scope from
+func (s Scope) options() (string, error) {
+	switch {
+	case s.allTenants:
+		return "-c app.all_tenants=on", nil
+	case s.tenantID > 0:
+		return fmt.Sprintf("-c app.tenant_id=%d", s.tenantID), nil
+	default:
+		return "", ErrNoTenant
+	}
+}
+
+// CheckRowLevelSecurity fails if the database user bypasses row-level security, as superusers and roles with
+// BYPASSRLS do, which would let every tenant see the data of the others
+func (s *Storage) CheckRowLevelSecurity(ctx context.Context) error {
+	var user string
+	var bypass bool
+	err := s.db.QueryRowContext(ctx,
+		"SELECT rolname, rolsuper OR rolbypassrls FROM pg_roles WHERE rolname = current_user").Scan(&user, &bypass)
+	if err != nil {
+		return fmt.Errorf("failed to check database role: %w", err)
+	}
+	if bypass {
+		return fmt.Errorf("database user %q is a superuser or bypasses row-level security", user)
+	}
+	return nil
+}
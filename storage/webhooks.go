@@ -0,0 +1,381 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"product-tracker/models"
+	"product-tracker/outbox"
+	"product-tracker/webhooks"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// webhookColumns selects a webhook row without its secret
+const webhookColumns = "id, url, events, active, description, created_at, updated_at"
+
+// deliveryColumns selects a webhook delivery row
+const deliveryColumns = "id, webhook_id, event_id, event, payload, state, attempts, next_attempt_at, last_status, last_error, " +
+	"created_at, delivered_at"
+
+// MaxDeliveryLimit caps the number of deliveries returned by GetWebhookDeliveries
+const MaxDeliveryLimit = 1000
+
+// defaultDeliveryLimit is the number of deliveries returned when no limit is given
+const defaultDeliveryLimit = 100
+
+// CreateWebhook stores a new webhook, generating its secret if it has none
+func (s *Storage) CreateWebhook(ctx context.Context, w *models.Webhook) error {
+	if err := webhooks.ValidateSubscription(w.URL, w.Events); err != nil {
+		return err
+	}
+	if w.Secret == "" {
+		secret, err := webhooks.NewSecret()
+		if err != nil {
+			return err
+		}
+		w.Secret = secret
+	}
+	if err := webhooks.ValidateSecret(w.Secret); err != nil {
+		return err
+	}
+
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO webhooks (url, secret, events, active, description)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at`,
+		w.URL, w.Secret, pq.Array(w.Events), w.Active, w.Description,
+	).Scan(&w.ID, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert webhook: %w", err)
+	}
+	return nil
+}
+
+// UpdateWebhook replaces a webhook. Its secret is only changed if w carries a new one.
+func (s *Storage) UpdateWebhook(ctx context.Context, w *models.Webhook) error {
+	if err := webhooks.ValidateSubscription(w.URL, w.Events); err != nil {
+		return err
+	}
+	if w.Secret != "" {
+		if err := webhooks.ValidateSecret(w.Secret); err != nil {
+			return err
+		}
+	}
+
+	err := s.db.QueryRowContext(ctx, `
+		UPDATE webhooks
+		SET url = $2, secret = COALESCE(NULLIF($3, ''), secret), events = $4, active = $5, description = $6, updated_at = NOW()
+		WHERE id = $1
+		RETURNING created_at, updated_at`,
+		w.ID, w.URL, w.Secret, pq.Array(w.Events), w.Active, w.Description,
+	).Scan(&w.CreatedAt, &w.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update webhook: %w", err)
+	}
+	w.Secret = ""
+	return nil
+}
+
+// DeleteWebhook removes a webhook with its deliveries
+func (s *Storage) DeleteWebhook(ctx context.Context, id int64) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM webhooks WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetWebhook retrieves a single webhook without its secret
+func (s *Storage) GetWebhook(ctx context.Context, id int64) (*models.Webhook, error) {
+	return scanWebhook(s.db.QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE id = $1", id))
+}
+
+// GetWebhooks retrieves every webhook without their secrets
+func (s *Storage) GetWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+webhookColumns+" FROM webhooks ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	hooks := []models.Webhook{}
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, *w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhooks: %w", err)
+	}
+	return hooks, nil
+}
+
+// scanWebhook scans a row selected with webhookColumns
+func scanWebhook(row rowScanner) (*models.Webhook, error) {
+	var w models.Webhook
+	var events pq.StringArray
+	err := row.Scan(&w.ID, &w.URL, &events, &w.Active, &w.Description, &w.CreatedAt, &w.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan webhook: %w", err)
+	}
+	w.Events = events
+	return &w, nil
+}
+
+// webhookSink is the outbox sink queuing a delivery of each event for every active webhook of its tenant
+// subscribed to it.
+// The event is queued at most once per webhook, however often it is published.
+type webhookSink struct {
+	s *Storage
+}
+
+// Name returns "webhooks"
+func (webhookSink) Name() string {
+	return "webhooks"
+}
+
+// Publish queues the deliveries of an event
+func (w webhookSink) Publish(ctx context.Context, event outbox.Event) error {
+	if !webhooks.ValidEvent(event.Type) {
+		return nil
+	}
+	eventID := strconv.FormatInt(event.ID, 10)
+	payload, err := json.Marshal(models.WebhookEvent{ID: eventID, Type: event.Type, CreatedAt: event.CreatedAt.UTC(), Data: event.Payload})
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	_, err = w.s.db.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload)
+		SELECT id, $1, $2, $3 FROM webhooks WHERE active AND $2 = ANY(events) AND tenant_id = $4
+		ON CONFLICT (webhook_id, event_id) DO NOTHING`,
+		eventID, event.Type, string(payload), event.TenantID)
+	if err != nil {
+		return fmt.Errorf("failed to queue deliveries: %w", err)
+	}
+	return nil
+}
+
+// ClaimWebhookDeliveries leases up to limit due deliveries of active webhooks for lease, so that other
+// instances skip them. A delivery whose sender dies before recording the attempt is retried once the lease ends.
+func (s *Storage) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]webhooks.Message, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		WITH due AS (
+			SELECT d.id
+			FROM webhook_deliveries d
+			JOIN webhooks w ON w.id = d.webhook_id
+			WHERE d.state = 'pending' AND d.next_attempt_at <= NOW() AND w.active
+			ORDER BY d.next_attempt_at, d.id
+			LIMIT $1
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = NOW() + make_interval(secs => $2)
+		FROM due, webhooks w
+		WHERE d.id = due.id AND w.id = d.webhook_id
+		RETURNING d.id, d.event, d.payload, d.attempts, w.url, w.secret`,
+		limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []webhooks.Message
+	for rows.Next() {
+		var m webhooks.Message
+		if err := rows.Scan(&m.DeliveryID, &m.Event, &m.Payload, &m.Attempts, &m.URL, &m.Secret); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook deliveries: %w", err)
+	}
+	return messages, nil
+}
+
+// RecordWebhookAttempt logs an attempt and moves its delivery on: to succeeded if the receiver accepted it,
+// back to pending until retryAt, or to dead when retryAt is nil
+func (s *Storage) RecordWebhookAttempt(ctx context.Context, deliveryID int64, attempt webhooks.Attempt, retryAt *time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO webhook_attempts (delivery_id, status_code, response, error, duration_ms)
+		VALUES ($1, $2, $3, $4, $5)`,
+		deliveryID, attempt.StatusCode, attempt.Response, attempt.Err, attempt.Duration.Milliseconds()); err != nil {
+		return fmt.Errorf("failed to log webhook attempt: %w", err)
+	}
+
+	state, lastError := webhooks.StatePending, sql.NullString{String: attempt.Err, Valid: attempt.Err != ""}
+	switch {
+	case attempt.Succeeded():
+		state = webhooks.StateSucceeded
+	case retryAt == nil:
+		state = webhooks.StateDead
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET state = $2, attempts = attempts + 1, last_status = $3, last_error = $4,
+			next_attempt_at = COALESCE($5, next_attempt_at),
+			delivered_at = CASE WHEN $2 = 'succeeded' THEN NOW() END
+		WHERE id = $1`,
+		deliveryID, state, attempt.StatusCode, lastError, retryAt); err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// DeliveryFilter narrows down a listing of webhook deliveries
+type DeliveryFilter struct {
+	// State keeps only deliveries in this state when set
+	State string
+	// Limit caps the number of deliveries, defaulting to 100
+	Limit int
+}
+
+// GetWebhookDeliveries retrieves the deliveries of a webhook, newest first
+func (s *Storage) GetWebhookDeliveries(ctx context.Context, webhookID int64, filter DeliveryFilter) ([]models.WebhookDelivery, error) {
+	if _, err := s.GetWebhook(ctx, webhookID); err != nil {
+		return nil, err
+	}
+
+	conditions := []string{"webhook_id = $1"}
+	args := []interface{}{webhookID}
+	if filter.State != "" {
+		args = append(args, filter.State)
+		conditions = append(conditions, fmt.Sprintf("state = $%d", len(args)))
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultDeliveryLimit
+	}
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT %s FROM webhook_deliveries
+		%s
+		ORDER BY id DESC
+		LIMIT $%d`, deliveryColumns, whereClause(conditions), len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// GetWebhookDelivery retrieves a delivery of a webhook with its attempt log
+func (s *Storage) GetWebhookDelivery(ctx context.Context, webhookID, deliveryID int64) (*models.WebhookDelivery, error) {
+	d, err := scanDelivery(s.db.QueryRowContext(ctx,
+		"SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE id = $1 AND webhook_id = $2", deliveryID, webhookID))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, status_code, response, error, duration_ms, attempted_at
+		FROM webhook_attempts
+		WHERE delivery_id = $1
+		ORDER BY id`, deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook attempts: %w", err)
+	}
+	defer rows.Close()
+
+	d.AttemptLog = []models.WebhookAttempt{}
+	for rows.Next() {
+		var a models.WebhookAttempt
+		var statusCode sql.NullInt64
+		if err := rows.Scan(&a.ID, &statusCode, &a.Response, &a.Error, &a.DurationMs, &a.AttemptedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook attempt: %w", err)
+		}
+		if statusCode.Valid {
+			code := int(statusCode.Int64)
+			a.StatusCode = &code
+		}
+		d.AttemptLog = append(d.AttemptLog, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook attempts: %w", err)
+	}
+	return d, nil
+}
+
+// RedeliverWebhookDelivery queues a delivery again right away with a fresh set of attempts, whatever its state.
+// The original payload and event ID are sent, so receivers can recognise events they already processed.
+func (s *Storage) RedeliverWebhookDelivery(ctx context.Context, webhookID, deliveryID int64) (*models.WebhookDelivery, error) {
+	return scanDelivery(s.db.QueryRowContext(ctx, `
+		UPDATE webhook_deliveries
+		SET state = 'pending', attempts = 0, next_attempt_at = NOW(), delivered_at = NULL
+		WHERE id = $1 AND webhook_id = $2
+		RETURNING `+deliveryColumns, deliveryID, webhookID))
+}
+
+// scanDelivery scans a row selected with deliveryColumns
+func scanDelivery(row rowScanner) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var payload []byte
+	var nextAttemptAt time.Time
+	var lastStatus sql.NullInt64
+	var lastError sql.NullString
+	err := row.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.Event, &payload, &d.State, &d.Attempts, &nextAttemptAt,
+		&lastStatus, &lastError, &d.CreatedAt, &d.DeliveredAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+	}
+	d.Payload = payload
+	if d.State == webhooks.StatePending {
+		d.NextAttemptAt = &nextAttemptAt
+	}
+	if lastStatus.Valid {
+		status := int(lastStatus.Int64)
+		d.LastStatus = &status
+	}
+	if lastError.Valid {
+		d.LastError = &lastError.String
+	}
+	return &d, nil
+}
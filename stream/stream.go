@@ -0,0 +1,206 @@
+package stream
+
+import (
+	"sync"
+)
+
+// EventResync tells a client that it may have missed events and should reload what it displays
+const EventResync = "resync"
+
+// Item is one product or reading carried by an event, with what filters match it against
+type Item struct {
+	ProductID  *int64
+	CategoryID *int64
+	Data       interface{}
+}
+
+// Event is a change pushed to stream clients. Its ID is that of the outbox event it comes from, so clients can
+// resume from it on any server instance.
+type Event struct {
+	ID   string
+	Type string
+	// Batch events carry a list of items, sent as an array; other events carry a single item
+	Batch bool
+	Items []Item
+	// TenantID is the organization the event belongs to
+	TenantID int64
+}
+
+// Filter keeps the items of given products or categories. The zero Filter keeps everything.
+type Filter struct {
+	// TenantID keeps only the events of one organization when set
+	TenantID    int64
+	ProductIDs  map[int64]bool
+	CategoryIDs map[int64]bool
+}
+
+// match reports whether the filter keeps item
+func (f Filter) match(item Item) bool {
+	if len(f.ProductIDs) > 0 && (item.ProductID == nil || !f.ProductIDs[*item.ProductID]) {
+		return false
+	}
+	if len(f.CategoryIDs) > 0 && (item.CategoryID == nil || !f.CategoryIDs[*item.CategoryID]) {
+		return false
+	}
+	return true
+}
+
+// Apply returns the data of an event to send to a client with this filter, and false if nothing in it matches
+func (f Filter) Apply(e Event) (interface{}, bool) {
+	if e.Type == EventResync {
+		return struct{}{}, true
+	}
+	if f.TenantID != 0 && e.TenantID != f.TenantID {
+		return nil, false
+	}
+	if !e.Batch {
+		if len(e.Items) == 0 || !f.match(e.Items[0]) {
+			return nil, false
+		}
+		return e.Items[0].Data, true
+	}
+	var kept []interface{}
+	for _, item := range e.Items {
+		if f.match(item) {
+			kept = append(kept, item.Data)
+		}
+	}
+	return kept, len(kept) > 0
+}
+
+// Client receives the events published while it is subscribed
+type Client struct {
+	events chan Event
+	done   chan struct{}
+}
+
+// Events delivers published events in order
+func (c *Client) Events() <-chan Event {
+	return c.events
+}
+
+// Done is closed when the client fell too far behind and was dropped. It should reconnect with the ID of the
+// last event it received to catch up from the replay buffer.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Hub fans events out to clients and keeps the latest ones for clients resuming after a disconnection.
+// Publishing never blocks: a client whose buffer is full is dropped rather than slowing everyone down.
+type Hub struct {
+	mu           sync.Mutex
+	replay       []Event
+	next         int
+	full         bool
+	seen         map[string]bool
+	clients      map[*Client]struct{}
+	clientBuffer int
+}
+
+// NewHub returns a hub replaying up to replaySize events and buffering up to clientBuffer events per client
+func NewHub(replaySize, clientBuffer int) *Hub {
+	return &Hub{
+		replay:       make([]Event, replaySize),
+		seen:         make(map[string]bool, replaySize),
+		clients:      make(map[*Client]struct{}),
+		clientBuffer: clientBuffer,
+	}
+}
+
+// Publish sends an event to every client and adds it to the replay buffer. Events already in the buffer are
+// ignored, as outbox events may be published more than once.
+func (h *Hub) Publish(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.seen[e.ID] {
+		return
+	}
+	if h.full {
+		delete(h.seen, h.replay[h.next].ID)
+	}
+	h.replay[h.next] = e
+	h.seen[e.ID] = true
+	h.next = (h.next + 1) % len(h.replay)
+	h.full = h.full || h.next == 0
+
+	h.broadcast(e)
+}
+
+// Resync tells every client that events may have been missed, for instance while the hub was cut off from
+// the database
+func (h *Hub) Resync() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.broadcast(Event{Type: EventResync})
+}
+
+// broadcast sends e to every client, dropping those that cannot keep up. h.mu must be held.
+func (h *Hub) broadcast(e Event) {
+	for c := range h.clients {
+		select {
+		case c.events <- e:
+		default:
+			delete(h.clients, c)
+			close(c.done)
+		}
+	}
+}
+
+// Subscribe registers a client. If lastEventID is set, the buffered events published after it are returned to
+// be sent first; complete is false when lastEventID is no longer buffered, so that events may have been missed.
+func (h *Hub) Subscribe(lastEventID string) (client *Client, backlog []Event, complete bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	client = &Client{events: make(chan Event, h.clientBuffer), done: make(chan struct{})}
+	h.clients[client] = struct{}{}
+
+	if lastEventID == "" {
+		return client, nil, true
+	}
+	buffered := h.buffered()
+	for i, e := range buffered {
+		if e.ID == lastEventID {
+			return client, append([]Event(nil), buffered[i+1:]...), true
+		}
+	}
+	return client, nil, false
+}
+
+// Unsubscribe removes a client
+func (h *Hub) Unsubscribe(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[client]; ok {
+		delete(h.clients, client)
+		close(client.done)
+	}
+}
+
+// buffered returns the replay buffer oldest first. h.mu must be held.
+func (h *Hub) buffered() []Event {
+	if !h.full {
+		return h.replay[:h.next]
+	}
+	return append(append([]Event(nil), h.replay[h.next:]...), h.replay[:h.next]...)
+}
+
+var (
+	defaultMu  sync.RWMutex
+	defaultHub *Hub
+)
+
+// SetDefault sets the hub served by the stream endpoint
+func SetDefault(h *Hub) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultHub = h
+}
+
+// Default returns the hub served by the stream endpoint, or nil if streaming is not running
+func Default() *Hub {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultHub
+}
@@ -0,0 +1,190 @@
+package units
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Custom errors for unit parsing and conversion
+var (
+	ErrUnknownUnit       = errors.New("unknown unit")
+	ErrIncompatibleUnits = errors.New("incompatible units")
+)
+
+// Dimension identifies what a unit measures. Only units of the same dimension
+// convert directly; crossing dimensions needs extra information such as a duty cycle.
+type Dimension string
+
+const (
+	// Energy is an amount of energy, based on kWh
+	Energy Dimension = "energy"
+	// Power is a rate of energy use, based on kW
+	Power Dimension = "power"
+	// EnergyRate is the energy used over a calendar period, based on kWh/year
+	EnergyRate Dimension = "energy_rate"
+	// EnergyPerCycle is the energy used by one operating cycle, based on kWh/cycle
+	EnergyPerCycle Dimension = "energy_per_cycle"
+)
+
+// Canonical units used for storage
+const (
+	// ProductCanonical is the unit of products.energy_consumption
+	ProductCanonical = "kWh/year"
+	// ReadingCanonical is the unit of product_tracker.energy_consumed
+	ReadingCanonical = "kWh"
+)
+
+// hoursPerYear is the length of a non-leap year, used to annualize power figures
+const hoursPerYear = 8760
+
+// Unit is a supported unit of measure
+type Unit struct {
+	Symbol    string
+	Dimension Dimension
+	// factor converts a value in this unit to the base unit of its dimension
+	factor float64
+}
+
+var supported = []Unit{
+	{Symbol: "Wh", Dimension: Energy, factor: 0.001},
+	{Symbol: "kWh", Dimension: Energy, factor: 1},
+	{Symbol: "MWh", Dimension: Energy, factor: 1000},
+	{Symbol: "MJ", Dimension: Energy, factor: 1 / 3.6},
+	{Symbol: "GJ", Dimension: Energy, factor: 1000 / 3.6},
+	{Symbol: "W", Dimension: Power, factor: 0.001},
+	{Symbol: "kW", Dimension: Power, factor: 1},
+	{Symbol: "Wh/day", Dimension: EnergyRate, factor: 0.001 * 365},
+	{Symbol: "kWh/day", Dimension: EnergyRate, factor: 365},
+	{Symbol: "kWh/month", Dimension: EnergyRate, factor: 12},
+	{Symbol: "kWh/year", Dimension: EnergyRate, factor: 1},
+	{Symbol: "MWh/year", Dimension: EnergyRate, factor: 1000},
+	{Symbol: "Wh/cycle", Dimension: EnergyPerCycle, factor: 0.001},
+	{Symbol: "kWh/cycle", Dimension: EnergyPerCycle, factor: 1},
+}
+
+var bySymbol = func() map[string]Unit {
+	m := make(map[string]Unit, len(supported))
+	for _, u := range supported {
+		m[strings.ToLower(u.Symbol)] = u
+	}
+	return m
+}()
+
+// Parse looks up a unit by symbol, ignoring case and surrounding whitespace
+func Parse(symbol string) (Unit, error) {
+	u, ok := bySymbol[strings.ToLower(strings.TrimSpace(symbol))]
+	if !ok {
+		return Unit{}, fmt.Errorf("%w %q: supported units are %s", ErrUnknownUnit, symbol, strings.Join(Symbols(), ", "))
+	}
+	return u, nil
+}
+
+// Symbols returns the symbols of every supported unit in sorted order
+func Symbols() []string {
+	symbols := make([]string, 0, len(supported))
+	for _, u := range supported {
+		symbols = append(symbols, u.Symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// Options carries the information needed to convert between dimensions
+type Options struct {
+	// DutyCycle is the fraction of the time a device draws its rated power (0-1].
+	// It relates Power to EnergyRate.
+	DutyCycle *float64 `json:"duty_cycle,omitempty"`
+	// CyclesPerYear is the number of operating cycles per year.
+	// It relates EnergyPerCycle to EnergyRate.
+	CyclesPerYear *float64 `json:"cycles_per_year,omitempty"`
+}
+
+// Validate checks that the conversion options are in range
+func (o Options) Validate() error {
+	if o.DutyCycle != nil && (math.IsNaN(*o.DutyCycle) || *o.DutyCycle <= 0 || *o.DutyCycle > 1) {
+		return errors.New("duty_cycle must be greater than 0 and at most 1")
+	}
+	if o.CyclesPerYear != nil && (math.IsNaN(*o.CyclesPerYear) || math.IsInf(*o.CyclesPerYear, 0) || *o.CyclesPerYear <= 0) {
+		return errors.New("cycles_per_year must be a finite number greater than 0")
+	}
+	return nil
+}
+
+// Convert converts value from one unit to another
+func Convert(value float64, from, to Unit, opts Options) (float64, error) {
+	if err := opts.Validate(); err != nil {
+		return 0, err
+	}
+
+	base := value * from.factor
+	if from.Dimension != to.Dimension && (from.Dimension == Energy || to.Dimension == Energy) {
+		return 0, fmt.Errorf("%w: cannot convert %s (%s) to %s (%s) without a time period",
+			ErrIncompatibleUnits, from.Symbol, from.Dimension, to.Symbol, to.Dimension)
+	}
+	if from.Dimension != to.Dimension {
+		annual, err := toEnergyRate(base, from, opts)
+		if err != nil {
+			return 0, fmt.Errorf("cannot convert %s to %s: %w", from.Symbol, to.Symbol, err)
+		}
+		if base, err = fromEnergyRate(annual, to, opts); err != nil {
+			return 0, fmt.Errorf("cannot convert %s to %s: %w", from.Symbol, to.Symbol, err)
+		}
+	}
+	return base / to.factor, nil
+}
+
+// ConvertSymbols converts value between two unit symbols
+func ConvertSymbols(value float64, from, to string, opts Options) (float64, error) {
+	fromUnit, err := Parse(from)
+	if err != nil {
+		return 0, err
+	}
+	toUnit, err := Parse(to)
+	if err != nil {
+		return 0, err
+	}
+	return Convert(value, fromUnit, toUnit, opts)
+}
+
+// toEnergyRate converts a value in the base unit of u's dimension to kWh/year
+func toEnergyRate(base float64, u Unit, opts Options) (float64, error) {
+	switch u.Dimension {
+	case EnergyRate:
+		return base, nil
+	case Power:
+		if opts.DutyCycle == nil {
+			return 0, fmt.Errorf("%w: power and annual energy need a duty_cycle", ErrIncompatibleUnits)
+		}
+		return base * *opts.DutyCycle * hoursPerYear, nil
+	case EnergyPerCycle:
+		if opts.CyclesPerYear == nil {
+			return 0, fmt.Errorf("%w: energy per cycle and annual energy need cycles_per_year", ErrIncompatibleUnits)
+		}
+		return base * *opts.CyclesPerYear, nil
+	default:
+		return 0, fmt.Errorf("%w: an amount of %s has no time period", ErrIncompatibleUnits, u.Dimension)
+	}
+}
+
+// fromEnergyRate converts a value in kWh/year to the base unit of u's dimension
+func fromEnergyRate(annual float64, u Unit, opts Options) (float64, error) {
+	switch u.Dimension {
+	case EnergyRate:
+		return annual, nil
+	case Power:
+		if opts.DutyCycle == nil {
+			return 0, fmt.Errorf("%w: power and annual energy need a duty_cycle", ErrIncompatibleUnits)
+		}
+		return annual / (*opts.DutyCycle * hoursPerYear), nil
+	case EnergyPerCycle:
+		if opts.CyclesPerYear == nil {
+			return 0, fmt.Errorf("%w: energy per cycle and annual energy need cycles_per_year", ErrIncompatibleUnits)
+		}
+		return annual / *opts.CyclesPerYear, nil
+	default:
+		return 0, fmt.Errorf("%w: an amount of %s has no time period", ErrIncompatibleUnits, u.Dimension)
+	}
+}
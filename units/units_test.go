@@ -0,0 +1,100 @@
+package units
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestConvertSymbols(t *testing.T) {
+	half, full := 0.5, 1.0
+	daily := 365.0
+	tests := []struct {
+		name     string
+		value    float64
+		from, to string
+		opts     Options
+		want     float64
+		err      error
+	}{
+		{"canonical", 438, "kWh/year", ProductCanonical, Options{}, 438, nil},
+		{"per day", 1.2, "kWh/day", ProductCanonical, Options{}, 438, nil},
+		{"per month", 36.5, "kWh/month", ProductCanonical, Options{}, 438, nil},
+		{"Wh per day", 1200, "Wh/day", ProductCanonical, Options{}, 438, nil},
+		{"MWh per year", 0.438, "MWh/year", ProductCanonical, Options{}, 438, nil},
+		{"case and spaces", 438, " KWH/YEAR ", ProductCanonical, Options{}, 438, nil},
+		{"watts always on", 50, "W", ProductCanonical, Options{DutyCycle: &full}, 438, nil},
+		{"watts half the time", 100, "W", ProductCanonical, Options{DutyCycle: &half}, 438, nil},
+		{"kilowatts", 0.1, "kW", ProductCanonical, Options{DutyCycle: &half}, 438, nil},
+		{"per cycle", 1.2, "kWh/cycle", ProductCanonical, Options{CyclesPerYear: &daily}, 438, nil},
+		{"Wh per cycle", 1200, "Wh/cycle", ProductCanonical, Options{CyclesPerYear: &daily}, 438, nil},
+		{"annual to watts", 438, ProductCanonical, "W", Options{DutyCycle: &half}, 100, nil},
+		{"annual to cycles", 438, ProductCanonical, "kWh/cycle", Options{CyclesPerYear: &daily}, 1.2, nil},
+		{"watts to cycles", 100, "W", "Wh/cycle", Options{DutyCycle: &half, CyclesPerYear: &daily}, 1200, nil},
+		{"Wh reading", 1500, "Wh", ReadingCanonical, Options{}, 1.5, nil},
+		{"MJ reading", 3.6, "MJ", ReadingCanonical, Options{}, 1, nil},
+		{"GJ reading", 0.0036, "GJ", ReadingCanonical, Options{}, 1, nil},
+		{"watts without duty cycle", 50, "W", ProductCanonical, Options{}, 0, ErrIncompatibleUnits},
+		{"cycles without cycles per year", 1.2, "kWh/cycle", ProductCanonical, Options{}, 0, ErrIncompatibleUnits},
+		{"duty cycle does not replace cycles per year", 1.2, "kWh/cycle", ProductCanonical, Options{DutyCycle: &half}, 0, ErrIncompatibleUnits},
+		{"energy to annual energy", 438, "kWh", ProductCanonical, Options{}, 0, ErrIncompatibleUnits},
+		{"power to energy", 50, "W", ReadingCanonical, Options{DutyCycle: &full}, 0, ErrIncompatibleUnits},
+		{"unknown source", 1, "BTU", ProductCanonical, Options{}, 0, ErrUnknownUnit},
+		{"unknown target", 1, "kWh", "therm", Options{}, 0, ErrUnknownUnit},
+	}
+	for _, tt := range tests {
+		got, err := ConvertSymbols(tt.value, tt.from, tt.to, tt.opts)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: ConvertSymbols(%v, %q, %q) returned %v, want %v", tt.name, tt.value, tt.from, tt.to, err, tt.err)
+			continue
+		}
+		if err == nil && math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: ConvertSymbols(%v, %q, %q) = %v, want %v", tt.name, tt.value, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	value := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name  string
+		opts  Options
+		valid bool
+	}{
+		{"none", Options{}, true},
+		{"full duty cycle", Options{DutyCycle: value(1)}, true},
+		{"small duty cycle", Options{DutyCycle: value(0.01)}, true},
+		{"zero duty cycle", Options{DutyCycle: value(0)}, false},
+		{"negative duty cycle", Options{DutyCycle: value(-0.5)}, false},
+		{"duty cycle above 1", Options{DutyCycle: value(1.5)}, false},
+		{"NaN duty cycle", Options{DutyCycle: value(math.NaN())}, false},
+		{"cycles per year", Options{CyclesPerYear: value(220)}, true},
+		{"zero cycles per year", Options{CyclesPerYear: value(0)}, false},
+		{"negative cycles per year", Options{CyclesPerYear: value(-1)}, false},
+		{"NaN cycles per year", Options{CyclesPerYear: value(math.NaN())}, false},
+		{"infinite cycles per year", Options{CyclesPerYear: value(math.Inf(1))}, false},
+	}
+	for _, tt := range tests {
+		err := tt.opts.Validate()
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: Validate returned %v, want valid %v", tt.name, err, tt.valid)
+		}
+		// Conversions check the options even when they do not need them
+		if _, err := ConvertSymbols(1, "kWh/year", "kWh/day", tt.opts); (err == nil) != tt.valid {
+			t.Errorf("%s: ConvertSymbols returned %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	u, err := Parse("kwh/Cycle")
+	if err != nil || u.Symbol != "kWh/cycle" || u.Dimension != EnergyPerCycle {
+		t.Errorf("Parse(%q) = %+v, %v, want kWh/cycle", "kwh/Cycle", u, err)
+	}
+	if _, err := Parse(""); !errors.Is(err, ErrUnknownUnit) {
+		t.Errorf("Parse of an empty symbol returned %v, want %v", err, ErrUnknownUnit)
+	}
+	if len(Symbols()) != len(supported) {
+		t.Errorf("Symbols() = %v, want %d symbols", Symbols(), len(supported))
+	}
+}
@@ -20,6 +20,8 @@ var (
 	ErrJWTSecretNotFound    = errors.New("JWT secret not found in config")
 	ErrInvalidTokenFormat   = errors.New("invalid token format")
 	ErrTokenNotBefore       = errors.New("token not yet valid")
+	ErrTenantNotFound       = errors.New("tenant_id not found in token claims")
+	ErrCrossTenant          = errors.New("access to other organizations requires the superadmin role")
 )
 
 // Claim keys for better maintainability
@@ -29,14 +31,48 @@ const (
 	ClaimIAT    = "iat"
 	ClaimNBF    = "nbf"
 	ClaimJTI    = "jti"
+	ClaimRole   = "role"
+	// ClaimTenantID names the organization the user belongs to
+	ClaimTenantID = "tenant_id"
 )
 
+// Roles carried in the role claim
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+	// RoleSuperAdmin is an admin of every organization, who may access other organizations than their own
+	RoleSuperAdmin = "superadmin"
+)
+
+// HasRole reports whether a token with role satisfies a route requiring required. Superadmins satisfy every role.
+func HasRole(role, required string) bool {
+	return role == required || role == RoleSuperAdmin
+}
+
+// ResolveTenant returns the organization a request with claims acts on: requested when it is set, and the
+// organization of the token otherwise. Only superadmins may act on other organizations than their own; crossTenant
+// reports whether they do.
+func ResolveTenant(claims *TokenClaims, requested int64) (tenantID int64, crossTenant bool, err error) {
+	if claims.TenantID <= 0 {
+		return 0, false, ErrTenantNotFound
+	}
+	if requested <= 0 || requested == claims.TenantID {
+		return claims.TenantID, false, nil
+	}
+	if claims.Role != RoleSuperAdmin {
+		return 0, false, ErrCrossTenant
+	}
+	return requested, true, nil
+}
+
 // TokenOptions contains options for token generation
 type TokenOptions struct {
 	ExpirationTime time.Duration
 	NotBefore      time.Duration
 	Issuer         string
 	Audience       string
+	Role           string
+	TenantID       int64
 }
 
 // DefaultTokenOptions returns default token options
@@ -52,6 +88,8 @@ func DefaultTokenOptions() TokenOptions {
 // TokenClaims represents the custom claims structure
 type TokenClaims struct {
 	UserID   uint   `json:"user_id"`
+	TenantID int64  `json:"tenant_id"`
+	Role     string `json:"role,omitempty"`
 	IAT      int64  `json:"iat"`
 	Exp      int64  `json:"exp"`
 	NBF      int64  `json:"nbf,omitempty"`
@@ -151,6 +189,8 @@ func GenerateToken(userID uint, opts TokenOptions) (string, error) {
 	now := time.Now()
 	claims := &TokenClaims{
 		UserID:   userID,
+		TenantID: opts.TenantID,
+		Role:     opts.Role,
 		IAT:      now.Unix(),
 		Exp:      now.Add(opts.ExpirationTime).Unix(),
 		Issuer:   opts.Issuer,
@@ -172,6 +212,12 @@ func RefreshToken(oldToken string, opts TokenOptions) (string, error) {
 		return "", err
 	}
 
+	if opts.Role == "" {
+		opts.Role = claims.Role
+	}
+	if opts.TenantID == 0 {
+		opts.TenantID = claims.TenantID
+	}
 	return GenerateToken(claims.UserID, opts)
 }
 
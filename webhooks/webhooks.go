@@ -0,0 +1,270 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrInvalidWebhook is returned for subscriptions with an unusable URL, secret or event list
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+// ErrBlockedAddress is returned when a delivery would connect to an address that is not public
+var ErrBlockedAddress = errors.New("webhook address is not public")
+
+// Event types
+const (
+	EventProductCreated   = "product.created"
+	EventProductUpdated   = "product.updated"
+	EventProductDeleted   = "product.deleted"
+	EventReadingsIngested = "readings.ingested"
+)
+
+// Events lists the event types subscriptions can choose from
+var Events = []string{EventProductCreated, EventProductUpdated, EventProductDeleted, EventReadingsIngested}
+
+// Delivery states
+const (
+	StatePending   = "pending"
+	StateSucceeded = "succeeded"
+	StateDead      = "dead"
+)
+
+// Request headers of a delivery
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// minSecretLength is the shortest secret accepted, in bytes
+const minSecretLength = 16
+
+// maxResponseBody is how much of a response body is kept in the delivery log, in bytes
+const maxResponseBody = 1024
+
+// ValidateSubscription checks the URL and events of a subscription
+func ValidateSubscription(target string, events []string) error {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+	if len(events) == 0 {
+		return fmt.Errorf("%w: at least one event is required", ErrInvalidWebhook)
+	}
+	seen := make(map[string]bool, len(events))
+	for _, event := range events {
+		if !ValidEvent(event) {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, event)
+		}
+		if seen[event] {
+			return fmt.Errorf("%w: event %q is listed twice", ErrInvalidWebhook, event)
+		}
+		seen[event] = true
+	}
+	return nil
+}
+
+// ValidateSecret checks that a signing secret is long enough to resist guessing
+func ValidateSecret(secret string) error {
+	if len(secret) < minSecretLength {
+		return fmt.Errorf("%w: secret must be at least %d characters", ErrInvalidWebhook, minSecretLength)
+	}
+	return nil
+}
+
+// ValidEvent reports whether event is a known event type
+func ValidEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// NewSecret returns a random signing secret
+func NewSecret() (string, error) {
+	return randomHex(32)
+}
+
+// randomHex returns n random bytes hex-encoded
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Sign returns the signature of a payload sent at timestamp: the hex HMAC-SHA256 of "<timestamp>.<payload>"
+// under secret, prefixed with "sha256=". Receivers should recompute it and reject stale timestamps.
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff returns the delay before the retry following the given failed attempt, starting at 1. The delay doubles
+// with every attempt up to maxDelay, and a random jitter of up to half of it spreads out retries of a burst.
+func Backoff(attempt int, initial, maxDelay time.Duration) time.Duration {
+	delay := initial
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	half := int64(delay / 2)
+	if half <= 0 {
+		return delay
+	}
+	jitter, err := rand.Int(rand.Reader, big.NewInt(half))
+	if err != nil {
+		return delay
+	}
+	return delay - time.Duration(jitter.Int64())
+}
+
+// Attempt is the outcome of one delivery attempt
+type Attempt struct {
+	StatusCode *int
+	// Response is the start of the response body
+	Response string
+	// Err describes why the attempt failed, or is empty when the receiver answered with a 2xx status
+	Err      string
+	Duration time.Duration
+}
+
+// Succeeded reports whether the receiver accepted the delivery
+func (a Attempt) Succeeded() bool {
+	return a.Err == ""
+}
+
+// Message is a delivery ready to be sent
+type Message struct {
+	DeliveryID int64
+	Event      string
+	URL        string
+	Secret     string
+	Payload    []byte
+	// Attempts is the number of earlier attempts
+	Attempts int
+}
+
+// Sender posts signed deliveries
+type Sender struct {
+	Client *http.Client
+}
+
+// NewSender returns a sender whose requests time out after timeout. Unless allowPrivate is set, connections to
+// loopback, private, link-local and other non-public addresses are refused, so that webhooks cannot reach internal
+// services. The check is made on the address being connected to, after DNS resolution, so a host name resolving to
+// such an address is refused too.
+func NewSender(timeout time.Duration, allowPrivate bool) *Sender {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivate {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: refuseNonPublic}
+		transport.DialContext = dialer.DialContext
+		// A proxy would connect to the receiver on our behalf, out of reach of the check
+		transport.Proxy = nil
+	}
+	return &Sender{Client: &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		// Redirects are not followed so that a receiver cannot bounce signed payloads elsewhere
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}}
+}
+
+// nonPublicPrefixes are the ranges beyond those of the netip predicates that must not be reached: "this network",
+// shared address space, IETF protocol assignments, benchmarking, reserved addresses and NAT64, which may map to
+// any IPv4 address
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+}
+
+// PublicAddr reports whether a delivery may connect to addr
+func PublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() || addr.IsMulticast() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// refuseNonPublic is the dialer control refusing connections to addresses that are not public
+func refuseNonPublic(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, address)
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !PublicAddr(addr) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+	}
+	return nil
+}
+
+// Send posts a message to its URL. Any 2xx response counts as success.
+func (s *Sender) Send(ctx context.Context, m Message) Attempt {
+	start := time.Now()
+	timestamp := start.Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.URL, bytes.NewReader(m.Payload))
+	if err != nil {
+		return Attempt{Err: err.Error()}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "product-tracker-webhooks/1.0")
+	req.Header.Set(HeaderEvent, m.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(m.DeliveryID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(m.Secret, timestamp, m.Payload))
+
+	resp, err := s.Client.Do(req)
+	attempt := Attempt{Duration: time.Since(start)}
+	if err != nil {
+		attempt.Err = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	attempt.StatusCode = &resp.StatusCode
+	// The body is stored as text, which cannot hold invalid UTF-8 or NUL bytes
+	attempt.Response = strings.ReplaceAll(strings.ToValidUTF8(string(body), "\uFFFD"), "\x00", "")
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		attempt.Err = fmt.Sprintf("receiver answered %s", resp.Status)
+	}
+	return attempt
+}
@@ -0,0 +1,73 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"100.64.0.1", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"64:ff9b::a9fe:a9fe", false},
+	}
+	for _, tt := range tests {
+		if got := PublicAddr(netip.MustParseAddr(tt.addr)); got != tt.public {
+			t.Errorf("PublicAddr(%s) = %v, want %v", tt.addr, got, tt.public)
+		}
+	}
+}
+
+func TestSenderRefusesNonPublicAddresses(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	defer receiver.Close()
+
+	// localhost resolves to a loopback address, which is refused once resolved
+	target := strings.Replace(receiver.URL, "127.0.0.1", "localhost", 1)
+	for _, url := range []string{receiver.URL, target} {
+		attempt := NewSender(time.Second, false).Send(context.Background(), Message{URL: url, Secret: "secret"})
+		if attempt.Succeeded() || attempt.StatusCode != nil || attempt.Response != "" {
+			t.Fatalf("delivery to %s reached the receiver: %+v", url, attempt)
+		}
+		if !strings.Contains(attempt.Err, ErrBlockedAddress.Error()) {
+			t.Errorf("delivery to %s failed with %q, want %q", url, attempt.Err, ErrBlockedAddress)
+		}
+	}
+
+	attempt := NewSender(time.Second, true).Send(context.Background(), Message{URL: receiver.URL, Secret: "secret"})
+	if !attempt.Succeeded() || attempt.Response != "internal" {
+		t.Errorf("delivery with private networks allowed failed: %+v", attempt)
+	}
+}
+
+func TestRefuseNonPublicRejectsUnparsableAddresses(t *testing.T) {
+	if err := refuseNonPublic("tcp", "not-an-address", nil); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("refuseNonPublic() = %v, want ErrBlockedAddress", err)
+	}
+}